@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bizoe-3d-store/internal/catalog"
+	"bizoe-3d-store/internal/config"
+	"bizoe-3d-store/internal/database"
+	"bizoe-3d-store/internal/models"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm/logger"
+)
+
+const usage = `Usage:
+  catalog import -file products.csv [-format csv|json] [-dry-run]
+  catalog export [-format csv|json] [-out products.csv]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg := config.Load()
+
+	// Initialize database
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// GORM logs SQL to stdout, which would corrupt exports written there
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		file := fs.String("file", "", "catalog file to import")
+		format := fs.String("format", "", "csv or json (defaults to the file extension)")
+		dryRun := fs.Bool("dry-run", false, "validate rows without writing")
+		fs.Parse(os.Args[2:])
+
+		if *file == "" {
+			log.Fatal("-file is required")
+		}
+		if *format == "" {
+			*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+		}
+
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *file, err)
+		}
+		defer f.Close()
+
+		rows, err := catalog.Parse(*format, f)
+		if err != nil {
+			log.Fatalf("Failed to parse %s: %v", *file, err)
+		}
+
+		result, err := catalog.NewImporter(db).Run(rows, *dryRun, func(r catalog.Result) {
+			log.Printf("Processed %d/%d rows", r.Processed, r.Total)
+		})
+		if err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		format := fs.String("format", catalog.FormatCSV, "csv or json")
+		outPath := fs.String("out", "", "output file (defaults to stdout)")
+		fs.Parse(os.Args[2:])
+
+		var products []models.Product
+		if err := db.Preload("Category").Order("sku ASC").Find(&products).Error; err != nil {
+			log.Fatalf("Failed to fetch products: %v", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if *outPath != "" {
+			f, err := os.Create(*outPath)
+			if err != nil {
+				log.Fatalf("Failed to create %s: %v", *outPath, err)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if err := catalog.Write(*format, w, products); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/carriers"
 	"bizoe-3d-store/internal/config"
 	"bizoe-3d-store/internal/database"
+	"bizoe-3d-store/internal/downloads"
+	"bizoe-3d-store/internal/email"
 	"bizoe-3d-store/internal/handlers"
+	"bizoe-3d-store/internal/jobs"
+	"bizoe-3d-store/internal/media"
 	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/payments"
+	"bizoe-3d-store/internal/recommend"
+	"bizoe-3d-store/internal/storage"
 	"log"
 	"net/http"
 	"time"
@@ -28,12 +38,125 @@ func main() {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Order numbers come from a database sequence in the configured format
+	orderNumbers, err := models.ParseNumberFormat(cfg.OrderNumberFormat, models.NumberReset(cfg.OrderNumberReset))
+	if err != nil {
+		log.Fatalf("Invalid order number format: %v", err)
+	}
+	models.OrderNumbers = orderNumbers
+	// Each period's counter is created ahead of time, outside the order
+	// transactions that take numbers from it
+	jobs.Every("order-number-sequences", time.Hour, func() error {
+		return orderNumbers.Prepare(db, models.OrderSequence, time.Now())
+	})
+
+	// Initialize file storage for uploads
+	store, err := storage.NewLocalStorage(cfg.UploadDir, cfg.UploadBaseURL)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Digital product files are kept out of the public uploads directory
+	privateStore, err := storage.NewLocalStorage(cfg.PrivateDir, "")
+	if err != nil {
+		log.Fatalf("Failed to initialize private storage: %v", err)
+	}
+
+	// Outgoing email
+	mailer := email.NewSender(cfg)
+
+	// Background jobs
+	jobs.Every("image-gc", 6*time.Hour, func() error {
+		removed, err := media.CollectGarbage(db, store, time.Now().Add(-media.GCGracePeriod))
+		if removed > 0 {
+			log.Printf("Image GC removed %d unreferenced images", removed)
+		}
+		return err
+	})
+
+	jobs.Every("wishlist-alerts", 30*time.Minute, func() error {
+		sent, err := alerts.CheckWishlists(db, mailer)
+		if sent > 0 {
+			log.Printf("Wishlist alerts sent %d emails", sent)
+		}
+		return err
+	})
+
+	stockAlerts := alerts.NewStockNotifier(db, mailer, cfg.APIBaseURL)
+	jobs.Every("stock-alerts", 15*time.Minute, func() error {
+		sent, err := stockAlerts.Run()
+		if sent > 0 {
+			log.Printf("Back-in-stock alerts sent %d emails", sent)
+		}
+		return err
+	})
+
+	recommender := recommend.NewEngine(db)
+	jobs.Every("recommendations", time.Hour, recommender.Rebuild)
+
+	// Saved payment methods are charged for subscription orders
+	gateway, err := payments.NewGateway(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize payments: %v", err)
+	}
+	subscriptionHandler := handlers.NewSubscriptionHandler(db, gateway, mailer, stockAlerts)
+	jobs.Every("subscriptions", 15*time.Minute, func() error {
+		paid, err := subscriptionHandler.BillDue()
+		if paid > 0 {
+			log.Printf("Subscription billing paid %d orders", paid)
+		}
+		return err
+	})
+
+	// Price differences of order edits that could not be settled right away are retried
+	orderEditHandler := handlers.NewOrderEditHandler(db, gateway, mailer, stockAlerts)
+	jobs.Every("order-edit-settlements", 15*time.Minute, func() error {
+		settled, err := orderEditHandler.SettleEdits()
+		if settled > 0 {
+			log.Printf("Order edit settlement settled %d edits", settled)
+		}
+		return err
+	})
+
+	// Paid custom prints are handed to free printers
+	productionHandler := handlers.NewProductionHandler(db, store)
+	jobs.Every("print-queue", time.Minute, func() error {
+		assigned, err := productionHandler.AssignJobs()
+		if assigned > 0 {
+			log.Printf("Print queue assigned %d jobs", assigned)
+		}
+		return err
+	})
+
+	// Parcels on their way are tracked with their carriers
+	shipmentHandler := handlers.NewShipmentHandler(db, carriers.NewRegistry(cfg), mailer)
+	jobs.Every("shipment-tracking", 30*time.Minute, func() error {
+		updated, err := shipmentHandler.PollTracking()
+		if updated > 0 {
+			log.Printf("Shipment tracking updated %d shipments", updated)
+		}
+		return err
+	})
+
+	// Invoices and credit notes are issued once orders are paid or refunded
+	documentHandler := handlers.NewDocumentHandler(db, privateStore, cfg)
+	jobs.Every("documents", 10*time.Minute, func() error {
+		issued, err := documentHandler.IssueDocuments()
+		if issued > 0 {
+			log.Printf("Invoicing issued %d invoices and credit notes", issued)
+		}
+		return err
+	})
+
 	// Initialize Gin router
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
 	// Middleware
 	router.Use(gin.Logger())
@@ -43,7 +166,7 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "https://your-domain.com"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Cache-Control"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Cache-Control", "X-Currency"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -51,10 +174,27 @@ func main() {
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, cfg)
-	productHandler := handlers.NewProductHandler(db)
+	productHandler := handlers.NewProductHandler(db, stockAlerts)
 	cartHandler := handlers.NewCartHandler(db)
-	orderHandler := handlers.NewOrderHandler(db, cfg)
+	orderHandler := handlers.NewOrderHandler(db, cfg, stockAlerts)
 	userHandler := handlers.NewUserHandler(db)
+	addressHandler := handlers.NewAddressHandler(db)
+	translationHandler := handlers.NewTranslationHandler(db)
+	currencyHandler := handlers.NewCurrencyHandler(db)
+	catalogHandler := handlers.NewCatalogHandler(db, stockAlerts)
+	mediaHandler := handlers.NewMediaHandler(db, store)
+	reviewHandler := handlers.NewReviewHandler(db, store)
+	questionHandler := handlers.NewQuestionHandler(db, mailer)
+	compatibilityHandler := handlers.NewCompatibilityHandler(db)
+	bundleHandler := handlers.NewBundleHandler(db)
+	recommendationHandler := handlers.NewRecommendationHandler(db, recommender)
+	wishlistHandler := handlers.NewWishlistHandler(db)
+	stockHandler := handlers.NewStockHandler(db, stockAlerts)
+	printHandler := handlers.NewPrintHandler(db, store)
+	downloadHandler := handlers.NewDownloadHandler(db, privateStore, downloads.NewSigner(cfg.DownloadSecret, cfg.APIBaseURL))
+	warrantyHandler := handlers.NewWarrantyHandler(db, store, mailer)
+	returnHandler := handlers.NewReturnHandler(db, store, gateway, mailer, stockAlerts, cfg.ReturnWindowDays)
+	trackingHandler := handlers.NewTrackingHandler(db)
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -65,8 +205,12 @@ func main() {
 		})
 	})
 
+	// Uploaded files
+	router.Static("/uploads", cfg.UploadDir)
+
 	// API routes
 	api := router.Group("/api")
+	api.Use(middleware.Locale(), middleware.Currency())
 	{
 		// Auth routes
 		auth := api.Group("/auth")
@@ -85,6 +229,13 @@ func main() {
 			products.GET("/category/:slug", productHandler.GetProductsByCategory)
 			products.GET("/search", productHandler.SearchProducts)
 			products.GET("/featured", productHandler.GetFeaturedProducts)
+			products.GET("/:id/reviews", reviewHandler.GetProductReviews)
+			products.POST("/:id/reviews", middleware.AuthRequired(cfg.JWTSecret), reviewHandler.CreateReview)
+			products.GET("/:id/questions", questionHandler.GetProductQuestions)
+			products.GET("/:id/recommendations", recommendationHandler.GetProductRecommendations)
+			products.POST("/:id/questions", middleware.AuthRequired(cfg.JWTSecret), questionHandler.AskQuestion)
+			products.POST("/:id/stock-alerts", middleware.OptionalAuth(cfg.JWTSecret), stockHandler.SubscribeToStock)
+			products.GET("/:id/subscription-plan", subscriptionHandler.GetSubscriptionPlan)
 		}
 
 		// Category routes
@@ -94,6 +245,26 @@ func main() {
 			categories.GET("/:id", productHandler.GetCategory)
 		}
 
+		// Currency routes
+		api.GET("/currencies", currencyHandler.GetCurrencies)
+
+		// Shared wishlists
+		api.GET("/wishlists/shared/:token", wishlistHandler.GetSharedWishlist)
+
+		// Print service materials
+		api.GET("/print/materials", printHandler.GetPrintMaterials)
+
+		// Signed digital downloads
+		api.GET("/downloads/:id/files/:fileId", downloadHandler.Download)
+
+		// Back-in-stock subscriptions
+		api.DELETE("/stock-alerts/:token", stockHandler.UnsubscribeFromStock)
+		api.GET("/stock-alerts/:token/unsubscribe", stockHandler.UnsubscribePage)
+		api.POST("/stock-alerts/:token/unsubscribe", stockHandler.UnsubscribeFromLink)
+
+		// Order tracking without signing in
+		api.POST("/track-order", middleware.RateLimit(cfg.TrackingRateLimit, time.Minute), trackingHandler.TrackOrder)
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthRequired(cfg.JWTSecret))
@@ -104,6 +275,10 @@ func main() {
 				user.GET("/profile", userHandler.GetProfile)
 				user.PUT("/profile", userHandler.UpdateProfile)
 				user.GET("/orders", userHandler.GetUserOrders)
+				user.GET("/addresses", addressHandler.GetAddresses)
+				user.POST("/addresses", addressHandler.CreateAddress)
+				user.PUT("/addresses/:id", addressHandler.UpdateAddress)
+				user.DELETE("/addresses/:id", addressHandler.DeleteAddress)
 			}
 
 			// Cart routes
@@ -114,6 +289,63 @@ func main() {
 				cart.PUT("/update", cartHandler.UpdateCart)
 				cart.DELETE("/remove/:productId", cartHandler.RemoveFromCart)
 				cart.DELETE("/clear", cartHandler.ClearCart)
+				cart.GET("/recommendations", recommendationHandler.GetCartRecommendations)
+			}
+
+			// Wishlist routes
+			wishlists := protected.Group("/wishlists")
+			{
+				wishlists.GET("", wishlistHandler.GetWishlists)
+				wishlists.POST("", wishlistHandler.CreateWishlist)
+				wishlists.GET("/:id", wishlistHandler.GetWishlist)
+				wishlists.PUT("/:id", wishlistHandler.UpdateWishlist)
+				wishlists.DELETE("/:id", wishlistHandler.DeleteWishlist)
+				wishlists.POST("/:id/share/reset", wishlistHandler.ResetShareLink)
+				wishlists.POST("/:id/items", wishlistHandler.AddWishlistItem)
+				wishlists.DELETE("/:id/items/:productId", wishlistHandler.RemoveWishlistItem)
+				wishlists.POST("/:id/move-to-cart", wishlistHandler.MoveToCart)
+			}
+
+			// Subscription routes
+			subscriptions := protected.Group("/subscriptions")
+			{
+				subscriptions.POST("/payment-method", subscriptionHandler.SetupPaymentMethod)
+				subscriptions.GET("", subscriptionHandler.GetSubscriptions)
+				subscriptions.POST("", subscriptionHandler.CreateSubscription)
+				subscriptions.GET("/:id", subscriptionHandler.GetSubscription)
+				subscriptions.PUT("/:id", subscriptionHandler.UpdateSubscription)
+				subscriptions.POST("/:id/skip", subscriptionHandler.SkipSubscription)
+				subscriptions.POST("/:id/pause", subscriptionHandler.PauseSubscription)
+				subscriptions.POST("/:id/resume", subscriptionHandler.ResumeSubscription)
+				subscriptions.POST("/:id/cancel", subscriptionHandler.CancelSubscription)
+			}
+
+			// Digital downloads
+			library := protected.Group("/downloads")
+			{
+				library.GET("", downloadHandler.GetDownloads)
+			}
+
+			// Serial registration and warranty claims
+			warranty := protected.Group("/warranty")
+			{
+				warranty.GET("/registrations", warrantyHandler.GetRegistrations)
+				warranty.POST("/registrations", warrantyHandler.RegisterSerial)
+				warranty.GET("/claims", warrantyHandler.GetClaims)
+				warranty.POST("/claims", warrantyHandler.CreateClaim)
+				warranty.GET("/claims/:id", warrantyHandler.GetClaim)
+			}
+
+			// Print service routes
+			printService := protected.Group("/print")
+			{
+				printService.POST("/models", printHandler.UploadPrintModel)
+				printService.GET("/models", printHandler.GetPrintModels)
+				printService.GET("/models/:id", printHandler.GetPrintModel)
+				printService.POST("/quotes", printHandler.CreatePrintQuote)
+				printService.GET("/quotes/:id", printHandler.GetPrintQuote)
+				printService.POST("/quotes/:id/cart", printHandler.AddQuoteToCart)
+				printService.DELETE("/quotes/:id/cart", printHandler.RemoveQuoteFromCart)
 			}
 
 			// Order routes
@@ -121,34 +353,194 @@ func main() {
 			{
 				orders.POST("", orderHandler.CreateOrder)
 				orders.GET("/:id", orderHandler.GetOrder)
+				orders.PUT("/:id", orderEditHandler.EditOrder)
+				orders.POST("/:id/edits/:editId/settle", orderEditHandler.SettleEdit)
 				orders.PUT("/:id/cancel", orderHandler.CancelOrder)
+				orders.POST("/:id/items/:itemId/returns", returnHandler.RequestReturn)
+				orders.GET("/:id/invoice", documentHandler.GetOrderInvoice)
+				orders.GET("/:id/documents", documentHandler.GetOrderDocuments)
 			}
 
+			// Returns
+			returns := protected.Group("/returns")
+			{
+				returns.GET("", returnHandler.GetReturns)
+				returns.GET("/:id", returnHandler.GetReturn)
+				returns.POST("/:id/cancel", returnHandler.CancelReturn)
+			}
+
+			// Invoices and credit notes
+			protected.GET("/documents/:id", documentHandler.DownloadDocument)
+
 			// Payment routes
 			payment := protected.Group("/payment")
 			{
 				payment.POST("/create-intent", orderHandler.CreatePaymentIntent)
 				payment.POST("/confirm", orderHandler.ConfirmPayment)
 			}
+
+			// Review routes
+			reviews := protected.Group("/reviews")
+			{
+				reviews.DELETE("/:id", reviewHandler.DeleteReview)
+				reviews.POST("/:id/helpful", reviewHandler.VoteHelpful)
+				reviews.DELETE("/:id/helpful", reviewHandler.RemoveHelpfulVote)
+			}
+
+			// Question routes
+			questions := protected.Group("/questions")
+			{
+				questions.POST("/:id/answers", questionHandler.AnswerQuestion)
+				questions.POST("/:id/vote", questionHandler.VoteQuestion)
+				questions.DELETE("/:id/vote", questionHandler.RemoveQuestionVote)
+			}
 		}
 
-		// Admin routes (TODO: Add admin middleware)
+		// Admin routes
 		admin := api.Group("/admin")
 		admin.Use(middleware.AuthRequired(cfg.JWTSecret))
+		admin.Use(middleware.AdminRequired())
 		{
 			// Product management
 			admin.POST("/products", productHandler.CreateProduct)
 			admin.PUT("/products/:id", productHandler.UpdateProduct)
 			admin.DELETE("/products/:id", productHandler.DeleteProduct)
+			admin.GET("/products/archived", productHandler.GetArchivedProducts)
+			admin.POST("/products/:id/restore", productHandler.RestoreProduct)
 
 			// Category management
 			admin.POST("/categories", productHandler.CreateCategory)
 			admin.PUT("/categories/:id", productHandler.UpdateCategory)
 			admin.DELETE("/categories/:id", productHandler.DeleteCategory)
+			admin.GET("/categories/archived", productHandler.GetArchivedCategories)
+			admin.POST("/categories/:id/restore", productHandler.RestoreCategory)
+
+			// Translation management
+			admin.GET("/products/:id/translations", translationHandler.GetProductTranslations)
+			admin.PUT("/products/:id/translations/:locale", translationHandler.UpsertProductTranslation)
+			admin.DELETE("/products/:id/translations/:locale", translationHandler.DeleteProductTranslation)
+			admin.GET("/categories/:id/translations", translationHandler.GetCategoryTranslations)
+			admin.PUT("/categories/:id/translations/:locale", translationHandler.UpsertCategoryTranslation)
+			admin.DELETE("/categories/:id/translations/:locale", translationHandler.DeleteCategoryTranslation)
+			admin.GET("/translations/missing", translationHandler.GetMissingTranslations)
+
+			// Currency management
+			admin.GET("/exchange-rates", currencyHandler.GetExchangeRates)
+			admin.PUT("/exchange-rates/:currency", currencyHandler.UpsertExchangeRate)
+			admin.GET("/products/:id/prices", currencyHandler.GetProductPrices)
+			admin.PUT("/products/:id/prices/:currency", currencyHandler.UpsertProductPrice)
+			admin.DELETE("/products/:id/prices/:currency", currencyHandler.DeleteProductPrice)
+
+			// Catalog import/export
+			admin.POST("/catalog/import", catalogHandler.ImportCatalog)
+			admin.GET("/catalog/imports", catalogHandler.GetImportJobs)
+			admin.GET("/catalog/imports/:id", catalogHandler.GetImportJob)
+			admin.GET("/catalog/export", catalogHandler.ExportCatalog)
+
+			// Product images
+			admin.GET("/products/:id/images", mediaHandler.GetProductImages)
+			admin.POST("/products/:id/images", mediaHandler.UploadProductImage)
+			admin.PUT("/products/:id/images/order", mediaHandler.ReorderProductImages)
+			admin.PUT("/products/:id/images/:imageId", mediaHandler.UpdateProductImage)
+			admin.DELETE("/products/:id/images/:imageId", mediaHandler.DeleteProductImage)
+			admin.POST("/media/gc", mediaHandler.CollectGarbage)
+
+			// Compatibility management
+			admin.GET("/products/:id/compatibility", compatibilityHandler.GetProductCompatibility)
+			admin.POST("/products/:id/compatibility", compatibilityHandler.UpsertCompatibility)
+			admin.DELETE("/compatibility/:id", compatibilityHandler.DeleteCompatibility)
+
+			// Stock
+			admin.POST("/products/:id/stock/receive", stockHandler.ReceiveStock)
+			admin.GET("/products/:id/stock/receipts", stockHandler.GetStockReceipts)
+			admin.GET("/products/:id/stock-alerts", stockHandler.GetStockSubscriptions)
+
+			// Bundles
+			admin.PUT("/products/:id/bundle", bundleHandler.SetBundle)
+			admin.DELETE("/products/:id/bundle", bundleHandler.DeleteBundle)
+
+			// Recommendations
+			admin.GET("/products/:id/recommendations", recommendationHandler.GetRecommendationOverrides)
+			admin.PUT("/products/:id/recommendations/:targetId", recommendationHandler.UpsertRecommendationOverride)
+			admin.DELETE("/products/:id/recommendations/:targetId", recommendationHandler.DeleteRecommendationOverride)
+			admin.POST("/recommendations/rebuild", recommendationHandler.RebuildRecommendations)
+
+			// Subscriptions
+			admin.PUT("/products/:id/subscription-plan", subscriptionHandler.UpsertSubscriptionPlan)
+			admin.DELETE("/products/:id/subscription-plan", subscriptionHandler.DeleteSubscriptionPlan)
+			admin.GET("/subscriptions", subscriptionHandler.GetAllSubscriptions)
+			admin.POST("/subscriptions/run", subscriptionHandler.RunSubscriptionBilling)
+
+			// Print service
+			admin.GET("/print/materials", printHandler.GetAllPrintMaterials)
+			admin.POST("/print/materials", printHandler.CreatePrintMaterial)
+			admin.PUT("/print/materials/:id", printHandler.UpdatePrintMaterial)
+			admin.DELETE("/print/materials/:id", printHandler.DeletePrintMaterial)
+
+			// Digital products
+			admin.GET("/products/:id/files", downloadHandler.GetDigitalFiles)
+			admin.POST("/products/:id/files", downloadHandler.UploadDigitalFile)
+			admin.DELETE("/products/:id/files/:fileId", downloadHandler.DeleteDigitalFile)
+			admin.POST("/downloads/:id/reset", downloadHandler.ResetDownloads)
+
+			// Print production
+			admin.GET("/printers", productionHandler.GetPrinters)
+			admin.POST("/printers", productionHandler.CreatePrinter)
+			admin.PUT("/printers/:id", productionHandler.UpdatePrinter)
+			admin.DELETE("/printers/:id", productionHandler.DeletePrinter)
+			admin.GET("/production/queue", productionHandler.GetProductionQueue)
+			admin.GET("/production/queue/stream", productionHandler.StreamProductionQueue)
+			admin.POST("/production/assign", productionHandler.RunAssignment)
+			admin.GET("/production/jobs", productionHandler.GetPrintJobs)
+			admin.GET("/production/jobs/:id", productionHandler.GetPrintJob)
+			admin.GET("/production/jobs/:id/model", productionHandler.DownloadPrintJobModel)
+			admin.PUT("/production/jobs/:id/status", productionHandler.UpdatePrintJobStatus)
+			admin.PUT("/production/jobs/:id/printer", productionHandler.AssignPrintJob)
+
+			// Review moderation
+			admin.GET("/reviews", reviewHandler.GetReviews)
+			admin.PUT("/reviews/:id/moderate", reviewHandler.ModerateReview)
+
+			// Q&A moderation
+			admin.GET("/questions", questionHandler.GetQuestions)
+			admin.PUT("/questions/:id/moderate", questionHandler.ModerateQuestion)
+			admin.POST("/questions/:id/answers", questionHandler.StaffAnswer)
+			admin.GET("/answers", questionHandler.GetAnswers)
+			admin.PUT("/answers/:id/moderate", questionHandler.ModerateAnswer)
 
 			// Order management
 			admin.GET("/orders", orderHandler.GetAllOrders)
 			admin.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus)
+			admin.GET("/orders/pending-lines", orderHandler.GetPendingLines)
+			admin.PUT("/orders/:id/items/:itemId/serials", warrantyHandler.CaptureSerials)
+
+			// Shipments
+			admin.POST("/orders/:id/shipments", shipmentHandler.CreateShipment)
+			admin.GET("/orders/:id/shipments", shipmentHandler.GetOrderShipments)
+			admin.GET("/shipments", shipmentHandler.GetShipments)
+			admin.PUT("/shipments/:id/status", shipmentHandler.UpdateShipmentStatus)
+			admin.POST("/shipments/:id/refresh", shipmentHandler.RefreshTracking)
+			admin.DELETE("/shipments/:id", shipmentHandler.DeleteShipment)
+
+			// Returns
+			admin.GET("/returns", returnHandler.GetAllReturns)
+			admin.GET("/returns/:id", returnHandler.GetReturn)
+			admin.POST("/returns/:id/review", returnHandler.ReviewReturn)
+			admin.POST("/returns/:id/receive", returnHandler.ReceiveReturn)
+			admin.POST("/returns/:id/settle", returnHandler.SettleReturn)
+			admin.POST("/returns/:id/credit-note", documentHandler.IssueCreditNote)
+
+			// Documents
+			admin.GET("/documents", documentHandler.GetDocuments)
+			admin.GET("/documents/:id", documentHandler.DownloadDocument)
+			admin.POST("/documents/packing-slips", documentHandler.PrintPackingSlips)
+
+			// Warranty
+			admin.GET("/serials", warrantyHandler.GetSerials)
+			admin.GET("/warranty/claims", warrantyHandler.GetAllClaims)
+			admin.GET("/warranty/claims/:id", warrantyHandler.GetClaim)
+			admin.POST("/warranty/claims/:id/triage", warrantyHandler.TriageClaim)
+			admin.POST("/warranty/claims/:id/resolve", warrantyHandler.ResolveClaim)
 		}
 	}
 
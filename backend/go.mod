@@ -10,6 +10,7 @@ require (
 	github.com/joho/godotenv v1.4.0
 	github.com/stripe/stripe-go/v74 v74.30.0
 	golang.org/x/crypto v0.14.0
+	golang.org/x/image v0.13.0
 	gorm.io/driver/mysql v1.5.1
 	gorm.io/driver/sqlite v1.5.4
 	gorm.io/gorm v1.25.4
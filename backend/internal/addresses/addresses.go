@@ -0,0 +1,189 @@
+// Package addresses checks postal addresses against the rules of their
+// country and brings them into one canonical form before they are stored
+package addresses
+
+import (
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Error names the first field of an address that failed validation
+type Error struct {
+	Field   string // JSON name of the field
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// country holds what differs between the countries we ship to
+type country struct {
+	callingCode   string
+	trunkPrefix   string         // Dialled before national numbers, dropped in E.164
+	nationalDigit [2]int         // Length range of the national number without trunk prefix
+	postalCode    *regexp.Regexp // Nil where the country has no postal codes
+	postalExample string
+	states        map[string]bool // Required state or province codes, if any
+}
+
+var countries = map[string]country{
+	"US": {callingCode: "1", nationalDigit: [2]int{10, 10}, postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), postalExample: "94105 or 94105-1234", states: usStates},
+	"CA": {callingCode: "1", nationalDigit: [2]int{10, 10}, postalCode: regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[A-Z] \d[A-Z]\d$`), postalExample: "K1A 0B1"},
+	"TW": {callingCode: "886", trunkPrefix: "0", nationalDigit: [2]int{8, 9}, postalCode: regexp.MustCompile(`^\d{3}(\d{2}|\d{3})?$`), postalExample: "100, 10001 or 100001"},
+	"HK": {callingCode: "852", nationalDigit: [2]int{8, 8}},
+	"MO": {callingCode: "853", nationalDigit: [2]int{8, 8}},
+	"SG": {callingCode: "65", nationalDigit: [2]int{8, 8}, postalCode: regexp.MustCompile(`^\d{6}$`), postalExample: "018956"},
+	"CN": {callingCode: "86", trunkPrefix: "0", nationalDigit: [2]int{9, 11}, postalCode: regexp.MustCompile(`^\d{6}$`), postalExample: "100000"},
+	"JP": {callingCode: "81", trunkPrefix: "0", nationalDigit: [2]int{9, 10}, postalCode: regexp.MustCompile(`^\d{3}-\d{4}$`), postalExample: "100-0001"},
+	"KR": {callingCode: "82", trunkPrefix: "0", nationalDigit: [2]int{8, 10}, postalCode: regexp.MustCompile(`^\d{5}$`), postalExample: "03187"},
+	"GB": {callingCode: "44", trunkPrefix: "0", nationalDigit: [2]int{9, 10}, postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`), postalExample: "SW1A 1AA"},
+	"DE": {callingCode: "49", trunkPrefix: "0", nationalDigit: [2]int{6, 11}, postalCode: regexp.MustCompile(`^\d{5}$`), postalExample: "10115"},
+	"FR": {callingCode: "33", trunkPrefix: "0", nationalDigit: [2]int{9, 9}, postalCode: regexp.MustCompile(`^\d{5}$`), postalExample: "75001"},
+	"AU": {callingCode: "61", trunkPrefix: "0", nationalDigit: [2]int{9, 9}, postalCode: regexp.MustCompile(`^\d{4}$`), postalExample: "2000"},
+	"NZ": {callingCode: "64", trunkPrefix: "0", nationalDigit: [2]int{8, 10}, postalCode: regexp.MustCompile(`^\d{4}$`), postalExample: "6011"},
+}
+
+// US states, the federal district and the inhabited territories
+var usStates = setOf("AL", "AK", "AZ", "AR", "CA", "CO", "CT", "DE", "DC", "FL", "GA", "HI", "ID", "IL",
+	"IN", "IA", "KS", "KY", "LA", "ME", "MD", "MA", "MI", "MN", "MS", "MO", "MT", "NE", "NV", "NH", "NJ",
+	"NM", "NY", "NC", "ND", "OH", "OK", "OR", "PA", "RI", "SC", "SD", "TN", "TX", "UT", "VT", "VA", "WA",
+	"WV", "WI", "WY", "AS", "GU", "MP", "PR", "VI")
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Normalize validates a in place, upper-casing the country, state and postal
+// code, spacing postal codes the way the country writes them and rewriting
+// the phone number in E.164 form. Countries without specific rules only need
+// a phone number given with its country code.
+func Normalize(a *models.Address) error {
+	a.FirstName = strings.TrimSpace(a.FirstName)
+	a.LastName = strings.TrimSpace(a.LastName)
+	a.Email = strings.TrimSpace(a.Email)
+	a.Address = strings.TrimSpace(a.Address)
+	a.City = strings.TrimSpace(a.City)
+	a.Company = strings.TrimSpace(a.Company)
+	a.TaxID = strings.TrimSpace(a.TaxID)
+
+	required := []struct{ field, value string }{
+		{"firstName", a.FirstName},
+		{"lastName", a.LastName},
+		{"email", a.Email},
+		{"phone", a.Phone},
+		{"address", a.Address},
+		{"city", a.City},
+		{"country", a.Country},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return &Error{r.field, "is required"}
+		}
+	}
+	if parsed, err := mail.ParseAddress(a.Email); err != nil || parsed.Address != a.Email {
+		return &Error{"email", "is not a valid email address"}
+	}
+
+	a.Country = strings.ToUpper(strings.TrimSpace(a.Country))
+	if !countryCode.MatchString(a.Country) {
+		return &Error{"country", "must be a two-letter ISO country code"}
+	}
+	rules, known := countries[a.Country]
+
+	a.State = strings.ToUpper(strings.TrimSpace(a.State))
+	if rules.states != nil {
+		if a.State == "" {
+			return &Error{"state", "is required"}
+		}
+		if !rules.states[a.State] {
+			return &Error{"state", "must be a two-letter state code"}
+		}
+	}
+
+	a.ZipCode = postalCode(a.ZipCode, a.Country)
+	switch {
+	case !known:
+	case rules.postalCode == nil:
+		a.ZipCode = ""
+	case a.ZipCode == "":
+		return &Error{"zipCode", "is required"}
+	case !rules.postalCode.MatchString(a.ZipCode):
+		return &Error{"zipCode", fmt.Sprintf("is not a valid postal code, for example %s", rules.postalExample)}
+	}
+
+	phone, err := e164(a.Phone, rules, known)
+	if err != nil {
+		return err
+	}
+	a.Phone = phone
+	return nil
+}
+
+// postalCode upper-cases a postal code and fixes up the space the UK and
+// Canada put between its two halves
+func postalCode(code, country string) string {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	switch country {
+	case "CA", "GB":
+		if len(code) > 3 {
+			code = code[:len(code)-3] + " " + code[len(code)-3:]
+		}
+	}
+	return code
+}
+
+// e164 rewrites a phone number as + country code and subscriber number.
+// Numbers given in national form get the country's calling code.
+func e164(phone string, rules country, known bool) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+	var digits strings.Builder
+	for _, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0 && international:
+		case strings.ContainsRune(" -.()/", r):
+		default:
+			return "", &Error{"phone", "may only contain digits, spaces, dashes, dots and brackets"}
+		}
+	}
+	number := digits.String()
+	if !international && strings.HasPrefix(number, "00") {
+		number, international = number[2:], true
+	}
+
+	if !international {
+		if !known {
+			return "", &Error{"phone", "must start with + and the country calling code"}
+		}
+		if rules.trunkPrefix != "" {
+			number = strings.TrimPrefix(number, rules.trunkPrefix)
+		} else if len(number) == rules.nationalDigit[1]+len(rules.callingCode) {
+			number = strings.TrimPrefix(number, rules.callingCode)
+		}
+		number = rules.callingCode + number
+	}
+
+	if known && strings.HasPrefix(number, rules.callingCode) {
+		national := len(number) - len(rules.callingCode)
+		if national < rules.nationalDigit[0] || national > rules.nationalDigit[1] {
+			return "", &Error{"phone", "has the wrong number of digits for the country"}
+		}
+	}
+	// E.164 numbers are at most 15 digits; none are shorter than 8
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", &Error{"phone", "is not a valid phone number"}
+	}
+	return "+" + number, nil
+}
+
+func setOf(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,159 @@
+package alerts
+
+import (
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StockAlertOversubscription is how many waiting subscribers are emailed
+	// per available unit, since not everyone who is told will buy
+	StockAlertOversubscription = 2
+	// StockAlertWindow is how long an emailed subscriber keeps their slot in
+	// the current batch before the next subscribers in line are emailed
+	StockAlertWindow = 24 * time.Hour
+)
+
+// StockNotifier emails back-in-stock subscribers in FIFO batches. A batch is
+// sized against the available quantity minus the subscribers already emailed
+// within StockAlertWindow, so a small restock only reaches the front of the
+// queue and the rest follow if the units are still there later.
+type StockNotifier struct {
+	db      *gorm.DB
+	mailer  email.Sender
+	baseURL string // Public API address unsubscribe links point at
+
+	mu sync.Mutex // Serializes batches so nobody is emailed twice
+}
+
+func NewStockNotifier(db *gorm.DB, mailer email.Sender, baseURL string) *StockNotifier {
+	return &StockNotifier{db: db, mailer: mailer, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// UnsubscribeURL is the link in alert emails that cancels a subscription
+// without an account. The token is only ever sent to the subscriber's mailbox.
+func (n *StockNotifier) UnsubscribeURL(token string) string {
+	return fmt.Sprintf("%s/api/stock-alerts/%s/unsubscribe", n.baseURL, token)
+}
+
+// Subscribed confirms a back-in-stock subscription to its mailbox, with the
+// link that cancels it
+func (n *StockNotifier) Subscribed(subscription *models.StockSubscription, product *models.Product) {
+	email.SendAsync(n.mailer, email.Message{
+		To:      []string{subscription.Email},
+		Subject: fmt.Sprintf("We will tell you when %s is back in stock", product.Name),
+		Body: fmt.Sprintf("Hi,\n\nWe will email you when %s (SKU %s) is available again.\n\n"+
+			"If you did not ask for this, or change your mind, visit %s\n",
+			product.Name, product.SKU, n.UnsubscribeURL(subscription.Token)),
+	})
+}
+
+// Restocked notifies the next subscribers of the products, and of bundles
+// built from them, in the background. Call it after stock was raised.
+func (n *StockNotifier) Restocked(productIDs ...string) {
+	if len(productIDs) == 0 {
+		return
+	}
+	go func() {
+		var bundleIDs []string
+		n.db.Model(&models.BundleItem{}).Where("component_id IN ?", productIDs).
+			Distinct().Pluck("bundle_id", &bundleIDs)
+
+		for _, id := range append(productIDs, bundleIDs...) {
+			if _, err := n.notifyProduct(id); err != nil {
+				log.Printf("Failed to send back-in-stock alerts for product %s: %v", id, err)
+			}
+		}
+	}()
+}
+
+// Run sends the next batch for every product with waiting subscribers and
+// returns how many emails were sent. It is scheduled as a background job so
+// later batches go out once earlier ones have had their window.
+func (n *StockNotifier) Run() (int, error) {
+	var productIDs []string
+	if err := n.db.Model(&models.StockSubscription{}).
+		Where("status = ?", models.StockSubscriptionWaiting).
+		Distinct().Pluck("product_id", &productIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to find waiting subscriptions: %w", err)
+	}
+
+	sent := 0
+	for _, id := range productIDs {
+		count, err := n.notifyProduct(id)
+		sent += count
+		if err != nil {
+			return sent, fmt.Errorf("failed to notify subscribers of product %s: %w", id, err)
+		}
+	}
+	return sent, nil
+}
+
+func (n *StockNotifier) notifyProduct(productID string) (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var product models.Product
+	if err := n.db.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil // Archived products are not coming back
+		}
+		return 0, err
+	}
+	if !product.InStock || product.StockQuantity <= 0 {
+		return 0, nil
+	}
+
+	var outstanding int64
+	if err := n.db.Model(&models.StockSubscription{}).
+		Where("product_id = ? AND status = ? AND notified_at > ?",
+			productID, models.StockSubscriptionNotified, time.Now().Add(-StockAlertWindow)).
+		Count(&outstanding).Error; err != nil {
+		return 0, err
+	}
+
+	batch := product.StockQuantity*StockAlertOversubscription - int(outstanding)
+	if batch <= 0 {
+		return 0, nil
+	}
+
+	var subscriptions []models.StockSubscription
+	if err := n.db.Where("product_id = ? AND status = ?", productID, models.StockSubscriptionWaiting).
+		Order("created_at ASC").Limit(batch).
+		Find(&subscriptions).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, subscription := range subscriptions {
+		result := n.db.Model(&models.StockSubscription{}).
+			Where("id = ? AND status = ?", subscription.ID, models.StockSubscriptionWaiting).
+			Updates(map[string]interface{}{
+				"status":      models.StockSubscriptionNotified,
+				"notified_at": time.Now(),
+			})
+		if result.Error != nil {
+			return sent, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // Unsubscribed meanwhile
+		}
+
+		email.SendAsync(n.mailer, email.Message{
+			To:      []string{subscription.Email},
+			Subject: fmt.Sprintf("%s is back in stock", product.Name),
+			Body: fmt.Sprintf("Hi,\n\n%s (SKU %s) is available again. Stock is limited and goes to whoever orders first.\n\n"+
+				"To stop back-in-stock alerts for this product, visit %s\n",
+				product.Name, product.SKU, n.UnsubscribeURL(subscription.Token)),
+		})
+		sent++
+	}
+	return sent, nil
+}
@@ -0,0 +1,107 @@
+package alerts
+
+import (
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// wishlistBatchSize is how many wishlists are checked per query
+const wishlistBatchSize = 100
+
+// CheckWishlists emails wishlist owners whose saved products dropped below
+// the price they were saved at, or came back in stock, and returns how many
+// emails were sent. Each price is only announced once: a further alert needs
+// the price to fall below the last one announced. Archived products are
+// skipped.
+func CheckWishlists(db *gorm.DB, mailer email.Sender) (int, error) {
+	sent := 0
+	var wishlists []models.Wishlist
+	result := db.Preload("User").Preload("Items.Product").
+		FindInBatches(&wishlists, wishlistBatchSize, func(tx *gorm.DB, batch int) error {
+			lines := make(map[string][]string)
+			users := make(map[string]*models.User)
+			seen := make(map[string]bool)
+
+			for _, wishlist := range wishlists {
+				if wishlist.User == nil {
+					continue
+				}
+				for i := range wishlist.Items {
+					item := &wishlist.Items[i]
+					line, err := checkWishlistItem(db, item)
+					if err != nil {
+						return err
+					}
+					key := wishlist.UserID + "/" + item.ProductID
+					if line == "" || seen[key] {
+						continue
+					}
+					seen[key] = true
+					users[wishlist.UserID] = wishlist.User
+					lines[wishlist.UserID] = append(lines[wishlist.UserID], line)
+				}
+			}
+
+			for userID, userLines := range lines {
+				user := users[userID]
+				email.SendAsync(mailer, email.Message{
+					To:      []string{user.Email},
+					Subject: "Good news about your wishlist",
+					Body: fmt.Sprintf("Hi %s,\n\nSome products on your wishlist changed:\n\n%s\n",
+						user.FirstName, strings.Join(userLines, "\n")),
+				})
+				sent++
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return sent, fmt.Errorf("failed to check wishlists: %w", result.Error)
+	}
+	return sent, nil
+}
+
+// checkWishlistItem updates the alert state of one item and returns the
+// email line to send about it, if any
+func checkWishlistItem(db *gorm.DB, item *models.WishlistItem) (string, error) {
+	product := &item.Product
+	if product.ID == "" {
+		return "", nil
+	}
+
+	if !product.InStockNow() {
+		if item.AwaitingStock {
+			return "", nil
+		}
+		item.AwaitingStock = true
+		return "", db.Model(item).UpdateColumn("awaiting_stock", true).Error
+	}
+
+	var notes []string
+	updates := map[string]interface{}{}
+	if item.AwaitingStock {
+		notes = append(notes, "is back in stock")
+		updates["awaiting_stock"] = false
+	}
+
+	threshold := item.PriceAtAdd
+	if item.AlertedPrice != nil && *item.AlertedPrice < threshold {
+		threshold = *item.AlertedPrice
+	}
+	if product.Price < threshold {
+		notes = append(notes, fmt.Sprintf("dropped to %.2f %s (was %.2f when you saved it)",
+			product.Price, product.Currency, item.PriceAtAdd))
+		updates["alerted_price"] = product.Price
+	}
+
+	if len(notes) == 0 {
+		return "", nil
+	}
+	if err := db.Model(item).UpdateColumns(updates).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("- %s %s", product.Name, strings.Join(notes, " and ")), nil
+}
@@ -0,0 +1,66 @@
+package carriers
+
+import (
+	"bizoe-3d-store/internal/config"
+	"errors"
+	"time"
+)
+
+// ErrUnknownParcel is returned for tracking numbers the carrier has no record of
+var ErrUnknownParcel = errors.New("unknown tracking number")
+
+// Status is where a parcel is on its way to the customer
+type Status string
+
+const (
+	StatusLabelCreated   Status = "label_created"
+	StatusInTransit      Status = "in_transit"
+	StatusOutForDelivery Status = "out_for_delivery"
+	StatusDelivered      Status = "delivered"
+	StatusException      Status = "exception" // Delayed, lost or returned to sender
+)
+
+// IsValidStatus reports whether s is a known parcel status
+func IsValidStatus(s Status) bool {
+	switch s {
+	case StatusLabelCreated, StatusInTransit, StatusOutForDelivery, StatusDelivered, StatusException:
+		return true
+	}
+	return false
+}
+
+// Event is one scan of a parcel, oldest first in a Tracking
+type Event struct {
+	Status      Status
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// Tracking is the progress of a parcel as reported by its carrier
+type Tracking struct {
+	Status Status
+	Events []Event
+}
+
+// Carrier looks up parcels with a shipping company
+type Carrier interface {
+	// Track returns the full scan history of a parcel. Parcels the carrier
+	// does not know yet wrap ErrUnknownParcel.
+	Track(trackingNumber string) (*Tracking, error)
+}
+
+// Registry maps carrier codes, as recorded on shipments, to their adapters.
+// Shipments with carriers that have no adapter are tracked by hand.
+type Registry map[string]Carrier
+
+// NewRegistry returns the carriers configured for the environment. Outside
+// production a fake carrier stands in, which keeps development setups
+// working without carrier accounts.
+func NewRegistry(cfg *config.Config) Registry {
+	registry := Registry{}
+	if cfg.Environment != "production" {
+		registry[FakeCode] = NewFakeCarrier(cfg.FakeCarrierStep)
+	}
+	return registry
+}
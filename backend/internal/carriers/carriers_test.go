@@ -0,0 +1,208 @@
+package carriers_test
+
+import (
+	"bizoe-3d-store/internal/carriers"
+	"bizoe-3d-store/internal/database"
+	"bizoe-3d-store/internal/handlers"
+	"bizoe-3d-store/internal/models"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const step = time.Hour
+
+// fixture is an order tracked through a fake carrier whose clock the test
+// moves by hand
+type fixture struct {
+	t       *testing.T
+	db      *gorm.DB
+	clock   time.Time
+	handler *handlers.ShipmentHandler
+	order   models.Order
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	db, err := database.Initialize("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Discard})
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixture{t: t, db: db, clock: time.Now()}
+	fake := carriers.NewFakeCarrier(step)
+	fake.SetClock(func() time.Time { return f.clock })
+	f.handler = handlers.NewShipmentHandler(db, carriers.Registry{carriers.FakeCode: fake}, nil)
+
+	// A printer and two bottles of resin
+	f.order = models.Order{
+		UserID:        "user",
+		Status:        models.OrderStatusConfirmed,
+		PaymentStatus: models.PaymentStatusPaid,
+		Items: []models.OrderItem{
+			{ProductID: "printer", ProductName: "Printer", Quantity: 1, Price: 300, Total: 300},
+			{ProductID: "resin", ProductName: "Resin", Quantity: 2, Price: 25, Total: 50},
+		},
+		Subtotal: 350,
+		Total:    350,
+	}
+	if err := db.Create(&f.order).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// ship records a parcel holding the given quantities of the order lines, by
+// line index
+func (f *fixture) ship(trackingNumber string, quantities map[int]int) models.Shipment {
+	f.t.Helper()
+	shipment := models.Shipment{
+		OrderID:        f.order.ID,
+		Carrier:        carriers.FakeCode,
+		TrackingNumber: trackingNumber,
+		ShippedAt:      f.clock,
+	}
+	for line, quantity := range quantities {
+		shipment.Items = append(shipment.Items, models.ShipmentItem{OrderItemID: f.order.Items[line].ID, Quantity: quantity})
+	}
+	if err := f.db.Create(&shipment).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	return shipment
+}
+
+// poll moves the carrier clock on by steps and runs the tracking job, as if
+// its poll interval had passed
+func (f *fixture) poll(steps int) {
+	f.t.Helper()
+	f.clock = f.clock.Add(time.Duration(steps) * step)
+	if err := f.db.Model(&models.Shipment{}).Where("1 = 1").Update("last_checked_at", nil).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	if _, err := f.handler.PollTracking(); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// expect checks the status of a shipment and of the order
+func (f *fixture) expect(shipmentID string, shipmentStatus models.ShipmentStatus, orderStatus models.OrderStatus) {
+	f.t.Helper()
+	var shipment models.Shipment
+	if err := f.db.First(&shipment, "id = ?", shipmentID).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	var order models.Order
+	if err := f.db.First(&order, "id = ?", f.order.ID).Error; err != nil {
+		f.t.Fatal(err)
+	}
+	if shipment.Status != shipmentStatus {
+		f.t.Errorf("shipment status = %s, want %s", shipment.Status, shipmentStatus)
+	}
+	if order.Status != orderStatus {
+		f.t.Errorf("order status = %s, want %s", order.Status, orderStatus)
+	}
+}
+
+func TestFakeCarrierRoute(t *testing.T) {
+	clock := time.Now()
+	fake := carriers.NewFakeCarrier(step)
+	fake.SetClock(func() time.Time { return clock })
+
+	want := []carriers.Status{
+		carriers.StatusLabelCreated,
+		carriers.StatusInTransit,
+		carriers.StatusInTransit,
+		carriers.StatusOutForDelivery,
+		carriers.StatusDelivered,
+		carriers.StatusDelivered, // Stays delivered
+	}
+	for i, status := range want {
+		tracking, err := fake.Track("PARCEL-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tracking.Status != status {
+			t.Errorf("step %d: status = %s, want %s", i, tracking.Status, status)
+		}
+		if scans := min(i+1, 5); len(tracking.Events) != scans {
+			t.Errorf("step %d: %d scans, want %d", i, len(tracking.Events), scans)
+		}
+		clock = clock.Add(step)
+	}
+
+	if _, err := fake.Track(" "); !errors.Is(err, carriers.ErrUnknownParcel) {
+		t.Errorf("empty tracking number: err = %v, want ErrUnknownParcel", err)
+	}
+}
+
+func TestPollTrackingDeliversFullyShippedOrder(t *testing.T) {
+	f := newFixture(t)
+	shipment := f.ship("PARCEL-1", map[int]int{0: 1, 1: 2})
+
+	f.poll(0)
+	f.expect(shipment.ID, models.ShipmentLabelCreated, models.OrderStatusShipped)
+
+	f.poll(1)
+	f.expect(shipment.ID, models.ShipmentInTransit, models.OrderStatusShipped)
+
+	f.poll(3)
+	f.expect(shipment.ID, models.ShipmentDelivered, models.OrderStatusDelivered)
+
+	var order models.Order
+	f.db.First(&order, "id = ?", f.order.ID)
+	if order.DeliveredAt == nil || !order.DeliveredAt.Equal(f.clock) {
+		t.Errorf("order delivered at %v, want %v", order.DeliveredAt, f.clock)
+	}
+	var events int64
+	f.db.Model(&models.ShipmentEvent{}).Where("shipment_id = ?", shipment.ID).Count(&events)
+	if events != 5 {
+		t.Errorf("%d shipment events, want 5", events)
+	}
+}
+
+func TestPollTrackingPartiallyShippedOrder(t *testing.T) {
+	f := newFixture(t)
+	printer := f.ship("PARCEL-1", map[int]int{0: 1})
+
+	f.poll(0)
+	f.expect(printer.ID, models.ShipmentLabelCreated, models.OrderStatusPartiallyShipped)
+
+	// Delivering the only parcel does not deliver an order with units left
+	f.poll(4)
+	f.expect(printer.ID, models.ShipmentDelivered, models.OrderStatusPartiallyShipped)
+
+	// The resin follows in a second parcel
+	resin := f.ship("PARCEL-2", map[int]int{1: 2})
+	f.poll(0)
+	f.expect(resin.ID, models.ShipmentLabelCreated, models.OrderStatusShipped)
+
+	f.poll(1)
+	f.expect(resin.ID, models.ShipmentInTransit, models.OrderStatusShipped)
+
+	f.poll(3)
+	f.expect(resin.ID, models.ShipmentDelivered, models.OrderStatusDelivered)
+}
+
+func TestPollTrackingException(t *testing.T) {
+	f := newFixture(t)
+	shipment := f.ship("LOST-1", map[int]int{0: 1, 1: 2})
+
+	f.poll(0)
+	f.poll(1)
+	f.expect(shipment.ID, models.ShipmentInTransit, models.OrderStatusShipped)
+
+	f.poll(1)
+	f.expect(shipment.ID, models.ShipmentException, models.OrderStatusShipped)
+
+	// A lost parcel stays lost and the order is never delivered
+	f.poll(5)
+	f.expect(shipment.ID, models.ShipmentException, models.OrderStatusShipped)
+}
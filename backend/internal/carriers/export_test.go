@@ -0,0 +1,9 @@
+package carriers
+
+import "time"
+
+// SetClock makes the fake carrier read the time from now instead of the
+// wall clock, so tests can move parcels along without waiting
+func (f *FakeCarrier) SetClock(now func() time.Time) {
+	f.now = now
+}
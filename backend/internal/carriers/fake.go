@@ -0,0 +1,75 @@
+package carriers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeCode is the carrier code of the fake carrier
+const FakeCode = "fake"
+
+// fakeRoute is the scans every fake parcel goes through, one per step
+var fakeRoute = []Event{
+	{Status: StatusLabelCreated, Description: "Shipping label created", Location: "Taipei"},
+	{Status: StatusInTransit, Description: "Picked up by carrier", Location: "Taipei"},
+	{Status: StatusInTransit, Description: "Arrived at sorting facility", Location: "Taoyuan"},
+	{Status: StatusOutForDelivery, Description: "Out for delivery", Location: "Local depot"},
+	{Status: StatusDelivered, Description: "Delivered", Location: "Front door"},
+}
+
+// FakeCarrier moves parcels along a fixed route, one scan per step from the
+// first time it is asked about them. Tracking numbers containing "lost" stop
+// with an exception once in transit, after the convention of the test
+// payment gateway. State is kept in memory only.
+type FakeCarrier struct {
+	step time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	first map[string]time.Time
+}
+
+func NewFakeCarrier(step time.Duration) *FakeCarrier {
+	return &FakeCarrier{step: step, now: time.Now, first: make(map[string]time.Time)}
+}
+
+// Track implements Carrier
+func (f *FakeCarrier) Track(trackingNumber string) (*Tracking, error) {
+	if strings.TrimSpace(trackingNumber) == "" {
+		return nil, fmt.Errorf("%w: empty tracking number", ErrUnknownParcel)
+	}
+
+	f.mu.Lock()
+	start, seen := f.first[trackingNumber]
+	if !seen {
+		start = f.now()
+		f.first[trackingNumber] = start
+	}
+	f.mu.Unlock()
+
+	scans := 1
+	if f.step > 0 {
+		scans += int(f.now().Sub(start) / f.step)
+	}
+	if scans > len(fakeRoute) {
+		scans = len(fakeRoute)
+	}
+
+	lost := strings.Contains(strings.ToLower(trackingNumber), "lost")
+	tracking := &Tracking{}
+	for i := 0; i < scans; i++ {
+		event := fakeRoute[i]
+		event.OccurredAt = start.Add(time.Duration(i) * f.step)
+		if lost && i == 2 {
+			event.Status = StatusException
+			event.Description = "Parcel could not be located"
+			tracking.Events = append(tracking.Events, event)
+			break
+		}
+		tracking.Events = append(tracking.Events, event)
+	}
+	tracking.Status = tracking.Events[len(tracking.Events)-1].Status
+	return tracking, nil
+}
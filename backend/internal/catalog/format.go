@@ -0,0 +1,323 @@
+package catalog
+
+import (
+	"bizoe-3d-store/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// specColumnPrefix marks CSV columns that hold flattened specifications,
+// e.g. "spec:Build Volume"
+const specColumnPrefix = "spec:"
+
+// imageSeparator joins multiple image URLs in a single CSV cell
+const imageSeparator = "|"
+
+// baseColumns are the fixed CSV columns, in export order
+var baseColumns = []string{
+	"sku", "name", "description", "price", "original_price", "currency",
+	"category", "images", "in_stock", "stock_quantity", "featured",
+}
+
+// Record is the interchange representation of a product shared by the CSV
+// and JSON formats. Optional fields are pointers so an update can tell
+// "not provided" apart from a zero value.
+type Record struct {
+	SKU            string            `json:"sku"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	Price          *float64          `json:"price"`
+	OriginalPrice  *float64          `json:"originalPrice,omitempty"`
+	Currency       string            `json:"currency,omitempty"`
+	Category       string            `json:"category"`
+	Images         []string          `json:"images,omitempty"`
+	Specifications map[string]string `json:"specifications,omitempty"`
+	InStock        *bool             `json:"inStock,omitempty"`
+	StockQuantity  *int              `json:"stockQuantity,omitempty"`
+	Featured       *bool             `json:"featured,omitempty"`
+}
+
+// Row is a parsed record with its 1-based position in the source and any
+// errors found while decoding it
+type Row struct {
+	Number int
+	Record Record
+	Errors []models.ImportError
+}
+
+// IsSupportedFormat reports whether format is csv or json
+func IsSupportedFormat(format string) bool {
+	return format == FormatCSV || format == FormatJSON
+}
+
+// Parse decodes rows in the given format
+func Parse(format string, r io.Reader) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r)
+	case FormatJSON:
+		return ParseJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// ParseCSV decodes a CSV file with a header row. Cell-level problems are
+// recorded on the row instead of aborting the whole file.
+func ParseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("csv file is empty")
+		}
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	var rows []Row
+	line := 1
+	for {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			rows = append(rows, Row{
+				Number: line,
+				Errors: []models.ImportError{{Row: line, Message: err.Error()}},
+			})
+			continue
+		}
+
+		rows = append(rows, decodeCSVRow(line, header, cells))
+	}
+
+	return rows, nil
+}
+
+func decodeCSVRow(line int, header, cells []string) Row {
+	row := Row{Number: line}
+	rec := &row.Record
+
+	fail := func(field, message string) {
+		row.Errors = append(row.Errors, models.ImportError{Row: line, SKU: rec.SKU, Field: field, Message: message})
+	}
+
+	for i, column := range header {
+		if i >= len(cells) {
+			break
+		}
+		value := strings.TrimSpace(cells[i])
+
+		if strings.HasPrefix(column, specColumnPrefix) {
+			if value != "" {
+				if rec.Specifications == nil {
+					rec.Specifications = make(map[string]string)
+				}
+				rec.Specifications[strings.TrimPrefix(column, specColumnPrefix)] = value
+			}
+			continue
+		}
+
+		if value == "" {
+			continue
+		}
+
+		switch column {
+		case "sku":
+			rec.SKU = value
+		case "name":
+			rec.Name = value
+		case "description":
+			rec.Description = value
+		case "currency":
+			rec.Currency = strings.ToUpper(value)
+		case "category":
+			rec.Category = value
+		case "images":
+			for _, image := range strings.Split(value, imageSeparator) {
+				if image = strings.TrimSpace(image); image != "" {
+					rec.Images = append(rec.Images, image)
+				}
+			}
+		case "price", "original_price":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				fail(column, "must be a number")
+				continue
+			}
+			if column == "price" {
+				rec.Price = &f
+			} else {
+				rec.OriginalPrice = &f
+			}
+		case "stock_quantity":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				fail(column, "must be a whole number")
+				continue
+			}
+			rec.StockQuantity = &n
+		case "in_stock", "featured":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				fail(column, "must be true or false")
+				continue
+			}
+			if column == "in_stock" {
+				rec.InStock = &b
+			} else {
+				rec.Featured = &b
+			}
+		}
+	}
+
+	// Errors raised before the sku cell was read carry no SKU
+	for i := range row.Errors {
+		row.Errors[i].SKU = rec.SKU
+	}
+
+	return row
+}
+
+// ParseJSON decodes a JSON array of records
+func ParseJSON(r io.Reader) ([]Row, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode json array: %w", err)
+	}
+
+	rows := make([]Row, 0, len(raw))
+	for i, item := range raw {
+		row := Row{Number: i + 1}
+		if err := json.Unmarshal(item, &row.Record); err != nil {
+			row.Errors = append(row.Errors, models.ImportError{Row: row.Number, Message: err.Error()})
+		}
+		row.Record.Currency = strings.ToUpper(row.Record.Currency)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// RecordFromProduct converts a product (with its category loaded) into an export record
+func RecordFromProduct(p *models.Product) Record {
+	price := p.Price
+	inStock := p.InStock
+	stock := p.StockQuantity
+	featured := p.Featured
+
+	return Record{
+		SKU:            p.SKU,
+		Name:           p.Name,
+		Description:    p.Description,
+		Price:          &price,
+		OriginalPrice:  p.OriginalPrice,
+		Currency:       p.Currency,
+		Category:       p.Category.Slug,
+		Images:         p.Images,
+		Specifications: p.Specifications,
+		InStock:        &inStock,
+		StockQuantity:  &stock,
+		Featured:       &featured,
+	}
+}
+
+// Write encodes products in the given format
+func Write(format string, w io.Writer, products []models.Product) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, products)
+	case FormatJSON:
+		return WriteJSON(w, products)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// WriteCSV encodes products as CSV, flattening specifications into one
+// spec:<name> column per distinct specification key
+func WriteCSV(w io.Writer, products []models.Product) error {
+	specKeys := map[string]bool{}
+	for _, p := range products {
+		for k := range p.Specifications {
+			specKeys[k] = true
+		}
+	}
+	keys := make([]string, 0, len(specKeys))
+	for k := range specKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	header := append([]string{}, baseColumns...)
+	for _, k := range keys {
+		header = append(header, specColumnPrefix+k)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := range products {
+		p := &products[i]
+		original := ""
+		if p.OriginalPrice != nil {
+			original = strconv.FormatFloat(*p.OriginalPrice, 'f', -1, 64)
+		}
+
+		cells := []string{
+			p.SKU,
+			p.Name,
+			p.Description,
+			strconv.FormatFloat(p.Price, 'f', -1, 64),
+			original,
+			p.Currency,
+			p.Category.Slug,
+			strings.Join(p.Images, imageSeparator),
+			strconv.FormatBool(p.InStock),
+			strconv.Itoa(p.StockQuantity),
+			strconv.FormatBool(p.Featured),
+		}
+		for _, k := range keys {
+			cells = append(cells, p.Specifications[k])
+		}
+
+		if err := writer.Write(cells); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON encodes products as an indented JSON array of records
+func WriteJSON(w io.Writer, products []models.Product) error {
+	records := make([]Record, len(products))
+	for i := range products {
+		records[i] = RecordFromProduct(&products[i])
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
@@ -0,0 +1,305 @@
+package catalog
+
+import (
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// progressInterval is how many rows are processed between progress writes
+const progressInterval = 25
+
+// Importer validates and upserts catalog rows keyed by SKU
+type Importer struct {
+	db *gorm.DB
+
+	// ProductChanged, when set, runs in the transaction of every product
+	// written so stock and price side effects match edits made elsewhere
+	ProductChanged func(tx *gorm.DB, productID string) error
+	// Restocked, when set, is called with products whose stock an import raised
+	Restocked func(productIDs ...string)
+}
+
+func NewImporter(db *gorm.DB) *Importer {
+	return &Importer{db: db}
+}
+
+// Result summarises an import run
+type Result struct {
+	Total     int                  `json:"total"`
+	Processed int                  `json:"processed"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Failed    int                  `json:"failed"`
+	Errors    []models.ImportError `json:"errors"`
+}
+
+// Run validates every row and, unless dryRun is set, upserts the valid ones.
+// Each row is written in its own transaction so one bad row does not abort
+// the file. progress, when non-nil, is called periodically and at the end.
+func (im *Importer) Run(rows []Row, dryRun bool, progress func(Result)) (Result, error) {
+	result := Result{Total: len(rows), Errors: []models.ImportError{}}
+
+	var categories []models.Category
+	if err := im.db.Find(&categories).Error; err != nil {
+		return result, fmt.Errorf("failed to load categories: %w", err)
+	}
+	categoryBySlug := make(map[string]string, len(categories))
+	for _, c := range categories {
+		categoryBySlug[c.Slug] = c.ID
+	}
+
+	seen := make(map[string]int, len(rows))
+
+	for i := range rows {
+		row := &rows[i]
+		errs := append([]models.ImportError{}, row.Errors...)
+		errs = append(errs, validate(row, categoryBySlug, seen)...)
+
+		if len(errs) == 0 {
+			created, err := im.upsert(row, categoryBySlug, dryRun)
+			if err != nil {
+				errs = append(errs, models.ImportError{Row: row.Number, SKU: row.Record.SKU, Message: err.Error()})
+			} else if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+
+		if len(errs) > 0 {
+			result.Failed++
+			result.Errors = append(result.Errors, errs...)
+		}
+
+		result.Processed++
+		if progress != nil && result.Processed%progressInterval == 0 {
+			progress(result)
+		}
+	}
+
+	if progress != nil {
+		progress(result)
+	}
+
+	return result, nil
+}
+
+// RunJob executes an import for a persisted job, recording progress and the
+// final outcome on the job row. It is meant to be started in a goroutine.
+func (im *Importer) RunJob(jobID string, rows []Row, dryRun bool) {
+	started := time.Now()
+	im.db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+		"status":     models.ImportJobRunning,
+		"total_rows": len(rows),
+		"started_at": &started,
+	})
+
+	result, err := im.Run(rows, dryRun, func(r Result) {
+		im.db.Model(&models.ImportJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+			"processed_rows": r.Processed,
+			"created_rows":   r.Created,
+			"updated_rows":   r.Updated,
+			"failed_rows":    r.Failed,
+		})
+	})
+
+	finished := time.Now()
+	job := models.ImportJob{
+		Status:        models.ImportJobCompleted,
+		ProcessedRows: result.Processed,
+		CreatedRows:   result.Created,
+		UpdatedRows:   result.Updated,
+		FailedRows:    result.Failed,
+		Errors:        result.Errors,
+		FinishedAt:    &finished,
+	}
+	if err != nil {
+		job.Status = models.ImportJobFailed
+		job.Message = err.Error()
+		log.Printf("Catalog import %s failed: %v", jobID, err)
+	}
+
+	im.db.Model(&models.ImportJob{}).Where("id = ?", jobID).
+		Select("status", "processed_rows", "created_rows", "updated_rows", "failed_rows", "errors", "message", "finished_at").
+		Updates(&job)
+}
+
+func validate(row *Row, categoryBySlug map[string]string, seen map[string]int) []models.ImportError {
+	rec := &row.Record
+	var errs []models.ImportError
+	fail := func(field, message string) {
+		errs = append(errs, models.ImportError{Row: row.Number, SKU: rec.SKU, Field: field, Message: message})
+	}
+
+	if rec.SKU == "" {
+		fail("sku", "is required")
+	} else if first, ok := seen[rec.SKU]; ok {
+		fail("sku", fmt.Sprintf("duplicates row %d", first))
+	} else {
+		seen[rec.SKU] = row.Number
+	}
+
+	if rec.Name == "" {
+		fail("name", "is required")
+	}
+	if rec.Price == nil {
+		if !hasFieldError(row, "price") {
+			fail("price", "is required")
+		}
+	} else if *rec.Price <= 0 {
+		fail("price", "must be greater than zero")
+	}
+	if rec.OriginalPrice != nil && *rec.OriginalPrice < 0 {
+		fail("original_price", "must not be negative")
+	}
+	if rec.Currency != "" && pricing.Normalize(rec.Currency) == "" {
+		fail("currency", "is not a supported currency")
+	}
+	if rec.Category == "" {
+		fail("category", "is required")
+	} else if _, ok := categoryBySlug[rec.Category]; !ok {
+		fail("category", fmt.Sprintf("unknown category slug %q", rec.Category))
+	}
+	if rec.StockQuantity != nil && *rec.StockQuantity < 0 {
+		fail("stock_quantity", "must not be negative")
+	}
+
+	return errs
+}
+
+// upsert writes one row, returning whether a new product was created. In
+// dry-run mode it only reports what would happen.
+func (im *Importer) upsert(row *Row, categoryBySlug map[string]string, dryRun bool) (bool, error) {
+	rec := &row.Record
+
+	// Archived products still own their SKU, so match them too; they are
+	// updated in place and stay archived until an admin restores them
+	var existing models.Product
+	err := im.db.Unscoped().Where("sku = ?", rec.SKU).First(&existing).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, err
+	}
+	exists := err == nil
+
+	if dryRun {
+		return !exists, nil
+	}
+
+	if !exists {
+		product := models.Product{
+			SKU:            rec.SKU,
+			Name:           rec.Name,
+			Description:    rec.Description,
+			Price:          *rec.Price,
+			OriginalPrice:  rec.OriginalPrice,
+			Currency:       pricing.BaseCurrency,
+			CategoryID:     categoryBySlug[rec.Category],
+			Images:         rec.Images,
+			Specifications: rec.Specifications,
+			InStock:        true,
+		}
+		if rec.Currency != "" {
+			product.Currency = rec.Currency
+		}
+		if rec.InStock != nil {
+			product.InStock = *rec.InStock
+		}
+		if rec.StockQuantity != nil {
+			product.StockQuantity = *rec.StockQuantity
+		}
+		if rec.Featured != nil {
+			product.Featured = *rec.Featured
+		}
+		if product.Images == nil {
+			product.Images = []string{}
+		}
+
+		return true, im.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&product).Error; err != nil {
+				return err
+			}
+			// in_stock defaults to true, so an explicit false has to be written separately
+			if !product.InStock {
+				if err := tx.Model(&product).Update("in_stock", false).Error; err != nil {
+					return err
+				}
+			}
+			return im.productChanged(tx, product.ID)
+		})
+	}
+
+	updates := map[string]interface{}{
+		"name":        rec.Name,
+		"price":       *rec.Price,
+		"category_id": categoryBySlug[rec.Category],
+	}
+	if rec.Description != "" {
+		updates["description"] = rec.Description
+	}
+	if rec.OriginalPrice != nil {
+		updates["original_price"] = *rec.OriginalPrice
+	}
+	if rec.Currency != "" {
+		updates["currency"] = rec.Currency
+	}
+	// Map updates bypass GORM's json serializer, so encode these columns by hand
+	if rec.Images != nil {
+		images, err := json.Marshal(rec.Images)
+		if err != nil {
+			return false, err
+		}
+		updates["images"] = string(images)
+	}
+	if rec.Specifications != nil {
+		specs, err := json.Marshal(rec.Specifications)
+		if err != nil {
+			return false, err
+		}
+		updates["specifications"] = string(specs)
+	}
+	if rec.InStock != nil {
+		updates["in_stock"] = *rec.InStock
+	}
+	if rec.StockQuantity != nil {
+		updates["stock_quantity"] = *rec.StockQuantity
+	}
+	if rec.Featured != nil {
+		updates["featured"] = *rec.Featured
+	}
+
+	err = im.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&existing).Updates(updates).Error; err != nil {
+			return err
+		}
+		return im.productChanged(tx, existing.ID)
+	})
+	restocked := (rec.StockQuantity != nil && *rec.StockQuantity > existing.StockQuantity) ||
+		(rec.InStock != nil && *rec.InStock && !existing.InStock)
+	if err == nil && restocked && im.Restocked != nil {
+		im.Restocked(existing.ID)
+	}
+	return false, err
+}
+
+func (im *Importer) productChanged(tx *gorm.DB, productID string) error {
+	if im.ProductChanged == nil {
+		return nil
+	}
+	return im.ProductChanged(tx, productID)
+}
+
+// hasFieldError reports whether decoding already rejected a field of the row
+func hasFieldError(row *Row, field string) bool {
+	for _, e := range row.Errors {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
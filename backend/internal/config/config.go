@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -35,8 +39,38 @@ type Config struct {
 	SMTPPass  string
 
 	// Company Info
-	CompanyName  string
-	CompanyEmail string
+	CompanyName    string
+	CompanyEmail   string
+	CompanyAddress string
+	CompanyTaxID   string
+
+	// Invoicing
+	FiscalYearStartMonth int // Invoice numbers restart in this month, 1 for January
+
+	// Uploads
+	UploadDir     string
+	UploadBaseURL string
+	PrivateDir    string // Files that are never served directly, such as digital products
+
+	// Digital downloads
+	DownloadSecret string
+
+	// Order numbering
+	OrderNumberFormat string // Template such as BIZOE-{YYYYMMDD}-{SEQ:4}
+	OrderNumberReset  string // never, daily or yearly
+
+	// Returns
+	ReturnWindowDays int // Days after delivery a return can be requested
+
+	// Shipping
+	FakeCarrierStep time.Duration // How fast fake carrier parcels move, outside production
+
+	// Public order tracking
+	TrackingRateLimit int // Lookups per client IP per minute
+
+	// Proxies whose X-Forwarded-For header is believed when finding the
+	// client IP. None by default, so clients cannot pick their own IP.
+	TrustedProxies []string
 }
 
 func Load() *Config {
@@ -63,6 +97,35 @@ func Load() *Config {
 		CompanyEmail:         getEnv("COMPANY_EMAIL", "luotian@joy8899.com"),
 	}
 
+	cfg.UploadDir = getEnv("UPLOAD_DIR", "./uploads")
+	cfg.UploadBaseURL = getEnv("UPLOAD_BASE_URL", cfg.APIBaseURL+"/uploads")
+	cfg.PrivateDir = getEnv("PRIVATE_UPLOAD_DIR", "./private")
+	cfg.DownloadSecret = getEnv("DOWNLOAD_SECRET", "")
+	if cfg.DownloadSecret == "" {
+		cfg.DownloadSecret = deriveSecret(cfg.JWTSecret, "download-links")
+	}
+	cfg.OrderNumberFormat = getEnv("ORDER_NUMBER_FORMAT", "BIZOE-{YYYYMMDD}-{SEQ:4}")
+	cfg.OrderNumberReset = getEnv("ORDER_NUMBER_RESET", "daily")
+	cfg.ReturnWindowDays = getEnvAsInt("RETURN_WINDOW_DAYS", 7)
+	cfg.FakeCarrierStep = 30 * time.Minute
+	if step, err := time.ParseDuration(getEnv("FAKE_CARRIER_STEP", "")); err == nil {
+		cfg.FakeCarrierStep = step
+	}
+	cfg.TrackingRateLimit = getEnvAsInt("TRACKING_RATE_LIMIT", 10)
+	if proxies := getEnv("TRUSTED_PROXIES", ""); proxies != "" {
+		for _, proxy := range strings.Split(proxies, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+			}
+		}
+	}
+	cfg.CompanyAddress = getEnv("COMPANY_ADDRESS", "")
+	cfg.CompanyTaxID = getEnv("COMPANY_TAX_ID", "")
+	cfg.FiscalYearStartMonth = getEnvAsInt("FISCAL_YEAR_START_MONTH", 1)
+	if cfg.FiscalYearStartMonth < 1 || cfg.FiscalYearStartMonth > 12 {
+		cfg.FiscalYearStartMonth = 1
+	}
+
 	// Parse JWT expiration
 	jwtExpiresIn := getEnv("JWT_EXPIRES_IN", "7d")
 	if duration, err := parseDuration(jwtExpiresIn); err == nil {
@@ -74,6 +137,14 @@ func Load() *Config {
 	return cfg
 }
 
+// deriveSecret derives a key for one purpose from a shared secret, so signed
+// download links and login tokens never share a key
+func deriveSecret(secret, label string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(label))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -30,6 +30,14 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 	if strings.HasPrefix(databaseURL, "sqlite://") {
 		// SQLite database
 		dbPath := strings.TrimPrefix(databaseURL, "sqlite://")
+		// Writers wait for each other instead of failing, and take the write
+		// lock when their transaction starts so two of them cannot deadlock
+		// upgrading a read lock, as concurrent checkouts numbering orders would
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		dbPath += separator + "_busy_timeout=5000&_txlock=immediate"
 		db, err = gorm.Open(sqlite.Open(dbPath), config)
 	} else {
 		// MySQL database
@@ -72,26 +80,125 @@ func Migrate(db *gorm.DB) error {
 		&models.CartItem{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.ProductTranslation{},
+		&models.CategoryTranslation{},
+		&models.ProductPrice{},
+		&models.ExchangeRate{},
+		&models.ImportJob{},
+		&models.ProductImage{},
+		&models.Review{},
+		&models.ReviewVote{},
+		&models.ProductQuestion{},
+		&models.ProductAnswer{},
+		&models.QuestionVote{},
+		&models.ProductCompatibility{},
+		&models.BundleItem{},
+		&models.RecommendationOverride{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.StockSubscription{},
+		&models.StockReceipt{},
+		&models.SubscriptionPlan{},
+		&models.Subscription{},
+		&models.SubscriptionCharge{},
+		&models.PrintMaterial{},
+		&models.PrintModel{},
+		&models.PrintQuote{},
+		&models.Printer{},
+		&models.PrintJob{},
+		&models.PrintJobEvent{},
+		&models.DigitalFile{},
+		&models.DownloadEntitlement{},
+		&models.ProductSerial{},
+		&models.WarrantyClaim{},
+		&models.ReturnRequest{},
+		&models.Shipment{},
+		&models.ShipmentItem{},
+		&models.ShipmentEvent{},
+		&models.Sequence{},
+		&models.Document{},
+		&models.DocumentFile{},
+		&models.SavedAddress{},
+		&models.OrderEdit{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := backfillProductSKUs(db); err != nil {
+		return fmt.Errorf("failed to backfill product SKUs: %w", err)
+	}
+
+	if err := backfillOrderItemSnapshots(db); err != nil {
+		return fmt.Errorf("failed to backfill order item snapshots: %w", err)
+	}
+
 	// Seed initial data
 	if err := seedInitialData(db); err != nil {
 		return fmt.Errorf("failed to seed initial data: %w", err)
 	}
 
+	if err := seedExchangeRates(db); err != nil {
+		return fmt.Errorf("failed to seed exchange rates: %w", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
 
+// backfillProductSKUs assigns generated SKUs to products created before SKUs existed
+func backfillProductSKUs(db *gorm.DB) error {
+	var products []models.Product
+	if err := db.Where("sku IS NULL OR sku = ''").Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if err := db.Model(&models.Product{}).Where("id = ?", p.ID).Update("sku", models.GenerateSKU(p.ID)).Error; err != nil {
+			return fmt.Errorf("failed to set SKU for product %s: %w", p.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillOrderItemSnapshots copies product details onto order items placed
+// before snapshots were recorded, as long as the product row still exists
+func backfillOrderItemSnapshots(db *gorm.DB) error {
+	var items []models.OrderItem
+	if err := db.Where("product_name IS NULL OR product_name = ''").Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var product models.Product
+		if err := db.Unscoped().First(&product, "id = ?", item.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return err
+		}
+
+		item.ProductName = product.Name
+		item.ProductSKU = product.SKU
+		item.ProductSpecifications = product.Specifications
+		if len(product.Images) > 0 {
+			item.ProductImage = product.Images[0]
+		}
+		if err := db.Model(&item).Select("product_name", "product_sku", "product_image", "product_specifications").Updates(&item).Error; err != nil {
+			return fmt.Errorf("failed to snapshot order item %s: %w", item.ID, err)
+		}
+	}
+
+	return nil
+}
+
 // seedInitialData creates initial categories and sample products
 func seedInitialData(db *gorm.DB) error {
 	// Check if categories already exist
 	var count int64
-	db.Model(&models.Category{}).Count(&count)
+	db.Unscoped().Model(&models.Category{}).Count(&count)
 	if count > 0 {
 		log.Println("Initial data already exists, skipping seed")
 		return nil
@@ -133,6 +240,20 @@ func seedInitialData(db *gorm.DB) error {
 		}
 	}
 
+	// Traditional Chinese category content for the zh-TW storefront
+	categoryTranslations := []models.CategoryTranslation{
+		{CategoryID: categories[0].ID, Locale: "zh-TW", Name: "3D 列印機", Description: "適合專業與愛好者使用的高精度 3D 列印機"},
+		{CategoryID: categories[1].ID, Locale: "zh-TW", Name: "列印耗材", Description: "優質光敏樹脂、線材與列印材料"},
+		{CategoryID: categories[2].ID, Locale: "zh-TW", Name: "後處理設備", Description: "固化、清洗與表面處理設備"},
+		{CategoryID: categories[3].ID, Locale: "zh-TW", Name: "3D 掃描器", Description: "專業 3D 掃描解決方案"},
+	}
+
+	for i := range categoryTranslations {
+		if err := db.Create(&categoryTranslations[i]).Error; err != nil {
+			return fmt.Errorf("failed to create category translation %s: %w", categoryTranslations[i].Name, err)
+		}
+	}
+
 	// Create sample products
 	products := []models.Product{
 		{
@@ -197,6 +318,7 @@ func seedInitialData(db *gorm.DB) error {
 			InStock:       true,
 			StockQuantity: 50,
 			Featured:      false,
+			SealedRestock: true,
 		},
 		{
 			Name:          "Professional Curing & Washing Station",
@@ -267,6 +389,67 @@ func seedInitialData(db *gorm.DB) error {
 		}
 	}
 
+	// Link the sample printers to the materials and equipment that suit them
+	compatibilities := []models.ProductCompatibility{
+		{
+			ProductID:           products[0].ID,
+			CompatibleProductID: products[2].ID,
+			Type:                models.CompatibilityMaterial,
+			Notes:               "Standard 405nm resin, tested on the 16K screen",
+			Settings: map[string]string{
+				"Layer Height":    "0.05mm",
+				"Exposure Time":   "2.5s",
+				"Bottom Exposure": "25s",
+				"Bottom Layers":   "5",
+			},
+		},
+		{
+			ProductID:           products[0].ID,
+			CompatibleProductID: products[3].ID,
+			Type:                models.CompatibilityPostProcessing,
+			Notes:               "Fits the full Revo build plate",
+		},
+		{
+			ProductID:           products[1].ID,
+			CompatibleProductID: products[4].ID,
+			Type:                models.CompatibilityMaterial,
+			Notes:               "1.75mm filament, dry before printing",
+			Settings: map[string]string{
+				"Nozzle Temp": "215°C",
+				"Bed Temp":    "60°C",
+				"Print Speed": "300mm/s",
+			},
+		},
+	}
+
+	for i := range compatibilities {
+		if err := db.Create(&compatibilities[i]).Error; err != nil {
+			return fmt.Errorf("failed to create product compatibility: %w", err)
+		}
+	}
+
 	log.Println("Initial data seeding completed successfully")
 	return nil
 }
+
+// seedExchangeRates creates default presentment rates so TWD and EUR work out of the box
+func seedExchangeRates(db *gorm.DB) error {
+	var count int64
+	db.Model(&models.ExchangeRate{}).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	rates := []models.ExchangeRate{
+		{Currency: "TWD", Rate: 32, Rounding: models.RoundingNearest, RoundingIncrement: 1},
+		{Currency: "EUR", Rate: 0.92, Rounding: models.RoundingCharm, RoundingIncrement: 1},
+	}
+
+	for i := range rates {
+		if err := db.Create(&rates[i]).Error; err != nil {
+			return fmt.Errorf("failed to create exchange rate %s: %w", rates[i].Currency, err)
+		}
+	}
+
+	return nil
+}
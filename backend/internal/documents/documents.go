@@ -0,0 +1,322 @@
+// Package documents renders invoices, credit notes and packing slips as PDF
+// in any supported locale
+package documents
+
+import (
+	"bizoe-3d-store/internal/config"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pdf"
+	"bizoe-3d-store/internal/pricing"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page layout in points
+const (
+	left   = 50.0
+	right  = pdf.PageWidth - 50
+	top    = 60.0
+	bottom = pdf.PageHeight - 70
+	rowGap = 16.0
+)
+
+// Issuer is the company named on the documents
+type Issuer struct {
+	Name    string
+	Address string // May span several lines
+	Email   string
+	TaxID   string
+}
+
+func NewIssuer(cfg *config.Config) Issuer {
+	return Issuer{
+		Name:    cfg.CompanyName,
+		Address: cfg.CompanyAddress,
+		Email:   cfg.CompanyEmail,
+		TaxID:   cfg.CompanyTaxID,
+	}
+}
+
+// PackingSlip lists what is left to pack for one order
+type PackingSlip struct {
+	Order *models.Order
+	Lines []PackingLine
+}
+
+type PackingLine struct {
+	SKU      string
+	Name     string
+	Quantity int
+}
+
+// Invoice renders an issued invoice for an order with its items loaded
+func Invoice(issuer Issuer, invoice *models.Document, order *models.Order, locale string) []byte {
+	doc := pdf.New(label(locale, "invoice") + " " + invoice.Number)
+	y := header(doc, issuer, locale, label(locale, "invoice"), [][2]string{
+		{label(locale, "invoiceNumber"), invoice.Number},
+		{label(locale, "date"), formatDate(invoice.IssuedAt, locale)},
+		{label(locale, "orderNumber"), order.OrderNumber},
+		{label(locale, "currency"), order.Currency},
+	})
+	y = addressBlock(doc, left, y, label(locale, "billTo"), billingLines(order.BillingAddress, locale))
+
+	t := newTable(doc, y+10, []column{
+		{title: label(locale, "description"), x: left, width: 230},
+		{title: label(locale, "sku"), x: 285, width: 85},
+		{title: label(locale, "quantity"), x: 410, width: 35, alignRight: true},
+		{title: label(locale, "unitPrice"), x: 475, width: 60, alignRight: true},
+		{title: label(locale, "amount"), x: right, width: 65, alignRight: true},
+	})
+	for _, item := range order.Items {
+		t.row(item.ProductName, item.ProductSKU, strconv.Itoa(item.Quantity),
+			formatMoney(item.Price, order.Currency), formatMoney(item.Total, order.Currency))
+	}
+
+	y = totals(doc, t.end(), [][2]string{
+		{label(locale, "subtotal"), formatMoney(order.Subtotal, order.Currency)},
+		{label(locale, "shipping"), formatMoney(order.Shipping, order.Currency)},
+		{label(locale, "tax"), formatMoney(order.Tax, order.Currency)},
+		{label(locale, "total"), formatMoney(order.Total, order.Currency) + " " + order.Currency},
+	})
+	doc.Text(left, y+10, 9, false, label(locale, "paid"))
+	return doc.Bytes()
+}
+
+// CreditNote renders an issued credit note for a refunded return with its
+// order line loaded
+func CreditNote(issuer Issuer, note *models.Document, order *models.Order, ret *models.ReturnRequest, locale string) []byte {
+	doc := pdf.New(label(locale, "creditNote") + " " + note.Number)
+	fields := [][2]string{
+		{label(locale, "creditNumber"), note.Number},
+		{label(locale, "date"), formatDate(note.IssuedAt, locale)},
+		{label(locale, "creditedNumber"), note.InvoiceNumber},
+		{label(locale, "orderNumber"), order.OrderNumber},
+	}
+	if ret.RMANumber != nil {
+		fields = append(fields, [2]string{label(locale, "rmaNumber"), *ret.RMANumber})
+	}
+	fields = append(fields, [2]string{label(locale, "currency"), order.Currency})
+	y := header(doc, issuer, locale, label(locale, "creditNote"), fields)
+	y = addressBlock(doc, left, y, label(locale, "billTo"), billingLines(order.BillingAddress, locale))
+
+	t := newTable(doc, y+10, []column{
+		{title: label(locale, "description"), x: left, width: 300},
+		{title: label(locale, "sku"), x: 355, width: 85},
+		{title: label(locale, "quantity"), x: 470, width: 35, alignRight: true},
+		{title: label(locale, "amount"), x: right, width: 65, alignRight: true},
+	})
+	name, sku := "", ""
+	if ret.OrderItem != nil {
+		name, sku = ret.OrderItem.ProductName, ret.OrderItem.ProductSKU
+	}
+	t.row(name, sku, strconv.Itoa(ret.Quantity), formatMoney(note.Amount, note.Currency))
+
+	y = totals(doc, t.end(), [][2]string{
+		{label(locale, "credited"), formatMoney(note.Amount, note.Currency) + " " + note.Currency},
+	})
+	doc.Text(left, y+10, 9, false, label(locale, "creditReason"))
+	return doc.Bytes()
+}
+
+// PackingSlips renders one page per order, for printing a batch at once
+func PackingSlips(issuer Issuer, slips []PackingSlip, locale string) []byte {
+	doc := pdf.New(label(locale, "packingSlip"))
+	for _, slip := range slips {
+		order := slip.Order
+		y := header(doc, issuer, locale, label(locale, "packingSlip"), [][2]string{
+			{label(locale, "orderNumber"), order.OrderNumber},
+			{label(locale, "orderDate"), formatDate(order.CreatedAt, locale)},
+		})
+		y = addressBlock(doc, left, y, label(locale, "shipTo"), shippingLines(order.ShippingAddress))
+
+		t := newTable(doc, y+10, []column{
+			{title: label(locale, "sku"), x: left, width: 85},
+			{title: label(locale, "description"), x: 140, width: 290},
+			{title: label(locale, "quantity"), x: 470, width: 35, alignRight: true},
+			{title: label(locale, "packed"), x: right, width: 50, alignRight: true},
+		})
+		for _, line := range slip.Lines {
+			t.row(line.SKU, line.Name, strconv.Itoa(line.Quantity), "")
+			checkbox(doc, right-10, t.y-rowGap-8)
+		}
+		doc.Text(left, t.end()+10, 9, false, label(locale, "packingNote"))
+	}
+	return doc.Bytes()
+}
+
+// header starts a page with the issuer, the document title and its
+// reference fields, and returns where the body starts
+func header(doc *pdf.Document, issuer Issuer, locale, title string, fields [][2]string) float64 {
+	doc.AddPage()
+	doc.TextRight(right, top, 20, true, title)
+	doc.Text(left, top, 11, true, pdf.Truncate(issuer.Name, 11, true, 340))
+
+	y := top + 16
+	lines := strings.Split(issuer.Address, "\n")
+	lines = append(lines, issuer.Email)
+	if issuer.TaxID != "" {
+		lines = append(lines, label(locale, "taxID")+": "+issuer.TaxID)
+	}
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			doc.Text(left, y, 9, false, pdf.Truncate(line, 9, false, 300))
+			y += 12
+		}
+	}
+
+	fy := top + 24
+	for _, field := range fields {
+		doc.Text(370, fy, 9, true, field[0])
+		doc.TextRight(right, fy, 9, false, field[1])
+		fy += 13
+	}
+
+	if fy > y {
+		y = fy
+	}
+	doc.Line(left, y+4, right, y+4)
+	return y + 24
+}
+
+// addressBlock draws a heading with address lines below it and returns the
+// position under the block
+func addressBlock(doc *pdf.Document, x, y float64, heading string, lines []string) float64 {
+	doc.Text(x, y, 10, true, heading)
+	y += 14
+	for _, line := range lines {
+		doc.Text(x, y, 9, false, pdf.Truncate(line, 9, false, 300))
+		y += 12
+	}
+	return y
+}
+
+// totals draws label and amount pairs under a table, the last one in bold,
+// and returns the position under them
+func totals(doc *pdf.Document, y float64, rows [][2]string) float64 {
+	for i, row := range rows {
+		bold := i == len(rows)-1
+		doc.Text(380, y, 9, bold, row[0])
+		doc.TextRight(right, y, 9, bold, row[1])
+		y += 14
+	}
+	return y
+}
+
+// checkbox draws an empty square for ticking by hand, its top-left corner at (x, y)
+func checkbox(doc *pdf.Document, x, y float64) {
+	const size = 8.0
+	doc.Line(x, y, x+size, y)
+	doc.Line(x+size, y, x+size, y+size)
+	doc.Line(x+size, y+size, x, y+size)
+	doc.Line(x, y+size, x, y)
+}
+
+type column struct {
+	title      string
+	x          float64 // Left edge, or right edge when aligned right
+	width      float64
+	alignRight bool
+}
+
+// table draws rows of columns, continuing on a new page when one fills up
+type table struct {
+	doc     *pdf.Document
+	columns []column
+	y       float64
+}
+
+func newTable(doc *pdf.Document, y float64, columns []column) *table {
+	t := &table{doc: doc, columns: columns, y: y}
+	t.header()
+	return t
+}
+
+func (t *table) header() {
+	titles := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		titles[i] = c.title
+	}
+	t.draw(titles, true)
+	t.doc.Line(left, t.y-rowGap+4, right, t.y-rowGap+4)
+	t.y += 4
+}
+
+func (t *table) row(values ...string) {
+	if t.y > bottom {
+		t.doc.AddPage()
+		t.y = top
+		t.header()
+	}
+	t.draw(values, false)
+}
+
+func (t *table) draw(values []string, bold bool) {
+	for i, c := range t.columns {
+		text := pdf.Truncate(values[i], 9, bold, c.width)
+		if c.alignRight {
+			t.doc.TextRight(c.x, t.y, 9, bold, text)
+		} else {
+			t.doc.Text(c.x, t.y, 9, bold, text)
+		}
+	}
+	t.y += rowGap
+}
+
+// end rules off the table and returns the position under it
+func (t *table) end() float64 {
+	t.doc.Line(left, t.y-rowGap+4, right, t.y-rowGap+4)
+	return t.y + 8
+}
+
+func billingLines(a models.Address, locale string) []string {
+	lines := []string{strings.TrimSpace(a.FirstName + " " + a.LastName)}
+	if a.Company != "" {
+		lines = append(lines, a.Company)
+	}
+	lines = append(lines, a.Address, cityLine(a), a.Country)
+	if a.TaxID != "" {
+		lines = append(lines, label(locale, "taxID")+": "+a.TaxID)
+	}
+	return append(lines, a.Email)
+}
+
+func shippingLines(a models.Address) []string {
+	lines := []string{strings.TrimSpace(a.FirstName + " " + a.LastName)}
+	if a.Company != "" {
+		lines = append(lines, a.Company)
+	}
+	return append(lines, a.Address, cityLine(a), a.Country, a.Phone)
+}
+
+func cityLine(a models.Address) string {
+	parts := []string{}
+	for _, part := range []string{a.City, a.State, a.ZipCode} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatDate(t time.Time, locale string) string {
+	return t.Format(label(locale, "dateFormat"))
+}
+
+// formatMoney writes an amount with the currency's decimals and thousands separators
+func formatMoney(amount float64, currency string) string {
+	text := strconv.FormatFloat(amount, 'f', pricing.Decimals(currency), 64)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		whole, fraction = text[:i], text[i:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return fmt.Sprintf("%s%s%s", sign, whole, fraction)
+}
@@ -0,0 +1,77 @@
+package documents
+
+import "bizoe-3d-store/internal/i18n"
+
+// labels holds the fixed wording of the documents per supported locale
+var labels = map[string]map[string]string{
+	"en": {
+		"invoice":        "INVOICE",
+		"creditNote":     "CREDIT NOTE",
+		"packingSlip":    "PACKING SLIP",
+		"invoiceNumber":  "Invoice No.",
+		"creditNumber":   "Credit Note No.",
+		"creditedNumber": "Credits Invoice",
+		"rmaNumber":      "RMA No.",
+		"date":           "Date",
+		"orderNumber":    "Order No.",
+		"orderDate":      "Order Date",
+		"currency":       "Currency",
+		"billTo":         "Bill to",
+		"shipTo":         "Ship to",
+		"taxID":          "Tax ID",
+		"description":    "Description",
+		"sku":            "SKU",
+		"quantity":       "Qty",
+		"unitPrice":      "Unit Price",
+		"amount":         "Amount",
+		"subtotal":       "Subtotal",
+		"shipping":       "Shipping",
+		"tax":            "Tax",
+		"total":          "Total",
+		"credited":       "Amount Credited",
+		"packed":         "Packed",
+		"paid":           "Paid in full. Thank you for your order.",
+		"creditReason":   "Refund for returned goods",
+		"packingNote":    "Check each line as it is packed.",
+		"dateFormat":     "Jan 2, 2006",
+	},
+	"zh-TW": {
+		"invoice":        "發票",
+		"creditNote":     "貸項通知單",
+		"packingSlip":    "裝箱單",
+		"invoiceNumber":  "發票號碼",
+		"creditNumber":   "通知單號碼",
+		"creditedNumber": "原發票號碼",
+		"rmaNumber":      "退貨授權編號",
+		"date":           "日期",
+		"orderNumber":    "訂單編號",
+		"orderDate":      "訂購日期",
+		"currency":       "幣別",
+		"billTo":         "買受人",
+		"shipTo":         "收件人",
+		"taxID":          "統一編號",
+		"description":    "品名",
+		"sku":            "料號",
+		"quantity":       "數量",
+		"unitPrice":      "單價",
+		"amount":         "金額",
+		"subtotal":       "小計",
+		"shipping":       "運費",
+		"tax":            "稅額",
+		"total":          "總計",
+		"credited":       "退款金額",
+		"packed":         "已裝箱",
+		"paid":           "已全額付款，感謝您的訂購。",
+		"creditReason":   "退貨退款",
+		"packingNote":    "裝箱時請逐項勾選。",
+		"dateFormat":     "2006/01/02",
+	},
+}
+
+// label returns the wording of key in locale, falling back to the default locale
+func label(locale, key string) string {
+	if text, ok := labels[locale][key]; ok {
+		return text
+	}
+	return labels[i18n.DefaultLocale][key]
+}
@@ -0,0 +1,105 @@
+package email
+
+import (
+	"bizoe-3d-store/internal/config"
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. SMTP is used when a host is configured; otherwise
+// messages are only logged, which keeps development setups working.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender returns the sender configured by the SMTP settings
+func NewSender(cfg *config.Config) Sender {
+	if cfg.SMTPHost == "" {
+		return LogSender{}
+	}
+	return &SMTPSender{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUser,
+		Password: cfg.SMTPPass,
+		From:     cfg.EmailFrom,
+		FromName: cfg.CompanyName,
+	}
+}
+
+// SendAsync delivers msg in the background so request handlers do not wait
+// on the mail server. Failures are logged.
+func SendAsync(sender Sender, msg Message) {
+	go func() {
+		if err := sender.Send(msg); err != nil {
+			log.Printf("Failed to send email %q to %s: %v", msg.Subject, strings.Join(msg.To, ", "), err)
+		}
+	}()
+}
+
+// SMTPSender sends mail through an SMTP server using net/smtp
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	FromName string
+}
+
+// Send implements Sender
+func (s *SMTPSender) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("email has no recipients")
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := s.Host + ":" + strconv.Itoa(s.Port)
+	return smtp.SendMail(addr, auth, s.From, msg.To, s.build(msg))
+}
+
+// build renders the RFC 5322 message with UTF-8 headers and body
+func (s *SMTPSender) build(msg Message) []byte {
+	from := s.From
+	if s.FromName != "" {
+		from = fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", s.FromName), s.From)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// LogSender writes messages to the log instead of sending them
+type LogSender struct{}
+
+// Send implements Sender
+func (LogSender) Send(msg Message) error {
+	log.Printf("Email to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Body)
+	return nil
+}
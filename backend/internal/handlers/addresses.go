@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/addresses"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSavedAddresses caps the size of a user's address book
+const maxSavedAddresses = 20
+
+var (
+	errAddressNotFound = errors.New("saved address not found")
+	errAddressRequired = errors.New("address required")
+)
+
+type AddressHandler struct {
+	db *gorm.DB
+}
+
+type SaveAddressRequest struct {
+	Label             string         `json:"label" binding:"max=50"`
+	Address           models.Address `json:"address" binding:"required"`
+	IsDefaultShipping bool           `json:"isDefaultShipping"`
+	IsDefaultBilling  bool           `json:"isDefaultBilling"`
+}
+
+func NewAddressHandler(db *gorm.DB) *AddressHandler {
+	return &AddressHandler{db: db}
+}
+
+// GetAddresses returns the user's address book, defaults first
+func (h *AddressHandler) GetAddresses(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var saved []models.SavedAddress
+	if err := h.db.Where("user_id = ?", userID).
+		Order("is_default_shipping DESC, is_default_billing DESC, created_at ASC").
+		Find(&saved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch addresses",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    saved,
+	})
+}
+
+// CreateAddress adds an address to the user's address book. The first
+// address saved becomes the default for both shipping and billing.
+func (h *AddressHandler) CreateAddress(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req SaveAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := addresses.Normalize(&req.Address); err != nil {
+		respondAddressError(c, "address", err)
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.SavedAddress{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save address",
+		})
+		return
+	}
+	if count >= maxSavedAddresses {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Address book full",
+			"message": "Remove an address before saving another one",
+		})
+		return
+	}
+
+	saved := models.SavedAddress{
+		UserID:            userID,
+		Label:             req.Label,
+		Address:           req.Address,
+		IsDefaultShipping: req.IsDefaultShipping || count == 0,
+		IsDefaultBilling:  req.IsDefaultBilling || count == 0,
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearOtherDefaults(tx, &saved); err != nil {
+			return err
+		}
+		return tx.Create(&saved).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save address",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Address saved successfully",
+		"data":    saved,
+	})
+}
+
+// UpdateAddress replaces a saved address. Orders already placed keep the
+// copy they were given at checkout.
+func (h *AddressHandler) UpdateAddress(c *gin.Context) {
+	saved, ok := h.findAddress(c)
+	if !ok {
+		return
+	}
+
+	var req SaveAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := addresses.Normalize(&req.Address); err != nil {
+		respondAddressError(c, "address", err)
+		return
+	}
+
+	saved.Label = req.Label
+	saved.Address = req.Address
+	saved.IsDefaultShipping = req.IsDefaultShipping
+	saved.IsDefaultBilling = req.IsDefaultBilling
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearOtherDefaults(tx, saved); err != nil {
+			return err
+		}
+		return tx.Save(saved).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update address",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Address updated successfully",
+		"data":    saved,
+	})
+}
+
+// DeleteAddress removes a saved address. A default it held passes to the
+// most recently saved address left, so checkout keeps a fallback.
+func (h *AddressHandler) DeleteAddress(c *gin.Context) {
+	saved, ok := h.findAddress(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(saved).Error; err != nil {
+			return err
+		}
+		for column, held := range map[string]bool{
+			"is_default_shipping": saved.IsDefaultShipping,
+			"is_default_billing":  saved.IsDefaultBilling,
+		} {
+			if !held {
+				continue
+			}
+			var next models.SavedAddress
+			err := tx.Where("user_id = ?", saved.UserID).Order("created_at DESC").First(&next).Error
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&next).Update(column, true).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete address",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Address deleted successfully",
+	})
+}
+
+// findAddress loads the caller's saved address named in the URL, writing the
+// error response itself when there is none
+func (h *AddressHandler) findAddress(c *gin.Context) (*models.SavedAddress, bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return nil, false
+	}
+
+	var saved models.SavedAddress
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&saved).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Address not found",
+				"message": "The requested address does not exist",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch address",
+		})
+		return nil, false
+	}
+	return &saved, true
+}
+
+// clearOtherDefaults takes the default flags saved is about to hold away from
+// the user's other addresses
+func clearOtherDefaults(tx *gorm.DB, saved *models.SavedAddress) error {
+	others := func() *gorm.DB {
+		query := tx.Model(&models.SavedAddress{}).Where("user_id = ?", saved.UserID)
+		if saved.ID != "" {
+			query = query.Where("id <> ?", saved.ID)
+		}
+		return query
+	}
+	if saved.IsDefaultShipping {
+		if err := others().Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if saved.IsDefaultBilling {
+		if err := others().Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkoutAddress picks the address an order ships or bills to: the saved
+// address with the given ID, else the address typed in at checkout, else
+// the user's default for defaultColumn. The result is a copy validated
+// against the current rules of its country.
+func checkoutAddress(db *gorm.DB, userID, savedID string, inline *models.Address, defaultColumn string) (models.Address, error) {
+	var address models.Address
+	switch {
+	case savedID != "":
+		var saved models.SavedAddress
+		if err := db.Where("id = ? AND user_id = ?", savedID, userID).First(&saved).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return address, errAddressNotFound
+			}
+			return address, err
+		}
+		address = saved.Address
+	case inline != nil:
+		address = *inline
+	default:
+		var saved models.SavedAddress
+		if err := db.Where("user_id = ? AND "+defaultColumn+" = ?", userID, true).First(&saved).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return address, errAddressRequired
+			}
+			return address, err
+		}
+		address = saved.Address
+	}
+	return address, addresses.Normalize(&address)
+}
+
+// respondAddressError reports an address that could not be used, naming the
+// offending field under the request field it came from
+func respondAddressError(c *gin.Context, field string, err error) {
+	var invalid *addresses.Error
+	switch {
+	case err == errAddressNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Address not found",
+			"message": "The saved " + field + " does not exist",
+		})
+		return
+	case err == errAddressRequired:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": field + " is required",
+		})
+		return
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid address",
+			"message": field + "." + invalid.Error(),
+			"field":   field + "." + invalid.Field,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Database error",
+		"message": "Failed to fetch address",
+	})
+}
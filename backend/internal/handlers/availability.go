@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validAvailabilitySettings checks the availability fields of a product
+// create or update. It writes an error response and returns false when they
+// are invalid.
+func validAvailabilitySettings(c *gin.Context, product *models.Product) bool {
+	message := ""
+	switch {
+	case product.Availability != "" && !models.IsValidAvailability(product.Availability):
+		message = "Availability must be in_stock, preorder or backorder"
+	case product.DepositPercent < 0 || product.DepositPercent > 100:
+		message = "Deposit percent must be between 0 and 100"
+	case product.BackorderLimit < 0:
+		message = "Backorder limit cannot be negative"
+	case product.DownloadLimit < 0:
+		message = "Download limit cannot be negative"
+	case product.WarrantyMonths < 0:
+		message = "Warranty months cannot be negative"
+	case product.IsDigital && (product.Availability == models.AvailabilityPreorder || product.Availability == models.AvailabilityBackorder):
+		message = "Digital products are always available and cannot be pre-ordered or backordered"
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": message,
+		})
+		return false
+	}
+	return true
+}
+
+// pendingLines restricts db to pre-order and backorder lines of live orders
+// that are still waiting for stock
+func pendingLines(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.line_type <> ? AND order_items.allocated_at IS NULL", models.OrderLineStock).
+		Where("orders.status <> ?", models.OrderStatusCancelled)
+}
+
+// pendingQuantity returns how many units of a product are owed to customers
+func pendingQuantity(db *gorm.DB, productID string) (int, error) {
+	var total int64
+	err := pendingLines(db).Where("order_items.product_id = ?", productID).
+		Select("COALESCE(SUM(order_items.quantity), 0)").Scan(&total).Error
+	return int(total), err
+}
+
+// hasPendingLines reports whether an order still waits for stock on any line
+func hasPendingLines(db *gorm.DB, orderID string) bool {
+	var count int64
+	db.Model(&models.OrderItem{}).
+		Where("order_id = ? AND line_type <> ? AND allocated_at IS NULL", orderID, models.OrderLineStock).
+		Count(&count)
+	return count > 0
+}
+
+// orderableQuantity returns how many units of a product a customer may
+// order right now. Digital products and pre-orders are unlimited;
+// backorders may owe up to BackorderLimit units beyond stock across all open
+// orders.
+func orderableQuantity(db *gorm.DB, product *models.Product) (int, error) {
+	if product.IsDigital {
+		return math.MaxInt32, nil
+	}
+	stock := product.StockQuantity
+	if stock < 0 {
+		stock = 0
+	}
+	if product.IsBundle {
+		return stock, nil
+	}
+
+	switch product.Availability {
+	case models.AvailabilityPreorder:
+		return math.MaxInt32, nil
+	case models.AvailabilityBackorder:
+		owed, err := pendingQuantity(db, product.ID)
+		if err != nil {
+			return 0, err
+		}
+		// Units owed to earlier orders are served from stock first
+		if remaining := stock + product.BackorderLimit - owed; remaining > 0 {
+			return remaining, nil
+		}
+		return 0, nil
+	default:
+		if !product.InStock {
+			return 0, nil
+		}
+		return stock, nil
+	}
+}
+
+// orderLines turns a cart item into order lines. In stock products take
+// their units out of stock; pre-order and backorder products use what stock
+// is left once earlier waiting orders are served and queue the rest on a
+// separate pending line. Digital products take no stock, and custom prints
+// are queued for production instead. It returns the balance left to pay on pre-order
+// deposits and the products whose stock changed.
+func orderLines(tx *gorm.DB, orderID string, cartItem *models.CartItem, currency string) (float64, []string, error) {
+	if cartItem.PrintQuote != nil {
+		return 0, nil, createPrintLine(tx, orderID, cartItem)
+	}
+
+	product := &cartItem.Product
+	now := time.Now()
+
+	line := models.OrderItem{
+		OrderID:   orderID,
+		ProductID: cartItem.ProductID,
+		Quantity:  cartItem.Quantity,
+		Price:     cartItem.Price,
+		LineType:  models.OrderLineStock,
+
+		ProductName:           product.Name,
+		ProductSKU:            product.SKU,
+		ProductSpecifications: product.Specifications,
+		BundleComponents:      bundleSnapshot(product),
+		AllocatedAt:           &now,
+	}
+	if len(product.Images) > 0 {
+		line.ProductImage = product.Images[0]
+	}
+
+	if product.IsDigital {
+		// Delivered as downloads once paid
+		line.LineType = models.OrderLineDigital
+		return 0, nil, tx.Create(&line).Error
+	}
+
+	if product.IsBundle || (product.Availability != models.AvailabilityPreorder && product.Availability != models.AvailabilityBackorder) {
+		if err := tx.Create(&line).Error; err != nil {
+			return 0, nil, err
+		}
+		touched, err := reserveStock(tx, &line)
+		return 0, touched, err
+	}
+
+	owed, err := pendingQuantity(tx, product.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Nobody jumps the queue: stock only serves new orders once nothing is owed
+	fromStock := 0
+	if owed == 0 && product.StockQuantity > 0 {
+		fromStock = cartItem.Quantity
+		if product.StockQuantity < fromStock {
+			fromStock = product.StockQuantity
+		}
+	}
+
+	var touched []string
+	if fromStock > 0 {
+		stockLine := line
+		stockLine.Quantity = fromStock
+		if err := tx.Create(&stockLine).Error; err != nil {
+			return 0, nil, err
+		}
+		if err := decrementStock(tx, product.ID, fromStock); err != nil {
+			return 0, nil, err
+		}
+		touched = append(touched, product.ID)
+	}
+
+	waiting := cartItem.Quantity - fromStock
+	if waiting == 0 {
+		return 0, touched, nil
+	}
+
+	pendingLine := line
+	pendingLine.Quantity = waiting
+	pendingLine.AllocatedAt = nil
+	balance := 0.0
+	if product.Availability == models.AvailabilityPreorder {
+		pendingLine.LineType = models.OrderLinePreorder
+		pendingLine.ExpectedShipDate = product.ExpectedShipDate
+		if product.DepositPercent > 0 && product.DepositPercent < 100 {
+			lineTotal := pendingLine.Price * float64(waiting)
+			pendingLine.DepositAmount = pricing.RoundAmount(lineTotal*product.DepositPercent/100, currency)
+			balance = lineTotal - pendingLine.DepositAmount
+		}
+	} else {
+		// Stock held back for the head of the queue counts against what is owed
+		if owed+waiting-(product.StockQuantity-fromStock) > product.BackorderLimit {
+			return 0, nil, errInsufficientStock
+		}
+		pendingLine.LineType = models.OrderLineBackorder
+	}
+	if err := tx.Create(&pendingLine).Error; err != nil {
+		return 0, nil, err
+	}
+	return balance, touched, nil
+}
+
+// productChanged gives waiting pre-orders and backorders first claim on a
+// product's stock and re-derives the bundles built from it. Call it in the
+// transaction that changed the product's stock or price.
+func productChanged(tx *gorm.DB, productID string) error {
+	if _, err := allocatePendingLines(tx, productID); err != nil {
+		return err
+	}
+	return syncBundlesContaining(tx, []string{productID})
+}
+
+// allocatePendingLines sets aside stock for waiting pre-order and backorder
+// lines of a product in order of purchase. A line is only allocated in full,
+// and allocation stops at the first line that does not fit so later orders
+// cannot overtake it. It returns the number of lines allocated.
+func allocatePendingLines(tx *gorm.DB, productID string) (int, error) {
+	var product models.Product
+	if err := tx.Select("id", "stock_quantity").First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var lines []models.OrderItem
+	if err := pendingLines(tx).Where("order_items.product_id = ?", productID).
+		Order("orders.created_at ASC").Order("order_items.created_at ASC").
+		Select("order_items.*").Find(&lines).Error; err != nil {
+		return 0, err
+	}
+
+	stock := product.StockQuantity
+	allocated := 0
+	for _, line := range lines {
+		if line.Quantity > stock {
+			break
+		}
+		if err := decrementStock(tx, productID, line.Quantity); err != nil {
+			return allocated, err
+		}
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", line.ID).
+			Update("allocated_at", time.Now()).Error; err != nil {
+			return allocated, err
+		}
+		stock -= line.Quantity
+		allocated++
+	}
+	return allocated, nil
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errInsufficientStock is returned when a guarded stock decrement finds too few units
+var errInsufficientStock = errors.New("insufficient stock")
+
+type BundleHandler struct {
+	db *gorm.DB
+}
+
+type BundleItemRequest struct {
+	ProductID string `json:"productId" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+type SetBundleRequest struct {
+	Pricing         string              `json:"pricing" binding:"required,oneof=fixed percent"`
+	DiscountPercent float64             `json:"discountPercent" binding:"min=0,max=100"`
+	Price           *float64            `json:"price"` // Required for fixed pricing
+	Items           []BundleItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+func NewBundleHandler(db *gorm.DB) *BundleHandler {
+	return &BundleHandler{db: db}
+}
+
+// SetBundle turns a product into a bundle, or replaces the components and
+// pricing of an existing bundle (admin only)
+func (h *BundleHandler) SetBundle(c *gin.Context) {
+	productID := c.Param("id")
+
+	var req SetBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	pricingMode := models.BundlePricing(req.Pricing)
+	if pricingMode == models.BundlePricingFixed && (req.Price == nil || *req.Price <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Fixed price bundles need a price greater than zero",
+		})
+		return
+	}
+
+	var bundle models.Product
+	if err := h.db.First(&bundle, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+
+	if bundle.IsDigital {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Digital products cannot be bundles",
+		})
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if item.ProductID == productID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid component",
+				"message": "A bundle cannot contain itself",
+			})
+			return
+		}
+		if seen[item.ProductID] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid component",
+				"message": fmt.Sprintf("Product %s is listed more than once", item.ProductID),
+			})
+			return
+		}
+		seen[item.ProductID] = true
+
+		var component models.Product
+		if err := h.db.First(&component, "id = ?", item.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid component",
+					"message": fmt.Sprintf("Product %s does not exist", item.ProductID),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to find component",
+			})
+			return
+		}
+		if component.IsBundle {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid component",
+				"message": fmt.Sprintf("%s is a bundle; bundles cannot be nested", component.Name),
+			})
+			return
+		}
+		if component.IsDigital {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid component",
+				"message": fmt.Sprintf("%s is a digital product; bundles hold physical goods only", component.Name),
+			})
+			return
+		}
+	}
+
+	updates := map[string]interface{}{
+		"is_bundle":       true,
+		"bundle_pricing":  pricingMode,
+		"bundle_discount": 0.0,
+	}
+	if pricingMode == models.BundlePricingFixed {
+		updates["price"] = *req.Price
+	} else {
+		updates["bundle_discount"] = req.DiscountPercent
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Where("bundle_id = ?", productID).Delete(&models.BundleItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to replace bundle components",
+		})
+		return
+	}
+	for _, item := range req.Items {
+		if err := tx.Create(&models.BundleItem{BundleID: productID, ComponentID: item.ProductID, Quantity: item.Quantity}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to save bundle components",
+			})
+			return
+		}
+	}
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update bundle",
+		})
+		return
+	}
+	if err := syncBundleColumns(tx, productID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update bundle",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update bundle",
+		})
+		return
+	}
+
+	preloadBundleItems(h.db.Preload("Category"), "").First(&bundle, "id = ?", productID)
+	if err := bundle.ResolveBundle(pricing.NewService(h.db).Convert); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Bundle saved successfully",
+		"data":    bundle,
+	})
+}
+
+// DeleteBundle turns a bundle back into a plain product without stock (admin only)
+func (h *BundleHandler) DeleteBundle(c *gin.Context) {
+	productID := c.Param("id")
+
+	var bundle models.Product
+	if err := h.db.First(&bundle, "id = ? AND is_bundle = ?", productID, true).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Bundle not found",
+				"message": "The requested product is not a bundle",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find bundle",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Where("bundle_id = ?", productID).Delete(&models.BundleItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove bundle components",
+		})
+		return
+	}
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"is_bundle":       false,
+		"bundle_pricing":  "",
+		"bundle_discount": 0,
+		"stock_quantity":  0,
+		"in_stock":        false,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Bundle removed; the product now has no stock",
+	})
+}
+
+// preloadBundleItems loads the components of the bundle products found at
+// path ("" for the products queried themselves), in the order they were
+// added. Call ResolveBundle on the products once they are loaded.
+func preloadBundleItems(db *gorm.DB, path string) *gorm.DB {
+	if path != "" {
+		path += "."
+	}
+	return db.Preload(path+"BundleItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Preload(path + "BundleItems.Component")
+}
+
+// loadBundle loads the components of a bundle product and derives its stock
+// and price from them. Other products are left as they are.
+func loadBundle(db *gorm.DB, product *models.Product) error {
+	if !product.IsBundle {
+		return nil
+	}
+	if err := db.Preload("Component").Where("bundle_id = ?", product.ID).
+		Order("created_at ASC").Find(&product.BundleItems).Error; err != nil {
+		return err
+	}
+	return product.ResolveBundle(pricing.NewService(db).Convert)
+}
+
+// syncBundleColumns stores the derived price, stock and availability on the
+// bundle row so listings, filters and sorting see current values. Product
+// pages, carts and checkout recompute them from the components.
+func syncBundleColumns(db *gorm.DB, bundleID string) error {
+	var bundle models.Product
+	if err := preloadBundleItems(db, "").First(&bundle, "id = ?", bundleID).Error; err != nil {
+		return err
+	}
+	if err := bundle.ResolveBundle(pricing.NewService(db).Convert); err != nil {
+		return err
+	}
+	return db.Model(&models.Product{}).Where("id = ?", bundleID).Updates(map[string]interface{}{
+		"price":          bundle.Price,
+		"original_price": bundle.OriginalPrice,
+		"stock_quantity": bundle.StockQuantity,
+		"in_stock":       bundle.InStock,
+	}).Error
+}
+
+// syncBundlesContaining refreshes the stored columns of every bundle that
+// uses one of the given products as a component
+func syncBundlesContaining(db *gorm.DB, productIDs []string) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	var bundleIDs []string
+	if err := db.Model(&models.BundleItem{}).Where("component_id IN ?", productIDs).
+		Distinct().Pluck("bundle_id", &bundleIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range bundleIDs {
+		if err := syncBundleColumns(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleSnapshot records the components one unit of a bundle consumes
+func bundleSnapshot(product *models.Product) []models.BundleComponent {
+	if !product.IsBundle {
+		return nil
+	}
+	components := make([]models.BundleComponent, 0, len(product.BundleItems))
+	for _, item := range product.BundleItems {
+		component := models.BundleComponent{ProductID: item.ComponentID, Quantity: item.Quantity}
+		if item.Component != nil {
+			component.SKU = item.Component.SKU
+			component.Name = item.Component.Name
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// reserveStock takes quantity units of an order item out of stock. Bundles
+// decrement each component. Every decrement is guarded so concurrent orders
+// cannot oversell; the caller rolls back the transaction on error.
+func reserveStock(tx *gorm.DB, item *models.OrderItem) ([]string, error) {
+	if len(item.BundleComponents) == 0 {
+		return []string{item.ProductID}, decrementStock(tx, item.ProductID, item.Quantity)
+	}
+
+	touched := make([]string, 0, len(item.BundleComponents))
+	for _, component := range item.BundleComponents {
+		if err := decrementStock(tx, component.ProductID, component.Quantity*item.Quantity); err != nil {
+			return touched, err
+		}
+		touched = append(touched, component.ProductID)
+	}
+	return touched, nil
+}
+
+// releaseStock puts the units of a cancelled order item back into stock,
+// including for products archived since the order was placed
+func releaseStock(tx *gorm.DB, item *models.OrderItem) ([]string, error) {
+	if len(item.BundleComponents) == 0 {
+		return []string{item.ProductID}, tx.Unscoped().Model(&models.Product{}).Where("id = ?", item.ProductID).
+			Update("stock_quantity", gorm.Expr("stock_quantity + ?", item.Quantity)).Error
+	}
+
+	touched := make([]string, 0, len(item.BundleComponents))
+	for _, component := range item.BundleComponents {
+		if err := tx.Unscoped().Model(&models.Product{}).Where("id = ?", component.ProductID).
+			Update("stock_quantity", gorm.Expr("stock_quantity + ?", component.Quantity*item.Quantity)).Error; err != nil {
+			return touched, err
+		}
+		touched = append(touched, component.ProductID)
+	}
+	return touched, nil
+}
+
+func decrementStock(tx *gorm.DB, productID string, quantity int) error {
+	result := tx.Model(&models.Product{}).
+		Where("id = ? AND stock_quantity >= ?", productID, quantity).
+		Update("stock_quantity", gorm.Expr("stock_quantity - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
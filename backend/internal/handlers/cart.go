@@ -3,6 +3,7 @@ package handlers
 import (
 	"bizoe-3d-store/internal/middleware"
 	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,7 +11,8 @@ import (
 )
 
 type CartHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	pricing *pricing.Service
 }
 
 type AddToCartRequest struct {
@@ -24,7 +26,7 @@ type UpdateCartRequest struct {
 }
 
 func NewCartHandler(db *gorm.DB) *CartHandler {
-	return &CartHandler{db: db}
+	return &CartHandler{db: db, pricing: pricing.NewService(db)}
 }
 
 // GetCart returns the user's cart
@@ -38,8 +40,10 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		return
 	}
 
+	currency := middleware.GetCurrency(c)
+
 	var cart models.Cart
-	err := h.db.Preload("Items.Product.Category").
+	err := preloadCartItems(h.db).
 		Where("user_id = ?", userID).
 		First(&cart).Error
 
@@ -47,10 +51,12 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		if err == gorm.ErrRecordNotFound {
 			// Create empty cart
 			cart = models.Cart{
-				UserID:      &userID,
-				TotalAmount: 0,
-				TotalItems:  0,
-				Items:       []models.CartItem{},
+				UserID:       &userID,
+				TotalAmount:  0,
+				TotalItems:   0,
+				Currency:     currency,
+				ExchangeRate: 1,
+				Items:        []models.CartItem{},
 			}
 			h.db.Create(&cart)
 		} else {
@@ -62,6 +68,22 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 		}
 	}
 
+	// Re-quote the cart when the shopper switched storefront currency, or to
+	// take custom prints whose quote is gone out of the totals
+	stale := cart.Currency != currency
+	for i := range cart.Items {
+		stale = stale || cart.Items[i].QuoteMissing()
+	}
+	if stale {
+		if err := repriceCart(h.db, h.pricing, &cart, currency); err != nil {
+			respondPricingError(c, err)
+			return
+		}
+	}
+
+	localizeCart(h.db, &cart, middleware.GetLocale(c))
+	cart.Warnings = compatibilityWarnings(h.db, userID, &cart)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    cart,
@@ -105,7 +127,23 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		return
 	}
 
-	if !product.InStock || product.StockQuantity < req.Quantity {
+	if err := loadBundle(h.db, &product); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	available, err := orderableQuantity(h.db, &product)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to check product availability",
+		})
+		return
+	}
+	if available < req.Quantity {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Insufficient stock",
 			"message": "Product is out of stock or insufficient quantity available",
@@ -113,17 +151,15 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		return
 	}
 
-	// Get or create cart
-	var cart models.Cart
-	err := h.db.Where("user_id = ?", userID).First(&cart).Error
-	if err == gorm.ErrRecordNotFound {
-		cart = models.Cart{
-			UserID:      &userID,
-			TotalAmount: 0,
-			TotalItems:  0,
-		}
-		h.db.Create(&cart)
-	} else if err != nil {
+	currency := middleware.GetCurrency(c)
+	price, _, err := h.pricing.Quote(&product, currency)
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	cart, err := userCart(h.db, userID, currency)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
 			"message": "Failed to fetch cart",
@@ -131,44 +167,28 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 		return
 	}
 
-	// Check if item already exists in cart
-	var existingItem models.CartItem
-	err = h.db.Where("cart_id = ? AND product_id = ?", cart.ID, req.ProductID).First(&existingItem).Error
-	if err == nil {
-		// Update existing item quantity
-		newQuantity := existingItem.Quantity + req.Quantity
-		if newQuantity > product.StockQuantity {
+	if err := addCartItem(h.db, cart.ID, product.ID, req.Quantity, available, price); err != nil {
+		if err == errInsufficientStock {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Insufficient stock",
 				"message": "Cannot add more items than available in stock",
 			})
 			return
 		}
-
-		existingItem.Quantity = newQuantity
-		h.db.Save(&existingItem)
-	} else if err == gorm.ErrRecordNotFound {
-		// Create new cart item
-		newItem := models.CartItem{
-			CartID:    cart.ID,
-			ProductID: req.ProductID,
-			Quantity:  req.Quantity,
-			Price:     product.Price,
-		}
-		h.db.Create(&newItem)
-	} else {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
-			"message": "Failed to check existing cart item",
+			"message": "Failed to add item to cart",
 		})
 		return
 	}
 
-	// Update cart totals
-	h.updateCartTotals(&cart)
-
-	// Return updated cart
-	h.db.Preload("Items.Product.Category").First(&cart, "id = ?", cart.ID)
+	// Re-quote the cart in the storefront currency and update totals
+	preloadCartItems(h.db).First(&cart, "id = ?", cart.ID)
+	if err := repriceCart(h.db, h.pricing, &cart, currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+	cart.Warnings = compatibilityWarnings(h.db, userID, &cart)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -216,7 +236,7 @@ func (h *CartHandler) UpdateCart(c *gin.Context) {
 
 	// Find cart item
 	var cartItem models.CartItem
-	if err := h.db.Where("cart_id = ? AND product_id = ?", cart.ID, req.ProductID).First(&cartItem).Error; err != nil {
+	if err := h.db.Where("cart_id = ? AND product_id = ? AND print_quote_id IS NULL", cart.ID, req.ProductID).First(&cartItem).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Item not found",
@@ -237,7 +257,11 @@ func (h *CartHandler) UpdateCart(c *gin.Context) {
 	} else {
 		// Verify stock availability
 		var product models.Product
-		if err := h.db.First(&product, "id = ?", req.ProductID).Error; err != nil {
+		err := h.db.First(&product, "id = ?", req.ProductID).Error
+		if err == nil {
+			err = loadBundle(h.db, &product)
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Database error",
 				"message": "Failed to fetch product",
@@ -245,7 +269,15 @@ func (h *CartHandler) UpdateCart(c *gin.Context) {
 			return
 		}
 
-		if !product.InStock || product.StockQuantity < req.Quantity {
+		available, err := orderableQuantity(h.db, &product)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to check product availability",
+			})
+			return
+		}
+		if available < req.Quantity {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Insufficient stock",
 				"message": "Requested quantity exceeds available stock",
@@ -262,7 +294,7 @@ func (h *CartHandler) UpdateCart(c *gin.Context) {
 	h.updateCartTotals(&cart)
 
 	// Return updated cart
-	h.db.Preload("Items.Product.Category").First(&cart, "id = ?", cart.ID)
+	preloadCartItems(h.db).First(&cart, "id = ?", cart.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -303,7 +335,7 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 
 	// Find and delete cart item
 	var cartItem models.CartItem
-	if err := h.db.Where("cart_id = ? AND product_id = ?", cart.ID, productID).First(&cartItem).Error; err != nil {
+	if err := h.db.Where("cart_id = ? AND product_id = ? AND print_quote_id IS NULL", cart.ID, productID).First(&cartItem).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Item not found",
@@ -330,7 +362,7 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	h.updateCartTotals(&cart)
 
 	// Return updated cart
-	h.db.Preload("Items.Product.Category").First(&cart, "id = ?", cart.ID)
+	preloadCartItems(h.db).First(&cart, "id = ?", cart.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -391,6 +423,53 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 	})
 }
 
+// userCart returns the user's cart, creating an empty one quoted in currency
+// when they have none yet
+func userCart(db *gorm.DB, userID, currency string) (models.Cart, error) {
+	var cart models.Cart
+	err := db.Where("user_id = ?", userID).First(&cart).Error
+	if err == gorm.ErrRecordNotFound {
+		cart = models.Cart{
+			UserID:       &userID,
+			TotalAmount:  0,
+			TotalItems:   0,
+			Currency:     currency,
+			ExchangeRate: 1,
+		}
+		err = db.Create(&cart).Error
+	}
+	return cart, err
+}
+
+// addCartItem adds quantity units of a product to a cart at price, merging
+// with an existing line. It returns errInsufficientStock when the line would
+// exceed the orderable quantity; the caller re-prices the cart afterwards.
+func addCartItem(db *gorm.DB, cartID, productID string, quantity, available int, price float64) error {
+	var existingItem models.CartItem
+	err := db.Where("cart_id = ? AND product_id = ? AND print_quote_id IS NULL", cartID, productID).First(&existingItem).Error
+	if err == gorm.ErrRecordNotFound {
+		if quantity > available {
+			return errInsufficientStock
+		}
+		return db.Create(&models.CartItem{
+			CartID:    cartID,
+			ProductID: productID,
+			Quantity:  quantity,
+			Price:     price,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	newQuantity := existingItem.Quantity + quantity
+	if newQuantity > available {
+		return errInsufficientStock
+	}
+	existingItem.Quantity = newQuantity
+	return db.Save(&existingItem).Error
+}
+
 // updateCartTotals recalculates and updates cart totals
 func (h *CartHandler) updateCartTotals(cart *models.Cart) {
 	var items []models.CartItem
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/catalog"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportSize caps uploaded catalog files at 20 MB
+const maxImportSize = 20 << 20
+
+type CatalogHandler struct {
+	db       *gorm.DB
+	importer *catalog.Importer
+}
+
+func NewCatalogHandler(db *gorm.DB, stockAlerts *alerts.StockNotifier) *CatalogHandler {
+	importer := catalog.NewImporter(db)
+	importer.ProductChanged = productChanged
+	importer.Restocked = stockAlerts.Restocked
+	return &CatalogHandler{db: db, importer: importer}
+}
+
+// ImportCatalog queues a background import of a CSV or JSON catalog file (admin only).
+// The file is sent as multipart field "file" or as the raw request body.
+func (h *CatalogHandler) ImportCatalog(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	dryRun := c.Query("dryRun") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var reader io.Reader
+	format := strings.ToLower(c.Query("format"))
+
+	if file, err := c.FormFile("file"); err == nil {
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid file",
+				"message": "Failed to read uploaded file",
+			})
+			return
+		}
+		defer f.Close()
+		reader = f
+	} else {
+		if format == "" {
+			if strings.Contains(c.ContentType(), "json") {
+				format = catalog.FormatJSON
+			} else {
+				format = catalog.FormatCSV
+			}
+		}
+		reader = c.Request.Body
+	}
+
+	if !catalog.IsSupportedFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported format",
+			"message": "Format must be csv or json",
+		})
+		return
+	}
+
+	rows, err := catalog.Parse(format, reader)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	job := models.ImportJob{
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    models.ImportJobQueued,
+		TotalRows: len(rows),
+		CreatedBy: userID,
+	}
+	if err := h.db.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create import job",
+		})
+		return
+	}
+
+	go h.importer.RunJob(job.ID, rows, dryRun)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Catalog import started",
+		"data":    job,
+	})
+}
+
+// GetImportJobs returns the most recent import jobs (admin only)
+func (h *CatalogHandler) GetImportJobs(c *gin.Context) {
+	var jobs []models.ImportJob
+	if err := h.db.Omit("errors").Order("created_at DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch import jobs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    jobs,
+	})
+}
+
+// GetImportJob returns the progress and row errors of an import job (admin only)
+func (h *CatalogHandler) GetImportJob(c *gin.Context) {
+	var job models.ImportJob
+	if err := h.db.First(&job, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Import job not found",
+				"message": "The requested import job does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch import job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// ExportCatalog downloads the catalog in the same CSV or JSON format accepted by imports (admin only)
+func (h *CatalogHandler) ExportCatalog(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", catalog.FormatCSV))
+	if !catalog.IsSupportedFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported format",
+			"message": "Format must be csv or json",
+		})
+		return
+	}
+
+	var products []models.Product
+	if err := h.db.Preload("Category").Order("sku ASC").Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch products",
+		})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == catalog.FormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := fmt.Sprintf("catalog-%s.%s", time.Now().Format("20060102"), format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := catalog.Write(format, c.Writer, products); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CompatibilityHandler struct {
+	db *gorm.DB
+}
+
+type UpsertCompatibilityRequest struct {
+	CompatibleProductID string            `json:"compatibleProductId" binding:"required"`
+	Type                string            `json:"type" binding:"required"`
+	Notes               string            `json:"notes"`
+	Settings            map[string]string `json:"settings"`
+}
+
+func NewCompatibilityHandler(db *gorm.DB) *CompatibilityHandler {
+	return &CompatibilityHandler{db: db}
+}
+
+// GetProductCompatibility lists every compatibility entry a product takes part in (admin only)
+func (h *CompatibilityHandler) GetProductCompatibility(c *gin.Context) {
+	productID := c.Param("id")
+
+	var entries []models.ProductCompatibility
+	if err := h.db.Preload("Product", unscoped).Preload("CompatibleProduct", unscoped).
+		Where("product_id = ? OR compatible_product_id = ?", productID, productID).
+		Order("type ASC").Order("created_at ASC").
+		Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch compatibility",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// UpsertCompatibility links a printer to a compatible product, or updates the
+// notes and settings of an existing link (admin only)
+func (h *CompatibilityHandler) UpsertCompatibility(c *gin.Context) {
+	productID := c.Param("id")
+
+	var req UpsertCompatibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	compatType := models.CompatibilityType(req.Type)
+	if !models.IsValidCompatibilityType(compatType) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid type",
+			"message": fmt.Sprintf("Compatibility type must be one of %v", models.CompatibilityTypes),
+		})
+		return
+	}
+
+	if req.CompatibleProductID == productID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid compatibility",
+			"message": "A product cannot be compatible with itself",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Product{}).Where("id IN ?", []string{productID, req.CompatibleProductID}).Count(&count)
+	if count != 2 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Product not found",
+			"message": "Both products must exist",
+		})
+		return
+	}
+
+	settings := req.Settings
+	if settings == nil {
+		settings = map[string]string{}
+	}
+
+	var entry models.ProductCompatibility
+	err := h.db.Where("product_id = ? AND compatible_product_id = ? AND type = ?", productID, req.CompatibleProductID, compatType).
+		First(&entry).Error
+	status := http.StatusOK
+	if err == gorm.ErrRecordNotFound {
+		entry = models.ProductCompatibility{
+			ProductID:           productID,
+			CompatibleProductID: req.CompatibleProductID,
+			Type:                compatType,
+		}
+		status = http.StatusCreated
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch compatibility",
+		})
+		return
+	}
+
+	entry.Notes = strings.TrimSpace(req.Notes)
+	entry.Settings = settings
+	if err := h.db.Save(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save compatibility",
+		})
+		return
+	}
+
+	h.db.Preload("Product").Preload("CompatibleProduct").First(&entry, "id = ?", entry.ID)
+
+	c.JSON(status, gin.H{
+		"success": true,
+		"message": "Compatibility saved successfully",
+		"data":    entry,
+	})
+}
+
+// DeleteCompatibility removes a compatibility entry (admin only)
+func (h *CompatibilityHandler) DeleteCompatibility(c *gin.Context) {
+	result := h.db.Delete(&models.ProductCompatibility{}, "id = ?", c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete compatibility",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Compatibility not found",
+			"message": "The requested compatibility entry does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Compatibility deleted successfully",
+	})
+}
+
+// loadCompatibilityLinks returns the storefront view of a product's
+// compatibility: accessories for a printer and printers for an accessory.
+// Links to archived products are left out.
+func loadCompatibilityLinks(db *gorm.DB, productID string) ([]models.CompatibilityLink, error) {
+	var entries []models.ProductCompatibility
+	if err := db.Preload("Product").Preload("CompatibleProduct").
+		Where("product_id = ? OR compatible_product_id = ?", productID, productID).
+		Order("type ASC").Order("created_at ASC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	links := make([]models.CompatibilityLink, 0, len(entries))
+	for _, entry := range entries {
+		link := models.CompatibilityLink{
+			ID:       entry.ID,
+			Type:     entry.Type,
+			Notes:    entry.Notes,
+			Settings: entry.Settings,
+		}
+		if entry.ProductID == productID {
+			if entry.CompatibleProduct == nil {
+				continue
+			}
+			link.Role = "accessory"
+			link.Product = *entry.CompatibleProduct
+		} else {
+			if entry.Product == nil {
+				continue
+			}
+			link.Role = "printer"
+			link.Product = *entry.Product
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// compatibleProductsFilter restricts a product query to products linked to
+// productID in either direction, optionally of one compatibility type
+func compatibleProductsFilter(db *gorm.DB, productID, compatType string) *gorm.DB {
+	accessories := db.Session(&gorm.Session{NewDB: true}).Model(&models.ProductCompatibility{}).
+		Select("compatible_product_id").Where("product_id = ?", productID)
+	printers := db.Session(&gorm.Session{NewDB: true}).Model(&models.ProductCompatibility{}).
+		Select("product_id").Where("compatible_product_id = ?", productID)
+	if compatType != "" {
+		accessories = accessories.Where("type = ?", compatType)
+		printers = printers.Where("type = ?", compatType)
+	}
+	return db.Where("products.id IN (?) OR products.id IN (?)", accessories, printers)
+}
+
+// compatibilityWarnings flags materials in the cart that fit none of the
+// printers the customer has bought or is buying in the same cart. Materials
+// without any compatibility data are not flagged.
+func compatibilityWarnings(db *gorm.DB, userID string, cart *models.Cart) []models.CartWarning {
+	if len(cart.Items) == 0 {
+		return nil
+	}
+
+	cartProductIDs := make([]string, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		// Custom prints are made from the material here, not bought as material
+		if item.PrintQuoteID == nil {
+			cartProductIDs = append(cartProductIDs, item.ProductID)
+		}
+	}
+
+	var entries []models.ProductCompatibility
+	if err := db.Preload("Product", unscoped).
+		Where("compatible_product_id IN ? AND type = ?", cartProductIDs, models.CompatibilityMaterial).
+		Find(&entries).Error; err != nil || len(entries) == 0 {
+		return nil
+	}
+
+	printersByMaterial := make(map[string][]models.ProductCompatibility)
+	for _, entry := range entries {
+		printersByMaterial[entry.CompatibleProductID] = append(printersByMaterial[entry.CompatibleProductID], entry)
+	}
+
+	var owned []string
+	db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status <> ?", userID, models.OrderStatusCancelled).
+		Distinct().Pluck("order_items.product_id", &owned)
+
+	printers := make(map[string]bool, len(owned)+len(cartProductIDs))
+	for _, id := range owned {
+		printers[id] = true
+	}
+	for _, id := range cartProductIDs {
+		printers[id] = true
+	}
+
+	var warnings []models.CartWarning
+	for _, item := range cart.Items {
+		compatible, ok := printersByMaterial[item.ProductID]
+		if !ok || item.PrintQuoteID != nil {
+			continue
+		}
+
+		fits := false
+		names := make([]string, 0, len(compatible))
+		for _, entry := range compatible {
+			if printers[entry.ProductID] {
+				fits = true
+				break
+			}
+			if entry.Product != nil && !entry.Product.ArchivedAt.Valid {
+				names = append(names, entry.Product.Name)
+			}
+		}
+		if fits {
+			continue
+		}
+
+		message := fmt.Sprintf("%s is not compatible with any printer you have bought", item.Product.Name)
+		if len(names) > 0 {
+			message += ". It works with: " + strings.Join(names, ", ")
+		}
+		warnings = append(warnings, models.CartWarning{
+			Code:      "incompatible_material",
+			ProductID: item.ProductID,
+			Message:   message,
+		})
+	}
+	return warnings
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CurrencyHandler struct {
+	db *gorm.DB
+}
+
+type ExchangeRateRequest struct {
+	Rate              float64             `json:"rate" binding:"required,gt=0"`
+	Rounding          models.RoundingMode `json:"rounding"`
+	RoundingIncrement float64             `json:"roundingIncrement" binding:"gte=0"`
+}
+
+type ProductPriceRequest struct {
+	Price         float64  `json:"price" binding:"required,gt=0"`
+	OriginalPrice *float64 `json:"originalPrice"`
+}
+
+func NewCurrencyHandler(db *gorm.DB) *CurrencyHandler {
+	return &CurrencyHandler{db: db}
+}
+
+// GetCurrencies returns the storefront currencies and their current rates
+func (h *CurrencyHandler) GetCurrencies(c *gin.Context) {
+	var rates []models.ExchangeRate
+	if err := h.db.Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch exchange rates",
+		})
+		return
+	}
+
+	byCurrency := make(map[string]models.ExchangeRate, len(rates))
+	for _, r := range rates {
+		byCurrency[r.Currency] = r
+	}
+
+	currencies := make([]gin.H, 0, len(pricing.SupportedCurrencies))
+	for _, code := range pricing.SupportedCurrencies {
+		rate := 1.0
+		if code != pricing.BaseCurrency {
+			r, ok := byCurrency[code]
+			if !ok {
+				continue
+			}
+			rate = r.Rate
+		}
+		currencies = append(currencies, gin.H{
+			"code":     code,
+			"rate":     rate,
+			"decimals": pricing.Decimals(code),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    currencies,
+		"base":    pricing.BaseCurrency,
+	})
+}
+
+// GetExchangeRates returns all configured exchange rates (admin only)
+func (h *CurrencyHandler) GetExchangeRates(c *gin.Context) {
+	var rates []models.ExchangeRate
+	if err := h.db.Order("currency ASC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch exchange rates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rates,
+		"base":    pricing.BaseCurrency,
+	})
+}
+
+// UpsertExchangeRate creates or updates the rate and rounding rule for a currency (admin only)
+func (h *CurrencyHandler) UpsertExchangeRate(c *gin.Context) {
+	currency := pricing.Normalize(c.Param("currency"))
+	if currency == "" || currency == pricing.BaseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid currency",
+			"message": "Currency must be a supported, non-base currency",
+		})
+		return
+	}
+
+	var req ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	switch req.Rounding {
+	case "":
+		req.Rounding = models.RoundingNearest
+	case models.RoundingNearest, models.RoundingUp, models.RoundingDown, models.RoundingCharm:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid rounding",
+			"message": "Rounding must be one of nearest, up, down or charm",
+		})
+		return
+	}
+
+	var rate models.ExchangeRate
+	err := h.db.Where("currency = ?", currency).First(&rate).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch exchange rate",
+		})
+		return
+	}
+
+	rate.Currency = currency
+	rate.Rate = req.Rate
+	rate.Rounding = req.Rounding
+	rate.RoundingIncrement = req.RoundingIncrement
+
+	if err := h.db.Save(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save exchange rate",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Exchange rate saved successfully",
+		"data":    rate,
+	})
+}
+
+// GetProductPrices returns the explicit price list entries of a product (admin only)
+func (h *CurrencyHandler) GetProductPrices(c *gin.Context) {
+	var prices []models.ProductPrice
+	if err := h.db.Where("product_id = ?", c.Param("id")).Order("currency ASC").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product prices",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    prices,
+	})
+}
+
+// UpsertProductPrice sets an explicit product price in a currency (admin only)
+func (h *CurrencyHandler) UpsertProductPrice(c *gin.Context) {
+	productID := c.Param("id")
+	currency := pricing.Normalize(c.Param("currency"))
+	if currency == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid currency",
+			"message": "Supported currencies are USD, TWD and EUR",
+		})
+		return
+	}
+
+	var req ProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+
+	var price models.ProductPrice
+	err := h.db.Where("product_id = ? AND currency = ?", productID, currency).First(&price).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product price",
+		})
+		return
+	}
+
+	price.ProductID = productID
+	price.Currency = currency
+	price.Price = req.Price
+	price.OriginalPrice = req.OriginalPrice
+
+	if err := h.db.Save(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save product price",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product price saved successfully",
+		"data":    price,
+	})
+}
+
+// DeleteProductPrice removes an explicit price so the product falls back to conversion (admin only)
+func (h *CurrencyHandler) DeleteProductPrice(c *gin.Context) {
+	currency := pricing.Normalize(c.Param("currency"))
+	result := h.db.Where("product_id = ? AND currency = ?", c.Param("id"), currency).Delete(&models.ProductPrice{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete product price",
+		})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Price not found",
+			"message": "The product has no explicit price in this currency",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product price deleted successfully",
+	})
+}
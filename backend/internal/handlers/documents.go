@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/config"
+	"bizoe-3d-store/internal/documents"
+	"bizoe-3d-store/internal/i18n"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	// errNotInvoiceable is returned for orders that are not paid in full or cost nothing
+	errNotInvoiceable = errors.New("order cannot be invoiced")
+	// errNoRefund is returned for returns that were not settled with a refund
+	errNoRefund = errors.New("return has no refund")
+)
+
+// invoiceablePaymentStatuses are the payment states of orders that were paid in full
+var invoiceablePaymentStatuses = []models.PaymentStatus{
+	models.PaymentStatusPaid,
+	models.PaymentStatusPartiallyRefunded,
+	models.PaymentStatusRefunded,
+}
+
+type DocumentHandler struct {
+	db              *gorm.DB
+	storage         storage.Storage
+	issuer          documents.Issuer
+	fiscalYearStart time.Month
+}
+
+type PackingSlipsRequest struct {
+	OrderIDs []string `json:"orderIds" binding:"required,min=1,max=100"`
+}
+
+func NewDocumentHandler(db *gorm.DB, store storage.Storage, cfg *config.Config) *DocumentHandler {
+	return &DocumentHandler{
+		db:              db,
+		storage:         store,
+		issuer:          documents.NewIssuer(cfg),
+		fiscalYearStart: time.Month(cfg.FiscalYearStartMonth),
+	}
+}
+
+// GetOrderInvoice downloads the invoice of a paid order, issuing it on first request
+func (h *DocumentHandler) GetOrderInvoice(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	invoice, err := h.ensureInvoice(order.ID)
+	if err != nil {
+		if err == errNotInvoiceable {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Invoice not available",
+				"message": "Invoices are issued once an order is paid in full",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Document error",
+			"message": "Failed to issue invoice",
+		})
+		return
+	}
+
+	h.serve(c, invoice)
+}
+
+// GetOrderDocuments lists the invoice and credit notes issued for an order
+func (h *DocumentHandler) GetOrderDocuments(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	var docs []models.Document
+	if err := h.db.Preload("Files").Where("order_id = ?", order.ID).Order("issued_at ASC").Find(&docs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch documents",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    docs,
+	})
+}
+
+// DownloadDocument downloads an issued document in the request locale
+func (h *DocumentHandler) DownloadDocument(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	query := h.db.Preload("Files")
+	// Non-admin users can only download documents of their own orders
+	if !middleware.IsAdmin(c) {
+		query = query.Where("order_id IN (?)", h.db.Model(&models.Order{}).Select("id").Where("user_id = ?", userID))
+	}
+
+	var doc models.Document
+	if err := query.First(&doc, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Document not found",
+				"message": "The requested document does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch document",
+		})
+		return
+	}
+
+	h.serve(c, &doc)
+}
+
+// GetDocuments lists issued documents by type and fiscal year (admin only)
+func (h *DocumentHandler) GetDocuments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.Document{})
+	if docType := c.Query("type"); docType != "" {
+		query = query.Where("type = ?", docType)
+	}
+	if year, err := strconv.Atoi(c.Query("fiscalYear")); err == nil {
+		query = query.Where("fiscal_year = ?", year)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count documents",
+		})
+		return
+	}
+
+	var docs []models.Document
+	if err := query.Preload("Files").Order("issued_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&docs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch documents",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    docs,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// IssueCreditNote issues the credit note of a refunded return now instead of
+// waiting for the background job (admin only)
+func (h *DocumentHandler) IssueCreditNote(c *gin.Context) {
+	note, err := h.ensureCreditNote(c.Param("id"))
+	if err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Return not found",
+				"message": "The requested return does not exist",
+			})
+		case errNoRefund:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "No refund",
+				"message": "Credit notes are issued for completed returns that were refunded",
+			})
+		case errNotInvoiceable:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Invoice not available",
+				"message": "The order of this return cannot be invoiced",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Document error",
+				"message": "Failed to issue credit note",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Credit note issued successfully",
+		"data":    note,
+	})
+}
+
+// PrintPackingSlips renders the packing slips of several orders as one PDF,
+// one page per order listing the units left to ship (admin only)
+func (h *DocumentHandler) PrintPackingSlips(c *gin.Context) {
+	var req PackingSlipsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var orders []models.Order
+	if err := h.db.Preload("Items").Where("id IN ?", req.OrderIDs).Order("created_at ASC").Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch orders",
+		})
+		return
+	}
+	if len(orders) != len(req.OrderIDs) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Order not found",
+			"message": "One or more of the requested orders do not exist",
+		})
+		return
+	}
+
+	slips := make([]documents.PackingSlip, 0, len(orders))
+	for i := range orders {
+		order := &orders[i]
+		switch order.Status {
+		case models.OrderStatusConfirmed, models.OrderStatusInProduction, models.OrderStatusProcessing, models.OrderStatusPartiallyShipped:
+		default:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Order not fulfillable",
+				"message": fmt.Sprintf("Order %s is %s and has nothing to pack", order.OrderNumber, order.Status),
+			})
+			return
+		}
+
+		shipped, err := shippedQuantities(h.db, order.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch shipped quantities",
+			})
+			return
+		}
+
+		slip := documents.PackingSlip{Order: order}
+		for _, item := range order.Items {
+			remaining := item.Quantity - shipped[item.ID]
+			if item.LineType == models.OrderLineDigital || item.IsPending() || remaining <= 0 {
+				continue
+			}
+			slip.Lines = append(slip.Lines, documents.PackingLine{SKU: item.ProductSKU, Name: item.ProductName, Quantity: remaining})
+		}
+		slips = append(slips, slip)
+	}
+
+	content := documents.PackingSlips(h.issuer, slips, middleware.GetLocale(c))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "packing-slips-" + time.Now().Format("20060102-150405") + ".pdf",
+	}))
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+// IssueDocuments issues the invoices of paid orders whose edit window has
+// passed and the credit notes of refunded returns that do not have one yet,
+// oldest first, and returns how many were issued. It is scheduled as a
+// background job.
+func (h *DocumentHandler) IssueDocuments() (int, error) {
+	var orderIDs []string
+	if err := h.db.Model(&models.Order{}).
+		Where("payment_status IN ? AND total > 0", invoiceablePaymentStatuses).
+		Where("created_at < ?", time.Now().Add(-orderEditWindow)).
+		Where("NOT EXISTS (SELECT 1 FROM documents WHERE documents.type = ? AND documents.source_id = orders.id)", models.DocumentInvoice).
+		Order("created_at ASC").Pluck("id", &orderIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch orders to invoice: %w", err)
+	}
+
+	issued := 0
+	for _, orderID := range orderIDs {
+		if _, err := h.ensureInvoice(orderID); err != nil {
+			return issued, fmt.Errorf("failed to invoice order %s: %w", orderID, err)
+		}
+		issued++
+	}
+
+	var returnIDs []string
+	if err := h.db.Model(&models.ReturnRequest{}).
+		Where("status = ? AND refund_amount > 0", models.ReturnCompleted).
+		Where("NOT EXISTS (SELECT 1 FROM documents WHERE documents.type = ? AND documents.source_id = return_requests.id)", models.DocumentCreditNote).
+		Order("completed_at ASC").Pluck("id", &returnIDs).Error; err != nil {
+		return issued, fmt.Errorf("failed to fetch returns to credit: %w", err)
+	}
+
+	for _, returnID := range returnIDs {
+		if _, err := h.ensureCreditNote(returnID); err != nil {
+			return issued, fmt.Errorf("failed to credit return %s: %w", returnID, err)
+		}
+		issued++
+	}
+	return issued, nil
+}
+
+// ensureInvoice returns the invoice of an order, issuing it if the order is
+// paid and has none yet
+func (h *DocumentHandler) ensureInvoice(orderID string) (*models.Document, error) {
+	if invoice, err := h.findDocument(models.DocumentInvoice, orderID); err != gorm.ErrRecordNotFound {
+		return invoice, err
+	}
+
+	invoice := models.Document{Type: models.DocumentInvoice, SourceID: orderID, OrderID: orderID}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Preload("Items").First(&order, "id = ?", orderID).Error; err != nil {
+			return err
+		}
+		if order.Total <= 0 || !containsPaymentStatus(invoiceablePaymentStatuses, order.PaymentStatus) {
+			return errNotInvoiceable
+		}
+
+		invoice.Amount = order.Total
+		invoice.Currency = order.Currency
+		return h.issue(tx, &invoice, "INV", func(locale string) []byte {
+			return documents.Invoice(h.issuer, &invoice, &order, locale)
+		})
+	})
+	if err != nil {
+		// A concurrent request may have issued it first
+		if existing, findErr := h.findDocument(models.DocumentInvoice, orderID); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ensureCreditNote returns the credit note of a refunded return, issuing it
+// and the invoice it credits if needed
+func (h *DocumentHandler) ensureCreditNote(returnID string) (*models.Document, error) {
+	if note, err := h.findDocument(models.DocumentCreditNote, returnID); err != gorm.ErrRecordNotFound {
+		return note, err
+	}
+
+	var ret models.ReturnRequest
+	if err := h.db.Preload("Order").Preload("OrderItem").First(&ret, "id = ?", returnID).Error; err != nil {
+		return nil, err
+	}
+	if ret.Status != models.ReturnCompleted || ret.RefundAmount <= 0 {
+		return nil, errNoRefund
+	}
+
+	invoice, err := h.ensureInvoice(ret.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	note := models.Document{
+		Type:          models.DocumentCreditNote,
+		SourceID:      ret.ID,
+		OrderID:       ret.OrderID,
+		InvoiceNumber: invoice.Number,
+		Amount:        ret.RefundAmount,
+		Currency:      ret.Order.Currency,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return h.issue(tx, &note, "CN", func(locale string) []byte {
+			return documents.CreditNote(h.issuer, &note, ret.Order, &ret, locale)
+		})
+	})
+	if err != nil {
+		if existing, findErr := h.findDocument(models.DocumentCreditNote, returnID); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return &note, nil
+}
+
+// issue numbers a document from its type's sequence for the current fiscal
+// year, then renders and stores it in every supported locale. It runs in the
+// transaction that creates the document, so a failure anywhere gives the
+// number back and the sequence stays gapless.
+func (h *DocumentHandler) issue(tx *gorm.DB, doc *models.Document, prefix string, render func(locale string) []byte) error {
+	doc.IssuedAt = time.Now()
+	doc.FiscalYear = h.fiscalYear(doc.IssuedAt)
+
+	n, err := models.NextSequenceValue(tx, fmt.Sprintf("%s-%d", doc.Type, doc.FiscalYear))
+	if err != nil {
+		return err
+	}
+	doc.Number = fmt.Sprintf("%s-%d-%06d", prefix, doc.FiscalYear, n)
+	if err := tx.Create(doc).Error; err != nil {
+		return err
+	}
+
+	for _, locale := range i18n.SupportedLocales {
+		content := render(locale)
+		sum := sha256.Sum256(content)
+		file := models.DocumentFile{
+			DocumentID: doc.ID,
+			Locale:     locale,
+			StorageKey: fmt.Sprintf("documents/%s/%d/%s.%s.pdf", doc.Type, doc.FiscalYear, doc.Number, locale),
+			Checksum:   hex.EncodeToString(sum[:]),
+			FileSize:   int64(len(content)),
+		}
+		if err := h.storage.Put(file.StorageKey, bytes.NewReader(content), "application/pdf"); err != nil {
+			return err
+		}
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+		doc.Files = append(doc.Files, file)
+	}
+	return nil
+}
+
+// fiscalYear returns the fiscal year t falls in, named after the calendar
+// year it starts in
+func (h *DocumentHandler) fiscalYear(t time.Time) int {
+	if t.Month() < h.fiscalYearStart {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// serve sends a document's PDF in the request locale, or the default locale
+// when there is none. Files that no longer match their checksum are refused.
+func (h *DocumentHandler) serve(c *gin.Context, doc *models.Document) {
+	var file *models.DocumentFile
+	for _, locale := range []string{middleware.GetLocale(c), i18n.DefaultLocale} {
+		for i := range doc.Files {
+			if doc.Files[i].Locale == locale {
+				file = &doc.Files[i]
+				break
+			}
+		}
+		if file != nil {
+			break
+		}
+	}
+	if file == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found",
+			"message": "The document is not available right now",
+		})
+		return
+	}
+
+	reader, err := h.storage.Open(file.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found",
+			"message": "The document is not available right now",
+		})
+		return
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": "Failed to read document",
+		})
+		return
+	}
+	if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != file.Checksum {
+		log.Printf("Document %s (%s) does not match its checksum", doc.Number, file.Locale)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Document damaged",
+			"message": "The stored document does not match the issued one",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.Number + ".pdf"}))
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+// findDocument loads the document of a type issued for a source, with its files
+func (h *DocumentHandler) findDocument(docType models.DocumentType, sourceID string) (*models.Document, error) {
+	var doc models.Document
+	if err := h.db.Preload("Files").Where("type = ? AND source_id = ?", docType, sourceID).First(&doc).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// findOrder loads the order named by the id parameter, which must belong to
+// the user unless they are an admin. It writes an error response and returns
+// false when that fails.
+func (h *DocumentHandler) findOrder(c *gin.Context) (models.Order, bool) {
+	var order models.Order
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return order, false
+	}
+
+	query := h.db
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&order, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order not found",
+				"message": "The requested order does not exist",
+			})
+			return order, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return order, false
+	}
+	return order, true
+}
+
+func containsPaymentStatus(statuses []models.PaymentStatus, status models.PaymentStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/downloads"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxDigitalFileSize caps uploaded digital product files at 1 GB
+const maxDigitalFileSize = 1 << 30
+
+type DownloadHandler struct {
+	db      *gorm.DB
+	storage storage.Storage // Private; files are only served through signed links
+	signer  *downloads.Signer
+}
+
+func NewDownloadHandler(db *gorm.DB, store storage.Storage, signer *downloads.Signer) *DownloadHandler {
+	return &DownloadHandler{db: db, storage: store, signer: signer}
+}
+
+// GetDownloads lists the user's digital purchases with fresh signed links to
+// their files. Links are only issued while downloads are left.
+func (h *DownloadHandler) GetDownloads(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var entitlements []models.DownloadEntitlement
+	if err := h.db.Preload("Product.Files", func(db *gorm.DB) *gorm.DB { return db.Order("file_name ASC") }).
+		Preload("Product", unscoped).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&entitlements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch downloads",
+		})
+		return
+	}
+
+	expires := time.Now().Add(downloads.LinkValidity)
+	for i := range entitlements {
+		entitlement := &entitlements[i]
+		entitlement.Files = entitlement.Product.Files
+		if entitlement.Files == nil {
+			entitlement.Files = []models.DigitalFile{}
+		}
+		if entitlement.Remaining() == 0 {
+			continue
+		}
+		for j := range entitlement.Files {
+			file := &entitlement.Files[j]
+			file.URL = h.signer.URL(entitlement.ID, file.ID, expires)
+			file.URLExpiresAt = &expires
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entitlements,
+	})
+}
+
+// Download serves a digital product file through a signed link and counts
+// it against the purchase's download limit. The link is the credential, so
+// it works without a session, such as in a new browser tab.
+func (h *DownloadHandler) Download(c *gin.Context) {
+	entitlementID, fileID := c.Param("id"), c.Param("fileId")
+
+	if err := h.signer.Verify(entitlementID, fileID, c.Query("expires"), c.Query("signature")); err != nil {
+		if errors.Is(err, downloads.ErrLinkExpired) {
+			c.JSON(http.StatusGone, gin.H{
+				"error":   "Link expired",
+				"message": "This download link has expired; get a new one from your account",
+			})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Invalid link",
+			"message": "This download link is not valid",
+		})
+		return
+	}
+
+	var entitlement models.DownloadEntitlement
+	if err := h.db.Where("id = ? AND revoked_at IS NULL", entitlementID).First(&entitlement).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Download unavailable",
+				"message": "This purchase can no longer be downloaded",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch download",
+		})
+		return
+	}
+
+	var file models.DigitalFile
+	if err := h.db.Where("id = ? AND product_id = ?", fileID, entitlement.ProductID).First(&file).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "File not found",
+				"message": "This file has been removed from the product",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch file",
+		})
+		return
+	}
+
+	reader, err := h.storage.Open(file.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found",
+			"message": "The file is not available right now",
+		})
+		return
+	}
+	defer reader.Close()
+
+	// Count the download before sending it; the guard stops concurrent
+	// requests from going over the limit
+	result := h.db.Model(&models.DownloadEntitlement{}).
+		Where("id = ? AND download_count < download_limit", entitlement.ID).
+		Updates(map[string]interface{}{
+			"download_count":   gorm.Expr("download_count + 1"),
+			"last_download_at": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record download",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Download limit reached",
+			"message": fmt.Sprintf("This purchase allows %d downloads, and all have been used", entitlement.DownloadLimit),
+		})
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, file.FileSize, file.ContentType, reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}),
+		"Cache-Control":       "private, no-store",
+	})
+}
+
+// GetDigitalFiles lists the files of a digital product (admin only)
+func (h *DownloadHandler) GetDigitalFiles(c *gin.Context) {
+	var files []models.DigitalFile
+	if err := h.db.Where("product_id = ?", c.Param("id")).Order("file_name ASC").Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch files",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    files,
+	})
+}
+
+// UploadDigitalFile adds a file to a digital product. Customers who already
+// bought the product get it too (admin only).
+func (h *DownloadHandler) UploadDigitalFile(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+	if !product.IsDigital {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Not a digital product",
+			"message": "Files can only be added to digital products",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDigitalFileSize+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "A file is required in the \"file\" field",
+		})
+		return
+	}
+	if header.Size > maxDigitalFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "File too large",
+			"message": "Files must be 1 GB or smaller",
+		})
+		return
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer f.Close()
+
+	fileName := path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(fileName)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	file := models.DigitalFile{
+		ID:          uuid.New().String(),
+		ProductID:   product.ID,
+		FileName:    fileName,
+		ContentType: contentType,
+		FileSize:    header.Size,
+	}
+	file.StorageKey = fmt.Sprintf("digital/%s/%s%s", product.ID, file.ID, strings.ToLower(path.Ext(fileName)))
+
+	if err := h.storage.Put(file.StorageKey, f, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": "Failed to store file",
+		})
+		return
+	}
+	if err := h.db.Create(&file).Error; err != nil {
+		h.storage.Delete(file.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save file",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "File uploaded successfully",
+		"data":    file,
+	})
+}
+
+// DeleteDigitalFile removes a file from a digital product (admin only)
+func (h *DownloadHandler) DeleteDigitalFile(c *gin.Context) {
+	var file models.DigitalFile
+	if err := h.db.Where("id = ? AND product_id = ?", c.Param("fileId"), c.Param("id")).First(&file).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "File not found",
+				"message": "The requested file does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find file",
+		})
+		return
+	}
+
+	if err := h.db.Delete(&file).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete file",
+		})
+		return
+	}
+	h.storage.Delete(file.StorageKey)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "File deleted successfully",
+	})
+}
+
+// ResetDownloads gives a customer their full download allowance back, for
+// support cases such as a corrupted download (admin only)
+func (h *DownloadHandler) ResetDownloads(c *gin.Context) {
+	result := h.db.Model(&models.DownloadEntitlement{}).
+		Where("id = ? AND revoked_at IS NULL", c.Param("id")).
+		Update("download_count", 0)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to reset downloads",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Download not found",
+			"message": "The requested download does not exist or was revoked",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Downloads reset successfully",
+	})
+}
+
+// grantDownloads entitles the buyer of a paid order to download its digital
+// products. Granting again for the same order is a no-op.
+func grantDownloads(db *gorm.DB, order *models.Order) error {
+	var lines []models.OrderItem
+	if err := db.Preload("Product", unscoped).
+		Where("order_id = ? AND line_type = ?", order.ID, models.OrderLineDigital).
+		Find(&lines).Error; err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		limit := line.Product.DownloadLimit
+		if limit == 0 {
+			limit = downloads.DefaultLimit
+		}
+		entitlement := models.DownloadEntitlement{
+			UserID:        order.UserID,
+			OrderID:       order.ID,
+			OrderItemID:   line.ID,
+			ProductID:     line.ProductID,
+			ProductName:   line.ProductName,
+			DownloadLimit: limit,
+		}
+		if err := db.Where(models.DownloadEntitlement{OrderItemID: line.ID}).
+			FirstOrCreate(&entitlement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeDownloads stops the downloads of a cancelled order
+func revokeDownloads(tx *gorm.DB, orderID string) error {
+	return tx.Model(&models.DownloadEntitlement{}).
+		Where("order_id = ? AND revoked_at IS NULL", orderID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/i18n"
+	"bizoe-3d-store/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// localizeProducts overlays the best matching translation onto each product
+// and its category, following the i18n fallback chain for locale
+func localizeProducts(db *gorm.DB, products []models.Product, locale string) {
+	if len(products) == 0 {
+		return
+	}
+
+	chain := i18n.FallbackChain(locale)
+
+	productIDs := make([]string, 0, len(products))
+	categories := make([]*models.Category, 0, len(products))
+	for i := range products {
+		productIDs = append(productIDs, products[i].ID)
+		if products[i].Category.ID != "" {
+			categories = append(categories, &products[i].Category)
+		}
+	}
+
+	var translations []models.ProductTranslation
+	db.Where("product_id IN ? AND locale IN ?", productIDs, chain).Find(&translations)
+
+	byProduct := make(map[string]map[string]*models.ProductTranslation)
+	for i := range translations {
+		t := &translations[i]
+		if byProduct[t.ProductID] == nil {
+			byProduct[t.ProductID] = make(map[string]*models.ProductTranslation)
+		}
+		byProduct[t.ProductID][t.Locale] = t
+	}
+
+	for i := range products {
+		products[i].Locale = i18n.DefaultLocale
+		for _, l := range chain {
+			if t, ok := byProduct[products[i].ID][l]; ok {
+				products[i].ApplyTranslation(t)
+				break
+			}
+		}
+
+		for j := range products[i].Media {
+			image := &products[i].Media[j]
+			for _, l := range chain {
+				if alt := image.AltText[l]; alt != "" {
+					image.Alt = alt
+					break
+				}
+			}
+		}
+	}
+
+	localizeCategoryPointers(db, categories, locale)
+}
+
+// localizeProduct is the single product variant of localizeProducts
+func localizeProduct(db *gorm.DB, product *models.Product, locale string) {
+	products := []models.Product{*product}
+	localizeProducts(db, products, locale)
+	*product = products[0]
+}
+
+// localizeCategories overlays the best matching translation onto each category and its children
+func localizeCategories(db *gorm.DB, categories []models.Category, locale string) {
+	pointers := make([]*models.Category, 0, len(categories))
+	for i := range categories {
+		pointers = append(pointers, &categories[i])
+		for j := range categories[i].Children {
+			pointers = append(pointers, &categories[i].Children[j])
+		}
+	}
+	localizeCategoryPointers(db, pointers, locale)
+}
+
+func localizeCategoryPointers(db *gorm.DB, categories []*models.Category, locale string) {
+	if len(categories) == 0 {
+		return
+	}
+
+	chain := i18n.FallbackChain(locale)
+
+	categoryIDs := make([]string, 0, len(categories))
+	for _, category := range categories {
+		categoryIDs = append(categoryIDs, category.ID)
+	}
+
+	var translations []models.CategoryTranslation
+	db.Where("category_id IN ? AND locale IN ?", categoryIDs, chain).Find(&translations)
+
+	byCategory := make(map[string]map[string]*models.CategoryTranslation)
+	for i := range translations {
+		t := &translations[i]
+		if byCategory[t.CategoryID] == nil {
+			byCategory[t.CategoryID] = make(map[string]*models.CategoryTranslation)
+		}
+		byCategory[t.CategoryID][t.Locale] = t
+	}
+
+	for _, category := range categories {
+		category.Locale = i18n.DefaultLocale
+		for _, l := range chain {
+			if t, ok := byCategory[category.ID][l]; ok {
+				category.ApplyTranslation(t)
+				break
+			}
+		}
+	}
+}
+
+// localizeCart localizes the products referenced by cart items
+func localizeCart(db *gorm.DB, cart *models.Cart, locale string) {
+	if len(cart.Items) == 0 {
+		return
+	}
+
+	products := make([]models.Product, len(cart.Items))
+	for i := range cart.Items {
+		products[i] = cart.Items[i].Product
+	}
+	localizeProducts(db, products, locale)
+	for i := range cart.Items {
+		cart.Items[i].Product = products[i]
+	}
+}
+
+// translatedProductSearch restricts db to products whose base content or any
+// translation matches the lower-cased LIKE pattern
+func translatedProductSearch(db *gorm.DB, pattern string) *gorm.DB {
+	translated := db.Session(&gorm.Session{NewDB: true}).
+		Model(&models.ProductTranslation{}).
+		Select("product_id").
+		Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ?", pattern, pattern)
+
+	return db.Where("LOWER(products.name) LIKE ? OR LOWER(products.description) LIKE ? OR products.id IN (?)",
+		pattern, pattern, translated)
+}
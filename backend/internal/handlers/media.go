@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/i18n"
+	"bizoe-3d-store/internal/media"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxImageSize caps uploaded images at 10 MB
+const maxImageSize = 10 << 20
+
+type MediaHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+}
+
+type UpdateImageRequest struct {
+	AltText map[string]string `json:"altText" binding:"required"`
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []string `json:"imageIds" binding:"required,min=1"`
+}
+
+func NewMediaHandler(db *gorm.DB, store storage.Storage) *MediaHandler {
+	return &MediaHandler{db: db, storage: store}
+}
+
+// UploadProductImage stores an uploaded image and its variants and appends it to the product gallery (admin only).
+// Alt text is sent as a JSON object keyed by locale in the "altText" form field.
+func (h *MediaHandler) UploadProductImage(c *gin.Context) {
+	productID := c.Param("id")
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "An image file is required in the \"file\" field",
+		})
+		return
+	}
+	if file.Size > maxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "File too large",
+			"message": "Images must be 10 MB or smaller",
+		})
+		return
+	}
+
+	altText, ok := parseAltText(c, c.PostForm("altText"))
+	if !ok {
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+
+	variants, err := media.Process(data)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "Unsupported file type",
+				"message": "Only JPEG, PNG and GIF images are accepted",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	image := models.ProductImage{
+		ID:        uuid.New().String(),
+		ProductID: &productID,
+		AltText:   altText,
+	}
+
+	image.Variants, err = storeVariants(h.storage, fmt.Sprintf("products/%s/%s", productID, image.ID), variants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": "Failed to store image",
+		})
+		return
+	}
+	image.URL, image.Width, image.Height, image.ContentType = primaryVariant(image.Variants)
+
+	// Append to the end of the gallery
+	var maxPosition int
+	h.db.Model(&models.ProductImage{}).Where("product_id = ?", productID).
+		Select("COALESCE(MAX(position), -1)").Row().Scan(&maxPosition)
+	image.Position = maxPosition + 1
+
+	if err := h.db.Create(&image).Error; err != nil {
+		deleteVariants(h.storage, image.Variants)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save image",
+		})
+		return
+	}
+
+	if err := h.syncProductImages(productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product images",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Image uploaded successfully",
+		"data":    image,
+	})
+}
+
+// GetProductImages returns the image gallery of a product in display order (admin only)
+func (h *MediaHandler) GetProductImages(c *gin.Context) {
+	var images []models.ProductImage
+	if err := h.db.Where("product_id = ?", c.Param("id")).Order("position ASC").Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product images",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    images,
+	})
+}
+
+// UpdateProductImage replaces the per-locale alt text of an image (admin only)
+func (h *MediaHandler) UpdateProductImage(c *gin.Context) {
+	var req UpdateImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	altText, ok := normalizeAltText(c, req.AltText)
+	if !ok {
+		return
+	}
+
+	var image models.ProductImage
+	if err := h.db.Where("product_id = ?", c.Param("id")).First(&image, "id = ?", c.Param("imageId")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Image not found",
+				"message": "The requested image does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find image",
+		})
+		return
+	}
+
+	image.AltText = altText
+	if err := h.db.Save(&image).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update image",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Image updated successfully",
+		"data":    image,
+	})
+}
+
+// ReorderProductImages sets the gallery order from a list of image IDs (admin only)
+func (h *MediaHandler) ReorderProductImages(c *gin.Context) {
+	productID := c.Param("id")
+
+	var req ReorderImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.ProductImage{}).Where("product_id = ?", productID).Count(&count)
+	if int(count) != len(req.ImageIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid order",
+			"message": "The order must list every image of the product exactly once",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	for position, imageID := range req.ImageIDs {
+		result := tx.Model(&models.ProductImage{}).
+			Where("id = ? AND product_id = ?", imageID, productID).
+			Update("position", position)
+		if result.Error != nil || result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid order",
+				"message": fmt.Sprintf("Image %s does not belong to this product", imageID),
+			})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to reorder images",
+		})
+		return
+	}
+
+	if err := h.syncProductImages(productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product images",
+		})
+		return
+	}
+
+	h.GetProductImages(c)
+}
+
+// DeleteProductImage detaches an image from its product. The stored files
+// are removed by the next garbage collection run. (admin only)
+func (h *MediaHandler) DeleteProductImage(c *gin.Context) {
+	productID := c.Param("id")
+
+	result := h.db.Model(&models.ProductImage{}).
+		Where("id = ? AND product_id = ?", c.Param("imageId"), productID).
+		Update("product_id", nil)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete image",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Image not found",
+			"message": "The requested image does not exist",
+		})
+		return
+	}
+
+	if err := h.syncProductImages(productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product images",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Image deleted successfully",
+	})
+}
+
+// CollectGarbage removes unreferenced images immediately (admin only)
+func (h *MediaHandler) CollectGarbage(c *gin.Context) {
+	removed, err := media.CollectGarbage(h.db, h.storage, time.Now().Add(-media.GCGracePeriod))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Garbage collection completed",
+		"data": gin.H{
+			"removed": removed,
+		},
+	})
+}
+
+// syncProductImages rewrites Product.Images so clients that only read the URL
+// list see managed images in gallery order, followed by any external URLs
+func (h *MediaHandler) syncProductImages(productID string) error {
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", productID).Error; err != nil {
+		return err
+	}
+
+	var images []models.ProductImage
+	if err := h.db.Where("product_id = ?", productID).Order("position ASC").Find(&images).Error; err != nil {
+		return err
+	}
+
+	managedPrefix := h.storage.URL("")
+	urls := make([]string, 0, len(images)+len(product.Images))
+	for _, image := range images {
+		urls = append(urls, image.URL)
+	}
+	for _, url := range product.Images {
+		if !strings.HasPrefix(url, managedPrefix) {
+			urls = append(urls, url)
+		}
+	}
+
+	product.Images = urls
+	return h.db.Model(&product).Select("images").Updates(&product).Error
+}
+
+// storeVariants writes processed image variants under prefix. On failure the
+// variants stored so far are removed again.
+func storeVariants(store storage.Storage, prefix string, variants []media.Variant) ([]models.ImageVariant, error) {
+	stored := make([]models.ImageVariant, 0, len(variants))
+	for _, v := range variants {
+		key := fmt.Sprintf("%s/%s.%s", prefix, v.Name, v.Format)
+		if err := store.Put(key, bytes.NewReader(v.Data), v.ContentType); err != nil {
+			deleteVariants(store, stored)
+			return nil, err
+		}
+		stored = append(stored, models.ImageVariant{
+			Name: v.Name, Format: v.Format, ContentType: v.ContentType,
+			Width: v.Width, Height: v.Height, Key: key, URL: store.URL(key),
+		})
+	}
+	return stored, nil
+}
+
+// primaryVariant picks the display URL (the large rendition in the source
+// format) and the dimensions and type of the original
+func primaryVariant(variants []models.ImageVariant) (url string, width, height int, contentType string) {
+	for _, v := range variants {
+		if v.Name == "original" {
+			width, height, contentType = v.Width, v.Height, v.ContentType
+		}
+		if v.Name == "large" && v.Format != "webp" {
+			url = v.URL
+		}
+	}
+	return url, width, height, contentType
+}
+
+func deleteVariants(store storage.Storage, variants []models.ImageVariant) {
+	for _, v := range variants {
+		store.Delete(v.Key)
+	}
+}
+
+// parseAltText decodes the optional altText form field
+func parseAltText(c *gin.Context, raw string) (map[string]string, bool) {
+	if raw == "" {
+		return map[string]string{}, true
+	}
+
+	var altText map[string]string
+	if err := json.Unmarshal([]byte(raw), &altText); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "altText must be a JSON object keyed by locale",
+		})
+		return nil, false
+	}
+	return normalizeAltText(c, altText)
+}
+
+// normalizeAltText checks alt text locales and maps them onto supported locale tags
+func normalizeAltText(c *gin.Context, altText map[string]string) (map[string]string, bool) {
+	normalized := make(map[string]string, len(altText))
+	for tag, text := range altText {
+		locale := i18n.Normalize(tag)
+		if locale == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid locale",
+				"message": fmt.Sprintf("Unsupported alt text locale %q", tag),
+			})
+			return nil, false
+		}
+		normalized[locale] = strings.TrimSpace(text)
+	}
+	return normalized, true
+}
@@ -0,0 +1,994 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/payments"
+	"bizoe-3d-store/internal/pricing"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// orderEditWindow is how long after checkout the items and billing address
+// of a paid order may still change. Invoices are only issued once it has
+// passed, so an edit never contradicts an issued invoice.
+const orderEditWindow = time.Hour
+
+// Customers may change an order until it is being made or packed; staff may
+// change it until the first parcel ships
+var (
+	customerEditableStatuses = []models.OrderStatus{models.OrderStatusPending, models.OrderStatusConfirmed}
+	adminEditableStatuses    = []models.OrderStatus{models.OrderStatusPending, models.OrderStatusConfirmed,
+		models.OrderStatusInProduction, models.OrderStatusProcessing}
+)
+
+var (
+	errOrderChanged          = errors.New("order changed")
+	errNothingChanged        = errors.New("nothing changed")
+	errEmptyOrder            = errors.New("order has no items")
+	errInvoiceIssued         = errors.New("invoice already issued")
+	errPaymentMethodRequired = errors.New("payment method required")
+	errEditWindowClosed      = errors.New("edit window closed")
+)
+
+type OrderEditHandler struct {
+	db          *gorm.DB
+	pricing     *pricing.Service
+	gateway     payments.Gateway
+	mailer      email.Sender
+	stockAlerts *alerts.StockNotifier
+}
+
+// EditOrderRequest changes the lines and addresses of a placed order. Each
+// address is taken typed in or as the ID of one of the customer's saved
+// addresses, and left unchanged when neither is given.
+type EditOrderRequest struct {
+	Items             []EditOrderLine `json:"items" binding:"omitempty,max=50,dive"`
+	ShippingAddress   *models.Address `json:"shippingAddress"`
+	ShippingAddressID string          `json:"shippingAddressId"`
+	BillingAddress    *models.Address `json:"billingAddress"`
+	BillingAddressID  string          `json:"billingAddressId"`
+	PaymentMethodID   string          `json:"paymentMethodId"` // Saved payment method charged when a paid order costs more
+	Note              string          `json:"note" binding:"max=500"`
+}
+
+// SettleEditRequest pays the difference of an edit whose charge was declined
+type SettleEditRequest struct {
+	PaymentMethodID string `json:"paymentMethodId" binding:"required"`
+}
+
+// EditOrderLine sets the quantity of the order line ItemID, removing it at
+// zero, or adds Quantity units of ProductID as a new line
+type EditOrderLine struct {
+	ItemID    string `json:"itemId"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity" binding:"min=0,max=1000"`
+}
+
+func NewOrderEditHandler(db *gorm.DB, gateway payments.Gateway, mailer email.Sender, stockAlerts *alerts.StockNotifier) *OrderEditHandler {
+	return &OrderEditHandler{
+		db:          db,
+		pricing:     pricing.NewService(db),
+		gateway:     gateway,
+		mailer:      mailer,
+		stockAlerts: stockAlerts,
+	}
+}
+
+// EditOrder adds, removes or changes the items and addresses of an order
+// before it is fulfilled. Tax and shipping are worked out again; lines kept
+// keep the price they were bought at and added products are quoted at
+// today's price in the order's currency. Stock follows the new quantities.
+// On paid orders the difference is charged to a saved payment method or
+// refunded once the edit is saved; a settlement that fails is kept on the
+// edit and retried. Every edit is recorded in the order history.
+func (h *OrderEditHandler) EditOrder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+	isAdmin := middleware.IsAdmin(c)
+
+	var req EditOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(req.Items) == 0 && req.ShippingAddress == nil && req.ShippingAddressID == "" &&
+		req.BillingAddress == nil && req.BillingAddressID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Nothing to change",
+		})
+		return
+	}
+	seen := map[string]bool{}
+	for _, line := range req.Items {
+		if (line.ItemID == "") == (line.ProductID == "") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "Each item names either an existing itemId or a productId to add",
+			})
+			return
+		}
+		if line.ProductID != "" && line.Quantity < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "Added products need a quantity of at least 1",
+			})
+			return
+		}
+		key := line.ItemID + "/" + line.ProductID
+		if seen[key] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "Each item may only be listed once",
+			})
+			return
+		}
+		seen[key] = true
+	}
+
+	var order models.Order
+	query := preloadBundleItems(h.db.Preload("Items.Product", unscoped), "Items.Product").Preload("User")
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&order, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order not found",
+				"message": "The requested order does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return
+	}
+
+	if !h.editable(c, &order, isAdmin, len(req.Items) > 0) {
+		return
+	}
+	for i := range order.Items {
+		if err := order.Items[i].Product.ResolveBundle(h.pricing.Convert); err != nil {
+			respondPricingError(c, err)
+			return
+		}
+	}
+
+	// Addresses are resolved against the customer's address book, also when staff edit
+	var shippingAddress, billingAddress *models.Address
+	if req.ShippingAddress != nil || req.ShippingAddressID != "" {
+		address, err := checkoutAddress(h.db, order.UserID, req.ShippingAddressID, req.ShippingAddress, "is_default_shipping")
+		if err != nil {
+			respondAddressError(c, "shippingAddress", err)
+			return
+		}
+		if address != order.ShippingAddress {
+			shippingAddress = &address
+		}
+	}
+	if req.BillingAddress != nil || req.BillingAddressID != "" {
+		address, err := checkoutAddress(h.db, order.UserID, req.BillingAddressID, req.BillingAddress, "is_default_billing")
+		if err != nil {
+			respondAddressError(c, "billingAddress", err)
+			return
+		}
+		if address != order.BillingAddress {
+			billingAddress = &address
+		}
+	}
+
+	paid := order.PaymentStatus == models.PaymentStatusPaid || order.PaymentStatus == models.PaymentStatusPartiallyRefunded
+	if paid && (len(req.Items) > 0 || billingAddress != nil) && time.Since(order.CreatedAt) > orderEditWindow {
+		h.respondEditError(c, errEditWindowClosed, &order, nil, "")
+		return
+	}
+
+	// Quote added products before taking any locks
+	lines := make(map[string]*models.OrderItem, len(order.Items))
+	for i := range order.Items {
+		lines[order.Items[i].ID] = &order.Items[i]
+	}
+	added := map[string]*models.CartItem{}
+	for _, line := range req.Items {
+		if line.ItemID != "" {
+			item, ok := lines[line.ItemID]
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error":   "Item not found",
+					"message": "The item is not part of this order",
+				})
+				return
+			}
+			if item.LineType == models.OrderLinePrint && line.Quantity != item.Quantity {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "Item not editable",
+					"message": "Custom prints cannot be changed once ordered",
+				})
+				return
+			}
+			if line.Quantity > item.Quantity && item.Product.ArchivedAt.Valid {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "Product unavailable",
+					"message": fmt.Sprintf("%s is no longer sold", item.ProductName),
+				})
+				return
+			}
+			continue
+		}
+
+		var product models.Product
+		if err := h.db.Preload("Category").First(&product, "id = ?", line.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error":   "Product not found",
+					"message": "The requested product does not exist",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch product",
+			})
+			return
+		}
+		if err := loadBundle(h.db, &product); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch product",
+			})
+			return
+		}
+		price, _, err := h.pricing.Quote(&product, order.Currency)
+		if err != nil {
+			respondPricingError(c, err)
+			return
+		}
+		if order.PaymentStatus != models.PaymentStatusPending && order.PaymentStatus != models.PaymentStatusFailed {
+			// The order is already paid for, so the difference is charged in full
+			product.DepositPercent = 0
+		}
+		added[line.ProductID] = &models.CartItem{
+			ProductID: product.ID,
+			Product:   product,
+			Quantity:  line.Quantity,
+			Price:     price,
+		}
+	}
+
+	edit := models.OrderEdit{
+		OrderID:       order.ID,
+		EditedBy:      userID,
+		ByAdmin:       isAdmin,
+		Note:          strings.TrimSpace(req.Note),
+		PreviousTotal: order.Total,
+	}
+	var released, touched []string
+	failedProduct, staleIntent := "", ""
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the order first so concurrent edits cannot interleave
+		result := tx.Model(&models.Order{}).Where("id = ? AND updated_at = ?", order.ID, order.UpdatedAt).
+			Update("updated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderChanged
+		}
+
+		// Issued invoices are final; what they show can no longer change. The
+		// window keeps the invoice job away, but customers can ask for theirs early.
+		if len(req.Items) > 0 || billingAddress != nil {
+			var invoices int64
+			if err := tx.Model(&models.Document{}).
+				Where("type = ? AND source_id = ?", models.DocumentInvoice, order.ID).Count(&invoices).Error; err != nil {
+				return err
+			}
+			if invoices > 0 {
+				return errInvoiceIssued
+			}
+		}
+
+		for _, line := range req.Items {
+			if line.ItemID == "" {
+				continue
+			}
+			item := lines[line.ItemID]
+			change := models.OrderChange{
+				Type:         models.OrderChangeQuantity,
+				ProductID:    item.ProductID,
+				ProductName:  item.ProductName,
+				FromQuantity: item.Quantity,
+				ToQuantity:   line.Quantity,
+			}
+			switch {
+			case line.Quantity == item.Quantity:
+				continue
+			case line.Quantity < item.Quantity:
+				productIDs, err := shrinkOrderLine(tx, item, line.Quantity, order.Currency)
+				if err != nil {
+					return err
+				}
+				released = append(released, productIDs...)
+				if line.Quantity == 0 {
+					change.Type = models.OrderChangeItemRemoved
+				}
+			default:
+				productIDs, err := growOrderLine(tx, &order, item, line.Quantity)
+				if err == errInsufficientStock {
+					failedProduct = item.ProductName
+				}
+				if err != nil {
+					return err
+				}
+				touched = append(touched, productIDs...)
+			}
+			edit.Changes = append(edit.Changes, change)
+		}
+
+		for _, line := range req.Items {
+			cartItem := added[line.ProductID]
+			if cartItem == nil {
+				continue
+			}
+			available, err := orderableQuantity(tx, &cartItem.Product)
+			if err != nil {
+				return err
+			}
+			if available < cartItem.Quantity {
+				failedProduct = cartItem.Product.Name
+				return errInsufficientStock
+			}
+			_, productIDs, err := orderLines(tx, order.ID, cartItem, order.Currency)
+			if err == errInsufficientStock {
+				failedProduct = cartItem.Product.Name
+			}
+			if err != nil {
+				return err
+			}
+			touched = append(touched, productIDs...)
+			edit.Changes = append(edit.Changes, models.OrderChange{
+				Type:        models.OrderChangeItemAdded,
+				ProductID:   cartItem.ProductID,
+				ProductName: cartItem.Product.Name,
+				ToQuantity:  cartItem.Quantity,
+			})
+		}
+
+		updates := map[string]interface{}{}
+		if shippingAddress != nil {
+			previous := order.ShippingAddress
+			edit.Changes = append(edit.Changes, models.OrderChange{Type: models.OrderChangeShippingAddress, PreviousAddress: &previous})
+			order.ShippingAddress = *shippingAddress
+			addressColumns(updates, "shipping_", order.ShippingAddress)
+		}
+		if billingAddress != nil {
+			previous := order.BillingAddress
+			edit.Changes = append(edit.Changes, models.OrderChange{Type: models.OrderChangeBillingAddress, PreviousAddress: &previous})
+			order.BillingAddress = *billingAddress
+			addressColumns(updates, "billing_", order.BillingAddress)
+		}
+		if len(edit.Changes) == 0 {
+			return errNothingChanged
+		}
+
+		if err := repriceOrder(tx, h.pricing, &order); err != nil {
+			return err
+		}
+		if len(order.Items) == 0 {
+			return errEmptyOrder
+		}
+		edit.NewTotal = order.Total
+		edit.Difference = pricing.RoundAmount(order.Total-edit.PreviousTotal, order.Currency)
+		if paid && edit.Difference != 0 {
+			if edit.Difference > 0 && (req.PaymentMethodID == "" || order.User.PaymentCustomerID == "") {
+				return errPaymentMethodRequired
+			}
+			edit.Settlement = models.OrderEditSettlementPending
+			edit.PaymentMethodID = req.PaymentMethodID
+		}
+		// A payment started for the old total can no longer pay for the order
+		unpaid := order.PaymentStatus == models.PaymentStatusPending || order.PaymentStatus == models.PaymentStatusFailed
+		if unpaid && edit.Difference != 0 && order.PaymentIntentID != "" {
+			staleIntent = order.PaymentIntentID
+			order.PaymentIntentID = ""
+			updates["payment_intent_id"] = ""
+		}
+
+		updates["subtotal"] = order.Subtotal
+		updates["tax"] = order.Tax
+		updates["shipping"] = order.Shipping
+		updates["total"] = order.Total
+		updates["balance_due"] = order.BalanceDue
+		if err := tx.Model(&order).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		for _, productID := range released {
+			if _, err := allocatePendingLines(tx, productID); err != nil {
+				return err
+			}
+		}
+		if err := syncBundlesContaining(tx, append(touched, released...)); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+		if paid {
+			return grantDownloads(tx, &order)
+		}
+		return nil
+	})
+	if err != nil {
+		h.respondEditError(c, err, &order, &edit, failedProduct)
+		return
+	}
+	h.stockAlerts.Restocked(released...)
+	if staleIntent != "" {
+		// The intent can no longer confirm the order; cancelling it keeps the customer from paying it
+		if err := h.gateway.CancelPayment(staleIntent); err != nil {
+			log.Printf("Failed to cancel payment intent %s of edited order %s: %v", staleIntent, order.ID, err)
+		}
+	}
+
+	// The edit stands whether or not its difference settles now
+	var settleErr error
+	if edit.Settlement == models.OrderEditSettlementPending {
+		settleErr = h.settle(&order, &edit)
+	}
+	if isAdmin && order.UserID != userID {
+		h.notifyCustomer(&order, &edit)
+	}
+
+	h.db.Preload("Items.Product", unscoped).Preload("Edits", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).First(&order, "id = ?", order.ID)
+
+	if settleErr != nil {
+		respondSettleError(c, settleErr, &edit, order)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Order updated successfully",
+		"data":    order,
+	})
+}
+
+// editable reports whether the order can still be changed by the caller,
+// writing the error response when it cannot
+func (h *OrderEditHandler) editable(c *gin.Context, order *models.Order, isAdmin, changesItems bool) bool {
+	statuses := customerEditableStatuses
+	if isAdmin {
+		statuses = adminEditableStatuses
+	}
+	editable := false
+	for _, status := range statuses {
+		if order.Status == status {
+			editable = true
+		}
+	}
+	if !editable {
+		message := "Orders can only be changed until they go into production or packing"
+		if isAdmin {
+			message = "Orders can only be changed until they ship"
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not editable",
+			"message": message,
+		})
+		return false
+	}
+
+	var shipments int64
+	if err := h.db.Model(&models.Shipment{}).Where("order_id = ?", order.ID).Count(&shipments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to check the order's shipments",
+		})
+		return false
+	}
+	if shipments > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not editable",
+			"message": "A parcel has already been created for this order; void it before changing the order",
+		})
+		return false
+	}
+
+	if !changesItems {
+		return true
+	}
+	if order.PaymentStatus == models.PaymentStatusDeposit {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not editable",
+			"message": "The items of an order with a pre-order deposit paid cannot be changed",
+		})
+		return false
+	}
+	if order.WarrantyClaimID != nil || order.PaymentMethod == "exchange" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not editable",
+			"message": "The items of a replacement order cannot be changed",
+		})
+		return false
+	}
+	return true
+}
+
+// SettleEdit pays the difference of an edit to a paid order whose charge was
+// declined, with another saved payment method
+func (h *OrderEditHandler) SettleEdit(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req SettleEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var order models.Order
+	query := h.db.Preload("User")
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&order, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order not found",
+				"message": "The requested order does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return
+	}
+
+	var edit models.OrderEdit
+	if err := h.db.Where("id = ? AND order_id = ?", c.Param("editId"), order.ID).First(&edit).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Edit not found",
+				"message": "The requested change is not part of this order",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order edit",
+		})
+		return
+	}
+	if edit.Settlement != models.OrderEditSettlementFailed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Nothing to pay",
+			"message": "Only changes whose payment was declined can be paid again",
+		})
+		return
+	}
+	if order.User.PaymentCustomerID == "" {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":   "Payment method required",
+			"message": "Save a payment method to pay the difference",
+		})
+		return
+	}
+
+	// A new payment method is a new attempt, so it gets a key of its own
+	edit.Settlement = models.OrderEditSettlementPending
+	edit.PaymentMethodID = req.PaymentMethodID
+	edit.IdempotencyKey = "order-edit-" + edit.ID + "-" + uuid.New().String()[:8]
+	result := h.db.Model(&models.OrderEdit{}).
+		Where("id = ? AND settlement = ?", edit.ID, models.OrderEditSettlementFailed).
+		Updates(map[string]interface{}{
+			"settlement":        edit.Settlement,
+			"payment_method_id": edit.PaymentMethodID,
+			"idempotency_key":   edit.IdempotencyKey,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update order edit",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Edit changed",
+			"message": "The payment for this change is already being retried",
+		})
+		return
+	}
+
+	if err := h.settle(&order, &edit); err != nil {
+		respondSettleError(c, err, &edit, edit)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Difference paid successfully",
+		"data":    edit,
+	})
+}
+
+// SettleEdits retries the settlements left pending, such as when the payment
+// provider could not be reached. Recent edits are left to the request that
+// made them. Customers whose charge is declined are asked to pay again.
+func (h *OrderEditHandler) SettleEdits() (int, error) {
+	var edits []models.OrderEdit
+	if err := h.db.Where("settlement = ? AND created_at < ?", models.OrderEditSettlementPending, time.Now().Add(-time.Minute)).
+		Order("created_at ASC").Find(&edits).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch order edits to settle: %w", err)
+	}
+
+	settled := 0
+	for i := range edits {
+		edit := &edits[i]
+		var order models.Order
+		if err := h.db.Preload("User").First(&order, "id = ?", edit.OrderID).Error; err != nil {
+			return settled, fmt.Errorf("failed to fetch order %s: %w", edit.OrderID, err)
+		}
+		err := h.settle(&order, edit)
+		if errors.Is(err, payments.ErrDeclined) {
+			email.SendAsync(h.mailer, email.Message{
+				To:      []string{order.User.Email},
+				Subject: "Payment declined for changes to your order " + order.OrderNumber,
+				Body: fmt.Sprintf("Hi %s,\n\nThe payment of %.2f %s for the changes to your order %s was declined. "+
+					"Please pay it with another saved payment method from your order page.\n",
+					order.User.FirstName, edit.Difference, order.Currency, order.OrderNumber),
+			})
+			continue
+		}
+		if err != nil {
+			return settled, fmt.Errorf("failed to settle order edit %s: %w", edit.ID, err)
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// settle charges or refunds the difference a saved edit made to a paid order
+// and records the outcome. Gateway calls carry the edit's idempotency key, so
+// a retry never charges or refunds twice. A declined charge marks the edit
+// failed; any other error leaves it pending for SettleEdits.
+func (h *OrderEditHandler) settle(order *models.Order, edit *models.OrderEdit) error {
+	metadata := map[string]string{
+		"order_id":      order.ID,
+		"order_edit_id": edit.ID,
+	}
+	if edit.Difference > 0 {
+		paymentID, err := h.gateway.Charge(payments.Charge{
+			CustomerID:      order.User.PaymentCustomerID,
+			PaymentMethodID: edit.PaymentMethodID,
+			Amount:          edit.Difference,
+			Currency:        order.Currency,
+			Description:     fmt.Sprintf("Changes to order %s", order.OrderNumber),
+			Metadata:        metadata,
+			IdempotencyKey:  edit.IdempotencyKey,
+		})
+		if errors.Is(err, payments.ErrDeclined) {
+			edit.Settlement = models.OrderEditSettlementFailed
+			if dbErr := h.db.Model(&models.OrderEdit{}).
+				Where("id = ? AND settlement = ?", edit.ID, models.OrderEditSettlementPending).
+				Update("settlement", edit.Settlement).Error; dbErr != nil {
+				return dbErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		edit.PaymentID = paymentID
+	} else {
+		refundID, err := h.gateway.Refund(payments.Refund{
+			PaymentID:      order.PaymentIntentID,
+			Amount:         -edit.Difference,
+			Currency:       order.Currency,
+			Metadata:       metadata,
+			IdempotencyKey: edit.IdempotencyKey,
+		})
+		if err != nil {
+			return err
+		}
+		edit.RefundID = refundID
+	}
+
+	now := time.Now()
+	edit.Settlement = models.OrderEditSettlementSettled
+	edit.SettledAt = &now
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrderEdit{}).
+			Where("id = ? AND settlement = ?", edit.ID, models.OrderEditSettlementPending).
+			Updates(map[string]interface{}{
+				"settlement": edit.Settlement,
+				"payment_id": edit.PaymentID,
+				"refund_id":  edit.RefundID,
+				"settled_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Nothing more to record when a concurrent retry with the same key got here first
+			return result.Error
+		}
+		if edit.RefundID == "" {
+			return nil
+		}
+		if err := tx.First(order, "id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		return recordRefund(tx, order, -edit.Difference)
+	})
+}
+
+// respondSettleError reports a difference that could not be settled. The
+// change itself is saved, so data carries it for the client to show.
+func respondSettleError(c *gin.Context, err error, edit *models.OrderEdit, data interface{}) {
+	if errors.Is(err, payments.ErrDeclined) {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":   "Payment declined",
+			"message": fmt.Sprintf("The order was changed but the payment of %.2f for the difference was declined; pay it with another saved payment method", edit.Difference),
+			"data":    data,
+		})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{
+		"error":   "Payment error",
+		"message": "The order was changed but the difference could not be settled yet; it will be retried",
+		"data":    data,
+	})
+}
+
+func (h *OrderEditHandler) respondEditError(c *gin.Context, err error, order *models.Order, edit *models.OrderEdit, failedProduct string) {
+	switch {
+	case err == errOrderChanged:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order changed",
+			"message": "The order was changed by someone else; reload it and try again",
+		})
+	case err == errInsufficientStock:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Insufficient stock",
+			"message": fmt.Sprintf("Product %s is out of stock or insufficient quantity available", failedProduct),
+		})
+	case err == errNothingChanged:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Nothing to change",
+		})
+	case err == errInvoiceIssued:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Invoice already issued",
+			"message": "The invoice for this order has been issued, so only the shipping address can be changed; cancel or return items instead",
+		})
+	case err == errEmptyOrder:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "An order needs at least one item; cancel it instead",
+		})
+	case err == errEditWindowClosed:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not editable",
+			"message": fmt.Sprintf("The items and billing address of a paid order can only be changed within %d minutes of checkout; return items instead", int(orderEditWindow.Minutes())),
+		})
+	case err == errPaymentMethodRequired:
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":   "Payment method required",
+			"message": fmt.Sprintf("The changes cost %.2f %s more; choose a saved payment method to pay the difference", edit.Difference, order.Currency),
+		})
+	case errors.Is(err, pricing.ErrCurrencyUnavailable):
+		respondPricingError(c, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update order",
+		})
+	}
+}
+
+// notifyCustomer tells the customer about changes staff made to their order
+func (h *OrderEditHandler) notifyCustomer(order *models.Order, edit *models.OrderEdit) {
+	var lines []string
+	for _, change := range edit.Changes {
+		switch change.Type {
+		case models.OrderChangeItemAdded:
+			lines = append(lines, fmt.Sprintf("- Added %d × %s", change.ToQuantity, change.ProductName))
+		case models.OrderChangeItemRemoved:
+			lines = append(lines, fmt.Sprintf("- Removed %s", change.ProductName))
+		case models.OrderChangeQuantity:
+			lines = append(lines, fmt.Sprintf("- %s: %d instead of %d", change.ProductName, change.ToQuantity, change.FromQuantity))
+		case models.OrderChangeShippingAddress:
+			lines = append(lines, "- Updated the shipping address")
+		case models.OrderChangeBillingAddress:
+			lines = append(lines, "- Updated the billing address")
+		}
+	}
+	body := fmt.Sprintf("We made the following changes to your order %s:\n\n%s\n\nThe order total is now %.2f %s.",
+		order.OrderNumber, strings.Join(lines, "\n"), order.Total, order.Currency)
+	switch {
+	case edit.PaymentID != "":
+		body += fmt.Sprintf(" The difference of %.2f %s was charged to your saved payment method.", edit.Difference, order.Currency)
+	case edit.RefundID != "":
+		body += fmt.Sprintf(" The difference of %.2f %s was refunded to your original payment method.", -edit.Difference, order.Currency)
+	case edit.Settlement != models.OrderEditSettlementNone && edit.Difference > 0:
+		body += fmt.Sprintf(" The difference of %.2f %s could not be charged yet; you can pay it from your order page.", edit.Difference, order.Currency)
+	case edit.Settlement != models.OrderEditSettlementNone:
+		body += fmt.Sprintf(" The difference of %.2f %s will be refunded to your original payment method shortly.", -edit.Difference, order.Currency)
+	}
+	if edit.Note != "" {
+		body += "\n\n" + edit.Note
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{order.User.Email},
+		Subject: "Changes to your order " + order.OrderNumber,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n", order.User.FirstName, body),
+	})
+}
+
+// shrinkOrderLine lowers the quantity of an order line, deleting it at zero.
+// Units set aside for it go back into stock, and downloads of a removed
+// digital line are revoked. It returns the products whose stock went up.
+func shrinkOrderLine(tx *gorm.DB, item *models.OrderItem, quantity int, currency string) ([]string, error) {
+	var released []string
+	holdsStock := item.AllocatedAt != nil && item.LineType != models.OrderLineDigital && item.LineType != models.OrderLinePrint
+	if holdsStock {
+		productIDs, err := releaseStock(tx, &models.OrderItem{
+			ProductID:        item.ProductID,
+			Quantity:         item.Quantity - quantity,
+			BundleComponents: item.BundleComponents,
+		})
+		if err != nil {
+			return nil, err
+		}
+		released = productIDs
+	}
+
+	if quantity == 0 {
+		if item.LineType == models.OrderLineDigital {
+			if err := tx.Model(&models.DownloadEntitlement{}).
+				Where("order_item_id = ? AND revoked_at IS NULL", item.ID).
+				Update("revoked_at", time.Now()).Error; err != nil {
+				return nil, err
+			}
+		}
+		return released, tx.Delete(item).Error
+	}
+
+	updates := map[string]interface{}{
+		"quantity": quantity,
+		"total":    item.Price * float64(quantity),
+	}
+	if item.DepositAmount > 0 {
+		updates["deposit_amount"] = pricing.RoundAmount(item.DepositAmount*float64(quantity)/float64(item.Quantity), currency)
+	}
+	return released, tx.Model(item).Updates(updates).Error
+}
+
+// growOrderLine raises the quantity of an order line at the price it was
+// bought at. In stock products take the extra units from stock; pre-order
+// and backorder products queue them on a new line behind earlier orders.
+// It returns the products whose stock went down.
+func growOrderLine(tx *gorm.DB, order *models.Order, item *models.OrderItem, quantity int) ([]string, error) {
+	extra := quantity - item.Quantity
+	product := item.Product
+	available, err := orderableQuantity(tx, &product)
+	if err != nil {
+		return nil, err
+	}
+	if available < extra {
+		return nil, errInsufficientStock
+	}
+
+	queued := product.Availability == models.AvailabilityPreorder || product.Availability == models.AvailabilityBackorder
+	if item.LineType == models.OrderLinePreorder || item.LineType == models.OrderLineBackorder || (queued && !product.IsBundle && !product.IsDigital) {
+		if order.PaymentStatus != models.PaymentStatusPending && order.PaymentStatus != models.PaymentStatusFailed {
+			product.DepositPercent = 0
+		}
+		_, touched, err := orderLines(tx, order.ID, &models.CartItem{
+			ProductID: item.ProductID,
+			Product:   product,
+			Quantity:  extra,
+			Price:     item.Price,
+		}, order.Currency)
+		return touched, err
+	}
+
+	var touched []string
+	if item.LineType == models.OrderLineStock {
+		touched, err = reserveStock(tx, &models.OrderItem{
+			ProductID:        item.ProductID,
+			Quantity:         extra,
+			BundleComponents: item.BundleComponents,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return touched, tx.Model(item).Updates(map[string]interface{}{
+		"quantity": quantity,
+		"total":    item.Price * float64(quantity),
+	}).Error
+}
+
+// repriceOrder works out an order's subtotal, tax, shipping and total again
+// from its current lines. Unpaid orders with pre-order deposits also get
+// their balance recalculated.
+func repriceOrder(tx *gorm.DB, svc *pricing.Service, order *models.Order) error {
+	var items []models.OrderItem
+	if err := tx.Preload("Product", unscoped).Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	subtotal, balance := 0.0, 0.0
+	shipped := false
+	for _, item := range items {
+		subtotal += item.Total
+		if item.DepositAmount > 0 {
+			balance += item.Total - item.DepositAmount
+		}
+		if !item.Product.IsDigital {
+			shipped = true
+		}
+	}
+	order.Items = items
+	order.Subtotal = pricing.RoundAmount(subtotal, order.Currency)
+	tax, shipping, total, err := orderTotals(svc, order.Subtotal, order.Currency, shipped)
+	if err != nil {
+		return err
+	}
+	order.Tax, order.Shipping, order.Total = tax, shipping, total
+	if order.PaymentStatus == models.PaymentStatusPending || order.PaymentStatus == models.PaymentStatusFailed {
+		order.BalanceDue = pricing.RoundAmount(math.Max(balance, 0), order.Currency)
+	}
+	return nil
+}
+
+// addressColumns adds the columns of an embedded order address to updates
+func addressColumns(updates map[string]interface{}, prefix string, address models.Address) {
+	updates[prefix+"first_name"] = address.FirstName
+	updates[prefix+"last_name"] = address.LastName
+	updates[prefix+"email"] = address.Email
+	updates[prefix+"phone"] = address.Phone
+	updates[prefix+"address"] = address.Address
+	updates[prefix+"city"] = address.City
+	updates[prefix+"state"] = address.State
+	updates[prefix+"country"] = address.Country
+	updates[prefix+"zip_code"] = address.ZipCode
+	updates[prefix+"company"] = address.Company
+	updates[prefix+"tax_id"] = address.TaxID
+}
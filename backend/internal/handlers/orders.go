@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"bizoe-3d-store/internal/alerts"
 	"bizoe-3d-store/internal/config"
 	"bizoe-3d-store/internal/middleware"
 	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v74"
@@ -15,14 +19,21 @@ import (
 )
 
 type OrderHandler struct {
-	db     *gorm.DB
-	config *config.Config
+	db          *gorm.DB
+	config      *config.Config
+	pricing     *pricing.Service
+	stockAlerts *alerts.StockNotifier
 }
 
+// CreateOrderRequest takes each address either typed in or as the ID of a
+// saved one. Left out, they default to the user's saved defaults, and the
+// billing address falls back to the shipping address after that.
 type CreateOrderRequest struct {
-	ShippingAddress models.Address `json:"shippingAddress" binding:"required"`
-	BillingAddress  models.Address `json:"billingAddress" binding:"required"`
-	PaymentMethod   string         `json:"paymentMethod" binding:"required"`
+	ShippingAddress   *models.Address `json:"shippingAddress"`
+	BillingAddress    *models.Address `json:"billingAddress"`
+	ShippingAddressID string          `json:"shippingAddressId"`
+	BillingAddressID  string          `json:"billingAddressId"`
+	PaymentMethod     string          `json:"paymentMethod" binding:"required"`
 }
 
 type CreatePaymentIntentRequest struct {
@@ -34,13 +45,15 @@ type ConfirmPaymentRequest struct {
 	OrderID         string `json:"orderId" binding:"required"`
 }
 
-func NewOrderHandler(db *gorm.DB, config *config.Config) *OrderHandler {
+func NewOrderHandler(db *gorm.DB, config *config.Config, stockAlerts *alerts.StockNotifier) *OrderHandler {
 	// Set Stripe API key
 	stripe.Key = config.StripeSecretKey
 
 	return &OrderHandler{
-		db:     db,
-		config: config,
+		db:          db,
+		config:      config,
+		pricing:     pricing.NewService(db),
+		stockAlerts: stockAlerts,
 	}
 }
 
@@ -64,9 +77,25 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	// Resolve the addresses now; the order keeps its own copy of them
+	shippingAddress, err := checkoutAddress(h.db, userID, req.ShippingAddressID, req.ShippingAddress, "is_default_shipping")
+	if err != nil {
+		respondAddressError(c, "shippingAddress", err)
+		return
+	}
+	billingAddress, err := checkoutAddress(h.db, userID, req.BillingAddressID, req.BillingAddress, "is_default_billing")
+	if err == errAddressRequired {
+		billingAddress, err = shippingAddress, nil
+	}
+	if err != nil {
+		respondAddressError(c, "billingAddress", err)
+		return
+	}
+
 	// Get user's cart
 	var cart models.Cart
-	if err := h.db.Preload("Items.Product").Where("user_id = ?", userID).First(&cart).Error; err != nil {
+	query := preloadBundleItems(preloadCartItems(h.db), "Items.Product")
+	if err := query.Where("user_id = ?", userID).First(&cart).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Cart not found",
@@ -89,9 +118,49 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	for i := range cart.Items {
+		if err := cart.Items[i].Product.ResolveBundle(h.pricing.Convert); err != nil {
+			respondPricingError(c, err)
+			return
+		}
+	}
+
 	// Validate stock availability
 	for _, item := range cart.Items {
-		if !item.Product.InStock || item.Product.StockQuantity < item.Quantity {
+		if item.Product.ArchivedAt.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Product unavailable",
+				"message": fmt.Sprintf("Product %s is no longer available", item.Product.Name),
+			})
+			return
+		}
+		if item.QuoteMissing() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Quote unavailable",
+				"message": "A custom print in your cart is no longer available; remove it to check out",
+			})
+			return
+		}
+		if item.PrintQuote != nil {
+			// Printed to order, so only the quote has to be current
+			if item.PrintQuote.IsExpired() || !item.PrintQuote.Material.IsActive {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Quote expired",
+					"message": fmt.Sprintf("The quote for %s has expired; request a new one", item.PrintQuote.Model.FileName),
+				})
+				return
+			}
+			continue
+		}
+		available, err := orderableQuantity(h.db, &item.Product)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to check product availability",
+			})
+			return
+		}
+		if available < item.Quantity {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":   "Insufficient stock",
 				"message": fmt.Sprintf("Product %s is out of stock or insufficient quantity available", item.Product.Name),
@@ -100,14 +169,20 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		}
 	}
 
+	// Quote the cart in the storefront currency and lock the rate for the order
+	currency := middleware.GetCurrency(c)
+	if err := repriceCart(h.db, h.pricing, &cart, currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
 	// Calculate order totals
 	subtotal := cart.TotalAmount
-	tax := subtotal * 0.08 // 8% tax rate
-	shipping := 0.0
-	if subtotal < 100 { // Free shipping over $100
-		shipping = 9.99
+	tax, shipping, total, err := orderTotals(h.pricing, subtotal, currency, needsShipping(cart.Items))
+	if err != nil {
+		respondPricingError(c, err)
+		return
 	}
-	total := subtotal + tax + shipping
 
 	// Start transaction
 	tx := h.db.Begin()
@@ -121,14 +196,16 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	order := models.Order{
 		UserID:          userID,
 		Status:          models.OrderStatusPending,
-		ShippingAddress: req.ShippingAddress,
-		BillingAddress:  req.BillingAddress,
+		ShippingAddress: shippingAddress,
+		BillingAddress:  billingAddress,
 		PaymentMethod:   req.PaymentMethod,
 		PaymentStatus:   models.PaymentStatusPending,
 		Subtotal:        subtotal,
 		Tax:             tax,
 		Shipping:        shipping,
 		Total:           total,
+		Currency:        cart.Currency,
+		ExchangeRate:    cart.ExchangeRate,
 	}
 
 	if err := tx.Create(&order).Error; err != nil {
@@ -140,37 +217,53 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Create order items
-	for _, cartItem := range cart.Items {
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: cartItem.ProductID,
-			Quantity:  cartItem.Quantity,
-			Price:     cartItem.Price,
-			Total:     cartItem.Price * float64(cartItem.Quantity),
-		}
-
-		if err := tx.Create(&orderItem).Error; err != nil {
+	// Create order items and take them out of stock; pre-orders and
+	// backorders queue what stock cannot cover on a separate line
+	var touched []string
+	balanceDue := 0.0
+	for i := range cart.Items {
+		cartItem := &cart.Items[i]
+		balance, productIDs, err := orderLines(tx, order.ID, cartItem, cart.Currency)
+		if err != nil {
 			tx.Rollback()
+			if err == errInsufficientStock {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Insufficient stock",
+					"message": fmt.Sprintf("Product %s is out of stock or insufficient quantity available", cartItem.Product.Name),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Database error",
 				"message": "Failed to create order item",
 			})
 			return
 		}
+		balanceDue += balance
+		touched = append(touched, productIDs...)
+	}
 
-		// Update product stock
-		if err := tx.Model(&cartItem.Product).
-			Update("stock_quantity", gorm.Expr("stock_quantity - ?", cartItem.Quantity)).Error; err != nil {
+	if balanceDue > 0 {
+		order.BalanceDue = pricing.RoundAmount(balanceDue, cart.Currency)
+		if err := tx.Model(&order).Update("balance_due", order.BalanceDue).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Database error",
-				"message": "Failed to update product stock",
+				"message": "Failed to create order",
 			})
 			return
 		}
 	}
 
+	if err := syncBundlesContaining(tx, touched); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update bundle stock",
+		})
+		return
+	}
+
 	// Clear cart
 	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
 		tx.Rollback()
@@ -184,7 +277,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	// Update cart totals
 	cart.TotalAmount = 0
 	cart.TotalItems = 0
-	if err := tx.Save(&cart).Error; err != nil {
+	if err := tx.Omit("Items").Save(&cart).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -203,7 +296,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	// Load complete order with relations
-	h.db.Preload("Items.Product").Preload("User").First(&order, "id = ?", order.ID)
+	h.db.Preload("Items.Product", unscoped).Preload("User").First(&order, "id = ?", order.ID)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
@@ -226,7 +319,14 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	orderID := c.Param("id")
 
 	var order models.Order
-	query := h.db.Preload("Items.Product.Category").Preload("User")
+	query := h.db.Preload("Items.Product", unscoped).Preload("Items.Product.Category", unscoped).Preload("User").
+		Preload("PrintJobs").Preload("Shipments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("shipped_at ASC")
+	}).Preload("Shipments.Items").Preload("Shipments.Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at ASC")
+	}).Preload("Edits", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	})
 
 	// Non-admin users can only see their own orders
 	if !middleware.IsAdmin(c) {
@@ -260,7 +360,7 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	status := c.Query("status")
 
-	query := h.db.Preload("Items.Product").Preload("User").Model(&models.Order{})
+	query := h.db.Preload("Items.Product", unscoped).Preload("User").Model(&models.Order{})
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -321,6 +421,7 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	validStatuses := []string{
 		string(models.OrderStatusPending),
 		string(models.OrderStatusConfirmed),
+		string(models.OrderStatusInProduction),
 		string(models.OrderStatusProcessing),
 		string(models.OrderStatusShipped),
 		string(models.OrderStatusDelivered),
@@ -360,7 +461,29 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order.Status = models.OrderStatus(updateData.Status)
+	status := models.OrderStatus(updateData.Status)
+	if status == models.OrderStatusShipped || status == models.OrderStatusDelivered {
+		if hasPendingLines(h.db, order.ID) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Order not fulfillable",
+				"message": "The order has pre-order or backorder lines still waiting for stock",
+			})
+			return
+		}
+		if hasUnfinishedPrintJobs(h.db, order.ID) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Order not fulfillable",
+				"message": "The order has custom prints still in production",
+			})
+			return
+		}
+	}
+
+	order.Status = status
+	if status == models.OrderStatusDelivered && order.DeliveredAt == nil {
+		now := time.Now()
+		order.DeliveredAt = &now
+	}
 	if err := h.db.Save(&order).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -369,8 +492,13 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
+	// Reviews written before the order arrived become verified on delivery
+	if order.Status == models.OrderStatusDelivered {
+		markVerifiedPurchases(h.db, &order)
+	}
+
 	// Load updated order with relations
-	h.db.Preload("Items.Product").Preload("User").First(&order, "id = ?", order.ID)
+	h.db.Preload("Items.Product", unscoped).Preload("User").First(&order, "id = ?", order.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -393,7 +521,7 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	orderID := c.Param("id")
 
 	var order models.Order
-	query := h.db.Preload("Items.Product")
+	query := h.db.Preload("Items.Product", unscoped)
 
 	// Non-admin users can only cancel their own orders
 	if !middleware.IsAdmin(c) {
@@ -415,11 +543,13 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	// Check if order can be cancelled
-	if order.Status == models.OrderStatusShipped || order.Status == models.OrderStatusDelivered {
+	// Check if order can be cancelled; delivered orders go through returns
+	switch order.Status {
+	case models.OrderStatusPartiallyShipped, models.OrderStatusShipped, models.OrderStatusDelivered,
+		models.OrderStatusPartiallyReturned, models.OrderStatusReturned:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Cannot cancel order",
-			"message": "Order has already been shipped or delivered",
+			"message": "Order has already been shipped or delivered; request a return instead",
 		})
 		return
 	}
@@ -432,25 +562,20 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		return
 	}
 
+	// Prints already being made are paid for; only staff can stop them
+	if order.Status == models.OrderStatusInProduction && !middleware.IsAdmin(c) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Cannot cancel order",
+			"message": "Order is already in production",
+		})
+		return
+	}
+
 	// Start transaction
 	tx := h.db.Begin()
 
-	// Restore product stock
-	for _, item := range order.Items {
-		if err := tx.Model(&item.Product).
-			Update("stock_quantity", gorm.Expr("stock_quantity + ?", item.Quantity)).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Database error",
-				"message": "Failed to restore product stock",
-			})
-			return
-		}
-	}
-
-	// Update order status
-	order.Status = models.OrderStatusCancelled
-	if err := tx.Save(&order).Error; err != nil {
+	touched, err := cancelOrder(tx, &order)
+	if err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -467,6 +592,7 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 		})
 		return
 	}
+	h.stockAlerts.Restocked(touched...)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -475,6 +601,86 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	})
 }
 
+// cancelOrder marks an order cancelled and restores its stock, including for
+// products archived since the order, then hands the released units to
+// waiting orders, stops production of its custom prints and revokes its
+// downloads. Items must be preloaded. It returns the products whose stock changed.
+func cancelOrder(tx *gorm.DB, order *models.Order) ([]string, error) {
+	// Lines still waiting for stock, custom prints and downloads never took any
+	var touched []string
+	for i := range order.Items {
+		if order.Items[i].IsPending() || order.Items[i].LineType == models.OrderLinePrint || order.Items[i].LineType == models.OrderLineDigital {
+			continue
+		}
+		productIDs, err := releaseStock(tx, &order.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		touched = append(touched, productIDs...)
+	}
+
+	// Update order status before handing the units to waiting orders
+	order.Status = models.OrderStatusCancelled
+	if err := tx.Model(order).Update("status", order.Status).Error; err != nil {
+		return nil, err
+	}
+	if err := cancelPrintJobs(tx, order.ID); err != nil {
+		return nil, err
+	}
+	if err := revokeDownloads(tx, order.ID); err != nil {
+		return nil, err
+	}
+
+	for _, productID := range touched {
+		if _, err := allocatePendingLines(tx, productID); err != nil {
+			return nil, err
+		}
+	}
+	return touched, syncBundlesContaining(tx, touched)
+}
+
+// placeNoChargeOrder creates order as a paid, zero-cost order for quantity
+// units of a product and takes them out of stock, for replacements and
+// exchanges. Products no longer sold or without stock fail with
+// errInsufficientStock.
+func placeNoChargeOrder(tx *gorm.DB, order *models.Order, productID string, quantity int) error {
+	var product models.Product
+	if err := tx.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errInsufficientStock
+		}
+		return err
+	}
+	if err := loadBundle(tx, &product); err != nil {
+		return err
+	}
+	available, err := orderableQuantity(tx, &product)
+	if err != nil {
+		return err
+	}
+	if available < quantity {
+		return errInsufficientStock
+	}
+
+	order.Status = models.OrderStatusConfirmed
+	order.PaymentStatus = models.PaymentStatusPaid
+	order.Currency = pricing.BaseCurrency
+	order.ExchangeRate = 1
+	if err := tx.Create(order).Error; err != nil {
+		return err
+	}
+
+	_, touched, err := orderLines(tx, order.ID, &models.CartItem{
+		ProductID: product.ID,
+		Product:   product,
+		Quantity:  quantity,
+	}, order.Currency)
+	if err != nil {
+		return err
+	}
+	return syncBundlesContaining(tx, touched)
+}
+
 // CreatePaymentIntent creates a Stripe PaymentIntent for an order
 func (h *OrderHandler) CreatePaymentIntent(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -521,10 +727,19 @@ func (h *OrderHandler) CreatePaymentIntent(c *gin.Context) {
 		return
 	}
 
-	// Create Stripe PaymentIntent
+	if order.PaymentStatus == models.PaymentStatusDeposit && hasPendingLines(h.db, order.ID) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Balance not due",
+			"message": "The balance is due once your pre-ordered items are in stock",
+		})
+		return
+	}
+	amount, currency := amountDue(&order)
+
+	// Create Stripe PaymentIntent in the currency locked at checkout
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(int64(order.Total * 100)), // Convert to cents
-		Currency: stripe.String("usd"),
+		Amount:   stripe.Int64(pricing.ToMinorUnits(amount, currency)),
+		Currency: stripe.String(strings.ToLower(currency)),
 	}
 	params.AddMetadata("order_id", order.ID)
 	params.AddMetadata("user_id", userID)
@@ -588,6 +803,22 @@ func (h *OrderHandler) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Order already paid",
+			"message": "This order has already been paid",
+		})
+		return
+	}
+	// Only the intent created for the order's current payment may settle it
+	if order.PaymentIntentID == "" || req.PaymentIntentID != order.PaymentIntentID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Payment verification failed",
+			"message": "The payment does not belong to this order",
+		})
+		return
+	}
+
 	// Verify PaymentIntent with Stripe
 	pi, err := paymentintent.Get(req.PaymentIntentID, nil)
 	if err != nil {
@@ -606,9 +837,27 @@ func (h *OrderHandler) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
-	// Update order payment status
-	order.PaymentStatus = models.PaymentStatusPaid
-	order.Status = models.OrderStatusConfirmed
+	amount, currency := amountDue(&order)
+	if pi.Amount != pricing.ToMinorUnits(amount, currency) || !strings.EqualFold(string(pi.Currency), currency) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Payment verification failed",
+			"message": "The payment does not match the amount due on this order",
+		})
+		return
+	}
+
+	// Update order payment status; a first payment on an order with a
+	// balance only covers the deposit
+	if order.PaymentStatus != models.PaymentStatusDeposit && order.BalanceDue > 0 {
+		order.PaymentStatus = models.PaymentStatusDeposit
+	} else {
+		order.PaymentStatus = models.PaymentStatusPaid
+		order.BalanceDue = 0
+	}
+	// Orders staff already moved on keep their status
+	if order.Status == models.OrderStatusPending {
+		order.Status = models.OrderStatusConfirmed
+	}
 	if err := h.db.Save(&order).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
@@ -617,9 +866,58 @@ func (h *OrderHandler) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
+	// Digital products can be downloaded once the order is paid in full
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		if err := grantDownloads(h.db, &order); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to grant downloads",
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Payment confirmed successfully",
 		"data":    order,
 	})
 }
+
+// amountDue returns what the order's next payment covers and its currency.
+// Pre-orders with a deposit are paid in two steps: everything but the
+// balance at checkout, the balance once the waiting lines have stock.
+func amountDue(order *models.Order) (float64, string) {
+	currency := order.Currency
+	if currency == "" {
+		currency = pricing.BaseCurrency
+	}
+	if order.PaymentStatus == models.PaymentStatusDeposit {
+		return order.BalanceDue, currency
+	}
+	return order.Total - order.BalanceDue, currency
+}
+
+// GetPendingLines lists pre-order and backorder lines waiting for stock in
+// allocation order, optionally for one product (admin only)
+func (h *OrderHandler) GetPendingLines(c *gin.Context) {
+	query := pendingLines(h.db).Preload("Order")
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("order_items.product_id = ?", productID)
+	}
+
+	var lines []models.OrderItem
+	if err := query.Order("orders.created_at ASC").Order("order_items.created_at ASC").
+		Select("order_items.*").Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch pending order lines",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    lines,
+	})
+}
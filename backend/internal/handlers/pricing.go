@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// presentProducts converts product prices into the storefront currency.
+// It writes an error response and returns false when conversion fails.
+func presentProducts(c *gin.Context, svc *pricing.Service, products []models.Product) bool {
+	if err := svc.PresentProducts(products, middleware.GetCurrency(c)); err != nil {
+		respondPricingError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondPricingError maps pricing errors onto API responses
+func respondPricingError(c *gin.Context, err error) {
+	if errors.Is(err, pricing.ErrCurrencyUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Currency unavailable",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Pricing error",
+		"message": "Failed to resolve prices",
+	})
+}
+
+// preloadCartItems loads a cart's items with their products and, for custom
+// prints, the quote with its model and material
+func preloadCartItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items.Product", unscoped).Preload("Items.Product.Category", unscoped).
+		Preload("Items.PrintQuote.Model").Preload("Items.PrintQuote.Material")
+}
+
+// repriceCart quotes every cart item in currency, locks the rate on the cart
+// and recalculates totals. Items must be loaded with preloadCartItems; custom
+// prints whose quote is gone are marked unavailable and left out.
+func repriceCart(db *gorm.DB, svc *pricing.Service, cart *models.Cart, currency string) error {
+	rate, err := svc.Rate(currency)
+	if err != nil {
+		return err
+	}
+
+	var totalAmount float64
+	var totalItems int
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		if item.QuoteMissing() {
+			item.Unavailable = true
+			continue
+		}
+		price, err := cartItemPrice(svc, item, currency)
+		if err != nil {
+			return err
+		}
+		if item.Price != price {
+			item.Price = price
+			if err := db.Model(item).Update("price", price).Error; err != nil {
+				return err
+			}
+		}
+		totalAmount += item.Price * float64(item.Quantity)
+		totalItems += item.Quantity
+	}
+
+	cart.Currency = currency
+	cart.ExchangeRate = rate.Rate
+	cart.TotalAmount = pricing.RoundAmount(totalAmount, currency)
+	cart.TotalItems = totalItems
+
+	return db.Model(cart).Updates(map[string]interface{}{
+		"currency":      cart.Currency,
+		"exchange_rate": cart.ExchangeRate,
+		"total_amount":  cart.TotalAmount,
+		"total_items":   cart.TotalItems,
+	}).Error
+}
+
+// cartItemPrice quotes a cart item in currency. Custom prints are priced
+// by their print quote rather than by the material product.
+func cartItemPrice(svc *pricing.Service, item *models.CartItem, currency string) (float64, error) {
+	if item.PrintQuote != nil {
+		price, err := svc.Convert(item.PrintQuote.UnitPrice, item.PrintQuote.Currency, currency)
+		if err != nil {
+			return 0, err
+		}
+		return pricing.RoundAmount(price, currency), nil
+	}
+	price, _, err := svc.Quote(&item.Product, currency)
+	return price, err
+}
+
+// orderTotals works out tax, shipping and the grand total of an order
+// subtotal in currency. Orders with nothing to ship pay no shipping.
+func orderTotals(svc *pricing.Service, subtotal float64, currency string, shipped bool) (tax, shipping, total float64, err error) {
+	freeShippingThreshold, err := svc.Convert(100, pricing.BaseCurrency, currency)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	flatShipping, err := svc.Convert(9.99, pricing.BaseCurrency, currency)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	tax = pricing.RoundAmount(subtotal*0.08, currency) // 8% tax rate
+	if shipped && subtotal < freeShippingThreshold {
+		// Free shipping over $100
+		shipping = flatShipping
+	}
+	total = pricing.RoundAmount(subtotal+tax+shipping, currency)
+	return tax, shipping, total, nil
+}
+
+// needsShipping reports whether any cart item is a physical good. Custom
+// prints count as their material, which is never digital.
+func needsShipping(items []models.CartItem) bool {
+	for i := range items {
+		if !items[i].Product.IsDigital {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,752 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/mesh"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"bizoe-3d-store/internal/printing"
+	"bizoe-3d-store/internal/storage"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxPrintModelSize = 64 << 20
+
+type PrintHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+	pricing *pricing.Service
+}
+
+type PrintMaterialRequest struct {
+	ProductID  string  `json:"productId" binding:"required"`
+	Name       string  `json:"name" binding:"required,max=100"`
+	CostPerCm3 float64 `json:"costPerCm3" binding:"required,gt=0"`
+	IsActive   *bool   `json:"isActive"`
+}
+
+type CreatePrintQuoteRequest struct {
+	ModelID    string `json:"modelId" binding:"required"`
+	MaterialID string `json:"materialId" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,min=1,max=100"`
+}
+
+func NewPrintHandler(db *gorm.DB, store storage.Storage) *PrintHandler {
+	return &PrintHandler{db: db, storage: store, pricing: pricing.NewService(db)}
+}
+
+// GetPrintMaterials lists the materials the print service prints with
+func (h *PrintHandler) GetPrintMaterials(c *gin.Context) {
+	var materials []models.PrintMaterial
+	if err := h.db.Preload("Product").
+		Joins("JOIN products ON products.id = print_materials.product_id AND products.deleted_at IS NULL").
+		Where("print_materials.is_active = ?", true).
+		Order("print_materials.name ASC").
+		Find(&materials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch print materials",
+		})
+		return
+	}
+
+	currency := middleware.GetCurrency(c)
+	for i := range materials {
+		cost, err := h.pricing.Convert(materials[i].CostPerCm3, pricing.BaseCurrency, currency)
+		if err != nil {
+			respondPricingError(c, err)
+			return
+		}
+		materials[i].CostPerCm3 = cost
+		materials[i].Currency = currency
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    materials,
+	})
+}
+
+// UploadPrintModel accepts an STL or OBJ file and analyses its mesh
+func (h *PrintHandler) UploadPrintModel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPrintModelSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "An STL or OBJ file is required in the \"file\" field",
+		})
+		return
+	}
+	if file.Size > maxPrintModelSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "File too large",
+			"message": "Models must be 64 MB or smaller",
+		})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+
+	fileName := path.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+	parsed, err := mesh.Parse(fileName, data)
+	if err != nil {
+		if errors.Is(err, mesh.ErrUnsupportedFormat) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "Unsupported file type",
+				"message": "Only STL (binary or ASCII) and OBJ files are accepted",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid mesh",
+			"message": err.Error(),
+		})
+		return
+	}
+	analysis := parsed.Analyze()
+	size := analysis.Size()
+
+	model := models.PrintModel{
+		ID:               uuid.New().String(),
+		UserID:           userID,
+		FileName:         fileName,
+		Format:           string(parsed.Format),
+		FileSize:         file.Size,
+		Triangles:        analysis.Triangles,
+		Watertight:       analysis.Watertight,
+		BoundaryEdges:    analysis.BoundaryEdges,
+		NonManifoldEdges: analysis.NonManifoldEdges,
+		// Models are in millimetres
+		VolumeCm3:      math.Round(analysis.Volume/1000*100) / 100,
+		SurfaceAreaCm2: math.Round(analysis.SurfaceArea/100*100) / 100,
+		SizeX:          math.Round(size[0]*100) / 100,
+		SizeY:          math.Round(size[1]*100) / 100,
+		SizeZ:          math.Round(size[2]*100) / 100,
+	}
+	model.StorageKey = fmt.Sprintf("print-models/%s/%s%s", userID, model.ID, strings.ToLower(path.Ext(fileName)))
+
+	if err := h.storage.Put(model.StorageKey, bytes.NewReader(data), "application/octet-stream"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": "Failed to store model",
+		})
+		return
+	}
+	if err := h.db.Create(&model).Error; err != nil {
+		h.storage.Delete(model.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save model",
+		})
+		return
+	}
+
+	message := "Model uploaded successfully"
+	if !model.Watertight {
+		message = "Model uploaded, but it is not watertight and cannot be quoted until the holes are repaired"
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": message,
+		"data":    model,
+	})
+}
+
+// GetPrintModels lists the user's uploaded models
+func (h *PrintHandler) GetPrintModels(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var printModels []models.PrintModel
+	if err := h.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&printModels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch models",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    printModels,
+	})
+}
+
+// GetPrintModel returns one of the user's uploaded models
+func (h *PrintHandler) GetPrintModel(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var model models.PrintModel
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&model).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Model not found",
+				"message": "The requested model does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch model",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    model,
+	})
+}
+
+// CreatePrintQuote prices printing one of the user's models in a material
+func (h *PrintHandler) CreatePrintQuote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req CreatePrintQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var model models.PrintModel
+	if err := h.db.Where("id = ? AND user_id = ?", req.ModelID, userID).First(&model).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Model not found",
+				"message": "The requested model does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch model",
+		})
+		return
+	}
+	if !model.Watertight {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Model not printable",
+			"message": fmt.Sprintf("The mesh is not watertight (%d open and %d non-manifold edges); repair it and upload it again", model.BoundaryEdges, model.NonManifoldEdges),
+		})
+		return
+	}
+
+	var material models.PrintMaterial
+	if err := h.db.Preload("Product").Where("id = ? AND is_active = ?", req.MaterialID, true).First(&material).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Material not found",
+				"message": "The requested material is not available",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch material",
+		})
+		return
+	}
+
+	estimate := printing.EstimatePart(model.VolumeCm3, model.SizeZ, material.CostPerCm3)
+	quote := models.PrintQuote{
+		UserID:       userID,
+		ModelID:      model.ID,
+		MaterialID:   material.ID,
+		Quantity:     req.Quantity,
+		MaterialCm3:  estimate.MaterialCm3,
+		MaterialCost: estimate.MaterialCost,
+		PrintHours:   estimate.PrintHours,
+		MachineCost:  estimate.MachineCost,
+		UnitPrice:    estimate.UnitPrice,
+		Total:        pricing.RoundAmount(estimate.UnitPrice*float64(req.Quantity), pricing.BaseCurrency),
+		Currency:     pricing.BaseCurrency,
+		ExpiresAt:    time.Now().Add(printing.QuoteValidity),
+	}
+	if err := h.db.Create(&quote).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save quote",
+		})
+		return
+	}
+	quote.Model = model
+	quote.Material = material
+
+	if err := h.presentQuote(&quote, middleware.GetCurrency(c)); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Quote created successfully",
+		"data":    quote,
+	})
+}
+
+// GetPrintQuote returns one of the user's quotes
+func (h *PrintHandler) GetPrintQuote(c *gin.Context) {
+	quote, ok := h.findQuote(c)
+	if !ok {
+		return
+	}
+
+	if err := h.presentQuote(&quote, middleware.GetCurrency(c)); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quote,
+	})
+}
+
+// AddQuoteToCart puts a quoted print in the cart as a custom line item
+func (h *PrintHandler) AddQuoteToCart(c *gin.Context) {
+	quote, ok := h.findQuote(c)
+	if !ok {
+		return
+	}
+	if quote.IsExpired() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Quote expired",
+			"message": "This quote has expired; request a new one",
+		})
+		return
+	}
+	if !quote.Material.IsActive || quote.Material.Product.ArchivedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Material unavailable",
+			"message": "The quoted material is no longer available; request a new quote",
+		})
+		return
+	}
+
+	currency := middleware.GetCurrency(c)
+	cart, err := userCart(h.db, quote.UserID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch cart",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.CartItem{}).Where("cart_id = ? AND print_quote_id = ?", cart.ID, quote.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Already in cart",
+			"message": "This quote is already in your cart",
+		})
+		return
+	}
+
+	item := models.CartItem{
+		CartID:       cart.ID,
+		ProductID:    quote.Material.ProductID,
+		Quantity:     quote.Quantity,
+		PrintQuoteID: &quote.ID,
+		PrintQuote:   &quote,
+	}
+	if item.Price, err = cartItemPrice(h.pricing, &item, currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+	if err := h.db.Omit("PrintQuote").Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to add item to cart",
+		})
+		return
+	}
+
+	h.respondWithCart(c, &cart, currency, "Print added to cart successfully")
+}
+
+// RemoveQuoteFromCart takes a quoted print out of the cart. It works from
+// the cart line alone, so prints whose quote is gone can be removed too.
+func (h *PrintHandler) RemoveQuoteFromCart(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	currency := middleware.GetCurrency(c)
+	cart, err := userCart(h.db, userID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch cart",
+		})
+		return
+	}
+
+	result := h.db.Where("cart_id = ? AND print_quote_id = ?", cart.ID, c.Param("id")).Delete(&models.CartItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove item from cart",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Item not found",
+			"message": "Item not found in cart",
+		})
+		return
+	}
+
+	h.respondWithCart(c, &cart, currency, "Print removed from cart successfully")
+}
+
+// GetAllPrintMaterials lists every print material, including inactive ones (admin only)
+func (h *PrintHandler) GetAllPrintMaterials(c *gin.Context) {
+	var materials []models.PrintMaterial
+	if err := h.db.Preload("Product", unscoped).Order("name ASC").Find(&materials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch print materials",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    materials,
+	})
+}
+
+// CreatePrintMaterial links a resin product to the print service (admin only)
+func (h *PrintHandler) CreatePrintMaterial(c *gin.Context) {
+	var req PrintMaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", req.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.PrintMaterial{}).Where("product_id = ?", product.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Material exists",
+			"message": "This product is already a print material",
+		})
+		return
+	}
+
+	material := models.PrintMaterial{
+		ProductID:  product.ID,
+		Name:       req.Name,
+		CostPerCm3: req.CostPerCm3,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+	}
+	if err := h.db.Create(&material).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create print material",
+		})
+		return
+	}
+	material.Product = product
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Print material created successfully",
+		"data":    material,
+	})
+}
+
+// UpdatePrintMaterial changes a print material's name, price or availability.
+// Existing quotes keep their price (admin only).
+func (h *PrintHandler) UpdatePrintMaterial(c *gin.Context) {
+	var req PrintMaterialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var material models.PrintMaterial
+	if err := h.db.First(&material, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Material not found",
+				"message": "The requested material does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch material",
+		})
+		return
+	}
+	if req.ProductID != material.ProductID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "The product of a print material cannot be changed",
+		})
+		return
+	}
+
+	material.Name = req.Name
+	material.CostPerCm3 = req.CostPerCm3
+	if req.IsActive != nil {
+		material.IsActive = *req.IsActive
+	}
+	if err := h.db.Model(&material).Select("name", "cost_per_cm3", "is_active").Updates(&material).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update print material",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Print material updated successfully",
+		"data":    material,
+	})
+}
+
+// DeletePrintMaterial removes a print material that was never quoted; quoted
+// materials are deactivated instead (admin only)
+func (h *PrintHandler) DeletePrintMaterial(c *gin.Context) {
+	var count int64
+	h.db.Model(&models.PrintQuote{}).Where("material_id = ?", c.Param("id")).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Material in use",
+			"message": "This material has been quoted; deactivate it instead",
+		})
+		return
+	}
+
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.PrintMaterial{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete print material",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Material not found",
+			"message": "The requested material does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Print material deleted successfully",
+	})
+}
+
+// findQuote loads a quote of the current user by the id route parameter. It
+// writes an error response and returns false when that fails.
+func (h *PrintHandler) findQuote(c *gin.Context) (models.PrintQuote, bool) {
+	var quote models.PrintQuote
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return quote, false
+	}
+
+	if err := h.db.Preload("Model").Preload("Material.Product", unscoped).
+		Where("id = ? AND user_id = ?", c.Param("id"), userID).
+		First(&quote).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Quote not found",
+				"message": "The requested quote does not exist",
+			})
+			return quote, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch quote",
+		})
+		return quote, false
+	}
+	return quote, true
+}
+
+// presentQuote converts the quote's amounts into currency for display, by
+// the same rules as any cart price so the unit price matches what is charged
+func (h *PrintHandler) presentQuote(quote *models.PrintQuote, currency string) error {
+	if quote.Currency == currency {
+		return nil
+	}
+	unitPrice, err := h.pricing.Convert(quote.UnitPrice, quote.Currency, currency)
+	if err != nil {
+		return err
+	}
+	for _, amount := range []*float64{&quote.MaterialCost, &quote.MachineCost} {
+		converted, err := h.pricing.Convert(*amount, quote.Currency, currency)
+		if err != nil {
+			return err
+		}
+		*amount = converted
+	}
+	quote.UnitPrice = unitPrice
+	quote.Total = pricing.RoundAmount(unitPrice*float64(quote.Quantity), currency)
+	quote.Currency = currency
+	return nil
+}
+
+// respondWithCart re-quotes the cart after a change and writes it as the response
+func (h *PrintHandler) respondWithCart(c *gin.Context, cart *models.Cart, currency, message string) {
+	preloadCartItems(h.db).First(cart, "id = ?", cart.ID)
+	if err := repriceCart(h.db, h.pricing, cart, currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data":    cart,
+	})
+}
+
+// createPrintLine adds the order line for a custom print cart item and
+// queues the print for production. The line keeps the material as its
+// product and snapshots what is being printed.
+func createPrintLine(tx *gorm.DB, orderID string, cartItem *models.CartItem) error {
+	quote := cartItem.PrintQuote
+	now := time.Now()
+	line := models.OrderItem{
+		OrderID:      orderID,
+		ProductID:    cartItem.ProductID,
+		Quantity:     cartItem.Quantity,
+		Price:        cartItem.Price,
+		LineType:     models.OrderLinePrint,
+		PrintQuoteID: &quote.ID,
+		AllocatedAt:  &now, // Nothing to wait for; printed once the order is confirmed
+
+		ProductName: "Custom print: " + quote.Model.FileName,
+		ProductSKU:  "PRINT-" + strings.ToUpper(quote.ID[:8]),
+		ProductSpecifications: map[string]string{
+			"material":   quote.Material.Name,
+			"volume":     fmt.Sprintf("%.2f cm³", quote.Model.VolumeCm3),
+			"dimensions": fmt.Sprintf("%.1f × %.1f × %.1f mm", quote.Model.SizeX, quote.Model.SizeY, quote.Model.SizeZ),
+			"printTime":  fmt.Sprintf("%.2f h", quote.PrintHours),
+		},
+	}
+	if err := tx.Create(&line).Error; err != nil {
+		return err
+	}
+
+	job := models.PrintJob{
+		OrderID:      orderID,
+		OrderItemID:  line.ID,
+		PrintQuoteID: quote.ID,
+		ModelID:      quote.ModelID,
+		MaterialID:   quote.MaterialID,
+		Quantity:     line.Quantity,
+		SizeX:        quote.Model.SizeX,
+		SizeY:        quote.Model.SizeY,
+		SizeZ:        quote.Model.SizeZ,
+		Status:       models.PrintJobQueued,
+	}
+	if err := tx.Create(&job).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.PrintJobEvent{JobID: job.ID, To: models.PrintJobQueued, Note: "Order placed"}).Error
+}
@@ -0,0 +1,691 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errJobChanged is returned when a print job changed state while it was
+// being moved
+var errJobChanged = errors.New("print job changed state")
+
+type ProductionHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+
+	// mu serialises scheduling and state changes so two printers are never
+	// handed the same job
+	mu sync.Mutex
+
+	watchersMu sync.Mutex
+	watchers   map[chan struct{}]struct{}
+}
+
+type PrinterRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	BuildX      float64  `json:"buildX" binding:"required,gt=0"`
+	BuildY      float64  `json:"buildY" binding:"required,gt=0"`
+	BuildZ      float64  `json:"buildZ" binding:"required,gt=0"`
+	MaterialIDs []string `json:"materialIds"`
+	IsActive    *bool    `json:"isActive"`
+}
+
+type UpdatePrintJobStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
+type AssignPrintJobRequest struct {
+	PrinterID string `json:"printerId" binding:"required"`
+}
+
+func NewProductionHandler(db *gorm.DB, store storage.Storage) *ProductionHandler {
+	return &ProductionHandler{db: db, storage: store, watchers: make(map[chan struct{}]struct{})}
+}
+
+// GetPrinters lists the printers on the shop floor (admin only)
+func (h *ProductionHandler) GetPrinters(c *gin.Context) {
+	var printers []models.Printer
+	if err := h.db.Order("name ASC").Find(&printers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch printers",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    printers,
+	})
+}
+
+// CreatePrinter adds a printer to the shop floor (admin only)
+func (h *ProductionHandler) CreatePrinter(c *gin.Context) {
+	var req PrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	materialIDs, ok := h.validMaterials(c, req.MaterialIDs)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Printer{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Printer exists",
+			"message": "A printer with this name already exists",
+		})
+		return
+	}
+
+	printer := models.Printer{
+		Name:        req.Name,
+		BuildX:      req.BuildX,
+		BuildY:      req.BuildY,
+		BuildZ:      req.BuildZ,
+		MaterialIDs: materialIDs,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	if err := h.db.Create(&printer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create printer",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	assignPrintJobs(h.db)
+	h.mu.Unlock()
+	h.notify()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Printer created successfully",
+		"data":    printer,
+	})
+}
+
+// UpdatePrinter changes a printer's build volume, materials or availability.
+// Jobs waiting on it that it can no longer take are scheduled again; jobs
+// already started stay (admin only).
+func (h *ProductionHandler) UpdatePrinter(c *gin.Context) {
+	var req PrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	materialIDs, ok := h.validMaterials(c, req.MaterialIDs)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var printer models.Printer
+	if err := h.db.First(&printer, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Printer not found",
+				"message": "The requested printer does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch printer",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Printer{}).Where("name = ? AND id <> ?", req.Name, printer.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Printer exists",
+			"message": "A printer with this name already exists",
+		})
+		return
+	}
+
+	printer.Name = req.Name
+	printer.BuildX = req.BuildX
+	printer.BuildY = req.BuildY
+	printer.BuildZ = req.BuildZ
+	printer.MaterialIDs = materialIDs
+	if req.IsActive != nil {
+		printer.IsActive = *req.IsActive
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&printer).
+			Select("name", "build_x", "build_y", "build_z", "material_ids", "is_active").
+			Updates(&printer).Error; err != nil {
+			return err
+		}
+		return releasePrinterJobs(tx, &printer)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update printer",
+		})
+		return
+	}
+	assignPrintJobs(h.db)
+	h.notify()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Printer updated successfully",
+		"data":    printer,
+	})
+}
+
+// DeletePrinter removes a printer that never had a job; printers with a
+// history are deactivated instead (admin only)
+func (h *ProductionHandler) DeletePrinter(c *gin.Context) {
+	var count int64
+	h.db.Model(&models.PrintJob{}).Where("printer_id = ?", c.Param("id")).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Printer in use",
+			"message": "This printer has print jobs; deactivate it instead",
+		})
+		return
+	}
+
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.Printer{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete printer",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Printer not found",
+			"message": "The requested printer does not exist",
+		})
+		return
+	}
+	h.notify()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Printer deleted successfully",
+	})
+}
+
+// GetPrintJobs lists print jobs, optionally by status, printer or order (admin only)
+func (h *ProductionHandler) GetPrintJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.PrintJob{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if printerID := c.Query("printerId"); printerID != "" {
+		query = query.Where("printer_id = ?", printerID)
+	}
+	if orderID := c.Query("orderId"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count print jobs",
+		})
+		return
+	}
+
+	var jobs []models.PrintJob
+	if err := query.Preload("Order").Preload("Printer").Preload("Model").Preload("Material").
+		Order("created_at DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch print jobs",
+		})
+		return
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    jobs,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// GetPrintJob returns a print job with its history (admin only)
+func (h *ProductionHandler) GetPrintJob(c *gin.Context) {
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// DownloadPrintJobModel downloads the mesh to slice for a print job (admin only)
+func (h *ProductionHandler) DownloadPrintJobModel(c *gin.Context) {
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.storage.Open(job.Model.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found",
+			"message": "The model file is no longer available",
+		})
+		return
+	}
+	defer file.Close()
+
+	c.DataFromReader(http.StatusOK, job.Model.FileSize, "application/octet-stream", file, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", job.Model.FileName),
+	})
+}
+
+// UpdatePrintJobStatus moves a print job to its next production state and
+// advances the order with it (admin only)
+func (h *ProductionHandler) UpdatePrintJobStatus(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req UpdatePrintJobStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	next := models.PrintJobStatus(req.Status)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+
+	if !job.Status.CanMoveTo(next) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Invalid transition",
+			"message": fmt.Sprintf("A print job cannot move from %s to %s", job.Status, req.Status),
+		})
+		return
+	}
+	if job.Status == models.PrintJobQueued {
+		if job.Order != nil && job.Order.Status == models.OrderStatusPending {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Awaiting payment",
+				"message": "Production starts once the order is paid",
+			})
+			return
+		}
+		if job.PrinterID == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "No printer",
+				"message": "Assign the job to a printer before slicing it",
+			})
+			return
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return moveJob(tx, &job, next, userID, req.Note)
+	})
+	if err != nil {
+		if errors.Is(err, errJobChanged) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Job changed",
+				"message": "The print job was changed or cancelled meanwhile; reload it",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update print job",
+		})
+		return
+	}
+
+	// A finished or failed print frees its printer for the next job
+	assignPrintJobs(h.db)
+	h.notify()
+
+	job, _ = h.findJob(c)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Print job updated successfully",
+		"data":    job,
+	})
+}
+
+// AssignPrintJob puts a job that has not started on a chosen printer
+// instead of the one the scheduler picked (admin only)
+func (h *ProductionHandler) AssignPrintJob(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req AssignPrintJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	job, ok := h.findJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.PrintJobQueued {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Job started",
+			"message": "Only queued jobs can be moved to another printer",
+		})
+		return
+	}
+
+	var printer models.Printer
+	if err := h.db.Where("id = ? AND is_active = ?", req.PrinterID, true).First(&printer).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Printer not found",
+				"message": "The requested printer does not exist or is inactive",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch printer",
+		})
+		return
+	}
+	if !printer.Supports(job.MaterialID) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Material not supported",
+			"message": "This printer does not run the job's material",
+		})
+		return
+	}
+	if !printer.Fits(job.SizeX, job.SizeY, job.SizeZ) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Part too large",
+			"message": fmt.Sprintf("A %.1f × %.1f × %.1f mm part does not fit the build volume", job.SizeX, job.SizeY, job.SizeZ),
+		})
+		return
+	}
+
+	busy, err := busyPrinters(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch printers",
+		})
+		return
+	}
+	if busy[printer.ID] && (job.PrinterID == nil || *job.PrinterID != printer.ID) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Printer busy",
+			"message": "This printer already has a job",
+		})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PrintJob{}).Where("id = ? AND status = ?", job.ID, models.PrintJobQueued).
+			Update("printer_id", printer.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errJobChanged
+		}
+		return tx.Create(&models.PrintJobEvent{
+			JobID:     job.ID,
+			From:      models.PrintJobQueued,
+			To:        models.PrintJobQueued,
+			PrinterID: &printer.ID,
+			Note:      "Moved to " + printer.Name,
+			ActorID:   userID,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, errJobChanged) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Job changed",
+				"message": "The print job was changed or cancelled meanwhile; reload it",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to assign print job",
+		})
+		return
+	}
+
+	// The printer the job left may take another one
+	assignPrintJobs(h.db)
+	h.notify()
+
+	job, _ = h.findJob(c)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Print job assigned successfully",
+		"data":    job,
+	})
+}
+
+// RunAssignment assigns waiting print jobs to free printers straight away
+// instead of waiting for the scheduler (admin only)
+func (h *ProductionHandler) RunAssignment(c *gin.Context) {
+	assigned, err := h.AssignJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to assign print jobs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Assigned %d print jobs", assigned),
+		"data":    gin.H{"assigned": assigned},
+	})
+}
+
+// GetProductionQueue returns a snapshot of printers, their jobs and the
+// jobs waiting for one (admin only)
+func (h *ProductionHandler) GetProductionQueue(c *gin.Context) {
+	queue, err := loadProductionQueue(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch production queue",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    queue,
+	})
+}
+
+// StreamProductionQueue streams the production queue as server-sent events,
+// sending a new snapshot whenever the shop floor changes (admin only)
+func (h *ProductionHandler) StreamProductionQueue(c *gin.Context) {
+	updates := h.watch()
+	defer h.unwatch(updates)
+
+	ticker := time.NewTicker(productionQueueRefresh)
+	defer ticker.Stop()
+
+	send := func() bool {
+		queue, err := loadProductionQueue(h.db)
+		if err != nil {
+			c.SSEvent("error", gin.H{"message": "Failed to fetch production queue"})
+			return false
+		}
+		c.SSEvent("queue", queue)
+		c.Writer.Flush()
+		return true
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	if !send() {
+		return
+	}
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-updates:
+		case <-ticker.C:
+		}
+		if !send() {
+			return
+		}
+	}
+}
+
+// findJob loads a print job with its order, printer, model, material and
+// history by the id route parameter. It writes an error response and
+// returns false when that fails.
+func (h *ProductionHandler) findJob(c *gin.Context) (models.PrintJob, bool) {
+	var job models.PrintJob
+	if err := h.db.Preload("Order").Preload("Printer").Preload("Model").Preload("Material").
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&job, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Print job not found",
+				"message": "The requested print job does not exist",
+			})
+			return job, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch print job",
+		})
+		return job, false
+	}
+	return job, true
+}
+
+// validMaterials checks that every id names a print material and returns
+// them without duplicates. It writes an error response and returns false
+// when one does not.
+func (h *ProductionHandler) validMaterials(c *gin.Context, ids []string) ([]string, bool) {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, true
+	}
+
+	var count int64
+	if err := h.db.Model(&models.PrintMaterial{}).Where("id IN ?", unique).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch print materials",
+		})
+		return nil, false
+	}
+	if int(count) != len(unique) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "One or more print materials do not exist",
+		})
+		return nil, false
+	}
+	return unique, true
+}
+
+// watch registers a live queue view for change notifications
+func (h *ProductionHandler) watch() chan struct{} {
+	updates := make(chan struct{}, 1)
+	h.watchersMu.Lock()
+	h.watchers[updates] = struct{}{}
+	h.watchersMu.Unlock()
+	return updates
+}
+
+func (h *ProductionHandler) unwatch(updates chan struct{}) {
+	h.watchersMu.Lock()
+	delete(h.watchers, updates)
+	h.watchersMu.Unlock()
+}
+
+// notify tells live queue views that the shop floor changed. Views that
+// already have a change pending are skipped.
+func (h *ProductionHandler) notify() {
+	h.watchersMu.Lock()
+	defer h.watchersMu.Unlock()
+	for updates := range h.watchers {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
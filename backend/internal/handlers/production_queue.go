@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// productionQueueRefresh is how often the live queue view is sent even when
+// nothing on the shop floor changed, so changes made elsewhere (orders paid
+// or cancelled) show up too
+const productionQueueRefresh = 15 * time.Second
+
+// activePrintJobStatuses are the states of jobs still on the shop floor
+var activePrintJobStatuses = []models.PrintJobStatus{
+	models.PrintJobQueued,
+	models.PrintJobSlicing,
+	models.PrintJobPrinting,
+	models.PrintJobPostProcessing,
+	models.PrintJobQC,
+}
+
+// printerQueue is a printer with the jobs holding it
+type printerQueue struct {
+	models.Printer
+	Jobs []models.PrintJob `json:"jobs"`
+}
+
+// productionQueue is a snapshot of the shop floor
+type productionQueue struct {
+	Printers  []printerQueue                `json:"printers"`
+	Waiting   []models.PrintJob             `json:"waiting"`   // Not assigned to a printer yet
+	Finishing []models.PrintJob             `json:"finishing"` // Post-processing and QC
+	Counts    map[models.PrintJobStatus]int `json:"counts"`
+	UpdatedAt time.Time                     `json:"updatedAt"`
+}
+
+// AssignJobs hands waiting print jobs to free printers and returns how many
+// were assigned. It is scheduled as a background job.
+func (h *ProductionHandler) AssignJobs() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	assigned, err := assignPrintJobs(h.db)
+	if assigned > 0 {
+		h.notify()
+	}
+	return assigned, err
+}
+
+// assignPrintJobs gives each waiting job of a paid order, oldest order first,
+// the smallest free printer that runs its material and fits the part. A
+// printer takes one job at a time until the print comes off the plate.
+func assignPrintJobs(db *gorm.DB) (int, error) {
+	var printers []models.Printer
+	if err := db.Where("is_active = ?", true).Order("build_x * build_y * build_z ASC").Order("name ASC").
+		Find(&printers).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch printers: %w", err)
+	}
+	busy, err := busyPrinters(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var waiting []models.PrintJob
+	if err := db.Joins("JOIN orders ON orders.id = print_jobs.order_id").
+		Where("print_jobs.status = ? AND print_jobs.printer_id IS NULL AND orders.status NOT IN ?", models.PrintJobQueued,
+			[]models.OrderStatus{models.OrderStatusPending, models.OrderStatusCancelled}).
+		Order("orders.created_at ASC").Order("print_jobs.created_at ASC").
+		Select("print_jobs.*").Find(&waiting).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch waiting print jobs: %w", err)
+	}
+
+	assigned := 0
+	for i := range waiting {
+		job := &waiting[i]
+		var printer *models.Printer
+		for j := range printers {
+			if !busy[printers[j].ID] && printers[j].Supports(job.MaterialID) && printers[j].Fits(job.SizeX, job.SizeY, job.SizeZ) {
+				printer = &printers[j]
+				break
+			}
+		}
+		if printer == nil {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.PrintJob{}).
+				Where("id = ? AND status = ? AND printer_id IS NULL", job.ID, models.PrintJobQueued).
+				Update("printer_id", printer.ID)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return tx.Create(&models.PrintJobEvent{
+				JobID:     job.ID,
+				From:      models.PrintJobQueued,
+				To:        models.PrintJobQueued,
+				PrinterID: &printer.ID,
+				Note:      "Assigned to " + printer.Name,
+			}).Error
+		})
+		if err != nil {
+			return assigned, fmt.Errorf("failed to assign print job %s: %w", job.ID, err)
+		}
+		busy[printer.ID] = true
+		assigned++
+	}
+	return assigned, nil
+}
+
+// busyPrinters returns the printers holding a job
+func busyPrinters(db *gorm.DB) (map[string]bool, error) {
+	var printerIDs []string
+	if err := db.Model(&models.PrintJob{}).
+		Where("printer_id IS NOT NULL AND status IN ?", []models.PrintJobStatus{
+			models.PrintJobQueued, models.PrintJobSlicing, models.PrintJobPrinting,
+		}).
+		Distinct().Pluck("printer_id", &printerIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch busy printers: %w", err)
+	}
+
+	busy := make(map[string]bool, len(printerIDs))
+	for _, id := range printerIDs {
+		busy[id] = true
+	}
+	return busy, nil
+}
+
+// releasePrinterJobs sends queued jobs that have not started back to the
+// waiting list when their printer can no longer take them
+func releasePrinterJobs(tx *gorm.DB, printer *models.Printer) error {
+	var jobs []models.PrintJob
+	if err := tx.Where("printer_id = ? AND status = ?", printer.ID, models.PrintJobQueued).Find(&jobs).Error; err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		if printer.IsActive && printer.Supports(job.MaterialID) && printer.Fits(job.SizeX, job.SizeY, job.SizeZ) {
+			continue
+		}
+		if err := tx.Model(job).Update("printer_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PrintJobEvent{
+			JobID: job.ID,
+			From:  models.PrintJobQueued,
+			To:    models.PrintJobQueued,
+			Note:  printer.Name + " can no longer take this job",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveJob moves a job to the next state and records who did it. Failed
+// prints and rejected parts free their printer and count another attempt.
+func moveJob(tx *gorm.DB, job *models.PrintJob, next models.PrintJobStatus, actorID, note string) error {
+	from := job.Status
+	now := time.Now()
+
+	updates := map[string]interface{}{"status": next}
+	switch next {
+	case models.PrintJobPrinting:
+		updates["started_at"] = now
+	case models.PrintJobDone:
+		updates["completed_at"] = now
+	case models.PrintJobQueued:
+		updates["printer_id"] = nil
+		updates["started_at"] = nil
+		if from != models.PrintJobSlicing {
+			updates["attempts"] = job.Attempts + 1
+		}
+	}
+
+	// Guard against a concurrent change, such as the order being cancelled
+	result := tx.Model(&models.PrintJob{}).Where("id = ? AND status = ?", job.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errJobChanged
+	}
+
+	if err := tx.Create(&models.PrintJobEvent{
+		JobID:     job.ID,
+		From:      from,
+		To:        next,
+		PrinterID: job.PrinterID,
+		Note:      note,
+		ActorID:   actorID,
+	}).Error; err != nil {
+		return err
+	}
+	return syncOrderProduction(tx, job.OrderID)
+}
+
+// syncOrderProduction moves a paid order forward as its prints progress: it
+// is in production once a job has started and ready to be processed for
+// shipping once every job is done. It never moves an order back.
+func syncOrderProduction(tx *gorm.DB, orderID string) error {
+	var order models.Order
+	if err := tx.First(&order, "id = ?", orderID).Error; err != nil {
+		return err
+	}
+	if order.Status != models.OrderStatusConfirmed && order.Status != models.OrderStatusInProduction {
+		return nil
+	}
+
+	var jobs []models.PrintJob
+	if err := tx.Where("order_id = ? AND status <> ?", orderID, models.PrintJobCancelled).Find(&jobs).Error; err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	started, done := false, true
+	for _, job := range jobs {
+		if job.Status != models.PrintJobQueued || job.Attempts > 1 {
+			started = true
+		}
+		if job.Status != models.PrintJobDone {
+			done = false
+		}
+	}
+
+	status := order.Status
+	switch {
+	case done:
+		status = models.OrderStatusProcessing
+	case started:
+		status = models.OrderStatusInProduction
+	}
+	if status == order.Status {
+		return nil
+	}
+	return tx.Model(&order).Update("status", status).Error
+}
+
+// cancelPrintJobs stops production of a cancelled order's prints
+func cancelPrintJobs(tx *gorm.DB, orderID string) error {
+	var jobs []models.PrintJob
+	if err := tx.Where("order_id = ? AND status IN ?", orderID, activePrintJobStatuses).Find(&jobs).Error; err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		if err := tx.Model(&jobs[i]).Update("status", models.PrintJobCancelled).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PrintJobEvent{
+			JobID:     jobs[i].ID,
+			From:      jobs[i].Status,
+			To:        models.PrintJobCancelled,
+			PrinterID: jobs[i].PrinterID,
+			Note:      "Order cancelled",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasUnfinishedPrintJobs reports whether an order still has prints in production
+func hasUnfinishedPrintJobs(db *gorm.DB, orderID string) bool {
+	var count int64
+	db.Model(&models.PrintJob{}).Where("order_id = ? AND status IN ?", orderID, activePrintJobStatuses).Count(&count)
+	return count > 0
+}
+
+// loadProductionQueue takes a snapshot of the shop floor. Waiting jobs say
+// why they cannot be assigned when no free printer is all they lack.
+func loadProductionQueue(db *gorm.DB) (*productionQueue, error) {
+	var printers []models.Printer
+	if err := db.Order("name ASC").Find(&printers).Error; err != nil {
+		return nil, err
+	}
+
+	var jobs []models.PrintJob
+	if err := db.Preload("Order").Preload("Model").Preload("Material").
+		Joins("JOIN orders ON orders.id = print_jobs.order_id").
+		Where("print_jobs.status IN ?", activePrintJobStatuses).
+		Order("orders.created_at ASC").Order("print_jobs.created_at ASC").
+		Select("print_jobs.*").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	queue := &productionQueue{
+		Printers:  make([]printerQueue, len(printers)),
+		Waiting:   []models.PrintJob{},
+		Finishing: []models.PrintJob{},
+		Counts:    make(map[models.PrintJobStatus]int),
+		UpdatedAt: time.Now(),
+	}
+	slots := make(map[string]*printerQueue, len(printers))
+	for i := range printers {
+		queue.Printers[i] = printerQueue{Printer: printers[i], Jobs: []models.PrintJob{}}
+		slots[printers[i].ID] = &queue.Printers[i]
+	}
+
+	for _, job := range jobs {
+		queue.Counts[job.Status]++
+		switch {
+		case job.PrinterID == nil:
+			job.Blocked = waitingReason(&job, printers)
+			queue.Waiting = append(queue.Waiting, job)
+		case job.Status.OccupiesPrinter() && slots[*job.PrinterID] != nil:
+			slot := slots[*job.PrinterID]
+			slot.Jobs = append(slot.Jobs, job)
+		default:
+			queue.Finishing = append(queue.Finishing, job)
+		}
+	}
+	return queue, nil
+}
+
+// waitingReason explains why an unassigned job is not being scheduled, or
+// returns an empty string when it is only waiting for a free printer
+func waitingReason(job *models.PrintJob, printers []models.Printer) string {
+	if job.Order != nil && job.Order.Status == models.OrderStatusPending {
+		return "Awaiting payment"
+	}
+	for i := range printers {
+		if printers[i].IsActive && printers[i].Supports(job.MaterialID) && printers[i].Fits(job.SizeX, job.SizeY, job.SizeZ) {
+			return ""
+		}
+	}
+	return "No active printer runs this material with a large enough build volume"
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/middleware"
 	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,7 +14,9 @@ import (
 )
 
 type ProductHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	pricing     *pricing.Service
+	stockAlerts *alerts.StockNotifier
 }
 
 type ProductQuery struct {
@@ -23,6 +28,10 @@ type ProductQuery struct {
 	MaxPrice string `form:"maxPrice"`
 	InStock  string `form:"inStock"`
 	SortBy   string `form:"sortBy,default=created_desc"`
+
+	// Compatibility filter: products linked to this product ID, optionally of one type
+	CompatibleWith    string `form:"compatibleWith"`
+	CompatibilityType string `form:"compatibilityType"`
 }
 
 type PaginationResponse struct {
@@ -32,8 +41,14 @@ type PaginationResponse struct {
 	TotalPages int   `json:"totalPages"`
 }
 
-func NewProductHandler(db *gorm.DB) *ProductHandler {
-	return &ProductHandler{db: db}
+func NewProductHandler(db *gorm.DB, stockAlerts *alerts.StockNotifier) *ProductHandler {
+	return &ProductHandler{db: db, pricing: pricing.NewService(db), stockAlerts: stockAlerts}
+}
+
+// unscoped is a preload condition that also resolves archived rows, used
+// wherever historical records (orders, carts) reference products
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
 }
 
 // GetProducts returns a paginated list of products with filtering
@@ -58,23 +73,34 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 
 	if query.Search != "" {
 		searchTerm := "%" + strings.ToLower(query.Search) + "%"
-		db = db.Where("LOWER(products.name) LIKE ? OR LOWER(products.description) LIKE ?", searchTerm, searchTerm)
+		db = translatedProductSearch(db, searchTerm)
 	}
 
+	// Price filters are given in the storefront currency, base prices are stored in USD
+	currency := middleware.GetCurrency(c)
+
 	if query.MinPrice != "" {
 		if minPrice, err := strconv.ParseFloat(query.MinPrice, 64); err == nil {
-			db = db.Where("products.price >= ?", minPrice)
+			if converted, err := h.pricing.Convert(minPrice, currency, pricing.BaseCurrency); err == nil {
+				db = db.Where("products.price >= ?", converted)
+			}
 		}
 	}
 
 	if query.MaxPrice != "" {
 		if maxPrice, err := strconv.ParseFloat(query.MaxPrice, 64); err == nil {
-			db = db.Where("products.price <= ?", maxPrice)
+			if converted, err := h.pricing.Convert(maxPrice, currency, pricing.BaseCurrency); err == nil {
+				db = db.Where("products.price <= ?", converted)
+			}
 		}
 	}
 
 	if query.InStock == "true" {
-		db = db.Where("products.in_stock = ? AND products.stock_quantity > 0", true)
+		db = db.Where("products.is_digital = ? OR (products.in_stock = ? AND products.stock_quantity > 0)", true, true)
+	}
+
+	if query.CompatibleWith != "" {
+		db = compatibleProductsFilter(db, query.CompatibleWith, query.CompatibilityType)
 	}
 
 	// Apply sorting
@@ -89,6 +115,10 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		db = db.Order("products.name DESC")
 	case "newest":
 		db = db.Order("products.created_at DESC")
+	case "rating_desc":
+		db = db.Order("products.rating_average DESC").Order("products.review_count DESC")
+	case "rating_asc":
+		db = db.Order("products.rating_average ASC").Order("products.review_count DESC")
 	default:
 		db = db.Order("products.created_at DESC")
 	}
@@ -118,6 +148,11 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		return
 	}
 
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    products,
@@ -135,7 +170,15 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	productID := c.Param("id")
 
 	var product models.Product
-	if err := h.db.Preload("Category").First(&product, "id = ?", productID).Error; err != nil {
+	query := h.db.Preload("Category").
+		Preload("Media", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Order("file_name ASC") }).
+		Preload("Questions", func(db *gorm.DB) *gorm.DB {
+			return preloadPublishedAnswers(db.Where("status = ?", models.ReviewStatusApproved).
+				Order("vote_count DESC").Order("created_at DESC").Limit(productQuestionLimit))
+		}).
+		Preload("Questions.User")
+	if err := preloadBundleItems(query, "").First(&product, "id = ?", productID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error":   "Product not found",
@@ -150,6 +193,37 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 		return
 	}
 
+	links, err := loadCompatibilityLinks(h.db, product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product compatibility",
+		})
+		return
+	}
+
+	if err := product.ResolveBundle(h.pricing.Convert); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+	setQuestionAuthors(product.Questions)
+
+	// Localize and price the product together with the products it links to
+	products := make([]models.Product, 0, len(links)+1)
+	products = append(products, product)
+	for _, link := range links {
+		products = append(products, link.Product)
+	}
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+	product = products[0]
+	for i := range links {
+		links[i].Product = products[i+1]
+	}
+	product.Compatibility = links
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    product,
@@ -187,6 +261,13 @@ func (h *ProductHandler) GetProductsByCategory(c *gin.Context) {
 		return
 	}
 
+	locale := middleware.GetLocale(c)
+	localizeProducts(h.db, products, locale)
+	localizeCategoryPointers(h.db, []*models.Category{&category}, locale)
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"data":     products,
@@ -208,8 +289,7 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 	searchPattern := "%" + strings.ToLower(searchTerm) + "%"
 
 	var products []models.Product
-	if err := h.db.Preload("Category").
-		Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ?", searchPattern, searchPattern).
+	if err := translatedProductSearch(h.db.Preload("Category"), searchPattern).
 		Order("featured DESC, created_at DESC").
 		Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -219,6 +299,11 @@ func (h *ProductHandler) SearchProducts(c *gin.Context) {
 		return
 	}
 
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    products,
@@ -241,6 +326,11 @@ func (h *ProductHandler) GetFeaturedProducts(c *gin.Context) {
 		return
 	}
 
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    products,
@@ -267,6 +357,8 @@ func (h *ProductHandler) GetCategories(c *gin.Context) {
 		categories[i].ProductCount = int(count)
 	}
 
+	localizeCategories(h.db, categories, middleware.GetLocale(c))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    categories,
@@ -298,6 +390,10 @@ func (h *ProductHandler) GetCategory(c *gin.Context) {
 	h.db.Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&count)
 	category.ProductCount = int(count)
 
+	categories := []models.Category{category}
+	localizeCategories(h.db, categories, middleware.GetLocale(c))
+	category = categories[0]
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    category,
@@ -317,6 +413,10 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	if !validAvailabilitySettings(c, &product) {
+		return
+	}
+
 	// Verify category exists
 	var category models.Category
 	if err := h.db.First(&category, "id = ?", product.CategoryID).Error; err != nil {
@@ -374,6 +474,10 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	if !validAvailabilitySettings(c, &updateData) {
+		return
+	}
+
 	// Verify category if being updated
 	if updateData.CategoryID != "" {
 		var category models.Category
@@ -386,7 +490,16 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Model(&product).Updates(updateData).Error; err != nil {
+	previousStock := product.StockQuantity
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&product).Updates(updateData).Error; err != nil {
+			return err
+		}
+		// Waiting pre-orders and backorders get first claim on new stock, and
+		// bundles built from this product derive their price and stock from it
+		return productChanged(tx, product.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
 			"message": "Failed to update product",
@@ -397,6 +510,10 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	// Load the updated product with relations
 	h.db.Preload("Category").First(&product, "id = ?", product.ID)
 
+	if product.StockQuantity > previousStock {
+		h.stockAlerts.Restocked(product.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Product updated successfully",
@@ -404,7 +521,7 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	})
 }
 
-// DeleteProduct deletes a product (admin only)
+// DeleteProduct archives a product so it disappears from the storefront (admin only)
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	productID := c.Param("id")
 
@@ -424,17 +541,114 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&product).Error; err != nil {
+	// Soft delete: orders, carts and reviews keep resolving the row. Bundles
+	// built from the product become unavailable with it.
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return syncBundlesContaining(tx, []string{product.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to archive product",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product archived successfully",
+	})
+}
+
+// GetArchivedProducts lists archived products (admin only)
+func (h *ProductHandler) GetArchivedProducts(c *gin.Context) {
+	var products []models.Product
+	if err := h.db.Unscoped().Preload("Category", unscoped).
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
-			"message": "Failed to delete product",
+			"message": "Failed to fetch archived products",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Product deleted successfully",
+		"data":    products,
+	})
+}
+
+// RestoreProduct brings an archived product back to the storefront (admin only)
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	productID := c.Param("id")
+
+	var product models.Product
+	if err := h.db.Unscoped().First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+
+	if !product.ArchivedAt.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Product not archived",
+			"message": "The product is already active",
+		})
+		return
+	}
+
+	// A product in an archived category would stay unreachable from the storefront
+	var category models.Category
+	if err := h.db.First(&category, "id = ?", product.CategoryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Category archived",
+				"message": "Restore the product's category first",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find category",
+		})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&product).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return syncBundlesContaining(tx, []string{product.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to restore product",
+		})
+		return
+	}
+	h.stockAlerts.Restocked(product.ID)
+
+	h.db.Preload("Category").First(&product, "id = ?", product.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product restored successfully",
+		"data":    product,
 	})
 }
 
@@ -508,7 +722,7 @@ func (h *ProductHandler) UpdateCategory(c *gin.Context) {
 	})
 }
 
-// DeleteCategory deletes a category (admin only)
+// DeleteCategory archives a category (admin only)
 func (h *ProductHandler) DeleteCategory(c *gin.Context) {
 	categoryID := c.Param("id")
 
@@ -525,7 +739,7 @@ func (h *ProductHandler) DeleteCategory(c *gin.Context) {
 	if productCount > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Category has products",
-			"message": "Cannot delete category that contains products",
+			"message": "Cannot archive category that contains active products",
 		})
 		return
 	}
@@ -549,13 +763,77 @@ func (h *ProductHandler) DeleteCategory(c *gin.Context) {
 	if err := h.db.Delete(&category).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
-			"message": "Failed to delete category",
+			"message": "Failed to archive category",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Category deleted successfully",
+		"message": "Category archived successfully",
+	})
+}
+
+// GetArchivedCategories lists archived categories (admin only)
+func (h *ProductHandler) GetArchivedCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := h.db.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch archived categories",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    categories,
+	})
+}
+
+// RestoreCategory brings an archived category back to the storefront (admin only)
+func (h *ProductHandler) RestoreCategory(c *gin.Context) {
+	categoryID := c.Param("id")
+
+	var category models.Category
+	if err := h.db.Unscoped().First(&category, "id = ?", categoryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Category not found",
+				"message": "The requested category does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find category",
+		})
+		return
+	}
+
+	if !category.ArchivedAt.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Category not archived",
+			"message": "The category is already active",
+		})
+		return
+	}
+
+	if err := h.db.Unscoped().Model(&category).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to restore category",
+		})
+		return
+	}
+	category.ArchivedAt = gorm.DeletedAt{}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Category restored successfully",
+		"data":    category,
 	})
 }
@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// productQuestionLimit is how many top questions GetProduct embeds
+const productQuestionLimit = 5
+
+type QuestionHandler struct {
+	db     *gorm.DB
+	mailer email.Sender
+}
+
+type AskQuestionRequest struct {
+	Body string `json:"body" binding:"required,min=10,max=1000"`
+}
+
+type AnswerQuestionRequest struct {
+	Body string `json:"body" binding:"required,min=2,max=5000"`
+}
+
+type QuestionQuery struct {
+	Page   int    `form:"page,default=1"`
+	Limit  int    `form:"limit,default=10"`
+	SortBy string `form:"sortBy,default=votes"`
+}
+
+func NewQuestionHandler(db *gorm.DB, mailer email.Sender) *QuestionHandler {
+	return &QuestionHandler{db: db, mailer: mailer}
+}
+
+// GetProductQuestions returns the approved questions of a product with their published answers
+func (h *QuestionHandler) GetProductQuestions(c *gin.Context) {
+	var query QuestionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 50 {
+		query.Limit = 10
+	}
+
+	db := h.db.Model(&models.ProductQuestion{}).
+		Where("product_id = ? AND status = ?", c.Param("id"), models.ReviewStatusApproved)
+
+	switch query.SortBy {
+	case "newest":
+		db = db.Order("created_at DESC")
+	default:
+		db = db.Order("vote_count DESC").Order("created_at DESC")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count questions",
+		})
+		return
+	}
+
+	offset := (query.Page - 1) * query.Limit
+	totalPages := int((total + int64(query.Limit) - 1) / int64(query.Limit))
+
+	var questions []models.ProductQuestion
+	if err := preloadPublishedAnswers(db).Preload("User").
+		Offset(offset).Limit(query.Limit).Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch questions",
+		})
+		return
+	}
+	setQuestionAuthors(questions)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    questions,
+		"pagination": PaginationResponse{
+			Page:       query.Page,
+			Limit:      query.Limit,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// AskQuestion submits a question about a product for moderation
+func (h *QuestionHandler) AskQuestion(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req AskQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	question := models.ProductQuestion{
+		ProductID: product.ID,
+		UserID:    userID,
+		Body:      strings.TrimSpace(req.Body),
+		Status:    models.ReviewStatusPending,
+		Answers:   []models.ProductAnswer{},
+	}
+	if err := h.db.Create(&question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create question",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Question submitted and awaiting moderation",
+		"data":    question,
+	})
+}
+
+// AnswerQuestion lets a verified owner of the product answer a question. The
+// answer is published after moderation.
+func (h *QuestionHandler) AnswerQuestion(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req AnswerQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	question, ok := h.findQuestion(c, models.ReviewStatusApproved)
+	if !ok {
+		return
+	}
+
+	if !hasDeliveredPurchase(h.db, userID, question.ProductID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Not a verified owner",
+			"message": "Only customers who received this product can answer questions",
+		})
+		return
+	}
+
+	answer := models.ProductAnswer{
+		QuestionID:    question.ID,
+		UserID:        userID,
+		Body:          strings.TrimSpace(req.Body),
+		VerifiedOwner: true,
+		Status:        models.ReviewStatusPending,
+	}
+	if err := h.db.Create(&answer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create answer",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Answer submitted and awaiting moderation",
+		"data":    answer,
+	})
+}
+
+// VoteQuestion upvotes an approved question for the current user
+func (h *QuestionHandler) VoteQuestion(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	question, ok := h.findQuestion(c, models.ReviewStatusApproved)
+	if !ok {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.QuestionVote{}).Where("question_id = ? AND user_id = ?", question.ID, userID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Already voted",
+			"message": "You have already voted for this question",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Create(&models.QuestionVote{QuestionID: question.ID, UserID: userID}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+	if err := tx.Model(&question).UpdateColumn("vote_count", gorm.Expr("vote_count + 1")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+
+	h.db.First(&question, "id = ?", question.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Vote recorded",
+		"data": gin.H{
+			"voteCount": question.VoteCount,
+		},
+	})
+}
+
+// RemoveQuestionVote withdraws the current user's vote for a question
+func (h *QuestionHandler) RemoveQuestionVote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	questionID := c.Param("id")
+
+	tx := h.db.Begin()
+	result := tx.Where("question_id = ? AND user_id = ?", questionID, userID).Delete(&models.QuestionVote{})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Vote not found",
+			"message": "You have not voted for this question",
+		})
+		return
+	}
+	if err := tx.Model(&models.ProductQuestion{}).Where("id = ? AND vote_count > 0", questionID).
+		UpdateColumn("vote_count", gorm.Expr("vote_count - 1")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Vote removed",
+	})
+}
+
+// GetQuestions lists questions for moderation, pending ones by default (admin only)
+func (h *QuestionHandler) GetQuestions(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.ReviewStatusPending))
+
+	db := h.db.Preload("Product", unscoped).Preload("User").Preload("Answers").Order("created_at ASC")
+	if status != "all" {
+		db = db.Where("status = ?", status)
+	}
+	if productID := c.Query("productId"); productID != "" {
+		db = db.Where("product_id = ?", productID)
+	}
+
+	var questions []models.ProductQuestion
+	if err := db.Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch questions",
+		})
+		return
+	}
+	setQuestionAuthors(questions)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    questions,
+	})
+}
+
+// ModerateQuestion approves or rejects a question (admin only)
+func (h *QuestionHandler) ModerateQuestion(c *gin.Context) {
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	question, ok := h.findQuestion(c, "")
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	question.Status = models.ReviewStatus(req.Status)
+	question.ModerationNote = strings.TrimSpace(req.Note)
+	question.ModeratedAt = &now
+	if err := h.db.Model(&question).Select("status", "moderation_note", "moderated_at").Updates(&question).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update question",
+		})
+		return
+	}
+
+	// Staff may have answered while the question was still pending
+	if question.Status == models.ReviewStatusApproved {
+		h.notifyPublishedAnswers(question.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Question " + req.Status,
+		"data":    question,
+	})
+}
+
+// StaffAnswer publishes an answer from the store team and approves the question (admin only)
+func (h *QuestionHandler) StaffAnswer(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req AnswerQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	question, ok := h.findQuestion(c, "")
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	answer := models.ProductAnswer{
+		QuestionID:  question.ID,
+		UserID:      userID,
+		Body:        strings.TrimSpace(req.Body),
+		IsStaff:     true,
+		Status:      models.ReviewStatusApproved,
+		ModeratedAt: &now,
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Create(&answer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create answer",
+		})
+		return
+	}
+	if question.Status == models.ReviewStatusPending {
+		if err := tx.Model(&question).Updates(map[string]interface{}{
+			"status":       models.ReviewStatusApproved,
+			"moderated_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to approve question",
+			})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create answer",
+		})
+		return
+	}
+
+	h.notifyPublishedAnswers(question.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Answer published successfully",
+		"data":    answer,
+	})
+}
+
+// GetAnswers lists owner answers for moderation, pending ones by default (admin only)
+func (h *QuestionHandler) GetAnswers(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.ReviewStatusPending))
+
+	db := h.db.Preload("User").Order("created_at ASC")
+	if status != "all" {
+		db = db.Where("status = ?", status)
+	}
+
+	var answers []models.ProductAnswer
+	if err := db.Find(&answers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch answers",
+		})
+		return
+	}
+	for i := range answers {
+		answers[i].Author = displayName(answers[i].User)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    answers,
+	})
+}
+
+// ModerateAnswer approves or rejects an owner answer; approval emails the asker (admin only)
+func (h *QuestionHandler) ModerateAnswer(c *gin.Context) {
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var answer models.ProductAnswer
+	if err := h.db.First(&answer, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Answer not found",
+				"message": "The requested answer does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find answer",
+		})
+		return
+	}
+
+	now := time.Now()
+	answer.Status = models.ReviewStatus(req.Status)
+	answer.ModerationNote = strings.TrimSpace(req.Note)
+	answer.ModeratedAt = &now
+	if err := h.db.Model(&answer).Select("status", "moderation_note", "moderated_at").Updates(&answer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update answer",
+		})
+		return
+	}
+
+	if answer.Status == models.ReviewStatusApproved {
+		h.notifyPublishedAnswers(answer.QuestionID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Answer " + req.Status,
+		"data":    answer,
+	})
+}
+
+// findQuestion loads the question in the :id route parameter, optionally
+// requiring a moderation status, and writes the error response if it fails
+func (h *QuestionHandler) findQuestion(c *gin.Context, status models.ReviewStatus) (models.ProductQuestion, bool) {
+	var question models.ProductQuestion
+	db := h.db
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if err := db.First(&question, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Question not found",
+				"message": "The requested question does not exist",
+			})
+			return question, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find question",
+		})
+		return question, false
+	}
+	return question, true
+}
+
+// notifyPublishedAnswers emails the asker about every published answer of an
+// approved question they have not been told about yet. Answers the asker
+// wrote themselves are skipped.
+func (h *QuestionHandler) notifyPublishedAnswers(questionID string) {
+	var question models.ProductQuestion
+	if err := h.db.Preload("User").Preload("Product", unscoped).
+		Where("status = ?", models.ReviewStatusApproved).
+		First(&question, "id = ?", questionID).Error; err != nil {
+		return
+	}
+	if question.User == nil || question.Product == nil {
+		return
+	}
+
+	var answers []models.ProductAnswer
+	h.db.Where("question_id = ? AND status = ? AND notified_at IS NULL AND user_id <> ?",
+		questionID, models.ReviewStatusApproved, question.UserID).
+		Order("created_at ASC").Find(&answers)
+
+	for _, answer := range answers {
+		responder := "A verified owner"
+		if answer.IsStaff {
+			responder = "Our team"
+		}
+
+		email.SendAsync(h.mailer, email.Message{
+			To:      []string{question.User.Email},
+			Subject: fmt.Sprintf("Your question about %s has been answered", question.Product.Name),
+			Body: fmt.Sprintf("Hi %s,\n\n%s answered your question about %s.\n\nQ: %s\n\nA: %s\n",
+				question.User.FirstName, responder, question.Product.Name, question.Body, answer.Body),
+		})
+
+		if err := h.db.Model(&answer).UpdateColumn("notified_at", time.Now()).Error; err != nil {
+			log.Printf("Failed to mark answer %s as notified: %v", answer.ID, err)
+		}
+	}
+}
+
+// preloadPublishedAnswers loads approved answers, staff first, for the questions in db
+func preloadPublishedAnswers(db *gorm.DB) *gorm.DB {
+	return db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ?", models.ReviewStatusApproved).
+			Order("is_staff DESC").Order("created_at ASC")
+	}).Preload("Answers.User")
+}
+
+// setQuestionAuthors fills in the public author names of questions and their answers
+func setQuestionAuthors(questions []models.ProductQuestion) {
+	for i := range questions {
+		questions[i].Author = displayName(questions[i].User)
+		for j := range questions[i].Answers {
+			questions[i].Answers[j].Author = displayName(questions[i].Answers[j].User)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"bizoe-3d-store/internal/recommend"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RecommendationHandler struct {
+	db      *gorm.DB
+	engine  *recommend.Engine
+	pricing *pricing.Service
+}
+
+type RecommendationOverrideRequest struct {
+	Action   string `json:"action" binding:"required,oneof=pin exclude"`
+	Position int    `json:"position"`
+}
+
+// RecommendedProduct is a product returned by the recommendation endpoints
+type RecommendedProduct struct {
+	Product models.Product `json:"product"`
+	Reason  string         `json:"reason"`
+	Score   float64        `json:"score"`
+}
+
+func NewRecommendationHandler(db *gorm.DB, engine *recommend.Engine) *RecommendationHandler {
+	return &RecommendationHandler{db: db, engine: engine, pricing: pricing.NewService(db)}
+}
+
+// GetProductRecommendations returns products frequently bought with, or similar to, a product
+func (h *RecommendationHandler) GetProductRecommendations(c *gin.Context) {
+	productID := c.Param("id")
+
+	var count int64
+	h.db.Model(&models.Product{}).Where("id = ?", productID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Product not found",
+			"message": "The requested product does not exist",
+		})
+		return
+	}
+
+	h.respond(c, []string{productID})
+}
+
+// GetCartRecommendations returns products that go with the items in the user's cart
+func (h *RecommendationHandler) GetCartRecommendations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var productIDs []string
+	h.db.Model(&models.CartItem{}).
+		Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Where("carts.user_id = ?", userID).
+		Pluck("cart_items.product_id", &productIDs)
+
+	h.respond(c, productIDs)
+}
+
+// GetRecommendationOverrides lists the pinned and excluded products of a product (admin only)
+func (h *RecommendationHandler) GetRecommendationOverrides(c *gin.Context) {
+	var overrides []models.RecommendationOverride
+	if err := h.db.Preload("TargetProduct", unscoped).
+		Where("product_id = ?", c.Param("id")).
+		Order("action ASC").Order("position ASC").
+		Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch recommendation overrides",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    overrides,
+	})
+}
+
+// UpsertRecommendationOverride pins or excludes a product in another product's recommendations (admin only)
+func (h *RecommendationHandler) UpsertRecommendationOverride(c *gin.Context) {
+	productID := c.Param("id")
+	targetID := c.Param("targetId")
+
+	var req RecommendationOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if productID == targetID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid override",
+			"message": "A product cannot be recommended for itself",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Product{}).Where("id IN ?", []string{productID, targetID}).Count(&count)
+	if count != 2 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Product not found",
+			"message": "Both products must exist",
+		})
+		return
+	}
+
+	var override models.RecommendationOverride
+	err := h.db.Where("product_id = ? AND target_product_id = ?", productID, targetID).First(&override).Error
+	if err == gorm.ErrRecordNotFound {
+		override = models.RecommendationOverride{ProductID: productID, TargetProductID: targetID}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch recommendation override",
+		})
+		return
+	}
+
+	override.Action = models.RecommendationAction(req.Action)
+	override.Position = req.Position
+	if err := h.db.Save(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save recommendation override",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Recommendation override saved successfully",
+		"data":    override,
+	})
+}
+
+// DeleteRecommendationOverride removes a pin or exclusion (admin only)
+func (h *RecommendationHandler) DeleteRecommendationOverride(c *gin.Context) {
+	result := h.db.Where("product_id = ? AND target_product_id = ?", c.Param("id"), c.Param("targetId")).
+		Delete(&models.RecommendationOverride{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete recommendation override",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Override not found",
+			"message": "The requested recommendation override does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Recommendation override deleted successfully",
+	})
+}
+
+// RebuildRecommendations recomputes the co-purchase model immediately (admin only)
+func (h *RecommendationHandler) RebuildRecommendations(c *gin.Context) {
+	if err := h.engine.Rebuild(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Rebuild failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Recommendations rebuilt",
+		"data":    h.engine.Stats(),
+	})
+}
+
+// respond loads, localizes and prices the recommendations for the source
+// products. Out of stock and archived products are dropped.
+func (h *RecommendationHandler) respond(c *gin.Context, sourceIDs []string) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "4"))
+	if err != nil || limit < 1 || limit > 20 {
+		limit = 4
+	}
+
+	// Over-fetch so dropping unavailable products still fills the list
+	recs, err := h.engine.Recommend(sourceIDs, limit*3)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to compute recommendations",
+		})
+		return
+	}
+
+	ids := make([]string, 0, len(recs))
+	for _, r := range recs {
+		ids = append(ids, r.ProductID)
+	}
+
+	var found []models.Product
+	if len(ids) > 0 {
+		if err := h.db.Preload("Category").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch recommended products",
+			})
+			return
+		}
+	}
+	byID := make(map[string]models.Product, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	products := make([]models.Product, 0, limit)
+	kept := make([]recommend.Recommendation, 0, limit)
+	for _, r := range recs {
+		p, ok := byID[r.ProductID]
+		if !ok || !p.InStockNow() {
+			continue
+		}
+		products = append(products, p)
+		kept = append(kept, r)
+		if len(products) == limit {
+			break
+		}
+	}
+
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return
+	}
+
+	data := make([]RecommendedProduct, len(products))
+	for i := range products {
+		data[i] = RecommendedProduct{Product: products[i], Reason: kept[i].Reason, Score: kept[i].Score}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
@@ -0,0 +1,845 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/payments"
+	"bizoe-3d-store/internal/pricing"
+	"bizoe-3d-store/internal/storage"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxReturnPhotos limits how many photos a single return request can carry
+const maxReturnPhotos = 4
+
+// openReturnStatuses are the states of returns that still hold units of their order line
+var openReturnStatuses = []models.ReturnStatus{
+	models.ReturnRequested,
+	models.ReturnApproved,
+	models.ReturnReceived,
+	models.ReturnCompleted,
+}
+
+// errReturnChanged is returned when a return was updated concurrently
+var errReturnChanged = errors.New("return changed")
+
+type ReturnHandler struct {
+	db          *gorm.DB
+	storage     storage.Storage
+	gateway     payments.Gateway
+	mailer      email.Sender
+	stockAlerts *alerts.StockNotifier
+	window      time.Duration // How long after delivery returns are accepted
+}
+
+type CreateReturnRequest struct {
+	Quantity   int    `form:"quantity" binding:"required,min=1"`
+	Reason     string `form:"reason" binding:"required,oneof=defective damaged_in_transit wrong_item not_as_described no_longer_needed other"`
+	Resolution string `form:"resolution" binding:"required,oneof=refund exchange"`
+	Comment    string `form:"comment" binding:"max=2000"`
+}
+
+type ReviewReturnRequest struct {
+	Decision   string `json:"decision" binding:"required,oneof=approve reject"`
+	Note       string `json:"note" binding:"max=2000"`
+	Resolution string `json:"resolution" binding:"omitempty,oneof=refund exchange"` // Overrides the customer's choice
+}
+
+type ReceiveReturnRequest struct {
+	Condition    string   `json:"condition" binding:"required,oneof=sealed opened damaged"`
+	Disposition  string   `json:"disposition" binding:"required,oneof=restock scrap"`
+	Note         string   `json:"note" binding:"max=2000"`
+	RefundAmount *float64 `json:"refundAmount"` // Less than the default for partial refunds, such as missing parts
+}
+
+func NewReturnHandler(db *gorm.DB, store storage.Storage, gateway payments.Gateway, mailer email.Sender, stockAlerts *alerts.StockNotifier, windowDays int) *ReturnHandler {
+	return &ReturnHandler{
+		db:          db,
+		storage:     store,
+		gateway:     gateway,
+		mailer:      mailer,
+		stockAlerts: stockAlerts,
+		window:      time.Duration(windowDays) * 24 * time.Hour,
+	}
+}
+
+// RequestReturn asks to send back units of a delivered order line for a
+// refund or an exchange, optionally with photos of the problem
+func (h *ReturnHandler) RequestReturn(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReturnPhotos*maxImageSize+1<<20)
+
+	var req CreateReturnRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var order models.Order
+	if err := h.db.Where("user_id = ?", userID).First(&order, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order not found",
+				"message": "The requested order does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return
+	}
+
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusPartiallyReturned {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not returnable",
+			"message": "Returns can be requested once the order has been delivered",
+		})
+		return
+	}
+	deliveredAt := order.UpdatedAt
+	if order.DeliveredAt != nil {
+		deliveredAt = *order.DeliveredAt
+	}
+	if time.Since(deliveredAt) > h.window {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Return window closed",
+			"message": fmt.Sprintf("Returns must be requested within %d days of delivery", int(h.window.Hours()/24)),
+		})
+		return
+	}
+
+	var item models.OrderItem
+	if err := h.db.Where("order_id = ?", order.ID).First(&item, "id = ?", c.Param("itemId")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order item not found",
+				"message": "The requested order item does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order item",
+		})
+		return
+	}
+	if item.LineType == models.OrderLineDigital || item.LineType == models.OrderLinePrint {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Item not returnable",
+			"message": "Digital products and custom prints cannot be returned",
+		})
+		return
+	}
+
+	returnable, err := returnableQuantity(h.db, &item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to check returnable quantity",
+		})
+		return
+	}
+	if req.Quantity > returnable {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid quantity",
+			"message": fmt.Sprintf("Only %d units of %s can still be returned", returnable, item.ProductName),
+		})
+		return
+	}
+
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["photos"]
+	}
+	if len(files) > maxReturnPhotos {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many photos",
+			"message": fmt.Sprintf("A return can include at most %d photos", maxReturnPhotos),
+		})
+		return
+	}
+
+	ret := models.ReturnRequest{
+		ID:          uuid.New().String(),
+		OrderID:     order.ID,
+		OrderItemID: item.ID,
+		UserID:      userID,
+		ProductID:   item.ProductID,
+		Quantity:    req.Quantity,
+		Reason:      models.ReturnReason(req.Reason),
+		Comment:     strings.TrimSpace(req.Comment),
+		Photos:      []models.CustomerPhoto{},
+		Resolution:  models.ReturnResolution(req.Resolution),
+		Status:      models.ReturnRequested,
+	}
+
+	for _, file := range files {
+		photo, ok := storeCustomerPhoto(c, h.storage, "returns/"+ret.ID, file)
+		if !ok {
+			deleteCustomerPhotos(h.storage, ret.Photos)
+			return
+		}
+		ret.Photos = append(ret.Photos, photo)
+	}
+
+	if err := h.db.Create(&ret).Error; err != nil {
+		deleteCustomerPhotos(h.storage, ret.Photos)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create return",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Return requested and awaiting approval",
+		"data":    ret,
+	})
+}
+
+// GetReturns returns the user's returns, newest first
+func (h *ReturnHandler) GetReturns(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var returns []models.ReturnRequest
+	if err := h.db.Preload("OrderItem").Where("user_id = ?", userID).
+		Order("created_at DESC").Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch returns",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    returns,
+	})
+}
+
+// GetReturn returns a return with its order line
+func (h *ReturnHandler) GetReturn(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	db := h.db.Preload("OrderItem")
+
+	// Non-admin users can only see their own returns
+	if middleware.IsAdmin(c) {
+		db = db.Preload("Order").Preload("User")
+	} else {
+		db = db.Where("user_id = ?", userID)
+	}
+
+	ret, ok := findReturn(c, db)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ret,
+	})
+}
+
+// CancelReturn withdraws a return that has not been reviewed yet
+func (h *ReturnHandler) CancelReturn(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	ret, ok := findReturn(c, h.db.Where("user_id = ?", userID))
+	if !ok {
+		return
+	}
+
+	result := h.db.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", ret.ID, models.ReturnRequested).
+		Update("status", models.ReturnCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to cancel return",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Cannot cancel return",
+			"message": "Only returns awaiting approval can be cancelled",
+		})
+		return
+	}
+	ret.Status = models.ReturnCancelled
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Return cancelled successfully",
+		"data":    ret,
+	})
+}
+
+// GetAllReturns returns returns, those awaiting approval by default, oldest first (admin only)
+func (h *ReturnHandler) GetAllReturns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.DefaultQuery("status", string(models.ReturnRequested))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.ReturnRequest{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if orderID := c.Query("orderId"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if rma := c.Query("rma"); rma != "" {
+		query = query.Where("rma_number = ?", strings.ToUpper(strings.TrimSpace(rma)))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count returns",
+		})
+		return
+	}
+
+	var returns []models.ReturnRequest
+	if err := query.Preload("OrderItem").Preload("User").
+		Order("created_at ASC").Offset((page - 1) * limit).Limit(limit).
+		Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch returns",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    returns,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// ReviewReturn approves a requested return and issues its RMA number, or
+// rejects it with a reason (admin only)
+func (h *ReturnHandler) ReviewReturn(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req ReviewReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if req.Decision == "reject" && note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "A note explaining the rejection is required",
+		})
+		return
+	}
+
+	ret, ok := findReturn(c, h.db.Preload("OrderItem").Preload("User"))
+	if !ok {
+		return
+	}
+	if ret.Status != models.ReturnRequested {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Return already reviewed",
+			"message": fmt.Sprintf("This return was already reviewed and is %s", ret.Status),
+		})
+		return
+	}
+
+	now := time.Now()
+	ret.ReviewNote = note
+	ret.ReviewedBy = adminID
+	ret.ReviewedAt = &now
+	if req.Decision == "approve" {
+		ret.Status = models.ReturnApproved
+		ret.IssueRMA()
+		if req.Resolution != "" {
+			ret.Resolution = models.ReturnResolution(req.Resolution)
+		}
+	} else {
+		ret.Status = models.ReturnRejected
+	}
+
+	// Guard against two admins reviewing the same return at once
+	result := h.db.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", ret.ID, models.ReturnRequested).
+		Updates(map[string]interface{}{
+			"status":      ret.Status,
+			"rma_number":  ret.RMANumber,
+			"resolution":  ret.Resolution,
+			"review_note": ret.ReviewNote,
+			"reviewed_by": ret.ReviewedBy,
+			"reviewed_at": ret.ReviewedAt,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to review return",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Return already reviewed",
+			"message": "This return was reviewed by someone else",
+		})
+		return
+	}
+
+	h.notifyCustomer(&ret)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Return " + string(ret.Status),
+		"data":    ret,
+	})
+}
+
+// ReceiveReturn books in the parcel of an approved return after inspection,
+// restocks or scraps the units, then refunds or sends the exchange. Units of
+// products restocked sealed only, such as resin, are scrapped once opened
+// (admin only).
+func (h *ReturnHandler) ReceiveReturn(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ret, ok := findReturn(c, h.db.Preload("Order").Preload("OrderItem.Product", unscoped).Preload("User"))
+	if !ok {
+		return
+	}
+	if ret.Status != models.ReturnApproved {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Return not approved",
+			"message": fmt.Sprintf("Only approved returns can be received; this return is %s", ret.Status),
+		})
+		return
+	}
+
+	condition := models.ReturnCondition(req.Condition)
+	disposition := models.ReturnDisposition(req.Disposition)
+	product := &ret.OrderItem.Product
+	if disposition == models.ReturnDispositionRestock && !condition.Restockable(product) {
+		message := "Damaged units cannot be restocked and must be scrapped"
+		if condition == models.ReturnConditionOpened {
+			message = fmt.Sprintf("%s is only restocked with its factory seal intact; opened units must be scrapped", product.Name)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Cannot restock",
+			"message": message,
+		})
+		return
+	}
+
+	refund := 0.0
+	if ret.Resolution == models.ReturnResolutionRefund {
+		refund = returnRefundAmount(ret.Order, ret.OrderItem, ret.Quantity)
+		if req.RefundAmount != nil {
+			if *req.RefundAmount < 0 || *req.RefundAmount > refund {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"message": fmt.Sprintf("Refund amount must be between 0 and %.2f %s", refund, ret.Order.Currency),
+				})
+				return
+			}
+			refund = pricing.RoundAmount(*req.RefundAmount, ret.Order.Currency)
+		}
+	}
+
+	now := time.Now()
+	ret.Condition = condition
+	ret.Disposition = disposition
+	ret.InspectionNote = strings.TrimSpace(req.Note)
+	ret.InspectedBy = adminID
+	ret.ReceivedAt = &now
+	ret.RefundAmount = refund
+
+	var touched []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", ret.ID, models.ReturnApproved).
+			Updates(map[string]interface{}{
+				"status":          models.ReturnReceived,
+				"condition":       ret.Condition,
+				"disposition":     ret.Disposition,
+				"inspection_note": ret.InspectionNote,
+				"inspected_by":    ret.InspectedBy,
+				"received_at":     ret.ReceivedAt,
+				"refund_amount":   ret.RefundAmount,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReturnChanged
+		}
+
+		if disposition != models.ReturnDispositionRestock {
+			return nil
+		}
+		var err error
+		touched, err = releaseStock(tx, &models.OrderItem{
+			ProductID:        ret.OrderItem.ProductID,
+			Quantity:         ret.Quantity,
+			BundleComponents: ret.OrderItem.BundleComponents,
+		})
+		if err != nil {
+			return err
+		}
+		for _, productID := range touched {
+			if _, err := allocatePendingLines(tx, productID); err != nil {
+				return err
+			}
+		}
+		return syncBundlesContaining(tx, touched)
+	})
+	if err != nil {
+		if err == errReturnChanged {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Return already received",
+				"message": "This return was received by someone else",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to receive return",
+		})
+		return
+	}
+	ret.Status = models.ReturnReceived
+	h.stockAlerts.Restocked(touched...)
+
+	h.settle(c, &ret)
+}
+
+// SettleReturn retries the refund or exchange of a received return whose
+// settlement failed, such as when the payment provider was down (admin only)
+func (h *ReturnHandler) SettleReturn(c *gin.Context) {
+	ret, ok := findReturn(c, h.db.Preload("Order").Preload("OrderItem.Product", unscoped).Preload("User"))
+	if !ok {
+		return
+	}
+	if ret.Status != models.ReturnReceived {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Return not received",
+			"message": fmt.Sprintf("Only received returns can be settled; this return is %s", ret.Status),
+		})
+		return
+	}
+
+	h.settle(c, &ret)
+}
+
+// settle refunds a received return or sends its exchange, completes it and
+// moves its order to a returned state, then writes the response. Reviews
+// lose their verified badge when the refund leaves the customer none of the
+// product. Refunds are keyed on the return so a retry never pays out twice.
+func (h *ReturnHandler) settle(c *gin.Context, ret *models.ReturnRequest) {
+	order := ret.Order
+
+	if ret.Resolution == models.ReturnResolutionRefund && ret.RefundAmount > 0 && ret.RefundID == "" {
+		refundID, err := h.gateway.Refund(payments.Refund{
+			PaymentID: order.PaymentIntentID,
+			Amount:    ret.RefundAmount,
+			Currency:  order.Currency,
+			Metadata: map[string]string{
+				"order_id":   order.ID,
+				"rma_number": *ret.RMANumber,
+			},
+			IdempotencyKey: "return-" + ret.ID,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Payment error",
+				"message": "The return was received but the refund failed; settle it again later",
+				"data":    ret,
+			})
+			return
+		}
+		ret.RefundID = refundID
+	}
+
+	now := time.Now()
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"status":       models.ReturnCompleted,
+			"refund_id":    ret.RefundID,
+			"completed_at": now,
+		}
+		if ret.Resolution == models.ReturnResolutionExchange {
+			exchange := models.Order{
+				UserID:          order.UserID,
+				ShippingAddress: order.ShippingAddress,
+				BillingAddress:  order.BillingAddress,
+				PaymentMethod:   "exchange",
+			}
+			if err := placeNoChargeOrder(tx, &exchange, ret.ProductID, ret.Quantity); err != nil {
+				return err
+			}
+			ret.ExchangeOrderID = &exchange.ID
+			updates["exchange_order_id"] = exchange.ID
+		}
+
+		result := tx.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", ret.ID, models.ReturnReceived).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReturnChanged
+		}
+
+		if ret.RefundAmount > 0 {
+			if err := recordRefund(tx, order, ret.RefundAmount); err != nil {
+				return err
+			}
+		}
+		// An exchange replaces the unit, so only a refund can end the purchase
+		if ret.Resolution != models.ReturnResolutionExchange {
+			if err := clearVerifiedPurchase(tx, order.UserID, ret.ProductID); err != nil {
+				return err
+			}
+		}
+		return syncOrderReturns(tx, order)
+	})
+	if err != nil {
+		switch err {
+		case errInsufficientStock:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Insufficient stock",
+				"message": fmt.Sprintf("%s is out of stock for the exchange; settle again once restocked", ret.OrderItem.ProductName),
+				"data":    ret,
+			})
+		case errReturnChanged:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Return already settled",
+				"message": "This return was settled by someone else",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to complete return",
+			})
+		}
+		return
+	}
+	ret.Status = models.ReturnCompleted
+	ret.CompletedAt = &now
+
+	h.notifyCustomer(ret)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Return completed successfully",
+		"data":    ret,
+	})
+}
+
+// notifyCustomer emails the customer the progress of their return
+func (h *ReturnHandler) notifyCustomer(ret *models.ReturnRequest) {
+	if ret.User == nil || ret.OrderItem == nil {
+		return
+	}
+
+	item := fmt.Sprintf("%d × %s", ret.Quantity, ret.OrderItem.ProductName)
+	var body string
+	switch ret.Status {
+	case models.ReturnApproved:
+		body = fmt.Sprintf("Your return of %s is approved. Your RMA number is %s; write it clearly on the parcel so we can match it to your return.",
+			item, *ret.RMANumber)
+	case models.ReturnRejected:
+		body = fmt.Sprintf("We were unable to approve your return of %s.", item)
+	case models.ReturnCompleted:
+		switch {
+		case ret.ExchangeOrderID != nil:
+			body = fmt.Sprintf("We received your return of %s and are sending you a replacement as a new order, which you can follow in your account.", item)
+		case ret.RefundAmount > 0:
+			body = fmt.Sprintf("We received your return of %s and refunded %.2f %s to your original payment method.",
+				item, ret.RefundAmount, ret.Order.Currency)
+		default:
+			body = fmt.Sprintf("We received your return of %s.", item)
+		}
+		if ret.InspectionNote != "" {
+			body += "\n\n" + ret.InspectionNote
+		}
+	default:
+		return
+	}
+	if ret.ReviewNote != "" && ret.Status != models.ReturnCompleted {
+		body += "\n\n" + ret.ReviewNote
+	}
+
+	subject := "Update on your return"
+	if ret.RMANumber != nil {
+		subject += " " + *ret.RMANumber
+	}
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{ret.User.Email},
+		Subject: subject,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n", ret.User.FirstName, body),
+	})
+}
+
+// findReturn loads the return named by the id parameter from db. It writes
+// an error response and returns false when that fails.
+func findReturn(c *gin.Context, db *gorm.DB) (models.ReturnRequest, bool) {
+	var ret models.ReturnRequest
+	if err := db.First(&ret, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Return not found",
+				"message": "The requested return does not exist",
+			})
+			return ret, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch return",
+		})
+		return ret, false
+	}
+	return ret, true
+}
+
+// returnableQuantity is how many units of an order line are not part of a
+// return yet
+func returnableQuantity(db *gorm.DB, item *models.OrderItem) (int, error) {
+	var returned int64
+	if err := db.Model(&models.ReturnRequest{}).
+		Where("order_item_id = ? AND status IN ?", item.ID, openReturnStatuses).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&returned).Error; err != nil {
+		return 0, err
+	}
+	return item.Quantity - int(returned), nil
+}
+
+// returnRefundAmount is what returning quantity units of an order line pays
+// back: their price plus their share of the order's tax. Shipping is not
+// refunded.
+func returnRefundAmount(order *models.Order, item *models.OrderItem, quantity int) float64 {
+	amount := item.Price * float64(quantity)
+	if order.Subtotal > 0 {
+		amount += order.Tax * amount / order.Subtotal
+	}
+	return pricing.RoundAmount(amount, order.Currency)
+}
+
+// recordRefund adds a refund to the order's refunded total and payment
+// status. Edits that lowered the total refunded the difference, so what was
+// paid is the total plus those refunds.
+func recordRefund(tx *gorm.DB, order *models.Order, amount float64) error {
+	var editRefunds float64
+	if err := tx.Model(&models.OrderEdit{}).
+		Where("order_id = ? AND settlement = ? AND difference < 0", order.ID, models.OrderEditSettlementSettled).
+		Select("COALESCE(SUM(-difference), 0)").Scan(&editRefunds).Error; err != nil {
+		return err
+	}
+	order.RefundedAmount = pricing.RoundAmount(order.RefundedAmount+amount, order.Currency)
+	order.PaymentStatus = models.PaymentStatusPartiallyRefunded
+	if order.RefundedAmount >= pricing.RoundAmount(order.Total+editRefunds, order.Currency) {
+		order.PaymentStatus = models.PaymentStatusRefunded
+	}
+	return tx.Model(order).Select("refunded_amount", "payment_status").Updates(order).Error
+}
+
+// syncOrderReturns moves a delivered order to returned once every unit that
+// can be returned came back, or to partially returned before that
+func syncOrderReturns(tx *gorm.DB, order *models.Order) error {
+	if order.Status != models.OrderStatusDelivered && order.Status != models.OrderStatusPartiallyReturned {
+		return nil
+	}
+
+	var sold, returned int64
+	if err := tx.Model(&models.OrderItem{}).
+		Where("order_id = ? AND line_type NOT IN ?", order.ID, []models.OrderLineType{models.OrderLineDigital, models.OrderLinePrint}).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&sold).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ReturnRequest{}).
+		Where("order_id = ? AND status = ?", order.ID, models.ReturnCompleted).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&returned).Error; err != nil {
+		return err
+	}
+
+	status := models.OrderStatusPartiallyReturned
+	if returned >= sold {
+		status = models.OrderStatusReturned
+	}
+	if status == order.Status {
+		return nil
+	}
+	order.Status = status
+	return tx.Model(order).Update("status", status).Error
+}
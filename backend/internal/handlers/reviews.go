@@ -0,0 +1,713 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/media"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxReviewPhotos limits how many photos a single review can carry
+const maxReviewPhotos = 4
+
+type ReviewHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+}
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" form:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" form:"title" binding:"required,max=150"`
+	Body   string `json:"body" form:"body" binding:"max=5000"`
+}
+
+type ReviewQuery struct {
+	Page     int    `form:"page,default=1"`
+	Limit    int    `form:"limit,default=10"`
+	Rating   int    `form:"rating"`
+	Verified string `form:"verified"`
+	SortBy   string `form:"sortBy,default=newest"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected"`
+	Note   string `json:"note"`
+}
+
+// ReviewSummary aggregates the approved reviews of a product
+type ReviewSummary struct {
+	Average      float64     `json:"average"`
+	Count        int         `json:"count"`
+	Distribution map[int]int `json:"distribution"` // Review count per star rating
+}
+
+func NewReviewHandler(db *gorm.DB, store storage.Storage) *ReviewHandler {
+	return &ReviewHandler{db: db, storage: store}
+}
+
+// GetProductReviews returns the approved reviews of a product with a rating summary
+func (h *ReviewHandler) GetProductReviews(c *gin.Context) {
+	productID := c.Param("id")
+
+	var query ReviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 || query.Limit > 50 {
+		query.Limit = 10
+	}
+
+	db := h.db.Model(&models.Review{}).
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved)
+	if query.Rating >= 1 && query.Rating <= 5 {
+		db = db.Where("rating = ?", query.Rating)
+	}
+	if query.Verified == "true" {
+		db = db.Where("verified_purchase = ?", true)
+	}
+
+	switch query.SortBy {
+	case "helpful":
+		db = db.Order("helpful_count DESC").Order("created_at DESC")
+	case "rating_desc":
+		db = db.Order("rating DESC").Order("created_at DESC")
+	case "rating_asc":
+		db = db.Order("rating ASC").Order("created_at DESC")
+	default:
+		db = db.Order("created_at DESC")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count reviews",
+		})
+		return
+	}
+
+	offset := (query.Page - 1) * query.Limit
+	totalPages := int((total + int64(query.Limit) - 1) / int64(query.Limit))
+
+	var reviews []models.Review
+	if err := db.Preload("User").Offset(offset).Limit(query.Limit).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch reviews",
+		})
+		return
+	}
+	setReviewAuthors(reviews)
+
+	summary, err := summarizeReviews(h.db, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to summarize reviews",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reviews,
+		"summary": summary,
+		"pagination": PaginationResponse{
+			Page:       query.Page,
+			Limit:      query.Limit,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// CreateReview submits a review for moderation. Photos can be attached by
+// sending the review as multipart form data with files in the "photos" field.
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	productID := c.Param("id")
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.Review{}).Where("product_id = ? AND user_id = ?", productID, userID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Review exists",
+			"message": "You have already reviewed this product",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReviewPhotos*maxImageSize+1<<20)
+
+	var req CreateReviewRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["photos"]
+	}
+	if len(files) > maxReviewPhotos {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many photos",
+			"message": fmt.Sprintf("A review can include at most %d photos", maxReviewPhotos),
+		})
+		return
+	}
+
+	review := models.Review{
+		ID:               uuid.New().String(),
+		ProductID:        productID,
+		UserID:           userID,
+		Rating:           req.Rating,
+		Title:            strings.TrimSpace(req.Title),
+		Body:             strings.TrimSpace(req.Body),
+		Photos:           []models.CustomerPhoto{},
+		VerifiedPurchase: hasDeliveredPurchase(h.db, userID, productID),
+		Status:           models.ReviewStatusPending,
+	}
+
+	for _, file := range files {
+		photo, ok := storeCustomerPhoto(c, h.storage, "reviews/"+review.ID, file)
+		if !ok {
+			deleteCustomerPhotos(h.storage, review.Photos)
+			return
+		}
+		review.Photos = append(review.Photos, photo)
+	}
+
+	if err := h.db.Create(&review).Error; err != nil {
+		deleteCustomerPhotos(h.storage, review.Photos)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create review",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Review submitted and awaiting moderation",
+		"data":    review,
+	})
+}
+
+// DeleteReview removes the user's own review
+func (h *ReviewHandler) DeleteReview(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var review models.Review
+	if err := h.db.Where("user_id = ?", userID).First(&review, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Review not found",
+				"message": "The requested review does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find review",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewVote{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete review votes",
+		})
+		return
+	}
+	if err := tx.Delete(&review).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete review",
+		})
+		return
+	}
+	if err := refreshProductRating(tx, review.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product rating",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete review",
+		})
+		return
+	}
+
+	deleteCustomerPhotos(h.storage, review.Photos)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Review deleted successfully",
+	})
+}
+
+// VoteHelpful marks an approved review as helpful for the current user
+func (h *ReviewHandler) VoteHelpful(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var review models.Review
+	if err := h.db.Where("status = ?", models.ReviewStatusApproved).First(&review, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Review not found",
+				"message": "The requested review does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find review",
+		})
+		return
+	}
+
+	if review.UserID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid vote",
+			"message": "You cannot vote on your own review",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.ReviewVote{}).Where("review_id = ? AND user_id = ?", review.ID, userID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Already voted",
+			"message": "You have already marked this review as helpful",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Create(&models.ReviewVote{ReviewID: review.ID, UserID: userID}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+	if err := tx.Model(&review).UpdateColumn("helpful_count", gorm.Expr("helpful_count + 1")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to record vote",
+		})
+		return
+	}
+
+	h.db.First(&review, "id = ?", review.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Vote recorded",
+		"data": gin.H{
+			"helpfulCount": review.HelpfulCount,
+		},
+	})
+}
+
+// RemoveHelpfulVote withdraws the current user's helpful vote
+func (h *ReviewHandler) RemoveHelpfulVote(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	reviewID := c.Param("id")
+
+	tx := h.db.Begin()
+	result := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&models.ReviewVote{})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Vote not found",
+			"message": "You have not marked this review as helpful",
+		})
+		return
+	}
+	if err := tx.Model(&models.Review{}).Where("id = ? AND helpful_count > 0", reviewID).
+		UpdateColumn("helpful_count", gorm.Expr("helpful_count - 1")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove vote",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Vote removed",
+	})
+}
+
+// GetReviews lists reviews for moderation, pending ones by default (admin only)
+func (h *ReviewHandler) GetReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", string(models.ReviewStatusPending))
+
+	db := h.db.Preload("Product", unscoped).Preload("User").Order("created_at ASC")
+	if status != "all" {
+		db = db.Where("status = ?", status)
+	}
+	if productID := c.Query("productId"); productID != "" {
+		db = db.Where("product_id = ?", productID)
+	}
+
+	var reviews []models.Review
+	if err := db.Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch reviews",
+		})
+		return
+	}
+	setReviewAuthors(reviews)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    reviews,
+	})
+}
+
+// ModerateReview approves or rejects a review and refreshes the product rating (admin only)
+func (h *ReviewHandler) ModerateReview(c *gin.Context) {
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var review models.Review
+	if err := h.db.First(&review, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Review not found",
+				"message": "The requested review does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find review",
+		})
+		return
+	}
+
+	now := time.Now()
+	review.Status = models.ReviewStatus(req.Status)
+	review.ModerationNote = strings.TrimSpace(req.Note)
+	review.ModeratedAt = &now
+
+	tx := h.db.Begin()
+	if err := tx.Model(&review).Select("status", "moderation_note", "moderated_at").Updates(&review).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update review",
+		})
+		return
+	}
+	if err := refreshProductRating(tx, review.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update product rating",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update review",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Review " + req.Status,
+		"data":    review,
+	})
+}
+
+// storeCustomerPhoto processes one uploaded customer photo and stores its
+// variants under keyPrefix. It writes an error response and returns false
+// when that fails.
+func storeCustomerPhoto(c *gin.Context, store storage.Storage, keyPrefix string, file *multipart.FileHeader) (models.CustomerPhoto, bool) {
+	if file.Size > maxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "File too large",
+			"message": "Images must be 10 MB or smaller",
+		})
+		return models.CustomerPhoto{}, false
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return models.CustomerPhoto{}, false
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid file",
+			"message": "Failed to read uploaded file",
+		})
+		return models.CustomerPhoto{}, false
+	}
+
+	variants, err := media.Process(data)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "Unsupported file type",
+				"message": "Only JPEG, PNG and GIF images are accepted",
+			})
+			return models.CustomerPhoto{}, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid image",
+			"message": err.Error(),
+		})
+		return models.CustomerPhoto{}, false
+	}
+
+	photo := models.CustomerPhoto{ID: uuid.New().String()}
+	photo.Variants, err = storeVariants(store, keyPrefix+"/"+photo.ID, variants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Storage error",
+			"message": "Failed to store image",
+		})
+		return models.CustomerPhoto{}, false
+	}
+	photo.URL, photo.Width, photo.Height, _ = primaryVariant(photo.Variants)
+
+	return photo, true
+}
+
+func deleteCustomerPhotos(store storage.Storage, photos []models.CustomerPhoto) {
+	for _, photo := range photos {
+		deleteVariants(store, photo.Variants)
+	}
+}
+
+// receivedOrderStatuses are the states of orders that reached the customer,
+// including those with some lines sent back since
+var receivedOrderStatuses = []models.OrderStatus{
+	models.OrderStatusDelivered,
+	models.OrderStatusPartiallyReturned,
+	models.OrderStatusReturned,
+}
+
+// hasDeliveredPurchase reports whether the user received the product in an
+// order and kept at least one unit of it
+func hasDeliveredPurchase(db *gorm.DB, userID, productID string) bool {
+	returned := db.Model(&models.ReturnRequest{}).Select("COALESCE(SUM(quantity), 0)").
+		Where("return_requests.order_item_id = order_items.id AND return_requests.status = ?", models.ReturnCompleted)
+
+	var count int64
+	db.Model(&models.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status IN ? AND order_items.product_id = ?",
+			userID, receivedOrderStatuses, productID).
+		Where("order_items.quantity > (?)", returned).
+		Count(&count)
+	return count > 0
+}
+
+// markVerifiedPurchases flags the buyer's existing reviews of the products in a delivered order
+func markVerifiedPurchases(db *gorm.DB, order *models.Order) {
+	db.Model(&models.Review{}).
+		Where("user_id = ? AND verified_purchase = ?", order.UserID, false).
+		Where("product_id IN (?)", db.Model(&models.OrderItem{}).Select("product_id").Where("order_id = ? AND line_type <> ?", order.ID, models.OrderLinePrint)).
+		Update("verified_purchase", true)
+}
+
+// clearVerifiedPurchase unflags the buyer's reviews of a product once
+// completed returns leave them no delivered unit of it
+func clearVerifiedPurchase(db *gorm.DB, userID, productID string) error {
+	if hasDeliveredPurchase(db, userID, productID) {
+		return nil
+	}
+	return db.Model(&models.Review{}).
+		Where("user_id = ? AND product_id = ? AND verified_purchase = ?", userID, productID, true).
+		Update("verified_purchase", false).Error
+}
+
+// refreshProductRating recomputes the cached rating aggregate of a product from its approved reviews
+func refreshProductRating(db *gorm.DB, productID string) error {
+	var aggregate struct {
+		Average float64
+		Count   int
+	}
+	err := db.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved).
+		Scan(&aggregate).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Unscoped().Model(&models.Product{}).Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"rating_average": math.Round(aggregate.Average*100) / 100,
+		"review_count":   aggregate.Count,
+	}).Error
+}
+
+// summarizeReviews builds the rating summary shown above a product's reviews
+func summarizeReviews(db *gorm.DB, productID string) (ReviewSummary, error) {
+	var rows []struct {
+		Rating int
+		Count  int
+	}
+	err := db.Model(&models.Review{}).
+		Select("rating, COUNT(*) AS count").
+		Where("product_id = ? AND status = ?", productID, models.ReviewStatusApproved).
+		Group("rating").
+		Scan(&rows).Error
+	if err != nil {
+		return ReviewSummary{}, err
+	}
+
+	summary := ReviewSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	total := 0
+	for _, row := range rows {
+		summary.Distribution[row.Rating] = row.Count
+		summary.Count += row.Count
+		total += row.Rating * row.Count
+	}
+	if summary.Count > 0 {
+		summary.Average = math.Round(float64(total)/float64(summary.Count)*100) / 100
+	}
+	return summary, nil
+}
+
+// setReviewAuthors fills in the public author name of each review
+func setReviewAuthors(reviews []models.Review) {
+	for i := range reviews {
+		reviews[i].Author = displayName(reviews[i].User)
+	}
+}
+
+// displayName is how customers are shown publicly: first name and last initial
+func displayName(user *models.User) string {
+	if user == nil {
+		return ""
+	}
+	name := user.FirstName
+	if last := []rune(strings.TrimSpace(user.LastName)); len(last) > 0 {
+		name += " " + string(last[0]) + "."
+	}
+	return name
+}
@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/carriers"
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trackingPollInterval is how long a shipment's tracking is trusted before
+// the carrier is asked again
+const trackingPollInterval = 30 * time.Minute
+
+type ShipmentHandler struct {
+	db       *gorm.DB
+	carriers carriers.Registry
+	mailer   email.Sender
+}
+
+type ShipmentItemRequest struct {
+	OrderItemID string `json:"orderItemId" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+}
+
+type CreateShipmentRequest struct {
+	Carrier        string                `json:"carrier" binding:"required,max=30"`
+	TrackingNumber string                `json:"trackingNumber" binding:"required,max=64"`
+	Warehouse      string                `json:"warehouse" binding:"max=64"`
+	Items          []ShipmentItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type UpdateShipmentStatusRequest struct {
+	Status      string `json:"status" binding:"required,oneof=label_created in_transit out_for_delivery delivered exception"`
+	Description string `json:"description" binding:"max=255"`
+	Location    string `json:"location" binding:"max=100"`
+}
+
+func NewShipmentHandler(db *gorm.DB, registry carriers.Registry, mailer email.Sender) *ShipmentHandler {
+	return &ShipmentHandler{db: db, carriers: registry, mailer: mailer}
+}
+
+// CreateShipment records a parcel sent for some or all of the unshipped
+// units of an order. The order becomes partially shipped until every unit
+// has left (admin only).
+func (h *ShipmentHandler) CreateShipment(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var order models.Order
+	if err := h.db.Preload("Items").Preload("PrintJobs").Preload("User").First(&order, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order not found",
+				"message": "The requested order does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return
+	}
+
+	switch order.Status {
+	case models.OrderStatusConfirmed, models.OrderStatusInProduction, models.OrderStatusProcessing, models.OrderStatusPartiallyShipped:
+	default:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not fulfillable",
+			"message": fmt.Sprintf("Only paid orders with units left to ship can be shipped; this order is %s", order.Status),
+		})
+		return
+	}
+	if order.PaymentStatus != models.PaymentStatusPaid && order.PaymentStatus != models.PaymentStatusPartiallyRefunded {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not fulfillable",
+			"message": "The order must be paid in full before it ships",
+		})
+		return
+	}
+	// Changes that made the order cost more must be paid for too
+	var unpaidEdits int64
+	if err := h.db.Model(&models.OrderEdit{}).
+		Where("order_id = ? AND difference > 0 AND settlement IN ?", order.ID,
+			[]models.OrderEditSettlement{models.OrderEditSettlementPending, models.OrderEditSettlementFailed}).
+		Count(&unpaidEdits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to check the order's changes",
+		})
+		return
+	}
+	if unpaidEdits > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not fulfillable",
+			"message": "The difference for a change to the order has not been paid yet",
+		})
+		return
+	}
+
+	shipped, err := shippedQuantities(h.db, order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch shipped quantities",
+		})
+		return
+	}
+
+	lines := make(map[string]*models.OrderItem, len(order.Items))
+	for i := range order.Items {
+		lines[order.Items[i].ID] = &order.Items[i]
+	}
+	printed := make(map[string]bool, len(order.PrintJobs))
+	for _, job := range order.PrintJobs {
+		printed[job.OrderItemID] = job.Status == models.PrintJobDone
+	}
+
+	shipment := models.Shipment{
+		OrderID:        order.ID,
+		Carrier:        strings.ToLower(strings.TrimSpace(req.Carrier)),
+		TrackingNumber: strings.TrimSpace(req.TrackingNumber),
+		Warehouse:      strings.TrimSpace(req.Warehouse),
+		Status:         models.ShipmentLabelCreated,
+		ShippedAt:      time.Now(),
+		CreatedBy:      adminID,
+	}
+	packed := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		line := lines[item.OrderItemID]
+		message := ""
+		switch {
+		case line == nil:
+			message = fmt.Sprintf("Order item %s is not part of this order", item.OrderItemID)
+		case packed[line.ID]:
+			message = fmt.Sprintf("%s is listed twice", line.ProductName)
+		case line.LineType == models.OrderLineDigital:
+			message = fmt.Sprintf("%s is delivered as a download and is not shipped", line.ProductName)
+		case line.IsPending():
+			message = fmt.Sprintf("%s is still waiting for stock", line.ProductName)
+		case line.LineType == models.OrderLinePrint && !printed[line.ID]:
+			message = fmt.Sprintf("%s is still in production", line.ProductName)
+		case item.Quantity > line.Quantity-shipped[line.ID]:
+			message = fmt.Sprintf("Only %d units of %s are left to ship", line.Quantity-shipped[line.ID], line.ProductName)
+		}
+		if message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid shipment",
+				"message": message,
+			})
+			return
+		}
+		packed[line.ID] = true
+		shipment.Items = append(shipment.Items, models.ShipmentItem{OrderItemID: line.ID, Quantity: item.Quantity})
+	}
+
+	description := "Shipping label created"
+	if shipment.Warehouse != "" {
+		description += " at " + shipment.Warehouse
+	}
+	shipment.Events = []models.ShipmentEvent{{
+		Status:      models.ShipmentLabelCreated,
+		Description: description,
+		Location:    shipment.Warehouse,
+		OccurredAt:  shipment.ShippedAt,
+	}}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		return syncOrderShipping(tx, order.ID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create shipment",
+		})
+		return
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{order.User.Email},
+		Subject: fmt.Sprintf("Your order %s has shipped", order.OrderNumber),
+		Body: fmt.Sprintf("Hi %s,\n\nA parcel from your order %s is on its way with %s, tracking number %s.\n",
+			order.User.FirstName, order.OrderNumber, shipment.Carrier, shipment.TrackingNumber),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Shipment created successfully",
+		"data":    shipment,
+	})
+}
+
+// GetOrderShipments lists the shipments of an order (admin only)
+func (h *ShipmentHandler) GetOrderShipments(c *gin.Context) {
+	var shipments []models.Shipment
+	if err := preloadShipmentDetails(h.db).Where("order_id = ?", c.Param("id")).
+		Order("shipped_at ASC").Find(&shipments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch shipments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    shipments,
+	})
+}
+
+// GetShipments lists shipments, optionally by status or carrier, such as
+// every parcel with a delivery exception (admin only)
+func (h *ShipmentHandler) GetShipments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.Shipment{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if carrier := c.Query("carrier"); carrier != "" {
+		query = query.Where("carrier = ?", strings.ToLower(carrier))
+	}
+	if tracking := c.Query("trackingNumber"); tracking != "" {
+		query = query.Where("tracking_number = ?", tracking)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count shipments",
+		})
+		return
+	}
+
+	var shipments []models.Shipment
+	if err := preloadShipmentDetails(query).Order("shipped_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&shipments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch shipments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    shipments,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// UpdateShipmentStatus records a scan by hand, for carriers without a
+// tracking adapter (admin only)
+func (h *ShipmentHandler) UpdateShipmentStatus(c *gin.Context) {
+	var req UpdateShipmentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	shipment, ok := findShipment(c, h.db)
+	if !ok {
+		return
+	}
+	if shipment.Status == models.ShipmentDelivered {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Shipment delivered",
+			"message": "This shipment has already been delivered",
+		})
+		return
+	}
+
+	event := models.ShipmentEvent{
+		ShipmentID:  shipment.ID,
+		Status:      models.ShipmentStatus(req.Status),
+		Description: strings.TrimSpace(req.Description),
+		Location:    strings.TrimSpace(req.Location),
+		OccurredAt:  time.Now(),
+	}
+	if event.Description == "" {
+		event.Description = "Status updated to " + strings.ReplaceAll(req.Status, "_", " ")
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return applyShipmentStatus(tx, &shipment, event.Status, event.OccurredAt)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to update shipment",
+		})
+		return
+	}
+
+	preloadShipmentDetails(h.db).First(&shipment, "id = ?", shipment.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Shipment status updated successfully",
+		"data":    shipment,
+	})
+}
+
+// RefreshTracking asks the carrier for the latest scans of a shipment now
+// instead of waiting for the next poll (admin only)
+func (h *ShipmentHandler) RefreshTracking(c *gin.Context) {
+	shipment, ok := findShipment(c, h.db)
+	if !ok {
+		return
+	}
+	if h.carriers[shipment.Carrier] == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "No tracking adapter",
+			"message": fmt.Sprintf("Carrier %s cannot be tracked automatically; update the status by hand", shipment.Carrier),
+		})
+		return
+	}
+
+	if _, err := h.track(&shipment); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Tracking error",
+			"message": "Failed to fetch tracking from the carrier",
+		})
+		return
+	}
+
+	preloadShipmentDetails(h.db).First(&shipment, "id = ?", shipment.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    shipment,
+	})
+}
+
+// DeleteShipment voids a shipment whose parcel has not been picked up, such
+// as one created with the wrong items. Its units can be shipped again (admin only).
+func (h *ShipmentHandler) DeleteShipment(c *gin.Context) {
+	shipment, ok := findShipment(c, h.db)
+	if !ok {
+		return
+	}
+	if shipment.Status != models.ShipmentLabelCreated {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Shipment in transit",
+			"message": "Only shipments that have not been picked up can be voided",
+		})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shipment_id = ?", shipment.ID).Delete(&models.ShipmentItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shipment_id = ?", shipment.ID).Delete(&models.ShipmentEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&shipment).Error; err != nil {
+			return err
+		}
+		return syncOrderShipping(tx, shipment.OrderID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to void shipment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Shipment voided successfully",
+	})
+}
+
+// PollTracking refreshes the tracking of parcels on their way with carriers
+// that have an adapter, and returns how many changed. It is scheduled as a
+// background job.
+func (h *ShipmentHandler) PollTracking() (int, error) {
+	codes := make([]string, 0, len(h.carriers))
+	for code := range h.carriers {
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return 0, nil
+	}
+
+	var shipments []models.Shipment
+	if err := h.db.Where("status <> ? AND carrier IN ?", models.ShipmentDelivered, codes).
+		Where("last_checked_at IS NULL OR last_checked_at < ?", time.Now().Add(-trackingPollInterval)).
+		Order("last_checked_at ASC").Find(&shipments).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch shipments to track: %w", err)
+	}
+
+	// One carrier being down should not hold up the others
+	changed, failed := 0, 0
+	var lastErr error
+	for i := range shipments {
+		updated, err := h.track(&shipments[i])
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		if updated {
+			changed++
+		}
+	}
+	if failed > 0 {
+		return changed, fmt.Errorf("failed to track %d shipments: %w", failed, lastErr)
+	}
+	return changed, nil
+}
+
+// track replaces a shipment's scans with the carrier's and reports whether
+// anything changed. Parcels the carrier does not know yet are left as they are.
+func (h *ShipmentHandler) track(shipment *models.Shipment) (bool, error) {
+	now := time.Now()
+	tracking, err := h.carriers[shipment.Carrier].Track(shipment.TrackingNumber)
+	if err != nil {
+		if errors.Is(err, carriers.ErrUnknownParcel) {
+			return false, h.db.Model(shipment).Update("last_checked_at", now).Error
+		}
+		return false, fmt.Errorf("failed to track %s %s: %w", shipment.Carrier, shipment.TrackingNumber, err)
+	}
+	if !carriers.IsValidStatus(tracking.Status) {
+		return false, fmt.Errorf("carrier %s reported unknown status %q", shipment.Carrier, tracking.Status)
+	}
+
+	var count int64
+	h.db.Model(&models.ShipmentEvent{}).Where("shipment_id = ?", shipment.ID).Count(&count)
+	status := models.ShipmentStatus(tracking.Status)
+	changed := status != shipment.Status || int(count) != len(tracking.Events)
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(shipment).Update("last_checked_at", now).Error; err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+
+		if err := tx.Where("shipment_id = ?", shipment.ID).Delete(&models.ShipmentEvent{}).Error; err != nil {
+			return err
+		}
+		events := make([]models.ShipmentEvent, len(tracking.Events))
+		for i, scan := range tracking.Events {
+			events[i] = models.ShipmentEvent{
+				ShipmentID:  shipment.ID,
+				Status:      models.ShipmentStatus(scan.Status),
+				Description: scan.Description,
+				Location:    scan.Location,
+				OccurredAt:  scan.OccurredAt,
+			}
+		}
+		if len(events) > 0 {
+			if err := tx.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+
+		at := now
+		if len(events) > 0 {
+			at = events[len(events)-1].OccurredAt
+		}
+		return applyShipmentStatus(tx, shipment, status, at)
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed && status == models.ShipmentException {
+		log.Printf("Shipment %s (%s %s) reported a delivery exception", shipment.ID, shipment.Carrier, shipment.TrackingNumber)
+	}
+	return changed, nil
+}
+
+// applyShipmentStatus moves a shipment to status as of at and brings its
+// order along
+func applyShipmentStatus(tx *gorm.DB, shipment *models.Shipment, status models.ShipmentStatus, at time.Time) error {
+	updates := map[string]interface{}{"status": status}
+	if status == models.ShipmentDelivered {
+		updates["delivered_at"] = at
+	}
+	if err := tx.Model(shipment).Updates(updates).Error; err != nil {
+		return err
+	}
+	return syncOrderShipping(tx, shipment.OrderID)
+}
+
+// syncOrderShipping derives a fulfilled order's status from its shipments:
+// partially shipped while units are left, shipped once every unit has left
+// and delivered once every parcel has arrived. Orders that are not being
+// fulfilled, such as cancelled or returned ones, are left alone.
+func syncOrderShipping(tx *gorm.DB, orderID string) error {
+	var order models.Order
+	if err := tx.First(&order, "id = ?", orderID).Error; err != nil {
+		return err
+	}
+	switch order.Status {
+	case models.OrderStatusConfirmed, models.OrderStatusInProduction, models.OrderStatusProcessing,
+		models.OrderStatusPartiallyShipped, models.OrderStatusShipped:
+	default:
+		return nil
+	}
+
+	var shippable int64
+	if err := tx.Model(&models.OrderItem{}).Where("order_id = ? AND line_type <> ?", orderID, models.OrderLineDigital).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&shippable).Error; err != nil {
+		return err
+	}
+
+	var shipments []models.Shipment
+	if err := tx.Preload("Items").Where("order_id = ?", orderID).Find(&shipments).Error; err != nil {
+		return err
+	}
+	shipped := 0
+	delivered := len(shipments) > 0
+	var deliveredAt time.Time
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			shipped += item.Quantity
+		}
+		if shipment.Status != models.ShipmentDelivered || shipment.DeliveredAt == nil {
+			delivered = false
+		} else if shipment.DeliveredAt.After(deliveredAt) {
+			deliveredAt = *shipment.DeliveredAt
+		}
+	}
+
+	status := order.Status
+	switch {
+	case shipped == 0:
+		if status == models.OrderStatusPartiallyShipped || status == models.OrderStatusShipped {
+			status = models.OrderStatusProcessing
+		}
+	case int64(shipped) < shippable:
+		status = models.OrderStatusPartiallyShipped
+	case delivered:
+		status = models.OrderStatusDelivered
+	default:
+		status = models.OrderStatusShipped
+	}
+	if status == order.Status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == models.OrderStatusDelivered {
+		updates["delivered_at"] = deliveredAt
+	}
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// Reviews written before the order arrived become verified on delivery
+	if status == models.OrderStatusDelivered {
+		markVerifiedPurchases(tx, &order)
+	}
+	return nil
+}
+
+// shippedQuantities returns how many units of each line of an order have shipped
+func shippedQuantities(db *gorm.DB, orderID string) (map[string]int, error) {
+	var rows []struct {
+		OrderItemID string
+		Quantity    int
+	}
+	if err := db.Model(&models.ShipmentItem{}).
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.order_id = ?", orderID).
+		Select("shipment_items.order_item_id, SUM(shipment_items.quantity) AS quantity").
+		Group("shipment_items.order_item_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	shipped := make(map[string]int, len(rows))
+	for _, row := range rows {
+		shipped[row.OrderItemID] = row.Quantity
+	}
+	return shipped, nil
+}
+
+// preloadShipmentDetails loads the items and scans, oldest first, of the shipments in db
+func preloadShipmentDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items").Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at ASC")
+	})
+}
+
+// findShipment loads the shipment named by the id parameter. It writes an
+// error response and returns false when that fails.
+func findShipment(c *gin.Context, db *gorm.DB) (models.Shipment, bool) {
+	var shipment models.Shipment
+	if err := db.First(&shipment, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Shipment not found",
+				"message": "The requested shipment does not exist",
+			})
+			return shipment, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch shipment",
+		})
+		return shipment, false
+	}
+	return shipment, true
+}
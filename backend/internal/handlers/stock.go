@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StockHandler struct {
+	db          *gorm.DB
+	stockAlerts *alerts.StockNotifier
+}
+
+type StockSubscribeRequest struct {
+	Email string `json:"email" binding:"omitempty,email"` // Defaults to the account email
+}
+
+type ReceiveStockRequest struct {
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+	Note     string `json:"note" binding:"max=500"`
+}
+
+func NewStockHandler(db *gorm.DB, stockAlerts *alerts.StockNotifier) *StockHandler {
+	return &StockHandler{db: db, stockAlerts: stockAlerts}
+}
+
+// SubscribeToStock queues an email address to hear when an out of stock product is available again
+func (h *StockHandler) SubscribeToStock(c *gin.Context) {
+	var req StockSubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var userID *string
+	if id, ok := middleware.GetUserID(c); ok {
+		userID = &id
+		if req.Email == "" {
+			req.Email, _ = middleware.GetUserEmail(c)
+		}
+	}
+	if req.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "An email address is required",
+		})
+		return
+	}
+	address := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	if product.InStockNow() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Product in stock",
+			"message": "This product can be ordered right now",
+		})
+		return
+	}
+
+	// Whether or not the address was already subscribed, the response is the
+	// same and the unsubscribe token only goes to the mailbox
+	var subscription models.StockSubscription
+	confirm := false
+	err := h.db.Where("product_id = ? AND email = ?", product.ID, address).First(&subscription).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		subscription = models.StockSubscription{
+			ProductID: product.ID,
+			Email:     address,
+			UserID:    userID,
+			Status:    models.StockSubscriptionWaiting,
+		}
+		err = h.db.Create(&subscription).Error
+		confirm = true
+	case err == nil && subscription.Status == models.StockSubscriptionNotified:
+		// Already told about an earlier restock; rejoin at the back of the queue
+		subscription.Status = models.StockSubscriptionWaiting
+		subscription.NotifiedAt = nil
+		if userID != nil {
+			subscription.UserID = userID
+		}
+		subscription.CreatedAt = time.Now()
+		err = h.db.Model(&subscription).Updates(map[string]interface{}{
+			"status":      subscription.Status,
+			"notified_at": nil,
+			"user_id":     subscription.UserID,
+			"created_at":  subscription.CreatedAt,
+		}).Error
+		confirm = true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save subscription",
+		})
+		return
+	}
+
+	if confirm {
+		h.stockAlerts.Subscribed(&subscription, &product)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "We will email you when this product is back in stock",
+	})
+}
+
+// unsubscribePage confirms an unsubscribe link from an alert email. The link
+// itself changes nothing, so mail scanners that follow it cannot cancel
+// anyone's alert; the button posts back to the same address.
+const unsubscribePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Back-in-stock alerts</title></head>
+<body><p>%s</p>%s</body></html>`
+
+const unsubscribeForm = `<form method="post"><button type="submit">Stop this alert</button></form>`
+
+// UnsubscribeFromStock cancels a back-in-stock subscription by its token
+func (h *StockHandler) UnsubscribeFromStock(c *gin.Context) {
+	cancelled, err := h.unsubscribe(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to cancel subscription",
+		})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Subscription not found",
+			"message": "The requested subscription does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription cancelled successfully",
+	})
+}
+
+// UnsubscribePage asks the subscriber following an emailed unsubscribe link to confirm
+func (h *StockHandler) UnsubscribePage(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, unsubscribePage, "Stop emailing me when this product is back in stock?", unsubscribeForm)
+}
+
+// UnsubscribeFromLink cancels the subscription of a confirmed unsubscribe link
+func (h *StockHandler) UnsubscribeFromLink(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	cancelled, err := h.unsubscribe(c.Param("token"))
+	switch {
+	case err != nil:
+		c.String(http.StatusInternalServerError, unsubscribePage, "Something went wrong; please try again later.", unsubscribeForm)
+	case !cancelled:
+		c.String(http.StatusNotFound, unsubscribePage, "This alert was already cancelled.", "")
+	default:
+		c.String(http.StatusOK, unsubscribePage, "You will no longer be emailed about this product.", "")
+	}
+}
+
+// unsubscribe deletes the subscription with the token, reporting whether there was one
+func (h *StockHandler) unsubscribe(token string) (bool, error) {
+	result := h.db.Where("token = ?", token).Delete(&models.StockSubscription{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetStockSubscriptions lists a product's back-in-stock queue in FIFO order (admin only)
+func (h *StockHandler) GetStockSubscriptions(c *gin.Context) {
+	var subscriptions []models.StockSubscription
+	if err := h.db.Where("product_id = ?", c.Param("id")).
+		Order("created_at ASC").
+		Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscriptions",
+		})
+		return
+	}
+
+	waiting := 0
+	for _, s := range subscriptions {
+		if s.Status == models.StockSubscriptionWaiting {
+			waiting++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"subscriptions": subscriptions,
+			"waiting":       waiting,
+			"notified":      len(subscriptions) - waiting,
+		},
+	})
+}
+
+// ReceiveStock books goods into stock, allocates it to waiting pre-orders and
+// backorders, and notifies back-in-stock subscribers of what is left (admin only)
+func (h *StockHandler) ReceiveStock(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	var req ReceiveStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	if product.IsBundle {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid product",
+			"message": "Bundle stock comes from its components; receive those instead",
+		})
+		return
+	}
+
+	receipt := models.StockReceipt{
+		ProductID:  product.ID,
+		Quantity:   req.Quantity,
+		Note:       req.Note,
+		ReceivedBy: userID,
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{
+			"stock_quantity": gorm.Expr("stock_quantity + ?", req.Quantity),
+			"in_stock":       true,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&product, "id = ?", product.ID).Error; err != nil {
+			return err
+		}
+		receipt.StockAfter = product.StockQuantity
+		receipt.StockBefore = product.StockQuantity - req.Quantity
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+
+		// Waiting pre-orders and backorders get first claim on new stock
+		allocated, err := allocatePendingLines(tx, product.ID)
+		if err != nil {
+			return err
+		}
+		receipt.AllocatedLines = allocated
+		if err := tx.First(&product, "id = ?", product.ID).Error; err != nil {
+			return err
+		}
+		return syncBundlesContaining(tx, []string{product.ID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to receive stock",
+		})
+		return
+	}
+
+	h.stockAlerts.Restocked(product.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Stock received successfully",
+		"data": gin.H{
+			"receipt": receipt,
+			"product": product,
+		},
+	})
+}
+
+// GetStockReceipts lists the goods received for a product, newest first (admin only)
+func (h *StockHandler) GetStockReceipts(c *gin.Context) {
+	var receipts []models.StockReceipt
+	if err := h.db.Where("product_id = ?", c.Param("id")).
+		Order("created_at DESC").
+		Find(&receipts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch stock receipts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    receipts,
+	})
+}
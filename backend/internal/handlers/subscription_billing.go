@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/payments"
+	"bizoe-3d-store/internal/pricing"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// subscriptionRetryDelays is the dunning schedule: how long after each
+// declined payment the charge is tried again. The subscription is cancelled
+// when the payment is still declined after the last retry.
+var subscriptionRetryDelays = []time.Duration{
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// subscriptionStockRetry is how long a subscription waits before trying
+// again when its product cannot be ordered
+const subscriptionStockRetry = 24 * time.Hour
+
+// BillDue resumes subscriptions whose pause has ended, then places and
+// charges an order for every subscription that is due, including payment
+// retries. It returns how many orders were paid. It is scheduled as a
+// background job.
+func (h *SubscriptionHandler) BillDue() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var resumed []models.Subscription
+	if err := h.db.Where("status = ? AND paused_until IS NOT NULL AND paused_until <= ?", models.SubscriptionPaused, time.Now()).
+		Find(&resumed).Error; err != nil {
+		return 0, fmt.Errorf("failed to find paused subscriptions: %w", err)
+	}
+	for i := range resumed {
+		if err := resumeSubscription(h.db, &resumed[i]); err != nil {
+			return 0, fmt.Errorf("failed to resume subscription %s: %w", resumed[i].ID, err)
+		}
+	}
+
+	var due []models.Subscription
+	if err := h.db.Where("status IN ? AND next_run_at <= ?",
+		[]models.SubscriptionStatus{models.SubscriptionActive, models.SubscriptionPastDue}, time.Now()).
+		Order("next_run_at ASC").
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to find due subscriptions: %w", err)
+	}
+
+	paid := 0
+	for i := range due {
+		ok, err := h.bill(&due[i])
+		if err != nil {
+			// One broken subscription must not hold up the others
+			log.Printf("Failed to bill subscription %s: %v", due[i].ID, err)
+			continue
+		}
+		if ok {
+			paid++
+		}
+	}
+	return paid, nil
+}
+
+// billNow bills one subscription straight away if it is due, for changes
+// that should not wait for the next scheduled run
+func (h *SubscriptionHandler) billNow(subscriptionID string) {
+	go func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		var subscription models.Subscription
+		if err := h.db.Where("id = ? AND status IN ? AND next_run_at <= ?", subscriptionID,
+			[]models.SubscriptionStatus{models.SubscriptionActive, models.SubscriptionPastDue}, time.Now()).
+			First(&subscription).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				log.Printf("Failed to load subscription %s: %v", subscriptionID, err)
+			}
+			return
+		}
+		if _, err := h.bill(&subscription); err != nil {
+			log.Printf("Failed to bill subscription %s: %v", subscriptionID, err)
+		}
+	}()
+}
+
+// bill charges the subscription's unpaid order, placing a new one first
+// unless a payment is being retried. It reports whether the order was paid.
+func (h *SubscriptionHandler) bill(subscription *models.Subscription) (bool, error) {
+	var user models.User
+	if err := h.db.First(&user, "id = ?", subscription.UserID).Error; err != nil {
+		return false, err
+	}
+
+	order, err := h.pendingSubscriptionOrder(subscription)
+	if err != nil {
+		return false, err
+	}
+	if order == nil {
+		order, err = h.placeSubscriptionOrder(subscription, &user)
+		if err != nil || order == nil {
+			return false, err
+		}
+	}
+
+	amount := order.Total - order.BalanceDue
+	attempt := subscription.FailedAttempts + 1
+	paymentID, err := h.payments.Charge(payments.Charge{
+		CustomerID:      user.PaymentCustomerID,
+		PaymentMethodID: subscription.PaymentMethodID,
+		Amount:          amount,
+		Currency:        order.Currency,
+		Description:     fmt.Sprintf("Subscription order %s", order.OrderNumber),
+		Metadata: map[string]string{
+			"order_id":        order.ID,
+			"subscription_id": subscription.ID,
+		},
+		IdempotencyKey: fmt.Sprintf("%s-%d", order.ID, attempt),
+	})
+	if err != nil && !errors.Is(err, payments.ErrDeclined) {
+		// The gateway could not be reached; try again on the next run
+		// without counting it against the customer
+		return false, err
+	}
+
+	charge := models.SubscriptionCharge{
+		SubscriptionID: subscription.ID,
+		OrderID:        order.ID,
+		Attempt:        attempt,
+		Amount:         amount,
+		Currency:       order.Currency,
+		Status:         models.SubscriptionChargeSucceeded,
+		PaymentID:      paymentID,
+	}
+	if err != nil {
+		charge.Status = models.SubscriptionChargeFailed
+		charge.Error = err.Error()
+	}
+	if err := h.db.Create(&charge).Error; err != nil {
+		return false, err
+	}
+
+	if charge.Status == models.SubscriptionChargeFailed {
+		return false, h.paymentDeclined(subscription, &user, order, charge.Error)
+	}
+	return true, h.paymentSucceeded(subscription, &user, order, paymentID)
+}
+
+// pendingSubscriptionOrder returns the unpaid order a payment retry is for,
+// or nil when a new order is due
+func (h *SubscriptionHandler) pendingSubscriptionOrder(subscription *models.Subscription) (*models.Order, error) {
+	if subscription.PendingOrderID == nil {
+		return nil, nil
+	}
+	var order models.Order
+	err := h.db.First(&order, "id = ?", *subscription.PendingOrderID).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == models.OrderStatusCancelled {
+		// Cancelled from the order pages; start over with a new order
+		return nil, nil
+	}
+	return &order, nil
+}
+
+// placeSubscriptionOrder creates the order for the next delivery. It returns
+// nil without an error when the product cannot be ordered right now, after
+// rescheduling or cancelling the subscription as appropriate.
+func (h *SubscriptionHandler) placeSubscriptionOrder(subscription *models.Subscription, user *models.User) (*models.Order, error) {
+	var product models.Product
+	if err := h.db.Unscoped().First(&product, "id = ?", subscription.ProductID).Error; err != nil {
+		return nil, err
+	}
+	if err := loadBundle(h.db, &product); err != nil {
+		return nil, err
+	}
+	if product.ArchivedAt.Valid {
+		h.endSubscription(subscription, user, "product_unavailable",
+			fmt.Sprintf("%s is no longer sold, so your subscription has ended.", product.Name))
+		return nil, nil
+	}
+
+	available, err := orderableQuantity(h.db, &product)
+	if err != nil {
+		return nil, err
+	}
+	if available < subscription.Quantity {
+		log.Printf("Subscription %s postponed: %s is out of stock", subscription.ID, product.Name)
+		return nil, h.db.Model(subscription).Updates(map[string]interface{}{
+			"next_run_at": time.Now().Add(subscriptionStockRetry),
+			"last_error":  "out_of_stock",
+		}).Error
+	}
+
+	price, err := h.subscriptionPrice(&product, subscription.Currency)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := h.pricing.Rate(subscription.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	subtotal := pricing.RoundAmount(price*float64(subscription.Quantity), subscription.Currency)
+	tax, shipping, total, err := orderTotals(h.pricing, subtotal, subscription.Currency, true)
+	if err != nil {
+		return nil, err
+	}
+
+	order := models.Order{
+		UserID:          subscription.UserID,
+		Status:          models.OrderStatusPending,
+		ShippingAddress: subscription.ShippingAddress,
+		BillingAddress:  subscription.ShippingAddress,
+		PaymentMethod:   "subscription",
+		PaymentStatus:   models.PaymentStatusPending,
+		Subtotal:        subtotal,
+		Tax:             tax,
+		Shipping:        shipping,
+		Total:           total,
+		Currency:        subscription.Currency,
+		ExchangeRate:    rate.Rate,
+		SubscriptionID:  &subscription.ID,
+	}
+	item := models.CartItem{
+		ProductID: product.ID,
+		Quantity:  subscription.Quantity,
+		Price:     price,
+		Product:   product,
+	}
+
+	var touched []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		balance, productIDs, err := orderLines(tx, order.ID, &item, order.Currency)
+		if err != nil {
+			return err
+		}
+		touched = productIDs
+		if balance > 0 {
+			order.BalanceDue = pricing.RoundAmount(balance, order.Currency)
+			if err := tx.Model(&order).Update("balance_due", order.BalanceDue).Error; err != nil {
+				return err
+			}
+		}
+		if err := syncBundlesContaining(tx, touched); err != nil {
+			return err
+		}
+
+		// Skipped, paused or cancelled while the order was being placed
+		result := tx.Model(&models.Subscription{}).
+			Where("id = ? AND status = ? AND next_run_at = ?", subscription.ID, subscription.Status, subscription.NextRunAt).
+			Update("pending_order_id", order.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSubscriptionChanged
+		}
+		return nil
+	})
+	if err == errSubscriptionChanged {
+		return nil, nil
+	}
+	if err == errInsufficientStock {
+		// Sold out between the availability check and the order
+		return nil, h.db.Model(subscription).Updates(map[string]interface{}{
+			"next_run_at": time.Now().Add(subscriptionStockRetry),
+			"last_error":  "out_of_stock",
+		}).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	subscription.PendingOrderID = &order.ID
+	return &order, nil
+}
+
+var errSubscriptionChanged = errors.New("subscription changed")
+
+// subscriptionPrice quotes the product in currency less the plan discount
+func (h *SubscriptionHandler) subscriptionPrice(product *models.Product, currency string) (float64, error) {
+	price, _, err := h.pricing.Quote(product, currency)
+	if err != nil {
+		return 0, err
+	}
+	var plan models.SubscriptionPlan
+	if err := h.db.Where("product_id = ?", product.ID).First(&plan).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return price, nil
+		}
+		return 0, err
+	}
+	return pricing.RoundAmount(price*(1-plan.DiscountPercent/100), currency), nil
+}
+
+// paymentSucceeded confirms the order and schedules the next delivery
+func (h *SubscriptionHandler) paymentSucceeded(subscription *models.Subscription, user *models.User, order *models.Order, paymentID string) error {
+	order.PaymentStatus = models.PaymentStatusPaid
+	if order.BalanceDue > 0 {
+		order.PaymentStatus = models.PaymentStatusDeposit
+	}
+	order.Status = models.OrderStatusConfirmed
+	order.PaymentIntentID = paymentID
+	if err := h.db.Model(order).Updates(map[string]interface{}{
+		"payment_status":    order.PaymentStatus,
+		"status":            order.Status,
+		"payment_intent_id": order.PaymentIntentID,
+	}).Error; err != nil {
+		return err
+	}
+
+	// Retries push the schedule back; regular runs keep their rhythm
+	interval := time.Duration(subscription.IntervalDays) * 24 * time.Hour
+	next := subscription.NextRunAt.Add(interval)
+	if subscription.FailedAttempts > 0 || !next.After(time.Now()) {
+		next = time.Now().Add(interval)
+	}
+
+	subscription.Status = models.SubscriptionActive
+	subscription.NextRunAt = next
+	subscription.FailedAttempts = 0
+	subscription.LastError = ""
+	subscription.PendingOrderID = nil
+	subscription.LastOrderID = &order.ID
+	if err := h.db.Model(subscription).Updates(map[string]interface{}{
+		"status":           subscription.Status,
+		"next_run_at":      subscription.NextRunAt,
+		"failed_attempts":  0,
+		"last_error":       "",
+		"pending_order_id": nil,
+		"last_order_id":    order.ID,
+	}).Error; err != nil {
+		return err
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{user.Email},
+		Subject: fmt.Sprintf("Your subscription order %s", order.OrderNumber),
+		Body: fmt.Sprintf("Hi %s,\n\nWe have placed and charged your subscription order %s for %.2f %s. "+
+			"Your next delivery is scheduled for %s.\n",
+			user.FirstName, order.OrderNumber, order.Total-order.BalanceDue, order.Currency, next.Format("2 January 2006")),
+	})
+	return nil
+}
+
+// paymentDeclined schedules the next retry from the dunning schedule, or
+// cancels the order and the subscription once the retries are used up
+func (h *SubscriptionHandler) paymentDeclined(subscription *models.Subscription, user *models.User, order *models.Order, reason string) error {
+	attempt := subscription.FailedAttempts + 1
+	if attempt > len(subscriptionRetryDelays) {
+		var touched []string
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Preload("Items").First(order, "id = ?", order.ID).Error; err != nil {
+				return err
+			}
+			var err error
+			touched, err = cancelOrder(tx, order)
+			if err != nil {
+				return err
+			}
+			return tx.Model(order).Update("payment_status", models.PaymentStatusFailed).Error
+		})
+		if err != nil {
+			return err
+		}
+		h.stockAlerts.Restocked(touched...)
+
+		subscription.PendingOrderID = nil
+		subscription.FailedAttempts = attempt
+		subscription.LastError = reason
+		h.db.Model(subscription).Updates(map[string]interface{}{
+			"pending_order_id": nil,
+			"failed_attempts":  attempt,
+			"last_error":       reason,
+		})
+		h.endSubscription(subscription, user, "payment_failed",
+			fmt.Sprintf("We could not take payment for order %s after several attempts, so the order "+
+				"and your subscription have been cancelled. You are welcome to subscribe again at any time.", order.OrderNumber))
+		return nil
+	}
+
+	retryAt := time.Now().Add(subscriptionRetryDelays[attempt-1])
+	subscription.Status = models.SubscriptionPastDue
+	subscription.FailedAttempts = attempt
+	subscription.LastError = reason
+	subscription.NextRunAt = retryAt
+	if err := h.db.Model(subscription).Updates(map[string]interface{}{
+		"status":          subscription.Status,
+		"failed_attempts": attempt,
+		"last_error":      reason,
+		"next_run_at":     retryAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{user.Email},
+		Subject: fmt.Sprintf("Payment failed for subscription order %s", order.OrderNumber),
+		Body: fmt.Sprintf("Hi %s,\n\nWe could not charge your saved payment method for order %s (%s). "+
+			"We will try again on %s. To avoid missing your delivery, please update the payment method "+
+			"on your subscription.\n",
+			user.FirstName, order.OrderNumber, reason, retryAt.Format("2 January 2006")),
+	})
+	return nil
+}
+
+// endSubscription cancels a subscription on the customer's behalf and tells them why
+func (h *SubscriptionHandler) endSubscription(subscription *models.Subscription, user *models.User, reason, explanation string) {
+	now := time.Now()
+	subscription.Status = models.SubscriptionCancelled
+	subscription.CancelReason = reason
+	subscription.CancelledAt = &now
+	if err := h.db.Model(subscription).Updates(map[string]interface{}{
+		"status":        subscription.Status,
+		"cancel_reason": reason,
+		"cancelled_at":  now,
+	}).Error; err != nil {
+		log.Printf("Failed to cancel subscription %s: %v", subscription.ID, err)
+		return
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{user.Email},
+		Subject: "Your subscription has been cancelled",
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n", user.FirstName, explanation),
+	})
+}
+
+// resumeSubscription reactivates a paused subscription. A delivery missed
+// during the pause is placed on the next run rather than all at once.
+func resumeSubscription(db *gorm.DB, subscription *models.Subscription) error {
+	subscription.Status = models.SubscriptionActive
+	subscription.PausedUntil = nil
+	if now := time.Now(); subscription.NextRunAt.Before(now) {
+		subscription.NextRunAt = now
+	}
+	return db.Model(subscription).Updates(map[string]interface{}{
+		"status":       subscription.Status,
+		"paused_until": nil,
+		"next_run_at":  subscription.NextRunAt,
+	}).Error
+}
@@ -0,0 +1,818 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/addresses"
+	"bizoe-3d-store/internal/alerts"
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/payments"
+	"bizoe-3d-store/internal/pricing"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubscriptionHandler struct {
+	db          *gorm.DB
+	pricing     *pricing.Service
+	payments    payments.Gateway
+	mailer      email.Sender
+	stockAlerts *alerts.StockNotifier
+
+	mu sync.Mutex // Serializes billing so no order is charged twice
+}
+
+type SubscriptionPlanRequest struct {
+	IntervalDays    []int   `json:"intervalDays" binding:"required,min=1,dive,min=1,max=365"`
+	DiscountPercent float64 `json:"discountPercent" binding:"min=0,max=100"`
+	IsActive        *bool   `json:"isActive"`
+}
+
+type CreateSubscriptionRequest struct {
+	ProductID       string         `json:"productId" binding:"required"`
+	Quantity        int            `json:"quantity" binding:"required,min=1"`
+	IntervalDays    int            `json:"intervalDays" binding:"required"`
+	PaymentMethodID string         `json:"paymentMethodId" binding:"required"`
+	ShippingAddress models.Address `json:"shippingAddress" binding:"required"`
+	StartAt         *time.Time     `json:"startAt"` // First delivery; defaults to now
+}
+
+type UpdateSubscriptionRequest struct {
+	Quantity        *int            `json:"quantity" binding:"omitempty,min=1"`
+	IntervalDays    *int            `json:"intervalDays"`
+	PaymentMethodID *string         `json:"paymentMethodId" binding:"omitempty,min=1"`
+	ShippingAddress *models.Address `json:"shippingAddress"`
+	NextRunAt       *time.Time      `json:"nextRunAt"` // Reschedule the next delivery
+}
+
+type PauseSubscriptionRequest struct {
+	ResumeAt *time.Time `json:"resumeAt"` // Paused until resumed by hand when empty
+}
+
+func NewSubscriptionHandler(db *gorm.DB, gateway payments.Gateway, mailer email.Sender, stockAlerts *alerts.StockNotifier) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		db:          db,
+		pricing:     pricing.NewService(db),
+		payments:    gateway,
+		mailer:      mailer,
+		stockAlerts: stockAlerts,
+	}
+}
+
+// GetSubscriptionPlan returns the active subscription plan of a product
+func (h *SubscriptionHandler) GetSubscriptionPlan(c *gin.Context) {
+	var plan models.SubscriptionPlan
+	if err := h.db.Where("product_id = ? AND is_active = ?", c.Param("id"), true).First(&plan).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Plan not found",
+				"message": "This product is not available on subscription",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscription plan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// SetupPaymentMethod starts saving a payment method for subscription orders
+// and returns the client secret to confirm it with
+func (h *SubscriptionHandler) SetupPaymentMethod(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch user",
+		})
+		return
+	}
+
+	if user.PaymentCustomerID == "" {
+		customerID, err := h.payments.CreateCustomer(user.Email, user.FirstName+" "+user.LastName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Payment error",
+				"message": "Failed to register payment customer",
+			})
+			return
+		}
+		user.PaymentCustomerID = customerID
+		if err := h.db.Model(&user).Update("payment_customer_id", customerID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to save payment customer",
+			})
+			return
+		}
+	}
+
+	clientSecret, err := h.payments.SetupPaymentMethod(user.PaymentCustomerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Payment error",
+			"message": "Failed to set up payment method",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"clientSecret": clientSecret,
+		},
+	})
+}
+
+// GetSubscriptions lists the user's subscriptions
+func (h *SubscriptionHandler) GetSubscriptions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var subscriptions []models.Subscription
+	if err := h.db.Preload("Product", unscoped).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscriptions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscriptions,
+	})
+}
+
+// CreateSubscription subscribes the user to repeat deliveries of a product.
+// The first order is placed at StartAt, or straight away.
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := addresses.Normalize(&req.ShippingAddress); err != nil {
+		respondAddressError(c, "shippingAddress", err)
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", req.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	var plan models.SubscriptionPlan
+	if err := h.db.Where("product_id = ? AND is_active = ?", product.ID, true).First(&plan).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Plan not found",
+				"message": "This product is not available on subscription",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscription plan",
+		})
+		return
+	}
+	if !plan.AllowsInterval(req.IntervalDays) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Choose one of the delivery intervals offered for this product",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch user",
+		})
+		return
+	}
+	if user.PaymentCustomerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "No payment method",
+			"message": "Save a payment method before subscribing",
+		})
+		return
+	}
+
+	// Orders are charged in the storefront currency the customer subscribed in
+	currency := middleware.GetCurrency(c)
+	if _, err := h.pricing.Rate(currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	nextRunAt := time.Now()
+	if req.StartAt != nil && req.StartAt.After(nextRunAt) {
+		nextRunAt = *req.StartAt
+	}
+
+	subscription := models.Subscription{
+		UserID:          userID,
+		ProductID:       product.ID,
+		Quantity:        req.Quantity,
+		IntervalDays:    req.IntervalDays,
+		Status:          models.SubscriptionActive,
+		Currency:        currency,
+		ShippingAddress: req.ShippingAddress,
+		PaymentMethodID: req.PaymentMethodID,
+		NextRunAt:       nextRunAt,
+	}
+	if err := h.db.Create(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create subscription",
+		})
+		return
+	}
+	h.billNow(subscription.ID)
+
+	subscription.Product = product
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Subscription created successfully",
+		"data":    subscription,
+	})
+}
+
+// GetSubscription returns one of the user's subscriptions with its payment history
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Where("subscription_id = ?", subscription.ID).
+		Order("created_at DESC").
+		Find(&subscription.Charges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscription payments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscription,
+	})
+}
+
+// UpdateSubscription changes the quantity, interval, payment method, address
+// or next delivery date of a subscription. A new payment method on a past
+// due subscription is charged straight away.
+func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	var req UpdateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.ShippingAddress != nil {
+		if err := addresses.Normalize(req.ShippingAddress); err != nil {
+			respondAddressError(c, "shippingAddress", err)
+			return
+		}
+	}
+
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+	if subscription.Status == models.SubscriptionCancelled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Subscription cancelled",
+			"message": "Cancelled subscriptions cannot be changed",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Quantity != nil {
+		updates["quantity"] = *req.Quantity
+	}
+	if req.IntervalDays != nil {
+		var plan models.SubscriptionPlan
+		err := h.db.Where("product_id = ?", subscription.ProductID).First(&plan).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch subscription plan",
+			})
+			return
+		}
+		if err == gorm.ErrRecordNotFound || !plan.AllowsInterval(*req.IntervalDays) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "Choose one of the delivery intervals offered for this product",
+			})
+			return
+		}
+		updates["interval_days"] = *req.IntervalDays
+	}
+	if address := req.ShippingAddress; address != nil {
+		updates["shipping_first_name"] = address.FirstName
+		updates["shipping_last_name"] = address.LastName
+		updates["shipping_email"] = address.Email
+		updates["shipping_phone"] = address.Phone
+		updates["shipping_address"] = address.Address
+		updates["shipping_city"] = address.City
+		updates["shipping_state"] = address.State
+		updates["shipping_country"] = address.Country
+		updates["shipping_zip_code"] = address.ZipCode
+	}
+	retry := false
+	if req.PaymentMethodID != nil {
+		updates["payment_method_id"] = *req.PaymentMethodID
+		if subscription.Status == models.SubscriptionPastDue {
+			updates["next_run_at"] = time.Now()
+			retry = true
+		}
+	}
+	if req.NextRunAt != nil && !retry {
+		if subscription.Status == models.SubscriptionPastDue {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Payment overdue",
+				"message": "Update the payment method to settle the overdue order first",
+			})
+			return
+		}
+		if !req.NextRunAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "The next delivery must be in the future",
+			})
+			return
+		}
+		updates["next_run_at"] = *req.NextRunAt
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&subscription).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to update subscription",
+			})
+			return
+		}
+	}
+	if retry {
+		h.billNow(subscription.ID)
+	}
+
+	h.db.Preload("Product", unscoped).First(&subscription, "id = ?", subscription.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription updated successfully",
+		"data":    subscription,
+	})
+}
+
+// SkipSubscription skips the next delivery of an active subscription
+func (h *SubscriptionHandler) SkipSubscription(c *gin.Context) {
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+	if subscription.Status != models.SubscriptionActive {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Subscription not active",
+			"message": "Only deliveries of active subscriptions can be skipped",
+		})
+		return
+	}
+
+	// Guard against the delivery being placed meanwhile
+	next := subscription.NextRunAt.AddDate(0, 0, subscription.IntervalDays)
+	result := h.db.Model(&models.Subscription{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", subscription.ID, subscription.Status, subscription.NextRunAt).
+		Update("next_run_at", next)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to skip delivery",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Delivery already placed",
+			"message": "The next delivery has already been ordered",
+		})
+		return
+	}
+	subscription.NextRunAt = next
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Next delivery skipped",
+		"data":    subscription,
+	})
+}
+
+// PauseSubscription stops deliveries until the subscription is resumed,
+// optionally resuming automatically at a given time
+func (h *SubscriptionHandler) PauseSubscription(c *gin.Context) {
+	var req PauseSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.ResumeAt != nil && !req.ResumeAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "The resume date must be in the future",
+		})
+		return
+	}
+
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+	if subscription.Status != models.SubscriptionActive {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Subscription not active",
+			"message": "Only active subscriptions can be paused",
+		})
+		return
+	}
+
+	subscription.Status = models.SubscriptionPaused
+	subscription.PausedUntil = req.ResumeAt
+	if err := h.db.Model(&subscription).Updates(map[string]interface{}{
+		"status":       subscription.Status,
+		"paused_until": subscription.PausedUntil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to pause subscription",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription paused successfully",
+		"data":    subscription,
+	})
+}
+
+// ResumeSubscription restarts deliveries of a paused subscription
+func (h *SubscriptionHandler) ResumeSubscription(c *gin.Context) {
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+	if subscription.Status != models.SubscriptionPaused {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Subscription not paused",
+			"message": "Only paused subscriptions can be resumed",
+		})
+		return
+	}
+
+	if err := resumeSubscription(h.db, &subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to resume subscription",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription resumed successfully",
+		"data":    subscription,
+	})
+}
+
+// CancelSubscription ends a subscription. An unpaid order from a failed
+// payment is cancelled with it.
+func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
+	subscription, ok := h.findSubscription(c)
+	if !ok {
+		return
+	}
+	if subscription.Status == models.SubscriptionCancelled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Subscription cancelled",
+			"message": "This subscription has already been cancelled",
+		})
+		return
+	}
+
+	// Not while a payment for the pending order is in flight
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	var touched []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&subscription, "id = ?", subscription.ID).Error; err != nil {
+			return err
+		}
+		if subscription.PendingOrderID != nil {
+			var order models.Order
+			err := tx.Preload("Items").
+				Where("id = ? AND status <> ? AND payment_status = ?", *subscription.PendingOrderID,
+					models.OrderStatusCancelled, models.PaymentStatusPending).
+				First(&order).Error
+			if err == nil {
+				if touched, err = cancelOrder(tx, &order); err != nil {
+					return err
+				}
+			} else if err != gorm.ErrRecordNotFound {
+				return err
+			}
+		}
+
+		subscription.Status = models.SubscriptionCancelled
+		subscription.CancelReason = "customer"
+		subscription.CancelledAt = &now
+		subscription.PendingOrderID = nil
+		return tx.Model(&subscription).Updates(map[string]interface{}{
+			"status":           subscription.Status,
+			"cancel_reason":    subscription.CancelReason,
+			"cancelled_at":     now,
+			"pending_order_id": nil,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to cancel subscription",
+		})
+		return
+	}
+	h.stockAlerts.Restocked(touched...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription cancelled successfully",
+		"data":    subscription,
+	})
+}
+
+// UpsertSubscriptionPlan offers a product on subscription (admin only)
+func (h *SubscriptionHandler) UpsertSubscriptionPlan(c *gin.Context) {
+	var req SubscriptionPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+	if product.IsDigital {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Digital products cannot be sold on subscription",
+		})
+		return
+	}
+
+	var plan models.SubscriptionPlan
+	err := h.db.Where("product_id = ?", product.ID).First(&plan).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscription plan",
+		})
+		return
+	}
+
+	plan.ProductID = product.ID
+	plan.IntervalDays = req.IntervalDays
+	plan.DiscountPercent = req.DiscountPercent
+	plan.IsActive = req.IsActive == nil || *req.IsActive
+	if err == gorm.ErrRecordNotFound {
+		err = h.db.Create(&plan).Error
+	} else {
+		err = h.db.Model(&plan).Select("interval_days", "discount_percent", "is_active").Updates(&plan).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save subscription plan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription plan saved successfully",
+		"data":    plan,
+	})
+}
+
+// DeleteSubscriptionPlan stops offering a product on subscription. Existing
+// subscriptions keep their deliveries (admin only).
+func (h *SubscriptionHandler) DeleteSubscriptionPlan(c *gin.Context) {
+	result := h.db.Where("product_id = ?", c.Param("id")).Delete(&models.SubscriptionPlan{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete subscription plan",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Plan not found",
+			"message": "This product is not available on subscription",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription plan deleted successfully",
+	})
+}
+
+// GetAllSubscriptions lists subscriptions, optionally by status (admin only)
+func (h *SubscriptionHandler) GetAllSubscriptions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.Subscription{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscriptions",
+		})
+		return
+	}
+
+	var subscriptions []models.Subscription
+	if err := query.Preload("Product", unscoped).
+		Order("next_run_at ASC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscriptions",
+		})
+		return
+	}
+
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    subscriptions,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": totalPages,
+		},
+	})
+}
+
+// RunSubscriptionBilling bills due subscriptions now instead of waiting for
+// the scheduled run (admin only)
+func (h *SubscriptionHandler) RunSubscriptionBilling(c *gin.Context) {
+	paid, err := h.BillDue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Billing failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Subscription billing completed",
+		"data": gin.H{
+			"paid": paid,
+		},
+	})
+}
+
+// findSubscription loads a subscription of the current user by the id route
+// parameter. It writes an error response and returns false when that fails.
+func (h *SubscriptionHandler) findSubscription(c *gin.Context) (models.Subscription, bool) {
+	var subscription models.Subscription
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return subscription, false
+	}
+
+	if err := h.db.Preload("Product", unscoped).
+		Where("id = ? AND user_id = ?", c.Param("id"), userID).
+		First(&subscription).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Subscription not found",
+				"message": "The requested subscription does not exist",
+			})
+			return subscription, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch subscription",
+		})
+		return subscription, false
+	}
+	return subscription, true
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TrackingHandler struct {
+	db *gorm.DB
+}
+
+type TrackOrderRequest struct {
+	OrderNumber string `json:"orderNumber" binding:"required,max=64"`
+	Email       string `json:"email" binding:"required,email"`
+}
+
+// TrackedOrder is the public view of an order for customers who cannot sign
+// in. It carries no personal data beyond the city the order ships to.
+type TrackedOrder struct {
+	OrderNumber string             `json:"orderNumber"`
+	Status      models.OrderStatus `json:"status"`
+	PlacedAt    time.Time          `json:"placedAt"`
+	DeliveredAt *time.Time         `json:"deliveredAt,omitempty"`
+	ShipToCity  string             `json:"shipToCity"`
+	Items       []TrackedItem      `json:"items"`
+	Timeline    []TrackingEvent    `json:"timeline"` // Oldest first
+	Shipments   []TrackedShipment  `json:"shipments"`
+}
+
+type TrackedItem struct {
+	ProductName string `json:"productName"`
+	Quantity    int    `json:"quantity"`
+}
+
+type TrackedShipment struct {
+	Carrier        string                `json:"carrier"`
+	TrackingNumber string                `json:"trackingNumber"`
+	Status         models.ShipmentStatus `json:"status"`
+	ShippedAt      time.Time             `json:"shippedAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	Events         []TrackingEvent       `json:"events"` // Carrier scans, oldest first
+}
+
+// TrackingEvent is one step of an order or parcel
+type TrackingEvent struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+func NewTrackingHandler(db *gorm.DB) *TrackingHandler {
+	return &TrackingHandler{db: db}
+}
+
+// TrackOrder looks up an order by its number and billing email, for
+// customers who have lost access to their account
+func (h *TrackingHandler) TrackOrder(c *gin.Context) {
+	var req TrackOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// A wrong email reads the same as an unknown order number, so the
+	// endpoint cannot be used to find out which order numbers exist
+	notFound := gin.H{
+		"error":   "Order not found",
+		"message": "No order matches that order number and email",
+	}
+
+	// Order numbers are stored upper case, so the lookup can use the index
+	orderNumber := strings.ToUpper(strings.TrimSpace(req.OrderNumber))
+	var order models.Order
+	err := h.db.Preload("Items").Preload("Shipments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("shipped_at ASC")
+	}).Preload("Shipments.Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at ASC")
+	}).First(&order, "order_number = ?", orderNumber).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, notFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order",
+		})
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(order.BillingAddress.Email), strings.TrimSpace(req.Email)) {
+		c.JSON(http.StatusNotFound, notFound)
+		return
+	}
+
+	var returns []models.ReturnRequest
+	if err := h.db.Where("order_id = ? AND status NOT IN ?", order.ID,
+		[]models.ReturnStatus{models.ReturnRejected, models.ReturnCancelled}).Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch returns",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    trackedOrder(&order, returns),
+	})
+}
+
+// trackedOrder redacts an order, with its shipments loaded, for public tracking
+func trackedOrder(order *models.Order, returns []models.ReturnRequest) TrackedOrder {
+	tracked := TrackedOrder{
+		OrderNumber: order.OrderNumber,
+		Status:      order.Status,
+		PlacedAt:    order.CreatedAt,
+		DeliveredAt: order.DeliveredAt,
+		ShipToCity:  order.ShippingAddress.City,
+		Items:       make([]TrackedItem, len(order.Items)),
+		Timeline: []TrackingEvent{{
+			Status:      string(models.OrderStatusPending),
+			Description: "Order placed",
+			OccurredAt:  order.CreatedAt,
+		}},
+		Shipments: make([]TrackedShipment, len(order.Shipments)),
+	}
+
+	for i, item := range order.Items {
+		tracked.Items[i] = TrackedItem{ProductName: item.ProductName, Quantity: item.Quantity}
+	}
+
+	for i, shipment := range order.Shipments {
+		parcel := TrackedShipment{
+			Carrier:        shipment.Carrier,
+			TrackingNumber: shipment.TrackingNumber,
+			Status:         shipment.Status,
+			ShippedAt:      shipment.ShippedAt,
+			DeliveredAt:    shipment.DeliveredAt,
+			Events:         make([]TrackingEvent, len(shipment.Events)),
+		}
+		for j, event := range shipment.Events {
+			parcel.Events[j] = TrackingEvent{
+				Status:      string(event.Status),
+				Description: event.Description,
+				Location:    event.Location,
+				OccurredAt:  event.OccurredAt,
+			}
+		}
+		tracked.Shipments[i] = parcel
+
+		tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+			Status:      string(models.OrderStatusShipped),
+			Description: fmt.Sprintf("Parcel %d of %d shipped with %s", i+1, len(order.Shipments), shipment.Carrier),
+			OccurredAt:  shipment.ShippedAt,
+		})
+		if shipment.DeliveredAt != nil {
+			tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+				Status:      string(models.OrderStatusDelivered),
+				Description: fmt.Sprintf("Parcel %d of %d delivered", i+1, len(order.Shipments)),
+				OccurredAt:  *shipment.DeliveredAt,
+			})
+		}
+	}
+
+	// Orders delivered without shipments on record were marked by hand
+	if order.DeliveredAt != nil && len(order.Shipments) == 0 {
+		tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+			Status:      string(models.OrderStatusDelivered),
+			Description: "Order delivered",
+			OccurredAt:  *order.DeliveredAt,
+		})
+	}
+
+	for _, ret := range returns {
+		tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+			Status:      string(models.ReturnRequested),
+			Description: "Return requested",
+			OccurredAt:  ret.CreatedAt,
+		})
+		if ret.ReceivedAt != nil {
+			tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+				Status:      string(models.ReturnReceived),
+				Description: "Return received",
+				OccurredAt:  *ret.ReceivedAt,
+			})
+		}
+		if ret.CompletedAt != nil {
+			tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+				Status:      string(models.ReturnCompleted),
+				Description: "Return completed",
+				OccurredAt:  *ret.CompletedAt,
+			})
+		}
+	}
+
+	if order.Status == models.OrderStatusCancelled {
+		tracked.Timeline = append(tracked.Timeline, TrackingEvent{
+			Status:      string(models.OrderStatusCancelled),
+			Description: "Order cancelled",
+			OccurredAt:  order.UpdatedAt,
+		})
+	}
+
+	sort.SliceStable(tracked.Timeline, func(i, j int) bool {
+		return tracked.Timeline[i].OccurredAt.Before(tracked.Timeline[j].OccurredAt)
+	})
+	return tracked
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/i18n"
+	"bizoe-3d-store/internal/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TranslationHandler struct {
+	db *gorm.DB
+}
+
+type ProductTranslationRequest struct {
+	Name           string            `json:"name" binding:"required"`
+	Description    string            `json:"description"`
+	Specifications map[string]string `json:"specifications"`
+}
+
+type CategoryTranslationRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+func NewTranslationHandler(db *gorm.DB) *TranslationHandler {
+	return &TranslationHandler{db: db}
+}
+
+// GetProductTranslations returns all translations of a product (admin only)
+func (h *TranslationHandler) GetProductTranslations(c *gin.Context) {
+	productID := c.Param("id")
+
+	var translations []models.ProductTranslation
+	if err := h.db.Where("product_id = ?", productID).Order("locale ASC").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product translations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    translations,
+	})
+}
+
+// UpsertProductTranslation creates or replaces a product translation for a locale (admin only)
+func (h *TranslationHandler) UpsertProductTranslation(c *gin.Context) {
+	productID := c.Param("id")
+	locale, ok := h.bindLocale(c)
+	if !ok {
+		return
+	}
+
+	var req ProductTranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", productID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+
+	var translation models.ProductTranslation
+	err := h.db.Where("product_id = ? AND locale = ?", productID, locale).First(&translation).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product translation",
+		})
+		return
+	}
+
+	translation.ProductID = productID
+	translation.Locale = locale
+	translation.Name = req.Name
+	translation.Description = req.Description
+	translation.Specifications = req.Specifications
+
+	if err := h.db.Save(&translation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save product translation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product translation saved successfully",
+		"data":    translation,
+	})
+}
+
+// DeleteProductTranslation removes a product translation for a locale (admin only)
+func (h *TranslationHandler) DeleteProductTranslation(c *gin.Context) {
+	locale, ok := h.bindLocale(c)
+	if !ok {
+		return
+	}
+
+	result := h.db.Where("product_id = ? AND locale = ?", c.Param("id"), locale).
+		Delete(&models.ProductTranslation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete product translation",
+		})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Translation not found",
+			"message": "The requested translation does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product translation deleted successfully",
+	})
+}
+
+// GetCategoryTranslations returns all translations of a category (admin only)
+func (h *TranslationHandler) GetCategoryTranslations(c *gin.Context) {
+	categoryID := c.Param("id")
+
+	var translations []models.CategoryTranslation
+	if err := h.db.Where("category_id = ?", categoryID).Order("locale ASC").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch category translations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    translations,
+	})
+}
+
+// UpsertCategoryTranslation creates or replaces a category translation for a locale (admin only)
+func (h *TranslationHandler) UpsertCategoryTranslation(c *gin.Context) {
+	categoryID := c.Param("id")
+	locale, ok := h.bindLocale(c)
+	if !ok {
+		return
+	}
+
+	var req CategoryTranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var category models.Category
+	if err := h.db.First(&category, "id = ?", categoryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Category not found",
+				"message": "The requested category does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find category",
+		})
+		return
+	}
+
+	var translation models.CategoryTranslation
+	err := h.db.Where("category_id = ? AND locale = ?", categoryID, locale).First(&translation).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch category translation",
+		})
+		return
+	}
+
+	translation.CategoryID = categoryID
+	translation.Locale = locale
+	translation.Name = req.Name
+	translation.Description = req.Description
+
+	if err := h.db.Save(&translation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save category translation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Category translation saved successfully",
+		"data":    translation,
+	})
+}
+
+// DeleteCategoryTranslation removes a category translation for a locale (admin only)
+func (h *TranslationHandler) DeleteCategoryTranslation(c *gin.Context) {
+	locale, ok := h.bindLocale(c)
+	if !ok {
+		return
+	}
+
+	result := h.db.Where("category_id = ? AND locale = ?", c.Param("id"), locale).
+		Delete(&models.CategoryTranslation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete category translation",
+		})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Translation not found",
+			"message": "The requested translation does not exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Category translation deleted successfully",
+	})
+}
+
+// GetMissingTranslations reports products without a complete translation for a locale (admin only)
+func (h *TranslationHandler) GetMissingTranslations(c *gin.Context) {
+	locale := i18n.Normalize(c.DefaultQuery("locale", "zh-TW"))
+	if locale == "" || locale == i18n.DefaultLocale {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid locale",
+			"message": "Locale must be a supported, non-default locale",
+		})
+		return
+	}
+
+	// Products with no translation row, or a row missing its description
+	complete := h.db.Model(&models.ProductTranslation{}).
+		Select("product_id").
+		Where("locale = ? AND name <> '' AND description <> ''", locale)
+
+	var products []models.Product
+	if err := h.db.Preload("Category").
+		Where("id NOT IN (?)", complete).
+		Order("created_at DESC").
+		Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch products missing translations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    products,
+		"locale":  locale,
+		"count":   len(products),
+	})
+}
+
+// bindLocale validates the :locale path parameter against the supported locales
+func (h *TranslationHandler) bindLocale(c *gin.Context) (string, bool) {
+	locale := i18n.Normalize(c.Param("locale"))
+	if locale == "" || !i18n.IsSupported(locale) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid locale",
+			"message": "Supported locales are en and zh-TW",
+		})
+		return "", false
+	}
+	return locale, true
+}
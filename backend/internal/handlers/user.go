@@ -216,7 +216,7 @@ func (h *UserHandler) GetUserOrders(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	status := c.Query("status")
 
-	query := h.db.Preload("Items.Product.Category").Where("user_id = ?", userID)
+	query := h.db.Preload("Items.Product", unscoped).Preload("Items.Product.Category", unscoped).Where("user_id = ?", userID)
 
 	if status != "" {
 		query = query.Where("status = ?", status)
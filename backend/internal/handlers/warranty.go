@@ -0,0 +1,919 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/email"
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxClaimPhotos limits how many photos a single warranty claim can carry
+const maxClaimPhotos = 4
+
+// activeWarrantyClaimStatuses are the states of claims still being worked on
+var activeWarrantyClaimStatuses = []models.WarrantyClaimStatus{
+	models.WarrantyClaimOpen,
+	models.WarrantyClaimRepairApproved,
+	models.WarrantyClaimReplacementApproved,
+}
+
+type WarrantyHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+	mailer  email.Sender
+}
+
+type CaptureSerialsRequest struct {
+	SerialNumbers []string `json:"serialNumbers" binding:"max=100,dive,required,max=64"`
+}
+
+type RegisterSerialRequest struct {
+	ProductID    string     `json:"productId" binding:"required"`
+	SerialNumber string     `json:"serialNumber" binding:"required,max=64"`
+	PurchaseDate *time.Time `json:"purchaseDate"` // Required for units we have no record of
+}
+
+type CreateClaimRequest struct {
+	SerialID    string `form:"serialId" binding:"required"`
+	Description string `form:"description" binding:"required,min=20,max=5000"`
+}
+
+type TriageClaimRequest struct {
+	Decision        string          `json:"decision" binding:"required,oneof=repair replace reject"`
+	Note            string          `json:"note" binding:"max=2000"`
+	ShippingAddress *models.Address `json:"shippingAddress"` // Replacements only; defaults to the original order's
+}
+
+type ResolveClaimRequest struct {
+	Note string `json:"note" binding:"max=2000"`
+}
+
+// serialConflictError reports a serial number that belongs to another unit or customer
+type serialConflictError struct {
+	serial string
+	reason string
+}
+
+func (e *serialConflictError) Error() string {
+	return fmt.Sprintf("serial number %s %s", e.serial, e.reason)
+}
+
+func NewWarrantyHandler(db *gorm.DB, store storage.Storage, mailer email.Sender) *WarrantyHandler {
+	return &WarrantyHandler{db: db, storage: store, mailer: mailer}
+}
+
+// CaptureSerials records the serial numbers of the units shipped on an order
+// line. Each becomes a verified registration owned by the buyer, covered from
+// the order date. Sending the full list again replaces it (admin only).
+func (h *WarrantyHandler) CaptureSerials(c *gin.Context) {
+	var req CaptureSerialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var item models.OrderItem
+	if err := h.db.Preload("Order").Preload("Product", unscoped).
+		Where("id = ? AND order_id = ?", c.Param("itemId"), c.Param("id")).
+		First(&item).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Order item not found",
+				"message": "The requested order item does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch order item",
+		})
+		return
+	}
+
+	if item.LineType == models.OrderLineDigital || item.LineType == models.OrderLinePrint || item.Product.IsBundle {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "No serial numbers",
+			"message": "Serial numbers are only captured for physical products shipped as single units",
+		})
+		return
+	}
+	if item.Order.Status == models.OrderStatusPending || item.Order.Status == models.OrderStatusCancelled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Order not fulfillable",
+			"message": "Serial numbers can only be captured for paid orders",
+		})
+		return
+	}
+
+	serials := make([]string, 0, len(req.SerialNumbers))
+	seen := make(map[string]bool, len(req.SerialNumbers))
+	for _, serial := range req.SerialNumbers {
+		serial = normalizeSerial(serial)
+		if serial == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "Serial numbers cannot be blank",
+			})
+			return
+		}
+		if seen[serial] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": fmt.Sprintf("Serial number %s is listed twice", serial),
+			})
+			return
+		}
+		seen[serial] = true
+		serials = append(serials, serial)
+	}
+	if len(serials) > item.Quantity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": fmt.Sprintf("This line has %d units but %d serial numbers were given", item.Quantity, len(serials)),
+		})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseSerials(tx, item.ID, seen); err != nil {
+			return err
+		}
+		for _, serial := range serials {
+			if err := captureSerial(tx, &item, serial); err != nil {
+				return err
+			}
+		}
+		item.SerialNumbers = serials
+		return tx.Model(&item).Select("serial_numbers").Updates(&item).Error
+	})
+	if err != nil {
+		var conflict *serialConflictError
+		if errors.As(err, &conflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Serial number conflict",
+				"message": "Serial number " + conflict.serial + " " + conflict.reason,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save serial numbers",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Serial numbers saved successfully",
+		"data":    item,
+	})
+}
+
+// GetSerials looks up registered units by serial number, product or order (admin only)
+func (h *WarrantyHandler) GetSerials(c *gin.Context) {
+	db := h.db.Preload("Product", unscoped).Order("created_at DESC").Limit(50)
+	if serial := c.Query("serial"); serial != "" {
+		db = db.Where("serial_number = ?", normalizeSerial(serial))
+	}
+	if productID := c.Query("productId"); productID != "" {
+		db = db.Where("product_id = ?", productID)
+	}
+	if orderID := c.Query("orderId"); orderID != "" {
+		db = db.Where("order_id = ?", orderID)
+	}
+
+	var serials []models.ProductSerial
+	if err := db.Find(&serials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch serial numbers",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    serials,
+	})
+}
+
+// GetRegistrations returns the units registered to the user
+func (h *WarrantyHandler) GetRegistrations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var serials []models.ProductSerial
+	if err := h.db.Preload("Product", unscoped).Where("owner_id = ?", userID).
+		Order("purchase_date DESC").Find(&serials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch registrations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    serials,
+	})
+}
+
+// RegisterSerial registers a unit to the user. Units shipped by us are
+// already on record; others, such as units bought from a reseller, are
+// registered unverified with the purchase date the customer gives.
+func (h *WarrantyHandler) RegisterSerial(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req RegisterSerialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	serialNumber := normalizeSerial(req.SerialNumber)
+	if serialNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "Serial number is required",
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.Unscoped().First(&product, "id = ?", req.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find product",
+		})
+		return
+	}
+	if product.WarrantyMonths == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "No warranty",
+			"message": fmt.Sprintf("%s is not sold with a warranty", product.Name),
+		})
+		return
+	}
+
+	now := time.Now()
+	var serial models.ProductSerial
+	err := h.db.Where("product_id = ? AND serial_number = ?", product.ID, serialNumber).First(&serial).Error
+	switch {
+	case err == nil:
+		if serial.OwnerID != nil && *serial.OwnerID != userID {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Already registered",
+				"message": "This serial number is registered to another account; contact support if you bought the unit second-hand",
+			})
+			return
+		}
+		serial.OwnerID = &userID
+		if serial.RegisteredAt == nil {
+			serial.RegisteredAt = &now
+		}
+		if err := h.db.Model(&serial).Select("owner_id", "registered_at").Updates(&serial).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to register serial number",
+			})
+			return
+		}
+		serial.Product = product
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Serial number registered successfully",
+			"data":    serial,
+		})
+		return
+	case err != gorm.ErrRecordNotFound:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find serial number",
+		})
+		return
+	}
+
+	if req.PurchaseDate == nil || req.PurchaseDate.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "We have no record of this unit; a purchase date that is not in the future is required",
+		})
+		return
+	}
+
+	serial = models.ProductSerial{
+		ProductID:      product.ID,
+		SerialNumber:   serialNumber,
+		OwnerID:        &userID,
+		PurchaseDate:   *req.PurchaseDate,
+		WarrantyEndsAt: warrantyEnd(*req.PurchaseDate, product.WarrantyMonths),
+		RegisteredAt:   &now,
+	}
+	if err := h.db.Create(&serial).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Already registered",
+			"message": "This serial number was registered at the same time; try again",
+		})
+		return
+	}
+	serial.Product = product
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Serial number registered and awaiting verification",
+		"data":    serial,
+	})
+}
+
+// GetClaims returns the user's warranty claims
+func (h *WarrantyHandler) GetClaims(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var claims []models.WarrantyClaim
+	if err := h.db.Preload("Serial.Product", unscoped).Where("user_id = ?", userID).
+		Order("created_at DESC").Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch claims",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    claims,
+	})
+}
+
+// GetClaim returns a warranty claim with its replacement order, if any
+func (h *WarrantyHandler) GetClaim(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	db := h.db.Preload("Serial.Product", unscoped).Preload("ReplacementOrder.Items")
+
+	// Non-admin users can only see their own claims
+	if middleware.IsAdmin(c) {
+		db = db.Preload("User")
+	} else {
+		db = db.Where("user_id = ?", userID)
+	}
+
+	claim, ok := findClaim(c, db)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    claim,
+	})
+}
+
+// CreateClaim opens a warranty claim for a unit registered to the user. The
+// unit must still be under warranty and have no other claim in progress.
+func (h *WarrantyHandler) CreateClaim(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxClaimPhotos*maxImageSize+1<<20)
+
+	var req CreateClaimRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var serial models.ProductSerial
+	if err := h.db.Preload("Product", unscoped).Where("owner_id = ?", userID).
+		First(&serial, "id = ?", req.SerialID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Registration not found",
+				"message": "Register the unit's serial number before opening a claim",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to find registration",
+		})
+		return
+	}
+
+	if !serial.UnderWarranty(time.Now()) {
+		message := fmt.Sprintf("%s is not sold with a warranty", serial.Product.Name)
+		if serial.WarrantyEndsAt != nil {
+			message = "The warranty for this unit ended on " + serial.WarrantyEndsAt.Format("2006-01-02")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Out of warranty",
+			"message": message,
+		})
+		return
+	}
+
+	var active int64
+	h.db.Model(&models.WarrantyClaim{}).Where("serial_id = ? AND status IN ?", serial.ID, activeWarrantyClaimStatuses).Count(&active)
+	if active > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Claim in progress",
+			"message": "This unit already has a warranty claim in progress",
+		})
+		return
+	}
+
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["photos"]
+	}
+	if len(files) > maxClaimPhotos {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Too many photos",
+			"message": fmt.Sprintf("A claim can include at most %d photos", maxClaimPhotos),
+		})
+		return
+	}
+
+	claim := models.WarrantyClaim{
+		ID:          uuid.New().String(),
+		SerialID:    serial.ID,
+		UserID:      userID,
+		ProductID:   serial.ProductID,
+		Description: strings.TrimSpace(req.Description),
+		Photos:      []models.CustomerPhoto{},
+		Status:      models.WarrantyClaimOpen,
+	}
+
+	for _, file := range files {
+		photo, ok := storeCustomerPhoto(c, h.storage, "warranty-claims/"+claim.ID, file)
+		if !ok {
+			deleteCustomerPhotos(h.storage, claim.Photos)
+			return
+		}
+		claim.Photos = append(claim.Photos, photo)
+	}
+
+	if err := h.db.Create(&claim).Error; err != nil {
+		deleteCustomerPhotos(h.storage, claim.Photos)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create claim",
+		})
+		return
+	}
+	claim.Serial = serial
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Warranty claim submitted",
+		"data":    claim,
+	})
+}
+
+// GetAllClaims returns warranty claims, open ones by default, oldest first (admin only)
+func (h *WarrantyHandler) GetAllClaims(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	status := c.DefaultQuery("status", string(models.WarrantyClaimOpen))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := h.db.Model(&models.WarrantyClaim{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to count claims",
+		})
+		return
+	}
+
+	var claims []models.WarrantyClaim
+	if err := query.Preload("Serial.Product", unscoped).Preload("User").
+		Order("created_at ASC").Offset((page - 1) * limit).Limit(limit).
+		Find(&claims).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch claims",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    claims,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// TriageClaim decides an open warranty claim: approve a repair, replace the
+// unit with a free replacement order, or reject it with a reason (admin only)
+func (h *WarrantyHandler) TriageClaim(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var req TriageClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if req.Decision == "reject" && note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": "A note explaining the rejection is required",
+		})
+		return
+	}
+
+	claim, ok := findClaim(c, h.db.Preload("Serial.Product", unscoped).Preload("User"))
+	if !ok {
+		return
+	}
+	if claim.Status != models.WarrantyClaimOpen {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Claim already triaged",
+			"message": fmt.Sprintf("This claim was already triaged and is %s", claim.Status),
+		})
+		return
+	}
+
+	now := time.Now()
+	claim.ResolutionNote = note
+	claim.TriagedBy = adminID
+	claim.TriagedAt = &now
+	switch req.Decision {
+	case "repair":
+		claim.Status = models.WarrantyClaimRepairApproved
+	case "replace":
+		claim.Status = models.WarrantyClaimReplacementApproved
+	case "reject":
+		claim.Status = models.WarrantyClaimRejected
+		claim.ResolvedAt = &now
+	}
+
+	var address *models.Address
+	if claim.Status == models.WarrantyClaimReplacementApproved {
+		address = req.ShippingAddress
+		if address == nil && claim.Serial.OrderID != nil {
+			var original models.Order
+			if err := h.db.First(&original, "id = ?", *claim.Serial.OrderID).Error; err == nil {
+				address = &original.ShippingAddress
+			}
+		}
+		if address == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"message": "The unit was not bought from us, so a shipping address for the replacement is required",
+			})
+			return
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if address != nil {
+			order, err := createReplacementOrder(tx, &claim, address)
+			if err != nil {
+				return err
+			}
+			claim.ReplacementOrderID = &order.ID
+		}
+
+		// Guard against two admins triaging the same claim at once
+		result := tx.Model(&models.WarrantyClaim{}).Where("id = ? AND status = ?", claim.ID, models.WarrantyClaimOpen).
+			Updates(map[string]interface{}{
+				"status":               claim.Status,
+				"resolution_note":      claim.ResolutionNote,
+				"triaged_by":           claim.TriagedBy,
+				"triaged_at":           claim.TriagedAt,
+				"resolved_at":          claim.ResolvedAt,
+				"replacement_order_id": claim.ReplacementOrderID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errClaimChanged
+		}
+		return nil
+	})
+	if err != nil {
+		switch err {
+		case errInsufficientStock:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Insufficient stock",
+				"message": fmt.Sprintf("%s is out of stock; approve a repair or try again once restocked", claim.Serial.Product.Name),
+			})
+		case errClaimChanged:
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Claim already triaged",
+				"message": "This claim was triaged by someone else",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to triage claim",
+			})
+		}
+		return
+	}
+
+	h.notifyClaimant(&claim)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Claim " + string(claim.Status),
+		"data":    claim,
+	})
+}
+
+// ResolveClaim closes an approved claim once the repaired unit or the
+// replacement has been sent (admin only)
+func (h *WarrantyHandler) ResolveClaim(c *gin.Context) {
+	var req ResolveClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	claim, ok := findClaim(c, h.db.Preload("Serial.Product", unscoped).Preload("User"))
+	if !ok {
+		return
+	}
+	if claim.Status != models.WarrantyClaimRepairApproved && claim.Status != models.WarrantyClaimReplacementApproved {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Claim not approved",
+			"message": fmt.Sprintf("Only approved claims can be resolved; this claim is %s", claim.Status),
+		})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      models.WarrantyClaimResolved,
+		"resolved_at": now,
+	}
+	if note := strings.TrimSpace(req.Note); note != "" {
+		updates["resolution_note"] = note
+		claim.ResolutionNote = note
+	}
+	result := h.db.Model(&models.WarrantyClaim{}).Where("id = ? AND status = ?", claim.ID, claim.Status).Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to resolve claim",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Claim changed",
+			"message": "This claim was updated by someone else",
+		})
+		return
+	}
+	claim.Status = models.WarrantyClaimResolved
+	claim.ResolvedAt = &now
+
+	h.notifyClaimant(&claim)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Claim resolved",
+		"data":    claim,
+	})
+}
+
+// errClaimChanged is returned when a claim was triaged concurrently
+var errClaimChanged = errors.New("warranty claim changed")
+
+// findClaim loads the claim named by the id parameter from db. It writes an
+// error response and returns false when that fails.
+func findClaim(c *gin.Context, db *gorm.DB) (models.WarrantyClaim, bool) {
+	var claim models.WarrantyClaim
+	if err := db.First(&claim, "id = ?", c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Claim not found",
+				"message": "The requested warranty claim does not exist",
+			})
+			return claim, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch claim",
+		})
+		return claim, false
+	}
+	return claim, true
+}
+
+// notifyClaimant emails the customer the outcome of their claim
+func (h *WarrantyHandler) notifyClaimant(claim *models.WarrantyClaim) {
+	if claim.User == nil {
+		return
+	}
+
+	product := claim.Serial.Product.Name
+	var outcome string
+	switch claim.Status {
+	case models.WarrantyClaimRepairApproved:
+		outcome = fmt.Sprintf("We approved a repair of your %s (serial %s). Our support team will be in touch about sending the unit in.",
+			product, claim.Serial.SerialNumber)
+	case models.WarrantyClaimReplacementApproved:
+		outcome = fmt.Sprintf("We are replacing your %s (serial %s) free of charge. The replacement ships as a new order, which you can follow in your account.",
+			product, claim.Serial.SerialNumber)
+	case models.WarrantyClaimRejected:
+		outcome = fmt.Sprintf("We were unable to approve your claim for your %s (serial %s).", product, claim.Serial.SerialNumber)
+	case models.WarrantyClaimResolved:
+		outcome = fmt.Sprintf("Your warranty claim for your %s (serial %s) is complete.", product, claim.Serial.SerialNumber)
+	default:
+		return
+	}
+	if claim.ResolutionNote != "" {
+		outcome += "\n\n" + claim.ResolutionNote
+	}
+
+	email.SendAsync(h.mailer, email.Message{
+		To:      []string{claim.User.Email},
+		Subject: fmt.Sprintf("Update on your warranty claim for %s", product),
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n", claim.User.FirstName, outcome),
+	})
+}
+
+// createReplacementOrder places a free order for one unit of the claimed
+// product, shipped like any other order
+func createReplacementOrder(tx *gorm.DB, claim *models.WarrantyClaim, address *models.Address) (*models.Order, error) {
+	order := models.Order{
+		UserID:          claim.UserID,
+		ShippingAddress: *address,
+		BillingAddress:  *address,
+		PaymentMethod:   "warranty",
+		WarrantyClaimID: &claim.ID,
+	}
+	if err := placeNoChargeOrder(tx, &order, claim.ProductID, 1); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// releaseSerials drops the serials captured for an order line that are not in
+// keep. Serials with a warranty claim stay on record.
+func releaseSerials(tx *gorm.DB, orderItemID string, keep map[string]bool) error {
+	var captured []models.ProductSerial
+	if err := tx.Where("order_item_id = ?", orderItemID).Find(&captured).Error; err != nil {
+		return err
+	}
+
+	for _, serial := range captured {
+		if keep[serial.SerialNumber] {
+			continue
+		}
+		var claims int64
+		tx.Model(&models.WarrantyClaim{}).Where("serial_id = ?", serial.ID).Count(&claims)
+		if claims > 0 {
+			return &serialConflictError{serial: serial.SerialNumber, reason: "has a warranty claim and cannot be removed"}
+		}
+		if err := tx.Delete(&serial).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureSerial records a unit shipped on an order line. A unit the buyer
+// registered themselves before it was captured is verified and linked.
+func captureSerial(tx *gorm.DB, item *models.OrderItem, serialNumber string) error {
+	order := &item.Order
+	serial := models.ProductSerial{
+		ProductID:      item.ProductID,
+		SerialNumber:   serialNumber,
+		OrderID:        &order.ID,
+		OrderItemID:    &item.ID,
+		OwnerID:        &order.UserID,
+		PurchaseDate:   order.CreatedAt,
+		WarrantyEndsAt: warrantyEnd(order.CreatedAt, item.Product.WarrantyMonths),
+		Verified:       true,
+	}
+
+	var existing models.ProductSerial
+	err := tx.Where("product_id = ? AND serial_number = ?", item.ProductID, serialNumber).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return tx.Create(&serial).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case existing.OrderItemID != nil && *existing.OrderItemID == item.ID:
+		return nil
+	case existing.OrderItemID != nil:
+		return &serialConflictError{serial: serialNumber, reason: "is already recorded on another order"}
+	case existing.OwnerID != nil && *existing.OwnerID != order.UserID:
+		return &serialConflictError{serial: serialNumber, reason: "is registered to another customer"}
+	}
+
+	serial.ID = existing.ID
+	serial.RegisteredAt = existing.RegisteredAt
+	return tx.Model(&existing).Select("order_id", "order_item_id", "owner_id", "purchase_date", "warranty_ends_at", "verified").
+		Updates(&serial).Error
+}
+
+// warrantyEnd returns when a warranty of the given length bought on purchased
+// runs out, or nil when there is no warranty
+func warrantyEnd(purchased time.Time, months int) *time.Time {
+	if months <= 0 {
+		return nil
+	}
+	end := purchased.AddDate(0, months, 0)
+	return &end
+}
+
+// normalizeSerial makes serial numbers typed by hand match the printed label
+func normalizeSerial(serial string) string {
+	return strings.ToUpper(strings.TrimSpace(serial))
+}
@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"bizoe-3d-store/internal/middleware"
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/pricing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WishlistHandler struct {
+	db      *gorm.DB
+	pricing *pricing.Service
+}
+
+type CreateWishlistRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	IsPublic bool   `json:"isPublic"`
+}
+
+type UpdateWishlistRequest struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
+	IsPublic *bool   `json:"isPublic"`
+}
+
+type AddWishlistItemRequest struct {
+	ProductID string `json:"productId" binding:"required"`
+}
+
+type MoveToCartRequest struct {
+	ProductIDs []string `json:"productIds"` // Empty moves every item
+}
+
+// SkippedWishlistItem explains why an item was left on the wishlist
+type SkippedWishlistItem struct {
+	ProductID string `json:"productId"`
+	Reason    string `json:"reason"`
+}
+
+func NewWishlistHandler(db *gorm.DB) *WishlistHandler {
+	return &WishlistHandler{db: db, pricing: pricing.NewService(db)}
+}
+
+// GetWishlists returns the user's wishlists with their products
+func (h *WishlistHandler) GetWishlists(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var wishlists []models.Wishlist
+	if err := preloadWishlistItems(h.db).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&wishlists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch wishlists",
+		})
+		return
+	}
+
+	if !h.presentWishlists(c, wishlists) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    wishlists,
+	})
+}
+
+// CreateWishlist creates a named wishlist for the user
+func (h *WishlistHandler) CreateWishlist(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var req CreateWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	wishlist := models.Wishlist{
+		UserID:   userID,
+		Name:     req.Name,
+		IsPublic: req.IsPublic,
+		Items:    []models.WishlistItem{},
+	}
+	if err := h.db.Create(&wishlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to create wishlist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Wishlist created successfully",
+		"data":    wishlist,
+	})
+}
+
+// GetWishlist returns one of the user's wishlists
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	lists := []models.Wishlist{wishlist}
+	if !h.presentWishlists(c, lists) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    lists[0],
+	})
+}
+
+// GetSharedWishlist returns a public wishlist by its share token
+func (h *WishlistHandler) GetSharedWishlist(c *gin.Context) {
+	var wishlist models.Wishlist
+	if err := preloadWishlistItems(h.db).Preload("User").
+		Where("share_token = ? AND is_public = ?", c.Param("token"), true).
+		First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Wishlist not found",
+				"message": "This wishlist does not exist or is private",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch wishlist",
+		})
+		return
+	}
+
+	// Visitors see the owner's public name, not their account or the token
+	wishlist.Owner = displayName(wishlist.User)
+	wishlist.UserID = ""
+	wishlist.ShareToken = ""
+
+	lists := []models.Wishlist{wishlist}
+	if !h.presentWishlists(c, lists) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    lists[0],
+	})
+}
+
+// UpdateWishlist renames a wishlist or changes whether it can be shared
+func (h *WishlistHandler) UpdateWishlist(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	var req UpdateWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.IsPublic != nil {
+		updates["is_public"] = *req.IsPublic
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&wishlist).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to update wishlist",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Wishlist updated successfully",
+		"data":    wishlist,
+	})
+}
+
+// ResetShareLink replaces a wishlist's share token so previously shared links stop working
+func (h *WishlistHandler) ResetShareLink(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(&wishlist).Update("share_token", uuid.New().String()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to reset share link",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Share link reset successfully",
+		"data":    wishlist,
+	})
+}
+
+// DeleteWishlist deletes a wishlist and its items
+func (h *WishlistHandler) DeleteWishlist(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("wishlist_id = ?", wishlist.ID).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&wishlist).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to delete wishlist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Wishlist deleted successfully",
+	})
+}
+
+// AddWishlistItem saves a product to a wishlist at its current price
+func (h *WishlistHandler) AddWishlistItem(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	var req AddWishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var product models.Product
+	if err := h.db.First(&product, "id = ?", req.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Product not found",
+				"message": "The requested product does not exist",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch product",
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.WishlistItem{}).Where("wishlist_id = ? AND product_id = ?", wishlist.ID, product.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Already saved",
+			"message": "This product is already on the wishlist",
+		})
+		return
+	}
+
+	item := models.WishlistItem{
+		WishlistID:    wishlist.ID,
+		ProductID:     product.ID,
+		PriceAtAdd:    product.Price,
+		AwaitingStock: !product.InStockNow(),
+	}
+	if err := h.db.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to save product to wishlist",
+		})
+		return
+	}
+
+	item.Product = product
+	lists := []models.Wishlist{{Items: []models.WishlistItem{item}}}
+	if !h.presentWishlists(c, lists) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Product saved to wishlist",
+		"data":    lists[0].Items[0],
+	})
+}
+
+// RemoveWishlistItem removes a product from a wishlist
+func (h *WishlistHandler) RemoveWishlistItem(c *gin.Context) {
+	wishlist, ok := h.findWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	result := h.db.Where("wishlist_id = ? AND product_id = ?", wishlist.ID, c.Param("productId")).
+		Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to remove product from wishlist",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Item not found",
+			"message": "Product is not on this wishlist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Product removed from wishlist",
+	})
+}
+
+// MoveToCart adds one unit of each selected wishlist product to the cart and
+// removes it from the wishlist. Unavailable products stay on the list and are
+// reported back.
+func (h *WishlistHandler) MoveToCart(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	wishlist, ok := h.findWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	var req MoveToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Validation failed",
+			"message": err.Error(),
+		})
+		return
+	}
+	selected := make(map[string]bool, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		selected[id] = true
+	}
+
+	currency := middleware.GetCurrency(c)
+	cart, err := userCart(h.db, userID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch cart",
+		})
+		return
+	}
+
+	moved := []string{}
+	skipped := []SkippedWishlistItem{}
+	for i := range wishlist.Items {
+		item := &wishlist.Items[i]
+		if len(selected) > 0 && !selected[item.ProductID] {
+			continue
+		}
+		delete(selected, item.ProductID)
+
+		product := &item.Product
+		if product.ArchivedAt.Valid {
+			skipped = append(skipped, SkippedWishlistItem{ProductID: item.ProductID, Reason: "unavailable"})
+			continue
+		}
+		if err := loadBundle(h.db, product); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to fetch product",
+			})
+			return
+		}
+		available, err := orderableQuantity(h.db, product)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to check product availability",
+			})
+			return
+		}
+		if available <= 0 {
+			skipped = append(skipped, SkippedWishlistItem{ProductID: item.ProductID, Reason: "out_of_stock"})
+			continue
+		}
+
+		price, _, err := h.pricing.Quote(product, currency)
+		if err != nil {
+			respondPricingError(c, err)
+			return
+		}
+		if err := addCartItem(h.db, cart.ID, product.ID, 1, available, price); err != nil {
+			if err == errInsufficientStock {
+				skipped = append(skipped, SkippedWishlistItem{ProductID: item.ProductID, Reason: "out_of_stock"})
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to add item to cart",
+			})
+			return
+		}
+		if err := h.db.Delete(item).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"message": "Failed to remove product from wishlist",
+			})
+			return
+		}
+		moved = append(moved, item.ProductID)
+	}
+	for id := range selected {
+		skipped = append(skipped, SkippedWishlistItem{ProductID: id, Reason: "not_on_wishlist"})
+	}
+
+	// Re-quote the cart in the storefront currency and update totals
+	preloadCartItems(h.db).First(&cart, "id = ?", cart.ID)
+	if err := repriceCart(h.db, h.pricing, &cart, currency); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+	localizeCart(h.db, &cart, middleware.GetLocale(c))
+	cart.Warnings = compatibilityWarnings(h.db, userID, &cart)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Wishlist items moved to cart",
+		"data": gin.H{
+			"cart":    cart,
+			"moved":   moved,
+			"skipped": skipped,
+		},
+	})
+}
+
+// findWishlist loads the wishlist in the :id route parameter when it belongs
+// to the current user, optionally with its items. It writes the error
+// response and returns false otherwise.
+func (h *WishlistHandler) findWishlist(c *gin.Context, withItems bool) (models.Wishlist, bool) {
+	var wishlist models.Wishlist
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "User not authenticated",
+		})
+		return wishlist, false
+	}
+
+	db := h.db
+	if withItems {
+		db = preloadWishlistItems(db)
+	}
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&wishlist).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Wishlist not found",
+				"message": "The requested wishlist does not exist",
+			})
+			return wishlist, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"message": "Failed to fetch wishlist",
+		})
+		return wishlist, false
+	}
+	return wishlist, true
+}
+
+// presentWishlists localizes and prices the products on the wishlists and
+// converts the saved prices into the storefront currency. It writes an error
+// response and returns false when pricing fails.
+func (h *WishlistHandler) presentWishlists(c *gin.Context, wishlists []models.Wishlist) bool {
+	currency := middleware.GetCurrency(c)
+
+	var products []models.Product
+	for i := range wishlists {
+		for j := range wishlists[i].Items {
+			item := &wishlists[i].Items[j]
+			item.PriceDropped = item.Product.Price < item.PriceAtAdd
+			if item.Product.Currency != "" {
+				saved, err := h.pricing.Convert(item.PriceAtAdd, item.Product.Currency, currency)
+				if err != nil {
+					respondPricingError(c, err)
+					return false
+				}
+				item.PriceAtAdd = saved
+			}
+			products = append(products, item.Product)
+		}
+	}
+
+	localizeProducts(h.db, products, middleware.GetLocale(c))
+	if !presentProducts(c, h.pricing, products) {
+		return false
+	}
+
+	n := 0
+	for i := range wishlists {
+		for j := range wishlists[i].Items {
+			wishlists[i].Items[j].Product = products[n]
+			n++
+		}
+	}
+	return true
+}
+
+// preloadWishlistItems loads wishlist items newest first with their products,
+// including archived ones so shoppers can see what is no longer sold
+func preloadWishlistItems(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).Preload("Items.Product", unscoped).Preload("Items.Product.Category", unscoped)
+}
@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the language the base product and category columns are written in
+const DefaultLocale = "en"
+
+// SupportedLocales lists the locales the storefront ships translations for
+var SupportedLocales = []string{"en", "zh-TW"}
+
+// IsSupported reports whether locale is one of the supported locales
+func IsSupported(locale string) bool {
+	for _, l := range SupportedLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize maps loose locale tags (zh_tw, zh-Hant, zh-HK, EN-us) onto a supported locale.
+// It returns an empty string when no supported locale matches.
+func Normalize(tag string) string {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "" {
+		return ""
+	}
+
+	for _, l := range SupportedLocales {
+		if strings.EqualFold(l, tag) {
+			return l
+		}
+	}
+
+	lower := strings.ToLower(tag)
+	switch {
+	case lower == "zh-hant" || strings.HasPrefix(lower, "zh-hant-") || lower == "zh-hk":
+		return "zh-TW"
+	case lower == "zh" || strings.HasPrefix(lower, "zh-"):
+		// Other Chinese tags (zh-CN, zh-Hans) have no translation of their own
+		// and are left to the next preference and the default locale
+		return ""
+	}
+
+	base := strings.SplitN(lower, "-", 2)[0]
+	for _, l := range SupportedLocales {
+		if strings.EqualFold(strings.SplitN(l, "-", 2)[0], base) {
+			return l
+		}
+	}
+	return ""
+}
+
+// Resolve picks the locale for a request, preferring an explicit ?locale=
+// parameter over the Accept-Language header and falling back to DefaultLocale
+func Resolve(queryLocale, acceptLanguage string) string {
+	if l := Normalize(queryLocale); l != "" {
+		return l
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if l := Normalize(tag); l != "" {
+			return l
+		}
+	}
+
+	return DefaultLocale
+}
+
+// FallbackChain returns the locales to try, in order, when looking up a translation
+func FallbackChain(locale string) []string {
+	chain := []string{}
+	if locale != "" {
+		chain = append(chain, locale)
+	}
+	if base := strings.SplitN(locale, "-", 2)[0]; base != locale && base != "" {
+		chain = append(chain, base)
+	}
+	if locale != DefaultLocale {
+		chain = append(chain, DefaultLocale)
+	}
+	return chain
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header ordered by quality
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
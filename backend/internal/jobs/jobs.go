@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs fn in a background goroutine once at start-up and then on every
+// tick of interval. Errors are logged and do not stop the schedule.
+func Every(name string, interval time.Duration, fn func() error) {
+	go func() {
+		run(name, fn)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run(name, fn)
+		}
+	}()
+}
+
+func run(name string, fn func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", name, r)
+		}
+	}()
+
+	start := time.Now()
+	if err := fn(); err != nil {
+		log.Printf("Job %s failed after %s: %v", name, time.Since(start), err)
+	}
+}
@@ -0,0 +1,47 @@
+package media
+
+import (
+	"bizoe-3d-store/internal/models"
+	"bizoe-3d-store/internal/storage"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GCGracePeriod keeps freshly detached images around in case an edit is undone
+const GCGracePeriod = time.Hour
+
+// CollectGarbage deletes images that no product references any more,
+// together with their stored variants, and returns how many were removed.
+// Archived products keep their images so they can be restored intact.
+func CollectGarbage(db *gorm.DB, store storage.Storage, olderThan time.Time) (int, error) {
+	var images []models.ProductImage
+	err := db.Where("updated_at < ?", olderThan).
+		Where("product_id IS NULL OR product_id NOT IN (?)", db.Unscoped().Model(&models.Product{}).Select("id")).
+		Find(&images).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find unreferenced images: %w", err)
+	}
+
+	removed := 0
+	for _, image := range images {
+		failed := false
+		for _, v := range image.Variants {
+			if err := store.Delete(v.Key); err != nil {
+				log.Printf("Failed to delete image object %s: %v", v.Key, err)
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+		if err := db.Delete(&image).Error; err != nil {
+			return removed, fmt.Errorf("failed to delete image %s: %w", image.ID, err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
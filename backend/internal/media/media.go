@@ -0,0 +1,145 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// ErrUnsupportedType is returned for uploads that are not JPEG, PNG or GIF images
+var ErrUnsupportedType = errors.New("unsupported image type")
+
+// ErrTooLarge is returned for images whose dimensions exceed MaxDimension or
+// MaxPixels
+var ErrTooLarge = errors.New("image too large")
+
+// Limits on the declared size of an upload, checked before it is decoded so
+// that a small file claiming huge dimensions cannot exhaust memory
+const (
+	MaxDimension = 10000
+	MaxPixels    = 40_000_000
+)
+
+// AllowedTypes are the upload MIME types, detected from file content rather
+// than the client supplied header
+var AllowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Size is a named bounding box variants are scaled to fit
+type Size struct {
+	Name   string
+	MaxDim int
+}
+
+// Sizes are generated for every upload. The original is re-encoded as well,
+// which drops EXIF and other metadata from the stored file.
+var Sizes = []Size{
+	{Name: "original", MaxDim: 2400},
+	{Name: "large", MaxDim: 1200},
+	{Name: "medium", MaxDim: 600},
+	{Name: "thumb", MaxDim: 200},
+}
+
+// Variant is one encoded rendition of an uploaded image
+type Variant struct {
+	Name        string
+	Format      string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// DetectType sniffs the MIME type of an upload and checks it is allowed
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !AllowedTypes[contentType] {
+		return contentType, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	return contentType, nil
+}
+
+// Process checks the declared size of an uploaded image, decodes it, applies its EXIF orientation and
+// produces every size in the source format (JPEG, or PNG for PNG and GIF)
+// plus a WebP rendition of each resized variant
+func Process(data []byte) ([]Variant, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d, at most %d pixels a side and %d in total are accepted",
+			ErrTooLarge, config.Width, config.Height, MaxDimension, MaxPixels)
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	src := toRGBA(img)
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+
+	format, outType := "png", "image/png"
+	if contentType == "image/jpeg" {
+		format, outType = "jpg", "image/jpeg"
+	}
+
+	var variants []Variant
+	for _, size := range Sizes {
+		w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), size.MaxDim)
+		scaled := resize(src, w, h)
+
+		var buf bytes.Buffer
+		if format == "jpg" {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", size.Name, err)
+		}
+		variants = append(variants, Variant{
+			Name: size.Name, Format: format, ContentType: outType,
+			Width: w, Height: h, Data: buf.Bytes(),
+		})
+
+		if size.Name == "original" {
+			continue
+		}
+
+		var webpBuf bytes.Buffer
+		if err := EncodeWebP(&webpBuf, scaled); err != nil {
+			return nil, fmt.Errorf("failed to encode %s webp variant: %w", size.Name, err)
+		}
+		variants = append(variants, Variant{
+			Name: size.Name, Format: "webp", ContentType: "image/webp",
+			Width: w, Height: h, Data: webpBuf.Bytes(),
+		})
+	}
+
+	return variants, nil
+}
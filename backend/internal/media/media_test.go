@@ -0,0 +1,71 @@
+package media_test
+
+import (
+	"bizoe-3d-store/internal/media"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// pngWithHeader encodes a small PNG and rewrites its header to declare the
+// given dimensions, as a hostile upload would
+func pngWithHeader(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Signature (8), IHDR length (4) and type (4), then width and height
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestProcessRejectsOversizedImages(t *testing.T) {
+	for _, size := range [][2]uint32{
+		{100000, 1},
+		{1, 100000},
+		{8000, 8000}, // Within the side limit but over the pixel count
+		{50000, 50000},
+	} {
+		_, err := media.Process(pngWithHeader(t, size[0], size[1]))
+		if !errors.Is(err, media.ErrTooLarge) {
+			t.Errorf("%dx%d: err = %v, want ErrTooLarge", size[0], size[1], err)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := media.Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]int{"original": {300, 200}, "large": {300, 200}, "medium": {300, 200}, "thumb": {200, 133}}
+	for _, variant := range variants {
+		size := want[variant.Name]
+		if variant.Width != size[0] || variant.Height != size[1] {
+			t.Errorf("%s %s: %dx%d, want %dx%d", variant.Name, variant.Format, variant.Width, variant.Height, size[0], size[1])
+		}
+	}
+	if len(variants) != 7 {
+		t.Errorf("%d variants, want 7", len(variants))
+	}
+}
@@ -0,0 +1,334 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// This file implements a small lossless WebP (VP8L) encoder. It uses no
+// transforms, backward references or color cache, so files are larger than
+// those produced by libwebp, but they decode everywhere WebP is supported
+// and need nothing beyond the standard library.
+
+const (
+	vp8lSignature        = 0x2f
+	vp8lMaxDimension     = 1 << 14
+	numLiteralCodes      = 256
+	numLengthCodes       = 24
+	numDistanceCodes     = 40
+	numCodeLengthCodes   = 19
+	maxCodeLength        = 15
+	maxCodeLengthCodeLen = 7
+)
+
+// codeLengthCodeOrder is the order code length code lengths are written in
+var codeLengthCodeOrder = [numCodeLengthCodes]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP writes img to w as a lossless WebP file
+func EncodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return errors.New("webp: image dimensions out of range")
+	}
+
+	// Collect ARGB channels and their histograms
+	n := width * height
+	pixels := make([][4]uint8, 0, n) // a, r, g, b
+	green := make([]int, numLiteralCodes+numLengthCodes)
+	red := make([]int, numLiteralCodes)
+	blue := make([]int, numLiteralCodes)
+	alpha := make([]int, numLiteralCodes)
+	hasAlpha := false
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, [4]uint8{c.A, c.R, c.G, c.B})
+			green[c.G]++
+			red[c.R]++
+			blue[c.B]++
+			alpha[c.A]++
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // version
+
+	bw.writeBits(0, 1) // no transforms
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // a single prefix code group for the whole image
+
+	greenCode := writePrefixCode(bw, green)
+	redCode := writePrefixCode(bw, red)
+	blueCode := writePrefixCode(bw, blue)
+	alphaCode := writePrefixCode(bw, alpha)
+	writePrefixCode(bw, make([]int, numDistanceCodes))
+
+	for _, p := range pixels {
+		greenCode.write(bw, int(p[2]))
+		redCode.write(bw, int(p[1]))
+		blueCode.write(bw, int(p[3]))
+		alphaCode.write(bw, int(p[0]))
+	}
+
+	data := bw.bytes()
+	pad := len(data) & 1
+
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+len(data)+pad))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(len(data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if pad == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// bitWriter packs bits least significant bit first, as VP8L requires
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(bits uint32, n uint) {
+	w.acc |= uint64(bits) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc = 0
+		w.nbits = 0
+	}
+	return w.buf
+}
+
+// prefixCode holds the bit-reversed canonical codes of an alphabet
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (pc *prefixCode) write(w *bitWriter, symbol int) {
+	if n := pc.lengths[symbol]; n > 0 {
+		w.writeBits(pc.codes[symbol], uint(n))
+	}
+}
+
+// writePrefixCode chooses an encoding for the histogram, writes its
+// description and returns the code used for the symbols
+func writePrefixCode(w *bitWriter, histogram []int) *prefixCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	lengths := make([]uint8, len(histogram))
+
+	// Simple codes describe up to two symbols below 256 directly
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < numLiteralCodes) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		w.writeBits(1, 1) // simple code
+		w.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.writeBits(0, 1)
+			w.writeBits(uint32(used[0]), 1)
+		} else {
+			w.writeBits(1, 1)
+			w.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			w.writeBits(uint32(used[1]), 8)
+			lengths[used[0]] = 1
+			lengths[used[1]] = 1
+		}
+		// A single symbol is decoded without reading any bits
+		return &prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+	}
+
+	lengths = huffmanLengths(histogram, maxCodeLength)
+
+	// Describe the code lengths with a code length code
+	clHistogram := make([]int, numCodeLengthCodes)
+	for _, l := range lengths {
+		clHistogram[l]++
+	}
+	ensureTwoSymbols(clHistogram)
+	clLengths := huffmanLengths(clHistogram, maxCodeLengthCodeLen)
+	clCode := &prefixCode{lengths: clLengths, codes: canonicalCodes(clLengths)}
+
+	numCodes := numCodeLengthCodes
+	for numCodes > 4 && clLengths[codeLengthCodeOrder[numCodes-1]] == 0 {
+		numCodes--
+	}
+
+	w.writeBits(0, 1) // normal code
+	w.writeBits(uint32(numCodes-4), 4)
+	for i := 0; i < numCodes; i++ {
+		w.writeBits(uint32(clLengths[codeLengthCodeOrder[i]]), 3)
+	}
+	w.writeBits(0, 1) // code lengths are given for every symbol
+
+	for _, l := range lengths {
+		clCode.write(w, int(l))
+	}
+
+	return &prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+// ensureTwoSymbols adds a dummy symbol so a Huffman code is never degenerate
+func ensureTwoSymbols(histogram []int) {
+	used := 0
+	for _, c := range histogram {
+		if c > 0 {
+			used++
+		}
+	}
+	for i := 0; used < 2 && i < len(histogram); i++ {
+		if histogram[i] == 0 {
+			histogram[i] = 1
+			used++
+		}
+	}
+}
+
+// huffmanLengths builds length-limited Huffman code lengths for a histogram.
+// When the tree gets too deep the smallest counts are raised and the tree is
+// rebuilt, which converges to a balanced code.
+func huffmanLengths(histogram []int, limit int) []uint8 {
+	counts := append([]int(nil), histogram...)
+	for floor := 1; ; floor *= 2 {
+		lengths, maxLen := buildHuffman(counts)
+		if maxLen <= limit {
+			return lengths
+		}
+		for i, c := range counts {
+			if c > 0 && c < floor {
+				counts[i] = floor
+			}
+		}
+	}
+}
+
+func buildHuffman(counts []int) ([]uint8, int) {
+	type node struct {
+		count       int
+		symbol      int
+		left, right int
+	}
+
+	lengths := make([]uint8, len(counts))
+	var nodes []node
+	var queue []int
+	for symbol, c := range counts {
+		if c > 0 {
+			nodes = append(nodes, node{count: c, symbol: symbol, left: -1, right: -1})
+			queue = append(queue, len(nodes)-1)
+		}
+	}
+	if len(queue) == 0 {
+		return lengths, 0
+	}
+	if len(queue) == 1 {
+		lengths[nodes[queue[0]].symbol] = 1
+		return lengths, 1
+	}
+
+	for len(queue) > 1 {
+		sort.SliceStable(queue, func(i, j int) bool { return nodes[queue[i]].count < nodes[queue[j]].count })
+		a, b := queue[0], queue[1]
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, symbol: -1, left: a, right: b})
+		queue = append(queue[2:], len(nodes)-1)
+	}
+
+	maxLen := 0
+	var walk func(i, depth int)
+	walk = func(i, depth int) {
+		if nodes[i].symbol >= 0 {
+			lengths[nodes[i].symbol] = uint8(depth)
+			if depth > maxLen {
+				maxLen = depth
+			}
+			return
+		}
+		walk(nodes[i].left, depth+1)
+		walk(nodes[i].right, depth+1)
+	}
+	walk(queue[0], 0)
+
+	return lengths, maxLen
+}
+
+// canonicalCodes assigns canonical codes to lengths, bit-reversed so they can
+// be written least significant bit first
+func canonicalCodes(lengths []uint8) []uint32 {
+	var blCount [maxCodeLength + 1]uint32
+	for _, l := range lengths {
+		if l > 0 {
+			blCount[l]++
+		}
+	}
+
+	var nextCode [maxCodeLength + 1]uint32
+	code := uint32(0)
+	for bits := 1; bits <= maxCodeLength; bits++ {
+		code = (code + blCount[bits-1]) << 1
+		nextCode[bits] = code
+	}
+
+	codes := make([]uint32, len(lengths))
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[symbol] = reverseBits(nextCode[l], l)
+		nextCode[l]++
+	}
+	return codes
+}
+
+func reverseBits(code uint32, n uint8) uint32 {
+	var r uint32
+	for i := uint8(0); i < n; i++ {
+		r = (r << 1) | (code & 1)
+		code >>= 1
+	}
+	return r
+}
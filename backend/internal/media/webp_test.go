@@ -0,0 +1,131 @@
+package media_test
+
+import (
+	"bizoe-3d-store/internal/media"
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// roundTrip encodes img as WebP, decodes it with x/image and compares every
+// pixel with the original
+func roundTrip(t *testing.T, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := media.EncodeWebP(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	b := img.Bounds()
+	if decoded.Bounds().Dx() != b.Dx() || decoded.Bounds().Dy() != b.Dy() {
+		t.Fatalf("decoded %v, want %v", decoded.Bounds(), b)
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			want := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y))
+			got := color.NRGBAModel.Convert(decoded.At(decoded.Bounds().Min.X+x, decoded.Bounds().Min.Y+y))
+			if got != want {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// paint fills a w×h image with the colors pixel returns
+func paint(w, h int, pixel func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, pixel(x, y))
+		}
+	}
+	return img
+}
+
+// fibonacciSymbols holds symbol i fib(i+1) times for i up to 19, padded with
+// the last symbol so the smallest counts stay a Fibonacci run
+var fibonacciSymbols = func() []uint8 {
+	symbols := make([]uint8, 178*100)
+	n, a, b := 0, 1, 1
+	for symbol := 0; symbol < 20; symbol++ {
+		for i := 0; i < a; i++ {
+			symbols[n] = uint8(symbol)
+			n++
+		}
+		a, b = b, a+b
+	}
+	for ; n < len(symbols); n++ {
+		symbols[n] = 19
+	}
+	return symbols
+}()
+
+func TestEncodeWebPSimpleCodes(t *testing.T) {
+	// Every channel uses at most two values, so only simple prefix codes are written
+	cases := map[string]*image.NRGBA{
+		"single pixel": paint(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{200, 100, 50, 255} }),
+		"solid":        paint(17, 9, func(x, y int) color.NRGBA { return color.NRGBA{12, 34, 56, 255} }),
+		"checkerboard": paint(16, 16, func(x, y int) color.NRGBA {
+			if (x+y)%2 == 0 {
+				return color.NRGBA{255, 255, 255, 255}
+			}
+			return color.NRGBA{0, 0, 0, 255}
+		}),
+		// Symbols 0 and 1 take the one-bit form of the first simple code symbol
+		"low values": paint(8, 8, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x % 2), uint8(y % 2), 1, 255}
+		}),
+		"two alphas": paint(8, 4, func(x, y int) color.NRGBA {
+			return color.NRGBA{10, 20, 30, uint8(128 + 127*(x%2))}
+		}),
+	}
+	for name, img := range cases {
+		t.Run(name, func(t *testing.T) { roundTrip(t, img) })
+	}
+}
+
+func TestEncodeWebPNormalCodes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cases := map[string]image.Image{
+		"gradient": paint(300, 200, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(y), uint8(x + y), 255}
+		}),
+		"noise with alpha": paint(64, 64, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))}
+		}),
+		"skewed": paint(256, 256, func(x, y int) color.NRGBA {
+			v := uint8(0)
+			for v < 255 && rng.Intn(3) == 0 {
+				v++
+			}
+			return color.NRGBA{v, v / 2, 255 - v, 255}
+		}),
+		// Fibonacci counts build Huffman trees deeper than the 15 bits VP8L allows
+		"fibonacci": paint(178, 100, func(x, y int) color.NRGBA {
+			return color.NRGBA{fibonacciSymbols[y*178+x], 0, 0, 255}
+		}),
+		// Channels mixing simple and normal codes
+		"three greens": paint(9, 9, func(x, y int) color.NRGBA {
+			return color.NRGBA{0, uint8(x % 3 * 100), 0, 255}
+		}),
+		"offset bounds": image.NewRGBA(image.Rect(5, 7, 40, 30)),
+	}
+	for name, img := range cases {
+		t.Run(name, func(t *testing.T) { roundTrip(t, img) })
+	}
+}
+
+func TestEncodeWebPRejectsOversizedImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := media.EncodeWebP(&buf, image.NewGray(image.Rect(0, 0, 1<<14+1, 1))); err == nil {
+		t.Error("expected an error for an image wider than 16384 pixels")
+	}
+}
@@ -0,0 +1,318 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxTriangles bounds the meshes accepted for analysis
+const MaxTriangles = 5_000_000
+
+// Format identifies the file format a mesh was read from
+type Format string
+
+const (
+	FormatSTLBinary Format = "stl_binary"
+	FormatSTLASCII  Format = "stl_ascii"
+	FormatOBJ       Format = "obj"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for files that are neither STL nor OBJ
+	ErrUnsupportedFormat = errors.New("unsupported mesh format")
+	// ErrEmptyMesh is returned when a file has no usable triangles
+	ErrEmptyMesh = errors.New("mesh has no triangles")
+	// ErrTooManyTriangles is returned for meshes above MaxTriangles
+	ErrTooManyTriangles = fmt.Errorf("mesh has more than %d triangles", MaxTriangles)
+)
+
+// Vec3 is a point or direction in model units, millimetres by convention
+type Vec3 [3]float64
+
+func (a Vec3) sub(b Vec3) Vec3 { return Vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+
+func (a Vec3) cross(b Vec3) Vec3 {
+	return Vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a Vec3) dot(b Vec3) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+
+func (a Vec3) length() float64 { return math.Sqrt(a.dot(a)) }
+
+// Triangle is a facet with vertices in counter-clockwise order seen from outside
+type Triangle [3]Vec3
+
+// Mesh is a triangle soup as read from a file
+type Mesh struct {
+	Format    Format
+	Triangles []Triangle
+}
+
+// Parse reads an STL (binary or ASCII) or OBJ mesh. The name's extension
+// tells OBJ apart from STL; binary and ASCII STL are told apart by content.
+func Parse(name string, data []byte) (*Mesh, error) {
+	var m *Mesh
+	var err error
+	switch ext := strings.ToLower(name[strings.LastIndex(name, ".")+1:]); ext {
+	case "stl":
+		if isBinarySTL(data) {
+			m, err = parseBinarySTL(data)
+		} else {
+			m, err = parseASCIISTL(data)
+		}
+	case "obj":
+		m, err = parseOBJ(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Triangles) == 0 {
+		return nil, ErrEmptyMesh
+	}
+	return m, nil
+}
+
+// isBinarySTL checks the size implied by the triangle count. Many exporters
+// start binary headers with "solid" too, so the keyword alone is not enough.
+func isBinarySTL(data []byte) bool {
+	if len(data) < 84 {
+		return false
+	}
+	count := binary.LittleEndian.Uint32(data[80:84])
+	return uint64(len(data)) == 84+uint64(count)*50
+}
+
+func parseBinarySTL(data []byte) (*Mesh, error) {
+	count := int(binary.LittleEndian.Uint32(data[80:84]))
+	if count > MaxTriangles {
+		return nil, ErrTooManyTriangles
+	}
+
+	m := &Mesh{Format: FormatSTLBinary, Triangles: make([]Triangle, 0, count)}
+	for i := 0; i < count; i++ {
+		// Each record: normal, three vertices, attribute byte count
+		record := data[84+i*50:]
+		var t Triangle
+		for v := 0; v < 3; v++ {
+			for axis := 0; axis < 3; axis++ {
+				bits := binary.LittleEndian.Uint32(record[12+v*12+axis*4:])
+				t[v][axis] = float64(math.Float32frombits(bits))
+			}
+		}
+		m.Triangles = append(m.Triangles, t)
+	}
+	return m, nil
+}
+
+func parseASCIISTL(data []byte) (*Mesh, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	m := &Mesh{Format: FormatSTLASCII}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var vertices []Vec3
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "vertex":
+			v, err := parseVec3(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			vertices = append(vertices, v)
+		case "endfacet":
+			if len(vertices) != 3 {
+				return nil, fmt.Errorf("line %d: facet has %d vertices", line, len(vertices))
+			}
+			if len(m.Triangles) == MaxTriangles {
+				return nil, ErrTooManyTriangles
+			}
+			m.Triangles = append(m.Triangles, Triangle{vertices[0], vertices[1], vertices[2]})
+			vertices = vertices[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseOBJ(data []byte) (*Mesh, error) {
+	m := &Mesh{Format: FormatOBJ}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var vertices []Vec3
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			v, err := parseVec3(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			vertices = append(vertices, v)
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: face has fewer than 3 vertices", line)
+			}
+			face := make([]Vec3, 0, len(fields)-1)
+			for _, ref := range fields[1:] {
+				// v, v/vt, v//vn or v/vt/vn; negative indices count back from the end
+				index, err := strconv.Atoi(strings.SplitN(ref, "/", 2)[0])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid vertex reference %q", line, ref)
+				}
+				if index < 0 {
+					index += len(vertices) + 1
+				}
+				if index < 1 || index > len(vertices) {
+					return nil, fmt.Errorf("line %d: vertex %s out of range", line, ref)
+				}
+				face = append(face, vertices[index-1])
+			}
+			// Polygons are split into a fan of triangles
+			for i := 1; i+1 < len(face); i++ {
+				if len(m.Triangles) == MaxTriangles {
+					return nil, ErrTooManyTriangles
+				}
+				m.Triangles = append(m.Triangles, Triangle{face[0], face[i], face[i+1]})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseVec3(fields []string) (Vec3, error) {
+	var v Vec3
+	if len(fields) < 3 {
+		return v, errors.New("vertex needs three coordinates")
+	}
+	for axis := 0; axis < 3; axis++ {
+		f, err := strconv.ParseFloat(fields[axis], 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return v, fmt.Errorf("invalid coordinate %q", fields[axis])
+		}
+		v[axis] = f
+	}
+	return v, nil
+}
+
+// Analysis describes the geometry of a mesh in model units
+type Analysis struct {
+	Triangles        int     `json:"triangles"`
+	Watertight       bool    `json:"watertight"`
+	BoundaryEdges    int     `json:"boundaryEdges"`    // Edges with a single facet: holes in the surface
+	NonManifoldEdges int     `json:"nonManifoldEdges"` // Edges shared by more than two facets
+	Volume           float64 `json:"volume"`           // Cubic units; only meaningful when watertight
+	SurfaceArea      float64 `json:"surfaceArea"`      // Square units
+	Min              Vec3    `json:"min"`
+	Max              Vec3    `json:"max"`
+}
+
+// Size returns the bounding box extents along x, y and z
+func (a *Analysis) Size() Vec3 {
+	return a.Max.sub(a.Min)
+}
+
+// weldTolerance merges vertices closer than this, in model units, when
+// checking that facets share their edges
+const weldTolerance = 1e-4
+
+type edge struct{ a, b int }
+
+// Analyze computes the volume, surface area and bounding box of the mesh and
+// checks that it is watertight: every edge must be shared by exactly two
+// facets. Degenerate facets are ignored.
+func (m *Mesh) Analyze() Analysis {
+	a := Analysis{
+		Min: Vec3{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Max: Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+
+	ids := make(map[[3]int64]int)
+	vertexID := func(v Vec3) int {
+		key := [3]int64{
+			int64(math.Round(v[0] / weldTolerance)),
+			int64(math.Round(v[1] / weldTolerance)),
+			int64(math.Round(v[2] / weldTolerance)),
+		}
+		id, ok := ids[key]
+		if !ok {
+			id = len(ids)
+			ids[key] = id
+		}
+		return id
+	}
+	edges := make(map[edge]int)
+
+	var signedVolume float64
+	for _, t := range m.Triangles {
+		for _, v := range t {
+			for axis := 0; axis < 3; axis++ {
+				a.Min[axis] = math.Min(a.Min[axis], v[axis])
+				a.Max[axis] = math.Max(a.Max[axis], v[axis])
+			}
+		}
+
+		normal := t[1].sub(t[0]).cross(t[2].sub(t[0]))
+		area := normal.length() / 2
+		if area == 0 {
+			continue
+		}
+		a.Triangles++
+		a.SurfaceArea += area
+		// Signed volume of the tetrahedron spanned with the origin
+		signedVolume += t[0].dot(t[1].cross(t[2])) / 6
+
+		v := [3]int{vertexID(t[0]), vertexID(t[1]), vertexID(t[2])}
+		for i := 0; i < 3; i++ {
+			p, q := v[i], v[(i+1)%3]
+			if p > q {
+				p, q = q, p
+			}
+			edges[edge{p, q}]++
+		}
+	}
+
+	for _, count := range edges {
+		switch {
+		case count == 1:
+			a.BoundaryEdges++
+		case count > 2:
+			a.NonManifoldEdges++
+		}
+	}
+	a.Watertight = a.Triangles > 0 && a.BoundaryEdges == 0 && a.NonManifoldEdges == 0
+	// Inside-out meshes give a negative volume
+	a.Volume = math.Abs(signedVolume)
+	if a.Triangles == 0 {
+		a.Min, a.Max = Vec3{}, Vec3{}
+	}
+	return a
+}
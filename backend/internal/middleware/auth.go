@@ -79,6 +79,30 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
+// OptionalAuth sets the user info like AuthRequired when a valid token is
+// sent, and lets the request through as a guest otherwise
+func OptionalAuth(jwtSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenParts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(tokenParts) == 2 && tokenParts[0] == "Bearer" {
+			token, err := jwt.ParseWithClaims(tokenParts[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, jwt.ErrSignatureInvalid
+				}
+				return []byte(jwtSecret), nil
+			})
+			if err == nil && token.Valid {
+				if claims, ok := token.Claims.(*Claims); ok {
+					c.Set("userID", claims.UserID)
+					c.Set("userEmail", claims.Email)
+					c.Set("isAdmin", claims.IsAdmin)
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
 // AdminRequired middleware checks if user is admin
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"bizoe-3d-store/internal/pricing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Currency middleware resolves the storefront currency from ?currency=, the
+// X-Currency header, or the request locale. It must run after Locale.
+func Currency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currency := pricing.Normalize(c.Query("currency"))
+		if currency == "" {
+			currency = pricing.Normalize(c.GetHeader("X-Currency"))
+		}
+		if currency == "" {
+			currency = pricing.DefaultForLocale(GetLocale(c))
+		}
+		c.Set("currency", currency)
+		c.Next()
+	}
+}
+
+// GetCurrency extracts the storefront currency from context
+func GetCurrency(c *gin.Context) string {
+	currency, exists := c.Get("currency")
+	if !exists {
+		return pricing.BaseCurrency
+	}
+	return currency.(string)
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"bizoe-3d-store/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale middleware resolves the request locale from ?locale= or Accept-Language
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Resolve(c.Query("locale"), c.GetHeader("Accept-Language"))
+		c.Set("locale", locale)
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
+
+// GetLocale extracts the resolved locale from context
+func GetLocale(c *gin.Context) string {
+	locale, exists := c.Get("locale")
+	if !exists {
+		return i18n.DefaultLocale
+	}
+	return locale.(string)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit middleware allows each client IP limit requests per window and
+// turns the rest away with 429. Counts are kept in memory, so every server
+// instance limits on its own.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	type counter struct {
+		count   int
+		resetAt time.Time
+	}
+
+	var mu sync.Mutex
+	clients := make(map[string]*counter)
+	lastSweep := time.Now()
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		// Forget clients whose window has passed so the map stays small
+		if now.Sub(lastSweep) > window {
+			for key, client := range clients {
+				if now.After(client.resetAt) {
+					delete(clients, key)
+				}
+			}
+			lastSweep = now
+		}
+		client := clients[ip]
+		if client == nil || now.After(client.resetAt) {
+			client = &counter{resetAt: now.Add(window)}
+			clients[ip] = client
+		}
+		client.count++
+		count, resetAt := client.count, client.resetAt
+		mu.Unlock()
+
+		if count > limit {
+			c.Header("Retry-After", strconv.Itoa(int(resetAt.Sub(now).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Too many requests",
+				"message": "Please wait a moment before trying again",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
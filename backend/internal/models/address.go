@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SavedAddress is an entry in a user's address book. Orders copy the address
+// at checkout, so editing or deleting it leaves past orders untouched.
+type SavedAddress struct {
+	ID                string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID            string    `json:"userId" gorm:"type:varchar(36);not null;index"`
+	Label             string    `json:"label" gorm:"type:varchar(50)"` // Home, Office, ...
+	Address           Address   `json:"address" gorm:"embedded"`
+	IsDefaultShipping bool      `json:"isDefaultShipping" gorm:"default:false"`
+	IsDefaultBilling  bool      `json:"isDefaultBilling" gorm:"default:false"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+
+	// Relationships
+	User *User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (a *SavedAddress) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
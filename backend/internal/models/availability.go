@@ -0,0 +1,40 @@
+package models
+
+// Availability selects how a product can be ordered when stock runs short
+type Availability string
+
+const (
+	// AvailabilityInStock only sells units that are in stock
+	AvailabilityInStock Availability = "in_stock"
+	// AvailabilityPreorder sells without limit ahead of the expected ship date
+	AvailabilityPreorder Availability = "preorder"
+	// AvailabilityBackorder sells up to BackorderLimit units beyond stock
+	AvailabilityBackorder Availability = "backorder"
+)
+
+// IsValidAvailability reports whether a is a known availability mode
+func IsValidAvailability(a Availability) bool {
+	switch a {
+	case AvailabilityInStock, AvailabilityPreorder, AvailabilityBackorder:
+		return true
+	}
+	return false
+}
+
+// OrderLineType tells whether an order line was served from stock or is
+// waiting for stock to arrive. Custom print and digital lines never touch
+// stock.
+type OrderLineType string
+
+const (
+	OrderLineStock     OrderLineType = "stock"
+	OrderLinePreorder  OrderLineType = "preorder"
+	OrderLineBackorder OrderLineType = "backorder"
+	OrderLinePrint     OrderLineType = "print"
+	OrderLineDigital   OrderLineType = "digital"
+)
+
+// IsPending reports whether the line still waits for stock to be allocated
+func (oi *OrderItem) IsPending() bool {
+	return oi.LineType != "" && oi.LineType != OrderLineStock && oi.AllocatedAt == nil
+}
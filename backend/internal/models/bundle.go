@@ -0,0 +1,106 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BundlePricing selects how a bundle's price is derived
+type BundlePricing string
+
+const (
+	// BundlePricingFixed sells the bundle at its own Price
+	BundlePricingFixed BundlePricing = "fixed"
+	// BundlePricingPercent takes BundleDiscount percent off the sum of the component prices
+	BundlePricingPercent BundlePricing = "percent"
+)
+
+// BundleItem is one component of a bundle product
+type BundleItem struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	BundleID    string    `json:"bundleId" gorm:"type:varchar(36);not null;uniqueIndex:idx_bundle_component"`
+	ComponentID string    `json:"componentId" gorm:"type:varchar(36);not null;uniqueIndex:idx_bundle_component;index"`
+	Quantity    int       `json:"quantity" gorm:"not null;default:1"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Relationships
+	Component *Product `json:"component,omitempty" gorm:"foreignKey:ComponentID"`
+}
+
+func (bi *BundleItem) BeforeCreate(tx *gorm.DB) error {
+	if bi.ID == "" {
+		bi.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BundleComponent records how many units of a product one bundle consumed
+// when it was ordered, so cancellations restock what was actually taken
+type BundleComponent struct {
+	ProductID string `json:"productId"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CurrencyConverter converts an amount between currencies and rounds it to
+// the target currency's precision, as pricing.Service.Convert does
+type CurrencyConverter func(amount float64, from, to string) (float64, error)
+
+// ResolveBundle derives a bundle's availability and, for percentage bundles,
+// its price from BundleItems, which must be loaded with their Component.
+// Stock on the bundle row itself is never used: a bundle is available as
+// many times as its scarcest component allows. Archived or missing
+// components make the bundle unavailable. Component prices are converted
+// into the bundle's currency before they are added up.
+func (p *Product) ResolveBundle(convert CurrencyConverter) error {
+	if !p.IsBundle {
+		return nil
+	}
+	available := math.MaxInt32
+	componentTotal := 0.0
+	for _, item := range p.BundleItems {
+		if item.Component == nil || item.Quantity <= 0 {
+			available = 0
+			continue
+		}
+		stock := item.Component.StockQuantity
+		if !item.Component.InStock {
+			stock = 0
+		}
+		if n := stock / item.Quantity; n < available {
+			available = n
+		}
+		price, err := convert(item.Component.Price*float64(item.Quantity), item.Component.Currency, p.Currency)
+		if err != nil {
+			return err
+		}
+		componentTotal += price
+	}
+	if len(p.BundleItems) == 0 {
+		available = 0
+	}
+
+	p.StockQuantity = available
+	p.InStock = available > 0
+
+	// Converting into the same currency only rounds to its precision
+	componentTotal, err := convert(componentTotal, p.Currency, p.Currency)
+	if err != nil {
+		return err
+	}
+	if p.BundlePricing == BundlePricingPercent {
+		if p.Price, err = convert(componentTotal*(100-p.BundleDiscount)/100, p.Currency, p.Currency); err != nil {
+			return err
+		}
+	}
+	// Show the component total as the compare-at price when the bundle saves money
+	if componentTotal > p.Price {
+		p.OriginalPrice = &componentTotal
+	}
+	return nil
+}
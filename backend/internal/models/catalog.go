@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportJob tracks a background catalog import and its progress
+type ImportJob struct {
+	ID            string          `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Format        string          `json:"format" gorm:"type:varchar(8);not null"`
+	DryRun        bool            `json:"dryRun" gorm:"default:false"`
+	Status        ImportJobStatus `json:"status" gorm:"default:'queued'"`
+	TotalRows     int             `json:"totalRows" gorm:"default:0"`
+	ProcessedRows int             `json:"processedRows" gorm:"default:0"`
+	CreatedRows   int             `json:"createdRows" gorm:"default:0"`
+	UpdatedRows   int             `json:"updatedRows" gorm:"default:0"`
+	FailedRows    int             `json:"failedRows" gorm:"default:0"`
+	Errors        []ImportError   `json:"errors" gorm:"serializer:json"`
+	Message       string          `json:"message"`
+	CreatedBy     string          `json:"createdBy" gorm:"type:varchar(36)"`
+	StartedAt     *time.Time      `json:"startedAt"`
+	FinishedAt    *time.Time      `json:"finishedAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+func (j *ImportJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ImportError describes a validation or write failure for one import row
+type ImportError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportJobStatus string
+
+const (
+	ImportJobQueued    ImportJobStatus = "queued"
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobFailed    ImportJobStatus = "failed"
+)
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CompatibilityType says what role the compatible product plays for the main product
+type CompatibilityType string
+
+const (
+	CompatibilityMaterial       CompatibilityType = "printer_material"
+	CompatibilitySparePart      CompatibilityType = "printer_spare_part"
+	CompatibilityPostProcessing CompatibilityType = "printer_post_processing"
+)
+
+// CompatibilityTypes lists every supported compatibility type
+var CompatibilityTypes = []CompatibilityType{
+	CompatibilityMaterial,
+	CompatibilitySparePart,
+	CompatibilityPostProcessing,
+}
+
+// ProductCompatibility links a printer (ProductID) to a material, spare part
+// or post-processing station (CompatibleProductID) that works with it
+type ProductCompatibility struct {
+	ID                  string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID           string            `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_compat_pair"`
+	CompatibleProductID string            `json:"compatibleProductId" gorm:"type:varchar(36);not null;uniqueIndex:idx_compat_pair;index"`
+	Type                CompatibilityType `json:"type" gorm:"type:varchar(32);not null;uniqueIndex:idx_compat_pair"`
+	Notes               string            `json:"notes" gorm:"type:text"`
+	Settings            map[string]string `json:"settings" gorm:"serializer:json"` // Recommended print settings, e.g. exposure time
+	CreatedAt           time.Time         `json:"createdAt"`
+	UpdatedAt           time.Time         `json:"updatedAt"`
+
+	// Relationships
+	Product           *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	CompatibleProduct *Product `json:"compatibleProduct,omitempty" gorm:"foreignKey:CompatibleProductID"`
+}
+
+func (pc *ProductCompatibility) BeforeCreate(tx *gorm.DB) error {
+	if pc.ID == "" {
+		pc.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsValidCompatibilityType reports whether t is a known compatibility type
+func IsValidCompatibilityType(t CompatibilityType) bool {
+	for _, known := range CompatibilityTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CompatibilityLink is one compatibility entry as seen from a product page:
+// the other product and whether it is the printer or the accessory side
+type CompatibilityLink struct {
+	ID       string            `json:"id"`
+	Type     CompatibilityType `json:"type"`
+	Role     string            `json:"role"` // "printer" or "accessory", the role of Product
+	Notes    string            `json:"notes"`
+	Settings map[string]string `json:"settings"`
+	Product  Product           `json:"product"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DocumentType is the kind of accounting document issued for an order
+type DocumentType string
+
+const (
+	DocumentInvoice    DocumentType = "invoice"
+	DocumentCreditNote DocumentType = "credit_note" // Issued for a refunded return
+)
+
+// Document is an issued invoice or credit note. Its number comes from a
+// gapless sequence per fiscal year, and its PDFs are rendered once in every
+// supported locale and never regenerated.
+type Document struct {
+	ID            string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Type          DocumentType `json:"type" gorm:"type:varchar(20);not null;uniqueIndex:idx_document_source"`
+	SourceID      string       `json:"sourceId" gorm:"type:varchar(36);not null;uniqueIndex:idx_document_source"` // Order for invoices, return for credit notes
+	Number        string       `json:"number" gorm:"type:varchar(32);not null;uniqueIndex"`
+	FiscalYear    int          `json:"fiscalYear" gorm:"not null;index"`
+	OrderID       string       `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	InvoiceNumber string       `json:"invoiceNumber,omitempty" gorm:"type:varchar(32)"` // Credit notes: the invoice credited
+	Amount        float64      `json:"amount" gorm:"not null"`
+	Currency      string       `json:"currency" gorm:"type:varchar(3);not null"`
+	IssuedAt      time.Time    `json:"issuedAt"`
+
+	// Relationships
+	Files []DocumentFile `json:"files"`
+}
+
+func (d *Document) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// DocumentFile is the PDF of a document in one locale, kept in private storage
+type DocumentFile struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	DocumentID string    `json:"documentId" gorm:"type:varchar(36);not null;uniqueIndex:idx_document_locale"`
+	Locale     string    `json:"locale" gorm:"type:varchar(10);not null;uniqueIndex:idx_document_locale"`
+	StorageKey string    `json:"-" gorm:"not null"`
+	Checksum   string    `json:"checksum" gorm:"type:varchar(64);not null"` // SHA-256 of the file, checked on download
+	FileSize   int64     `json:"fileSize"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (f *DocumentFile) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DigitalFile is a file delivered by a digital product, such as an STL
+// model pack or a printer profile. Files are kept in private storage and
+// only handed out through signed download links.
+type DigitalFile struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID   string    `json:"productId" gorm:"type:varchar(36);not null;index"`
+	FileName    string    `json:"fileName" gorm:"not null"`
+	StorageKey  string    `json:"-" gorm:"not null"`
+	ContentType string    `json:"contentType"`
+	FileSize    int64     `json:"fileSize"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	URL          string     `json:"url,omitempty" gorm:"-"` // Signed download link, set for entitled customers
+	URLExpiresAt *time.Time `json:"urlExpiresAt,omitempty" gorm:"-"`
+}
+
+func (f *DigitalFile) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// DownloadEntitlement lets a customer download the files of a digital
+// product they paid for. Every download counts against the limit.
+type DownloadEntitlement struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID         string     `json:"userId" gorm:"type:varchar(36);not null;index"`
+	OrderID        string     `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	OrderItemID    string     `json:"orderItemId" gorm:"type:varchar(36);uniqueIndex;not null"`
+	ProductID      string     `json:"productId" gorm:"type:varchar(36);not null"`
+	ProductName    string     `json:"productName"`
+	DownloadLimit  int        `json:"downloadLimit"`
+	DownloadCount  int        `json:"downloadCount" gorm:"default:0"`
+	LastDownloadAt *time.Time `json:"lastDownloadAt,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"` // Set when the order is cancelled
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// Relationships
+	Product Product `json:"-" gorm:"foreignKey:ProductID"`
+
+	Files []DigitalFile `json:"files" gorm:"-"`
+}
+
+func (e *DownloadEntitlement) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Remaining returns how many downloads are left
+func (e *DownloadEntitlement) Remaining() int {
+	if left := e.DownloadLimit - e.DownloadCount; left > 0 {
+		return left
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductImage is an uploaded product image and its generated variants.
+// Images with no product are unreferenced and get garbage-collected.
+type ProductImage struct {
+	ID          string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID   *string           `json:"productId" gorm:"type:varchar(36);index"`
+	Position    int               `json:"position" gorm:"default:0"`
+	URL         string            `json:"url" gorm:"not null"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	ContentType string            `json:"contentType"`
+	Variants    []ImageVariant    `json:"variants" gorm:"serializer:json"`
+	AltText     map[string]string `json:"altText" gorm:"serializer:json"` // Keyed by locale
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+
+	Alt string `json:"alt,omitempty" gorm:"-"` // Alt text in the request locale
+}
+
+func (pi *ProductImage) BeforeCreate(tx *gorm.DB) error {
+	if pi.ID == "" {
+		pi.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ImageVariant is one stored rendition of a product image
+type ImageVariant struct {
+	Name        string `json:"name"`
+	Format      string `json:"format"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +21,8 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
+	PaymentCustomerID string `json:"-"` // Payment gateway customer holding saved payment methods
+
 	// Relationships
 	Orders []Order `json:"orders,omitempty"`
 	Cart   *Cart   `json:"cart,omitempty"`
@@ -35,20 +38,23 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 
 // Category represents a product category
 type Category struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	Name        string    `json:"name" gorm:"not null"`
-	Slug        string    `json:"slug" gorm:"uniqueIndex;not null"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	ParentID    *string   `json:"parentId" gorm:"type:varchar(36)"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name        string         `json:"name" gorm:"not null"`
+	Slug        string         `json:"slug" gorm:"uniqueIndex;not null"`
+	Description string         `json:"description"`
+	Image       string         `json:"image"`
+	ParentID    *string        `json:"parentId" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	ArchivedAt  gorm.DeletedAt `json:"archivedAt,omitempty" gorm:"column:deleted_at;index"`
 
 	// Relationships
-	Products     []Product  `json:"products,omitempty"`
-	Parent       *Category  `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
-	Children     []Category `json:"children,omitempty" gorm:"foreignKey:ParentID"`
-	ProductCount int        `json:"productCount" gorm:"-"` // Not stored in DB, calculated dynamically
+	Products     []Product             `json:"products,omitempty"`
+	Parent       *Category             `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Children     []Category            `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	Translations []CategoryTranslation `json:"translations,omitempty"`
+	ProductCount int                   `json:"productCount" gorm:"-"`     // Not stored in DB, calculated dynamically
+	Locale       string                `json:"locale,omitempty" gorm:"-"` // Locale the content was rendered in
 }
 
 func (c *Category) BeforeCreate(tx *gorm.DB) error {
@@ -60,47 +66,96 @@ func (c *Category) BeforeCreate(tx *gorm.DB) error {
 
 // Product represents a product in the store
 type Product struct {
-	ID             string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	Name           string            `json:"name" gorm:"not null"`
-	Description    string            `json:"description" gorm:"type:text"`
-	Price          float64           `json:"price" gorm:"not null"`
-	OriginalPrice  *float64          `json:"originalPrice"`
-	Currency       string            `json:"currency" gorm:"default:'USD'"`
-	CategoryID     string            `json:"categoryId" gorm:"type:varchar(36);not null"`
-	Images         []string          `json:"images" gorm:"serializer:json"`
-	Specifications map[string]string `json:"specifications" gorm:"serializer:json"`
-	InStock        bool              `json:"inStock" gorm:"default:true"`
-	StockQuantity  int               `json:"stockQuantity" gorm:"default:0"`
-	Featured       bool              `json:"featured" gorm:"default:false"`
-	CreatedAt      time.Time         `json:"createdAt"`
-	UpdatedAt      time.Time         `json:"updatedAt"`
+	ID               string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SKU              string            `json:"sku" gorm:"type:varchar(64);uniqueIndex"`
+	Name             string            `json:"name" gorm:"not null"`
+	Description      string            `json:"description" gorm:"type:text"`
+	Price            float64           `json:"price" gorm:"not null"`
+	OriginalPrice    *float64          `json:"originalPrice"`
+	Currency         string            `json:"currency" gorm:"default:'USD'"`
+	CategoryID       string            `json:"categoryId" gorm:"type:varchar(36);not null"`
+	Images           []string          `json:"images" gorm:"serializer:json"`
+	Specifications   map[string]string `json:"specifications" gorm:"serializer:json"`
+	InStock          bool              `json:"inStock" gorm:"default:true"`
+	StockQuantity    int               `json:"stockQuantity" gorm:"default:0"`
+	Featured         bool              `json:"featured" gorm:"default:false"`
+	RatingAverage    float64           `json:"ratingAverage" gorm:"default:0;index"` // Mean of approved reviews
+	ReviewCount      int               `json:"reviewCount" gorm:"default:0"`
+	IsBundle         bool              `json:"isBundle" gorm:"default:false"`
+	BundlePricing    BundlePricing     `json:"bundlePricing,omitempty" gorm:"type:varchar(10)"`
+	BundleDiscount   float64           `json:"bundleDiscount,omitempty" gorm:"default:0"` // Percent off the component total
+	Availability     Availability      `json:"availability" gorm:"type:varchar(20);default:'in_stock'"`
+	ExpectedShipDate *time.Time        `json:"expectedShipDate,omitempty"`                // Pre-orders only
+	DepositPercent   float64           `json:"depositPercent,omitempty" gorm:"default:0"` // Pre-order deposit; 0 charges in full
+	BackorderLimit   int               `json:"backorderLimit,omitempty" gorm:"default:0"` // Units that may be owed beyond stock
+	IsDigital        bool              `json:"isDigital" gorm:"default:false"`
+	DownloadLimit    int               `json:"downloadLimit,omitempty" gorm:"default:0"`  // Downloads per purchase; 0 uses the default
+	WarrantyMonths   int               `json:"warrantyMonths,omitempty" gorm:"default:0"` // 0 when sold without a warranty
+	SealedRestock    bool              `json:"sealedRestock" gorm:"default:false"`        // Returned units are only restocked unopened, such as resin
+	CreatedAt        time.Time         `json:"createdAt"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+	ArchivedAt       gorm.DeletedAt    `json:"archivedAt,omitempty" gorm:"column:deleted_at;index"`
 
 	// Relationships
-	Category   Category    `json:"category" gorm:"foreignKey:CategoryID"`
-	CartItems  []CartItem  `json:"cartItems,omitempty"`
-	OrderItems []OrderItem `json:"orderItems,omitempty"`
+	Category     Category             `json:"category" gorm:"foreignKey:CategoryID"`
+	CartItems    []CartItem           `json:"cartItems,omitempty"`
+	OrderItems   []OrderItem          `json:"orderItems,omitempty"`
+	Translations []ProductTranslation `json:"translations,omitempty"`
+	Media        []ProductImage       `json:"media,omitempty"`
+	Questions    []ProductQuestion    `json:"questions,omitempty"`
+	BundleItems  []BundleItem         `json:"bundleItems,omitempty" gorm:"foreignKey:BundleID"`
+	Files        []DigitalFile        `json:"files,omitempty"`
+
+	Compatibility []CompatibilityLink `json:"compatibility,omitempty" gorm:"-"`
+
+	Locale string `json:"locale,omitempty" gorm:"-"` // Locale the content was rendered in
 }
 
 func (p *Product) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == "" {
 		p.ID = uuid.New().String()
 	}
+	if p.SKU == "" {
+		p.SKU = GenerateSKU(p.ID)
+	}
 	return nil
 }
 
+// InStockNow reports whether the product can be shipped right away. Digital
+// products never run out.
+func (p *Product) InStockNow() bool {
+	return p.IsDigital || (p.InStock && p.StockQuantity > 0)
+}
+
+// GenerateSKU derives a fallback SKU from a product ID
+func GenerateSKU(productID string) string {
+	return "BZ-" + strings.ToUpper(strings.ReplaceAll(productID, "-", "")[:8])
+}
+
 // Cart represents a shopping cart
 type Cart struct {
-	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	UserID      *string   `json:"userId" gorm:"type:varchar(36)"`
-	SessionID   string    `json:"sessionId" gorm:"index"`
-	TotalAmount float64   `json:"totalAmount" gorm:"default:0"`
-	TotalItems  int       `json:"totalItems" gorm:"default:0"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID           string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID       *string   `json:"userId" gorm:"type:varchar(36)"`
+	SessionID    string    `json:"sessionId" gorm:"index"`
+	TotalAmount  float64   `json:"totalAmount" gorm:"default:0"`
+	TotalItems   int       `json:"totalItems" gorm:"default:0"`
+	Currency     string    `json:"currency" gorm:"type:varchar(3);default:'USD'"`
+	ExchangeRate float64   `json:"exchangeRate" gorm:"default:1"` // Rate the item prices were quoted at
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 
 	// Relationships
 	User  *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Items []CartItem `json:"items"`
+
+	Warnings []CartWarning `json:"warnings,omitempty" gorm:"-"`
+}
+
+// CartWarning flags a potential problem with the cart contents that does not block checkout
+type CartWarning struct {
+	Code      string `json:"code"`
+	ProductID string `json:"productId"`
+	Message   string `json:"message"`
 }
 
 func (c *Cart) BeforeCreate(tx *gorm.DB) error {
@@ -120,9 +175,13 @@ type CartItem struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
+	PrintQuoteID *string `json:"printQuoteId,omitempty" gorm:"type:varchar(36)"` // Custom print; Product is its material
+	Unavailable  bool    `json:"unavailable,omitempty" gorm:"-"`                 // Cannot be ordered and left out of the totals
+
 	// Relationships
-	Cart    Cart    `json:"cart" gorm:"foreignKey:CartID"`
-	Product Product `json:"product" gorm:"foreignKey:ProductID"`
+	Cart       Cart        `json:"cart" gorm:"foreignKey:CartID"`
+	Product    Product     `json:"product" gorm:"foreignKey:ProductID"`
+	PrintQuote *PrintQuote `json:"printQuote,omitempty" gorm:"foreignKey:PrintQuoteID"`
 }
 
 func (ci *CartItem) BeforeCreate(tx *gorm.DB) error {
@@ -147,12 +206,22 @@ type Order struct {
 	Tax             float64       `json:"tax" gorm:"default:0"`
 	Shipping        float64       `json:"shipping" gorm:"default:0"`
 	Total           float64       `json:"total" gorm:"not null"`
+	Currency        string        `json:"currency" gorm:"type:varchar(3);default:'USD'"`
+	ExchangeRate    float64       `json:"exchangeRate" gorm:"default:1"` // Locked at checkout
+	BalanceDue      float64       `json:"balanceDue" gorm:"default:0"`   // Left to pay after a pre-order deposit
+	RefundedAmount  float64       `json:"refundedAmount" gorm:"default:0"`
+	DeliveredAt     *time.Time    `json:"deliveredAt,omitempty"` // Starts the return window
+	SubscriptionID  *string       `json:"subscriptionId,omitempty" gorm:"type:varchar(36);index"`
+	WarrantyClaimID *string       `json:"warrantyClaimId,omitempty" gorm:"type:varchar(36);index"` // Free replacement for a claim
 	CreatedAt       time.Time     `json:"createdAt"`
 	UpdatedAt       time.Time     `json:"updatedAt"`
 
 	// Relationships
-	User  User        `json:"user" gorm:"foreignKey:UserID"`
-	Items []OrderItem `json:"items"`
+	User      User        `json:"user" gorm:"foreignKey:UserID"`
+	Items     []OrderItem `json:"items"`
+	PrintJobs []PrintJob  `json:"printJobs,omitempty"`
+	Shipments []Shipment  `json:"shipments,omitempty"`
+	Edits     []OrderEdit `json:"edits,omitempty"`
 }
 
 func (o *Order) BeforeCreate(tx *gorm.DB) error {
@@ -160,8 +229,12 @@ func (o *Order) BeforeCreate(tx *gorm.DB) error {
 		o.ID = uuid.New().String()
 	}
 	if o.OrderNumber == "" {
-		// Generate order number (format: BIZOE-YYYYMMDD-XXXX)
-		o.OrderNumber = generateOrderNumber()
+		// Numbered in the order's own transaction, so a rollback frees the number
+		number, err := OrderNumbers.Next(tx.Session(&gorm.Session{NewDB: true}), OrderSequence, time.Now())
+		if err != nil {
+			return err
+		}
+		o.OrderNumber = number
 	}
 	return nil
 }
@@ -177,6 +250,23 @@ type OrderItem struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
+	// Snapshot of the product taken at purchase time
+	ProductName           string            `json:"productName"`
+	ProductSKU            string            `json:"productSku" gorm:"type:varchar(64)"`
+	ProductImage          string            `json:"productImage"`
+	ProductSpecifications map[string]string `json:"productSpecifications" gorm:"serializer:json"`
+	BundleComponents      []BundleComponent `json:"bundleComponents,omitempty" gorm:"serializer:json"` // Per bundle, empty for plain products
+
+	// Pre-order and backorder lines wait for stock; AllocatedAt is set once units are set aside
+	LineType         OrderLineType `json:"lineType" gorm:"type:varchar(20);default:'stock';index"`
+	ExpectedShipDate *time.Time    `json:"expectedShipDate,omitempty"`
+	DepositAmount    float64       `json:"depositAmount,omitempty" gorm:"default:0"`
+	AllocatedAt      *time.Time    `json:"allocatedAt,omitempty"`
+
+	PrintQuoteID *string `json:"printQuoteId,omitempty" gorm:"type:varchar(36);index"` // Custom print line; ProductID is its material
+
+	SerialNumbers []string `json:"serialNumbers,omitempty" gorm:"serializer:json"` // Captured at fulfilment
+
 	// Relationships
 	Order   Order   `json:"order" gorm:"foreignKey:OrderID"`
 	Product Product `json:"product" gorm:"foreignKey:ProductID"`
@@ -201,31 +291,33 @@ type Address struct {
 	State     string `json:"state" gorm:"not null"`
 	Country   string `json:"country" gorm:"not null"`
 	ZipCode   string `json:"zipCode" gorm:"not null"`
+	Company   string `json:"company,omitempty"` // Business customers, printed on invoices
+	TaxID     string `json:"taxId,omitempty"`
 }
 
 // Enums
 type OrderStatus string
 
 const (
-	OrderStatusPending    OrderStatus = "pending"
-	OrderStatusConfirmed  OrderStatus = "confirmed"
-	OrderStatusProcessing OrderStatus = "processing"
-	OrderStatusShipped    OrderStatus = "shipped"
-	OrderStatusDelivered  OrderStatus = "delivered"
-	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusPending           OrderStatus = "pending"
+	OrderStatusConfirmed         OrderStatus = "confirmed"
+	OrderStatusInProduction      OrderStatus = "in_production" // Custom prints on the shop floor
+	OrderStatusProcessing        OrderStatus = "processing"
+	OrderStatusPartiallyShipped  OrderStatus = "partially_shipped"
+	OrderStatusShipped           OrderStatus = "shipped"
+	OrderStatusDelivered         OrderStatus = "delivered"
+	OrderStatusCancelled         OrderStatus = "cancelled"
+	OrderStatusPartiallyReturned OrderStatus = "partially_returned"
+	OrderStatusReturned          OrderStatus = "returned" // Every returnable unit came back
 )
 
 type PaymentStatus string
 
 const (
-	PaymentStatusPending  PaymentStatus = "pending"
-	PaymentStatusPaid     PaymentStatus = "paid"
-	PaymentStatusFailed   PaymentStatus = "failed"
-	PaymentStatusRefunded PaymentStatus = "refunded"
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusPaid              PaymentStatus = "paid"
+	PaymentStatusDeposit           PaymentStatus = "deposit_paid" // Pre-order deposit paid, balance due on allocation
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
 )
-
-// Helper function to generate order number
-func generateOrderNumber() string {
-	now := time.Now()
-	return "BIZOE-" + now.Format("20060102") + "-" + uuid.New().String()[:8]
-}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrderChangeType is what an order edit changed
+type OrderChangeType string
+
+const (
+	OrderChangeItemAdded       OrderChangeType = "item_added"
+	OrderChangeItemRemoved     OrderChangeType = "item_removed"
+	OrderChangeQuantity        OrderChangeType = "quantity_changed"
+	OrderChangeShippingAddress OrderChangeType = "shipping_address"
+	OrderChangeBillingAddress  OrderChangeType = "billing_address"
+)
+
+// OrderEditSettlement is how far the price difference of an edit to a paid
+// order has been charged or refunded
+type OrderEditSettlement string
+
+const (
+	OrderEditSettlementNone    OrderEditSettlement = "none"    // Nothing to settle
+	OrderEditSettlementPending OrderEditSettlement = "pending" // Not settled yet, retried until it is
+	OrderEditSettlementFailed  OrderEditSettlement = "failed"  // Charge declined; waits for another payment method
+	OrderEditSettlementSettled OrderEditSettlement = "settled"
+)
+
+// OrderChange is one change within an order edit. Address changes keep the
+// address that was replaced.
+type OrderChange struct {
+	Type            OrderChangeType `json:"type"`
+	ProductID       string          `json:"productId,omitempty"`
+	ProductName     string          `json:"productName,omitempty"`
+	FromQuantity    int             `json:"fromQuantity,omitempty"`
+	ToQuantity      int             `json:"toQuantity,omitempty"`
+	PreviousAddress *Address        `json:"previousAddress,omitempty"`
+}
+
+// OrderEdit is an entry in an order's history: a change made after checkout
+// by the customer or by staff, and how the difference in price was settled
+type OrderEdit struct {
+	ID            string        `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	OrderID       string        `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	EditedBy      string        `json:"editedBy" gorm:"type:varchar(36);not null"` // User who made the change
+	ByAdmin       bool          `json:"byAdmin" gorm:"default:false"`
+	Changes       []OrderChange `json:"changes" gorm:"serializer:json"`
+	Note          string        `json:"note,omitempty" gorm:"type:text"`
+	PreviousTotal float64       `json:"previousTotal"`
+	NewTotal      float64       `json:"newTotal"`
+	Difference    float64       `json:"difference"` // Charged when positive, refunded when negative on paid orders
+
+	// Settlement of the difference, made after the edit is saved
+	Settlement      OrderEditSettlement `json:"settlement" gorm:"type:varchar(20);default:'none';index"`
+	PaymentMethodID string              `json:"-" gorm:"type:varchar(255)"` // Charged for the difference
+	IdempotencyKey  string              `json:"-" gorm:"type:varchar(100)"` // Sent with the charge or refund so retries cannot repeat it
+	PaymentID       string              `json:"paymentId,omitempty"`        // Extra charge
+	RefundID        string              `json:"refundId,omitempty"`
+	SettledAt       *time.Time          `json:"settledAt,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (e *OrderEdit) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Settlement == "" {
+		e.Settlement = OrderEditSettlementNone
+	}
+	if e.Settlement == OrderEditSettlementPending && e.IdempotencyKey == "" {
+		e.IdempotencyKey = "order-edit-" + e.ID
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductPrice is an explicit price for a product in a specific currency.
+// When present it wins over a converted price.
+type ProductPrice struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID     string    `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_product_currency"`
+	Currency      string    `json:"currency" gorm:"type:varchar(3);not null;uniqueIndex:idx_product_currency"`
+	Price         float64   `json:"price" gorm:"not null"`
+	OriginalPrice *float64  `json:"originalPrice"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func (pp *ProductPrice) BeforeCreate(tx *gorm.DB) error {
+	if pp.ID == "" {
+		pp.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ExchangeRate converts base currency amounts into Currency and describes how
+// converted prices are rounded for display
+type ExchangeRate struct {
+	ID                string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Currency          string       `json:"currency" gorm:"type:varchar(3);uniqueIndex;not null"`
+	Rate              float64      `json:"rate" gorm:"not null"` // Units of Currency per one unit of the base currency
+	Rounding          RoundingMode `json:"rounding" gorm:"default:'nearest'"`
+	RoundingIncrement float64      `json:"roundingIncrement" gorm:"default:0.01"`
+	CreatedAt         time.Time    `json:"createdAt"`
+	UpdatedAt         time.Time    `json:"updatedAt"`
+}
+
+func (er *ExchangeRate) BeforeCreate(tx *gorm.DB) error {
+	if er.ID == "" {
+		er.ID = uuid.New().String()
+	}
+	return nil
+}
+
+type RoundingMode string
+
+const (
+	RoundingNearest RoundingMode = "nearest"
+	RoundingUp      RoundingMode = "up"
+	RoundingDown    RoundingMode = "down"
+	RoundingCharm   RoundingMode = "charm" // Round up to the increment, then drop one minor unit (e.g. 1299 -> 1299.99 / NT$1,299)
+)
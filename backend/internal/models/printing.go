@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PrintMaterial is a resin the print service prints with, priced by volume.
+// It is linked to the resin product sold in the shop.
+type PrintMaterial struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID  string    `json:"productId" gorm:"type:varchar(36);uniqueIndex;not null"`
+	Name       string    `json:"name" gorm:"not null"`
+	CostPerCm3 float64   `json:"costPerCm3" gorm:"not null"` // In the base currency
+	IsActive   bool      `json:"isActive" gorm:"default:true"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// Relationships
+	Product Product `json:"product" gorm:"foreignKey:ProductID"`
+
+	Currency string `json:"currency,omitempty" gorm:"-"` // Currency CostPerCm3 is presented in
+}
+
+func (m *PrintMaterial) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// PrintModel is a mesh a customer uploaded for printing. Dimensions are in
+// millimetres, the usual unit of STL and OBJ files.
+type PrintModel struct {
+	ID               string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID           string    `json:"userId" gorm:"type:varchar(36);not null;index"`
+	FileName         string    `json:"fileName" gorm:"not null"`
+	Format           string    `json:"format" gorm:"type:varchar(20);not null"`
+	StorageKey       string    `json:"-" gorm:"not null"`
+	FileSize         int64     `json:"fileSize"`
+	Triangles        int       `json:"triangles"`
+	Watertight       bool      `json:"watertight"`
+	BoundaryEdges    int       `json:"boundaryEdges"`
+	NonManifoldEdges int       `json:"nonManifoldEdges"`
+	VolumeCm3        float64   `json:"volumeCm3"`
+	SurfaceAreaCm2   float64   `json:"surfaceAreaCm2"`
+	SizeX            float64   `json:"sizeX"`
+	SizeY            float64   `json:"sizeY"`
+	SizeZ            float64   `json:"sizeZ"` // Print height as uploaded
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+func (m *PrintModel) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// PrintQuote prices printing a model in a material. Amounts are per unit in
+// the base currency, and the quote can be ordered until it expires.
+type PrintQuote struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID       string    `json:"userId" gorm:"type:varchar(36);not null;index"`
+	ModelID      string    `json:"modelId" gorm:"type:varchar(36);not null"`
+	MaterialID   string    `json:"materialId" gorm:"type:varchar(36);not null"`
+	Quantity     int       `json:"quantity" gorm:"not null"`
+	MaterialCm3  float64   `json:"materialCm3"` // Model volume plus support allowance
+	MaterialCost float64   `json:"materialCost"`
+	PrintHours   float64   `json:"printHours"`
+	MachineCost  float64   `json:"machineCost"`
+	UnitPrice    float64   `json:"unitPrice"`
+	Total        float64   `json:"total"`
+	Currency     string    `json:"currency" gorm:"type:varchar(3);default:'USD'"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	CreatedAt    time.Time `json:"createdAt"`
+
+	// Relationships
+	Model    PrintModel    `json:"model" gorm:"foreignKey:ModelID"`
+	Material PrintMaterial `json:"material" gorm:"foreignKey:MaterialID"`
+}
+
+func (q *PrintQuote) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsExpired reports whether the quote can no longer be ordered
+func (q *PrintQuote) IsExpired() bool {
+	return time.Now().After(q.ExpiresAt)
+}
+
+// QuoteMissing reports whether the item is a custom print whose quote is no
+// longer there, which leaves the line impossible to price or order. The
+// quote must have been preloaded.
+func (ci *CartItem) QuoteMissing() bool {
+	return ci.PrintQuoteID != nil && ci.PrintQuote == nil
+}
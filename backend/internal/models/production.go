@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Printer is a resin printer on the shop floor. Build volume is in millimetres.
+type Printer struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	BuildX      float64   `json:"buildX" gorm:"not null"`
+	BuildY      float64   `json:"buildY" gorm:"not null"`
+	BuildZ      float64   `json:"buildZ" gorm:"not null"`
+	MaterialIDs []string  `json:"materialIds" gorm:"serializer:json"` // Print materials it can run
+	IsActive    bool      `json:"isActive" gorm:"default:true"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (p *Printer) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Supports reports whether the printer can run a print material
+func (p *Printer) Supports(materialID string) bool {
+	for _, id := range p.MaterialIDs {
+		if id == materialID {
+			return true
+		}
+	}
+	return false
+}
+
+// Fits reports whether a part with the given bounding box fits the build
+// volume. Parts may be turned on the plate but keep the height they were
+// quoted at.
+func (p *Printer) Fits(x, y, z float64) bool {
+	if z > p.BuildZ {
+		return false
+	}
+	return (x <= p.BuildX && y <= p.BuildY) || (y <= p.BuildX && x <= p.BuildY)
+}
+
+// PrintJobStatus tracks a custom print through production
+type PrintJobStatus string
+
+const (
+	PrintJobQueued         PrintJobStatus = "queued"
+	PrintJobSlicing        PrintJobStatus = "slicing"
+	PrintJobPrinting       PrintJobStatus = "printing"
+	PrintJobPostProcessing PrintJobStatus = "post_processing" // Washing, curing and support removal
+	PrintJobQC             PrintJobStatus = "qc"
+	PrintJobDone           PrintJobStatus = "done"
+	PrintJobCancelled      PrintJobStatus = "cancelled" // Only when the order is cancelled
+)
+
+// printJobTransitions lists the states each state can move to. Failed prints
+// and rejected parts go back to the queue to be printed again.
+var printJobTransitions = map[PrintJobStatus][]PrintJobStatus{
+	PrintJobQueued:         {PrintJobSlicing},
+	PrintJobSlicing:        {PrintJobPrinting, PrintJobQueued},
+	PrintJobPrinting:       {PrintJobPostProcessing, PrintJobQueued},
+	PrintJobPostProcessing: {PrintJobQC},
+	PrintJobQC:             {PrintJobDone, PrintJobQueued},
+}
+
+// CanMoveTo reports whether a job may go from status s to next
+func (s PrintJobStatus) CanMoveTo(next PrintJobStatus) bool {
+	for _, allowed := range printJobTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// OccupiesPrinter reports whether a job in this state holds its printer
+func (s PrintJobStatus) OccupiesPrinter() bool {
+	return s == PrintJobQueued || s == PrintJobSlicing || s == PrintJobPrinting
+}
+
+// PrintJob produces one custom print order line on a printer
+type PrintJob struct {
+	ID           string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	OrderID      string         `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	OrderItemID  string         `json:"orderItemId" gorm:"type:varchar(36);uniqueIndex;not null"`
+	PrintQuoteID string         `json:"printQuoteId" gorm:"type:varchar(36);not null"`
+	ModelID      string         `json:"modelId" gorm:"type:varchar(36);not null"`
+	MaterialID   string         `json:"materialId" gorm:"type:varchar(36);not null;index"`
+	Quantity     int            `json:"quantity" gorm:"not null"`
+	SizeX        float64        `json:"sizeX"` // Bounding box of one part, in millimetres
+	SizeY        float64        `json:"sizeY"`
+	SizeZ        float64        `json:"sizeZ"`
+	Status       PrintJobStatus `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	PrinterID    *string        `json:"printerId,omitempty" gorm:"type:varchar(36);index"`
+	Attempts     int            `json:"attempts" gorm:"default:1"`
+	StartedAt    *time.Time     `json:"startedAt,omitempty"`
+	CompletedAt  *time.Time     `json:"completedAt,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"` // Queue position
+	UpdatedAt    time.Time      `json:"updatedAt"`
+
+	// Relationships
+	Order    *Order          `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Printer  *Printer        `json:"printer,omitempty" gorm:"foreignKey:PrinterID"`
+	Model    *PrintModel     `json:"model,omitempty" gorm:"foreignKey:ModelID"`
+	Material *PrintMaterial  `json:"material,omitempty" gorm:"foreignKey:MaterialID"`
+	Events   []PrintJobEvent `json:"events,omitempty" gorm:"foreignKey:JobID"`
+
+	Blocked string `json:"blocked,omitempty" gorm:"-"` // Why a waiting job cannot be assigned
+}
+
+func (j *PrintJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// PrintJobEvent records a job moving between states or printers
+type PrintJobEvent struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	JobID     string         `json:"jobId" gorm:"type:varchar(36);not null;index"`
+	From      PrintJobStatus `json:"from" gorm:"type:varchar(20)"`
+	To        PrintJobStatus `json:"to" gorm:"type:varchar(20);not null"`
+	PrinterID *string        `json:"printerId,omitempty" gorm:"type:varchar(36)"`
+	Note      string         `json:"note"`
+	ActorID   string         `json:"actorId,omitempty" gorm:"type:varchar(36)"` // Empty for the scheduler
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+func (e *PrintJobEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductQuestion is a customer question about a product. Questions go
+// through the same moderation states as reviews before they are public.
+type ProductQuestion struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID      string       `json:"productId" gorm:"type:varchar(36);not null;index"`
+	UserID         string       `json:"userId" gorm:"type:varchar(36);not null"`
+	Body           string       `json:"body" gorm:"type:text;not null"`
+	Status         ReviewStatus `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	ModerationNote string       `json:"moderationNote,omitempty"`
+	ModeratedAt    *time.Time   `json:"moderatedAt,omitempty"`
+	VoteCount      int          `json:"voteCount" gorm:"default:0"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+
+	// Relationships
+	Product *Product        `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	User    *User           `json:"-" gorm:"foreignKey:UserID"`
+	Answers []ProductAnswer `json:"answers" gorm:"foreignKey:QuestionID"`
+
+	Author string `json:"author" gorm:"-"`
+}
+
+func (q *ProductQuestion) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ProductAnswer answers a question. Staff answers are published right away,
+// answers from verified owners are moderated first.
+type ProductAnswer struct {
+	ID             string       `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	QuestionID     string       `json:"questionId" gorm:"type:varchar(36);not null;index"`
+	UserID         string       `json:"userId" gorm:"type:varchar(36);not null"`
+	Body           string       `json:"body" gorm:"type:text;not null"`
+	IsStaff        bool         `json:"isStaff" gorm:"default:false"`
+	VerifiedOwner  bool         `json:"verifiedOwner" gorm:"default:false"`
+	Status         ReviewStatus `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	ModerationNote string       `json:"moderationNote,omitempty"`
+	ModeratedAt    *time.Time   `json:"moderatedAt,omitempty"`
+	NotifiedAt     *time.Time   `json:"-"` // When the asker was emailed about this answer
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+
+	// Relationships
+	User *User `json:"-" gorm:"foreignKey:UserID"`
+
+	Author string `json:"author" gorm:"-"`
+}
+
+func (a *ProductAnswer) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// QuestionVote records a user's upvote of a question; one per user and question
+type QuestionVote struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	QuestionID string    `json:"questionId" gorm:"type:varchar(36);not null;uniqueIndex:idx_qvote_question_user"`
+	UserID     string    `json:"userId" gorm:"type:varchar(36);not null;uniqueIndex:idx_qvote_question_user"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (qv *QuestionVote) BeforeCreate(tx *gorm.DB) error {
+	if qv.ID == "" {
+		qv.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecommendationAction is an admin override applied to a product pair
+type RecommendationAction string
+
+const (
+	// RecommendationPin always recommends the target first, ordered by Position
+	RecommendationPin RecommendationAction = "pin"
+	// RecommendationExclude never recommends the target alongside the product
+	RecommendationExclude RecommendationAction = "exclude"
+)
+
+// RecommendationOverride pins or excludes TargetProductID in the
+// recommendations shown for ProductID
+type RecommendationOverride struct {
+	ID              string               `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID       string               `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_rec_override_pair"`
+	TargetProductID string               `json:"targetProductId" gorm:"type:varchar(36);not null;uniqueIndex:idx_rec_override_pair"`
+	Action          RecommendationAction `json:"action" gorm:"type:varchar(10);not null"`
+	Position        int                  `json:"position" gorm:"default:0"`
+	CreatedAt       time.Time            `json:"createdAt"`
+	UpdatedAt       time.Time            `json:"updatedAt"`
+
+	// Relationships
+	TargetProduct *Product `json:"targetProduct,omitempty" gorm:"foreignKey:TargetProductID"`
+}
+
+func (ro *RecommendationOverride) BeforeCreate(tx *gorm.DB) error {
+	if ro.ID == "" {
+		ro.ID = uuid.New().String()
+	}
+	return nil
+}
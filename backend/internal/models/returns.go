@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReturnStatus tracks a return from request to refund or exchange
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved" // RMA issued, waiting for the parcel
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received" // Inspected, refund or exchange still to settle
+	ReturnCompleted ReturnStatus = "completed"
+	ReturnCancelled ReturnStatus = "cancelled" // Withdrawn by the customer
+)
+
+// ReturnReason is why the customer sends units back
+type ReturnReason string
+
+const (
+	ReturnReasonDefective      ReturnReason = "defective"
+	ReturnReasonDamaged        ReturnReason = "damaged_in_transit"
+	ReturnReasonWrongItem      ReturnReason = "wrong_item"
+	ReturnReasonNotAsDescribed ReturnReason = "not_as_described"
+	ReturnReasonNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnReasonOther          ReturnReason = "other"
+)
+
+// ReturnResolution is what the customer gets back
+type ReturnResolution string
+
+const (
+	ReturnResolutionRefund   ReturnResolution = "refund"
+	ReturnResolutionExchange ReturnResolution = "exchange" // Same product sent again at no charge
+)
+
+// ReturnCondition is the state a returned unit arrived in
+type ReturnCondition string
+
+const (
+	ReturnConditionSealed  ReturnCondition = "sealed" // Factory seal intact
+	ReturnConditionOpened  ReturnCondition = "opened" // Including resealed
+	ReturnConditionDamaged ReturnCondition = "damaged"
+)
+
+// ReturnDisposition is what happens to a returned unit after inspection
+type ReturnDisposition string
+
+const (
+	ReturnDispositionRestock ReturnDisposition = "restock"
+	ReturnDispositionScrap   ReturnDisposition = "scrap"
+)
+
+// Restockable reports whether a unit of product in this condition may go
+// back on sale. Products that must be restocked sealed, such as resin, are
+// scrapped once opened.
+func (c ReturnCondition) Restockable(product *Product) bool {
+	switch c {
+	case ReturnConditionSealed:
+		return true
+	case ReturnConditionOpened:
+		return !product.SealedRestock
+	}
+	return false
+}
+
+// ReturnRequest is a customer's request to send back units of one order line
+type ReturnRequest struct {
+	ID          string           `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	RMANumber   *string          `json:"rmaNumber,omitempty" gorm:"type:varchar(32);uniqueIndex"` // Issued on approval
+	OrderID     string           `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	OrderItemID string           `json:"orderItemId" gorm:"type:varchar(36);not null;index"`
+	UserID      string           `json:"userId" gorm:"type:varchar(36);not null;index"`
+	ProductID   string           `json:"productId" gorm:"type:varchar(36);not null"`
+	Quantity    int              `json:"quantity" gorm:"not null"`
+	Reason      ReturnReason     `json:"reason" gorm:"type:varchar(30);not null"`
+	Comment     string           `json:"comment" gorm:"type:text"`
+	Photos      []CustomerPhoto  `json:"photos" gorm:"serializer:json"`
+	Resolution  ReturnResolution `json:"resolution" gorm:"type:varchar(20);not null"`
+	Status      ReturnStatus     `json:"status" gorm:"type:varchar(20);not null;default:'requested';index"`
+
+	// Review
+	ReviewNote string     `json:"reviewNote,omitempty" gorm:"type:text"`
+	ReviewedBy string     `json:"reviewedBy,omitempty" gorm:"type:varchar(36)"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+
+	// Inspection on receipt
+	Condition      ReturnCondition   `json:"condition,omitempty" gorm:"type:varchar(20)"`
+	Disposition    ReturnDisposition `json:"disposition,omitempty" gorm:"type:varchar(20)"`
+	InspectionNote string            `json:"inspectionNote,omitempty" gorm:"type:text"`
+	InspectedBy    string            `json:"inspectedBy,omitempty" gorm:"type:varchar(36)"`
+	ReceivedAt     *time.Time        `json:"receivedAt,omitempty"`
+
+	// Settlement
+	RefundAmount    float64    `json:"refundAmount" gorm:"default:0"` // In the order currency
+	RefundID        string     `json:"refundId,omitempty"`
+	ExchangeOrderID *string    `json:"exchangeOrderId,omitempty" gorm:"type:varchar(36)"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// Relationships
+	Order     *Order     `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	OrderItem *OrderItem `json:"orderItem,omitempty" gorm:"foreignKey:OrderItemID"`
+	User      *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (r *ReturnRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// generateRMANumber builds a return merchandise authorization number in the
+// style of order numbers (format: RMA-YYYYMMDD-XXXX)
+func generateRMANumber() string {
+	return "RMA-" + time.Now().Format("20060102") + "-" + uuid.New().String()[:8]
+}
+
+// IssueRMA gives an approved return its RMA number
+func (r *ReturnRequest) IssueRMA() {
+	if r.RMANumber == nil {
+		rma := generateRMANumber()
+		r.RMANumber = &rma
+	}
+}
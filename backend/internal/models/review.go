@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReviewStatus is the moderation state of a review
+type ReviewStatus string
+
+const (
+	ReviewStatusPending  ReviewStatus = "pending"
+	ReviewStatusApproved ReviewStatus = "approved"
+	ReviewStatusRejected ReviewStatus = "rejected"
+)
+
+// Review is a customer's rating and write-up of a product. Only approved
+// reviews are shown on the storefront and counted in product ratings.
+type Review struct {
+	ID               string          `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID        string          `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_review_product_user"`
+	UserID           string          `json:"userId" gorm:"type:varchar(36);not null;uniqueIndex:idx_review_product_user"`
+	Rating           int             `json:"rating" gorm:"not null"`
+	Title            string          `json:"title" gorm:"not null"`
+	Body             string          `json:"body" gorm:"type:text"`
+	Photos           []CustomerPhoto `json:"photos" gorm:"serializer:json"`
+	VerifiedPurchase bool            `json:"verifiedPurchase" gorm:"default:false"`
+	Status           ReviewStatus    `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	ModerationNote   string          `json:"moderationNote,omitempty"`
+	ModeratedAt      *time.Time      `json:"moderatedAt,omitempty"`
+	HelpfulCount     int             `json:"helpfulCount" gorm:"default:0"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+
+	// Relationships
+	Product *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	User    *User    `json:"-" gorm:"foreignKey:UserID"`
+
+	Author string `json:"author" gorm:"-"` // Public display name, e.g. "Jane D."
+}
+
+func (r *Review) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// CustomerPhoto is a photo a customer attached to a review or warranty claim
+type CustomerPhoto struct {
+	ID       string         `json:"id"`
+	URL      string         `json:"url"`
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	Variants []ImageVariant `json:"variants"`
+}
+
+// ReviewVote records that a user found a review helpful; one per user and review
+type ReviewVote struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ReviewID  string    `json:"reviewId" gorm:"type:varchar(36);not null;uniqueIndex:idx_vote_review_user"`
+	UserID    string    `json:"userId" gorm:"type:varchar(36);not null;uniqueIndex:idx_vote_review_user"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (rv *ReviewVote) BeforeCreate(tx *gorm.DB) error {
+	if rv.ID == "" {
+		rv.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sequence is a named counter for numbers that must not skip, such as
+// invoice numbers
+type Sequence struct {
+	Name      string    `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Value     int64     `json:"value" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NextSequenceValue takes the next number of the named sequence, starting
+// at 1. It must run in the transaction that uses the number: the row stays
+// locked until commit, and a rollback hands the number out again, so issued
+// numbers have no gaps.
+func NextSequenceValue(tx *gorm.DB, name string) (int64, error) {
+	increment := func() (int64, error) {
+		result := tx.Model(&Sequence{}).Where("name = ?", name).Update("value", gorm.Expr("value + 1"))
+		return result.RowsAffected, result.Error
+	}
+
+	updated, err := increment()
+	if err != nil {
+		return 0, err
+	}
+	if updated == 0 {
+		// First number of a sequence nobody created beforehand. Two
+		// transactions getting here at once may deadlock on MySQL, which is
+		// why sequences are best made in advance with CreateSequence.
+		if err := CreateSequence(tx, name); err != nil {
+			return 0, err
+		}
+		if _, err := increment(); err != nil {
+			return 0, err
+		}
+	}
+
+	var sequence Sequence
+	if err := tx.First(&sequence, "name = ?", name).Error; err != nil {
+		return 0, err
+	}
+	return sequence.Value, nil
+}
+
+// CreateSequence makes sure the named sequence exists, leaving it alone if
+// it does. Run outside the transactions that take numbers, it keeps them
+// from racing to insert the row.
+func CreateSequence(db *gorm.DB, name string) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&Sequence{Name: name}).Error
+}
+
+// NumberReset is how often a numbering sequence starts again at 1
+type NumberReset string
+
+const (
+	ResetNever  NumberReset = "never"
+	ResetDaily  NumberReset = "daily"
+	ResetYearly NumberReset = "yearly"
+)
+
+// NumberFormat builds sequential numbers from a template such as
+// "BIZOE-{YYYYMMDD}-{SEQ:4}". {YYYY}, {YY}, {MM}, {DD} and {YYYYMMDD} are
+// replaced with the date, and {SEQ:n} with the counter zero-padded to at
+// least n digits.
+type NumberFormat struct {
+	Template string
+	Reset    NumberReset
+}
+
+// OrderNumbers numbers new orders. It is replaced from configuration at start-up.
+var OrderNumbers = NumberFormat{Template: "BIZOE-{YYYYMMDD}-{SEQ:4}", Reset: ResetDaily}
+
+// OrderSequence is the name of the sequences order numbers are taken from
+const OrderSequence = "order"
+
+var numberTokenPattern = regexp.MustCompile(`\{([A-Z]+)(?::(\d+))?\}`)
+
+// ParseNumberFormat validates a template and reset period. A template must
+// hold one counter, and the date parts that tell the periods of its reset
+// apart; otherwise numbers would repeat once the counter restarts. Numbers
+// are upper case, so order lookups can match them exactly.
+func ParseNumberFormat(template string, reset NumberReset) (NumberFormat, error) {
+	template = strings.ToUpper(template)
+	format := NumberFormat{Template: template, Reset: reset}
+	switch reset {
+	case ResetNever, ResetDaily, ResetYearly:
+	default:
+		return format, fmt.Errorf("unknown reset period %q", reset)
+	}
+
+	counters := 0
+	parts := map[string]bool{}
+	for _, match := range numberTokenPattern.FindAllStringSubmatch(template, -1) {
+		switch match[1] {
+		case "SEQ":
+			counters++
+		case "YYYYMMDD":
+			parts["year"], parts["month"], parts["day"] = true, true, true
+		case "YYYY", "YY":
+			parts["year"] = true
+		case "MM":
+			parts["month"] = true
+		case "DD":
+			parts["day"] = true
+		default:
+			return format, fmt.Errorf("unknown token {%s}", match[1])
+		}
+	}
+	if counters != 1 {
+		return format, fmt.Errorf("template must hold exactly one {SEQ} counter")
+	}
+	if strings.ContainsAny(numberTokenPattern.ReplaceAllString(template, ""), "{}") {
+		return format, fmt.Errorf("template has a malformed token")
+	}
+	if reset == ResetYearly && !parts["year"] {
+		return format, fmt.Errorf("yearly numbering needs the year in the template")
+	}
+	if reset == ResetDaily && !(parts["year"] && parts["month"] && parts["day"]) {
+		return format, fmt.Errorf("daily numbering needs the full date in the template")
+	}
+	return format, nil
+}
+
+// Next takes the next number of the named sequence in its period at t and
+// formats it. Like NextSequenceValue, it must run in the transaction that
+// uses the number.
+func (f NumberFormat) Next(tx *gorm.DB, name string, t time.Time) (string, error) {
+	value, err := NextSequenceValue(tx, f.sequenceName(name, t))
+	if err != nil {
+		return "", err
+	}
+
+	return numberTokenPattern.ReplaceAllStringFunc(f.Template, func(token string) string {
+		match := numberTokenPattern.FindStringSubmatch(token)
+		switch match[1] {
+		case "SEQ":
+			width, _ := strconv.Atoi(match[2])
+			return fmt.Sprintf("%0*d", width, value)
+		case "YYYYMMDD":
+			return t.Format("20060102")
+		case "YYYY":
+			return t.Format("2006")
+		case "YY":
+			return t.Format("06")
+		case "MM":
+			return t.Format("01")
+		case "DD":
+			return t.Format("02")
+		}
+		return token
+	}), nil
+}
+
+// Prepare creates the sequences of the period holding t and of the period
+// after it, so the first number of a period comes from a row that already
+// exists. Call it outside any transaction, ahead of the period changing.
+func (f NumberFormat) Prepare(db *gorm.DB, name string, t time.Time) error {
+	next := t
+	switch f.Reset {
+	case ResetDaily:
+		next = t.AddDate(0, 0, 1)
+	case ResetYearly:
+		next = t.AddDate(1, 0, 0)
+	}
+	for _, period := range []time.Time{t, next} {
+		if err := CreateSequence(db, f.sequenceName(name, period)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sequenceName returns the sequence that numbers the period holding t
+func (f NumberFormat) sequenceName(name string, t time.Time) string {
+	switch f.Reset {
+	case ResetDaily:
+		return name + "-" + t.Format("20060102")
+	case ResetYearly:
+		return name + "-" + t.Format("2006")
+	}
+	return name
+}
@@ -0,0 +1,162 @@
+package models_test
+
+import (
+	"bizoe-3d-store/internal/database"
+	"bizoe-3d-store/internal/models"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errRollback = errors.New("rollback")
+
+func newDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Initialize("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Discard})
+	if err := db.AutoMigrate(&models.Sequence{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// next takes a number in a transaction of its own, rolling it back when asked
+func next(db *gorm.DB, format models.NumberFormat, at time.Time, rollback bool) (string, error) {
+	var number string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		number, err = format.Next(tx, models.OrderSequence, at)
+		if err == nil && rollback {
+			return errRollback
+		}
+		return err
+	})
+	if err == errRollback {
+		err = nil
+	}
+	return number, err
+}
+
+func TestNextIsGaplessUnderConcurrency(t *testing.T) {
+	for _, prepared := range []bool{true, false} {
+		t.Run(fmt.Sprintf("prepared=%v", prepared), func(t *testing.T) {
+			db := newDB(t)
+			format := models.NumberFormat{Template: "T-{YYYYMMDD}-{SEQ:4}", Reset: models.ResetDaily}
+			at := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+			if prepared {
+				if err := format.Prepare(db, models.OrderSequence, at); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Every third checkout fails after taking its number
+			const checkouts = 30
+			var mu sync.Mutex
+			var numbers []string
+			var wg sync.WaitGroup
+			for i := 0; i < checkouts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					number, err := next(db, format, at, i%3 == 0)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if i%3 != 0 {
+						mu.Lock()
+						numbers = append(numbers, number)
+						mu.Unlock()
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			sort.Strings(numbers)
+			if len(numbers) != checkouts*2/3 {
+				t.Fatalf("%d numbers committed, want %d", len(numbers), checkouts*2/3)
+			}
+			for i, number := range numbers {
+				if want := fmt.Sprintf("T-20260314-%04d", i+1); number != want {
+					t.Fatalf("committed numbers %v are not unique and contiguous; #%d is %s, want %s", numbers, i, number, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareRollsOverToTheNextPeriod(t *testing.T) {
+	cases := []struct {
+		reset        models.NumberReset
+		template     string
+		at, next     time.Time
+		nextSequence string
+	}{
+		{models.ResetDaily, "T-{YYYYMMDD}-{SEQ:3}",
+			time.Date(2026, 12, 31, 23, 30, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 5, 0, 0, time.UTC), "order-20270101"},
+		{models.ResetYearly, "T-{YYYY}-{SEQ:3}",
+			time.Date(2026, 12, 31, 23, 30, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 5, 0, 0, time.UTC), "order-2027"},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.reset), func(t *testing.T) {
+			db := newDB(t)
+			format := models.NumberFormat{Template: tc.template, Reset: tc.reset}
+			if err := format.Prepare(db, models.OrderSequence, tc.at); err != nil {
+				t.Fatal(err)
+			}
+			var sequence models.Sequence
+			if err := db.First(&sequence, "name = ?", tc.nextSequence).Error; err != nil {
+				t.Fatalf("next period's sequence %s was not created: %v", tc.nextSequence, err)
+			}
+
+			for _, want := range []string{"001", "002"} {
+				number, err := next(db, format, tc.at, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if number != tc.at.Format(numberPrefix(tc.reset))+want {
+					t.Errorf("number %s, want suffix %s in the current period", number, want)
+				}
+			}
+
+			// Preparing again, as the hourly job does, leaves counters alone
+			if err := format.Prepare(db, models.OrderSequence, tc.at); err != nil {
+				t.Fatal(err)
+			}
+			number, err := next(db, format, tc.at, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tc.at.Format(numberPrefix(tc.reset)) + "003"; number != want {
+				t.Errorf("number after preparing again = %s, want %s", number, want)
+			}
+
+			// The new period starts again at 1
+			number, err = next(db, format, tc.next, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tc.next.Format(numberPrefix(tc.reset)) + "001"; number != want {
+				t.Errorf("first number of the next period = %s, want %s", number, want)
+			}
+		})
+	}
+}
+
+// numberPrefix is the time layout of the test templates up to the counter
+func numberPrefix(reset models.NumberReset) string {
+	if reset == models.ResetYearly {
+		return "T-2006-"
+	}
+	return "T-20060102-"
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ShipmentStatus is where a parcel is on its way to the customer
+type ShipmentStatus string
+
+const (
+	ShipmentLabelCreated   ShipmentStatus = "label_created"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	ShipmentException      ShipmentStatus = "exception" // Delayed, lost or returned to sender
+)
+
+// Shipment is one parcel of an order. Orders can ship in several parcels,
+// such as a printer from one warehouse and resin from another.
+type Shipment struct {
+	ID             string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	OrderID        string         `json:"orderId" gorm:"type:varchar(36);not null;index"`
+	Carrier        string         `json:"carrier" gorm:"type:varchar(30);not null"`
+	TrackingNumber string         `json:"trackingNumber" gorm:"type:varchar(64);not null;index"`
+	Warehouse      string         `json:"warehouse,omitempty" gorm:"type:varchar(64)"`
+	Status         ShipmentStatus `json:"status" gorm:"type:varchar(20);not null;default:'label_created';index"`
+	ShippedAt      time.Time      `json:"shippedAt"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty"`
+	LastCheckedAt  *time.Time     `json:"lastCheckedAt,omitempty"` // Last tracking poll
+	CreatedBy      string         `json:"createdBy,omitempty" gorm:"type:varchar(36)"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+
+	// Relationships
+	Items  []ShipmentItem  `json:"items"`
+	Events []ShipmentEvent `json:"events"`
+}
+
+func (s *Shipment) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ShipmentItem is the units of an order line packed in a shipment
+type ShipmentItem struct {
+	ID          string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ShipmentID  string `json:"shipmentId" gorm:"type:varchar(36);not null;index"`
+	OrderItemID string `json:"orderItemId" gorm:"type:varchar(36);not null;index"`
+	Quantity    int    `json:"quantity" gorm:"not null"`
+}
+
+func (i *ShipmentItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ShipmentEvent is one carrier scan of a shipment
+type ShipmentEvent struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ShipmentID  string         `json:"shipmentId" gorm:"type:varchar(36);not null;index"`
+	Status      ShipmentStatus `json:"status" gorm:"type:varchar(20);not null"`
+	Description string         `json:"description"`
+	Location    string         `json:"location,omitempty"`
+	OccurredAt  time.Time      `json:"occurredAt"`
+}
+
+func (e *ShipmentEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockSubscriptionStatus tracks where a subscriber is in the restock queue
+type StockSubscriptionStatus string
+
+const (
+	// StockSubscriptionWaiting subscribers have not been emailed yet
+	StockSubscriptionWaiting StockSubscriptionStatus = "waiting"
+	// StockSubscriptionNotified subscribers were emailed that the product is available
+	StockSubscriptionNotified StockSubscriptionStatus = "notified"
+)
+
+// StockSubscription asks to be emailed when an out of stock product is
+// available again. Guests subscribe with an email address only; Token lets
+// them unsubscribe without an account.
+type StockSubscription struct {
+	ID         string                  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID  string                  `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_stock_sub_email;index:idx_stock_sub_queue,priority:1"`
+	Email      string                  `json:"email" gorm:"not null;uniqueIndex:idx_stock_sub_email"`
+	UserID     *string                 `json:"userId,omitempty" gorm:"type:varchar(36)"`
+	Status     StockSubscriptionStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_stock_sub_queue,priority:2"`
+	Token      string                  `json:"-" gorm:"type:varchar(36);uniqueIndex;not null"`
+	NotifiedAt *time.Time              `json:"notifiedAt,omitempty"`
+	CreatedAt  time.Time               `json:"createdAt"` // Queue position; reset when resubscribing
+	UpdatedAt  time.Time               `json:"updatedAt"`
+}
+
+func (s *StockSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Token == "" {
+		s.Token = uuid.New().String()
+	}
+	return nil
+}
+
+// StockReceipt records goods received into stock for a product
+type StockReceipt struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID   string    `json:"productId" gorm:"type:varchar(36);not null;index"`
+	Quantity    int       `json:"quantity" gorm:"not null"`
+	Note        string    `json:"note"`
+	ReceivedBy  string    `json:"receivedBy" gorm:"type:varchar(36)"`
+	StockBefore int       `json:"stockBefore"`
+	StockAfter  int       `json:"stockAfter"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	AllocatedLines int `json:"allocatedLines" gorm:"-"` // Waiting order lines served by this receipt
+}
+
+func (r *StockReceipt) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
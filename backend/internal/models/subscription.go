@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubscriptionPlan makes a consumable product available for repeat delivery
+// at one of a fixed set of intervals
+type SubscriptionPlan struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID       string    `json:"productId" gorm:"type:varchar(36);uniqueIndex;not null"`
+	IntervalDays    []int     `json:"intervalDays" gorm:"serializer:json"` // Intervals customers can choose from
+	DiscountPercent float64   `json:"discountPercent" gorm:"default:0"`    // Off the product price on every delivery
+	IsActive        bool      `json:"isActive" gorm:"default:true"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (p *SubscriptionPlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AllowsInterval reports whether customers may choose days as their interval
+func (p *SubscriptionPlan) AllowsInterval(days int) bool {
+	for _, d := range p.IntervalDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionStatus tracks whether a subscription is generating orders
+type SubscriptionStatus string
+
+const (
+	// SubscriptionActive subscriptions generate an order every interval
+	SubscriptionActive SubscriptionStatus = "active"
+	// SubscriptionPaused subscriptions are skipped until resumed
+	SubscriptionPaused SubscriptionStatus = "paused"
+	// SubscriptionPastDue subscriptions have an unpaid order being retried
+	SubscriptionPastDue SubscriptionStatus = "past_due"
+	// SubscriptionCancelled subscriptions are finished
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription delivers a quantity of a product every IntervalDays, charged
+// to a saved payment method
+type Subscription struct {
+	ID              string             `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID          string             `json:"userId" gorm:"type:varchar(36);not null;index"`
+	ProductID       string             `json:"productId" gorm:"type:varchar(36);not null;index"`
+	Quantity        int                `json:"quantity" gorm:"not null"`
+	IntervalDays    int                `json:"intervalDays" gorm:"not null"`
+	Status          SubscriptionStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_subscription_due,priority:1"`
+	Currency        string             `json:"currency" gorm:"type:varchar(3);default:'USD'"` // Every order is charged in it
+	ShippingAddress Address            `json:"shippingAddress" gorm:"embedded;embeddedPrefix:shipping_"`
+	PaymentMethodID string             `json:"paymentMethodId" gorm:"not null"`
+	NextRunAt       time.Time          `json:"nextRunAt" gorm:"index:idx_subscription_due,priority:2"` // Next order, or next payment retry when past due
+	PausedUntil     *time.Time         `json:"pausedUntil,omitempty"`                                  // Resumes automatically when set
+	FailedAttempts  int                `json:"failedAttempts" gorm:"default:0"`
+	LastError       string             `json:"lastError,omitempty"`
+	PendingOrderID  *string            `json:"pendingOrderId,omitempty" gorm:"type:varchar(36)"` // Unpaid order being retried
+	LastOrderID     *string            `json:"lastOrderId,omitempty" gorm:"type:varchar(36)"`
+	CancelReason    string             `json:"cancelReason,omitempty"`
+	CancelledAt     *time.Time         `json:"cancelledAt,omitempty"`
+	CreatedAt       time.Time          `json:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt"`
+
+	// Relationships
+	User    User                 `json:"-" gorm:"foreignKey:UserID"`
+	Product Product              `json:"product" gorm:"foreignKey:ProductID"`
+	Charges []SubscriptionCharge `json:"charges,omitempty"`
+}
+
+func (s *Subscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// SubscriptionChargeStatus is the outcome of a subscription payment attempt
+type SubscriptionChargeStatus string
+
+const (
+	SubscriptionChargeSucceeded SubscriptionChargeStatus = "succeeded"
+	SubscriptionChargeFailed    SubscriptionChargeStatus = "failed"
+)
+
+// SubscriptionCharge records one attempt to pay for a subscription order
+type SubscriptionCharge struct {
+	ID             string                   `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SubscriptionID string                   `json:"subscriptionId" gorm:"type:varchar(36);not null;index"`
+	OrderID        string                   `json:"orderId" gorm:"type:varchar(36);not null"`
+	Attempt        int                      `json:"attempt" gorm:"not null"` // 1 for the first try, counting up through retries
+	Amount         float64                  `json:"amount" gorm:"not null"`
+	Currency       string                   `json:"currency" gorm:"type:varchar(3)"`
+	Status         SubscriptionChargeStatus `json:"status" gorm:"type:varchar(20);not null"`
+	PaymentID      string                   `json:"paymentId,omitempty"`
+	Error          string                   `json:"error,omitempty"`
+	CreatedAt      time.Time                `json:"createdAt"`
+}
+
+func (c *SubscriptionCharge) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
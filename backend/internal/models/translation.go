@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductTranslation holds localized product content for a single locale
+type ProductTranslation struct {
+	ID             string            `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID      string            `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_product_locale"`
+	Locale         string            `json:"locale" gorm:"type:varchar(16);not null;uniqueIndex:idx_product_locale"`
+	Name           string            `json:"name" gorm:"not null"`
+	Description    string            `json:"description" gorm:"type:text"`
+	Specifications map[string]string `json:"specifications" gorm:"serializer:json"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+}
+
+func (t *ProductTranslation) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// CategoryTranslation holds localized category content for a single locale
+type CategoryTranslation struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	CategoryID  string    `json:"categoryId" gorm:"type:varchar(36);not null;uniqueIndex:idx_category_locale"`
+	Locale      string    `json:"locale" gorm:"type:varchar(16);not null;uniqueIndex:idx_category_locale"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (t *CategoryTranslation) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ApplyTranslation overlays translated fields onto the product. Empty fields
+// keep the base value so partial translations still render.
+func (p *Product) ApplyTranslation(t *ProductTranslation) {
+	if t == nil {
+		return
+	}
+	if t.Name != "" {
+		p.Name = t.Name
+	}
+	if t.Description != "" {
+		p.Description = t.Description
+	}
+	if len(t.Specifications) > 0 {
+		// Spec labels are translated too, so the translated map replaces the base one
+		p.Specifications = t.Specifications
+	}
+	p.Locale = t.Locale
+}
+
+// ApplyTranslation overlays translated fields onto the category
+func (c *Category) ApplyTranslation(t *CategoryTranslation) {
+	if t == nil {
+		return
+	}
+	if t.Name != "" {
+		c.Name = t.Name
+	}
+	if t.Description != "" {
+		c.Description = t.Description
+	}
+	c.Locale = t.Locale
+}
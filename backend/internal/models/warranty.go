@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductSerial ties the serial number of a unit to its buyer. Serials
+// captured at fulfilment are verified; serials customers register
+// themselves, such as for units bought elsewhere, are not.
+type ProductSerial struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	ProductID      string     `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_product_serial"`
+	SerialNumber   string     `json:"serialNumber" gorm:"type:varchar(64);not null;uniqueIndex:idx_product_serial"`
+	OrderID        *string    `json:"orderId,omitempty" gorm:"type:varchar(36);index"`
+	OrderItemID    *string    `json:"orderItemId,omitempty" gorm:"type:varchar(36);index"`
+	OwnerID        *string    `json:"ownerId,omitempty" gorm:"type:varchar(36);index"`
+	PurchaseDate   time.Time  `json:"purchaseDate"`
+	WarrantyEndsAt *time.Time `json:"warrantyEndsAt,omitempty"` // Nil when the product has no warranty
+	Verified       bool       `json:"verified" gorm:"default:false"`
+	RegisteredAt   *time.Time `json:"registeredAt,omitempty"` // When the owner registered it
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// Relationships
+	Product Product `json:"product" gorm:"foreignKey:ProductID"`
+}
+
+func (s *ProductSerial) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// UnderWarranty reports whether the unit is still covered at t
+func (s *ProductSerial) UnderWarranty(t time.Time) bool {
+	return s.WarrantyEndsAt != nil && t.Before(*s.WarrantyEndsAt)
+}
+
+// WarrantyClaimStatus tracks a claim through triage
+type WarrantyClaimStatus string
+
+const (
+	WarrantyClaimOpen                WarrantyClaimStatus = "open"
+	WarrantyClaimRepairApproved      WarrantyClaimStatus = "repair_approved"
+	WarrantyClaimReplacementApproved WarrantyClaimStatus = "replacement_approved"
+	WarrantyClaimRejected            WarrantyClaimStatus = "rejected"
+	WarrantyClaimResolved            WarrantyClaimStatus = "resolved" // Repaired unit or replacement sent
+)
+
+// WarrantyClaim is a customer's report of a fault in a registered unit
+type WarrantyClaim struct {
+	ID                 string              `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	SerialID           string              `json:"serialId" gorm:"type:varchar(36);not null;index"`
+	UserID             string              `json:"userId" gorm:"type:varchar(36);not null;index"`
+	ProductID          string              `json:"productId" gorm:"type:varchar(36);not null"`
+	Description        string              `json:"description" gorm:"type:text;not null"`
+	Photos             []CustomerPhoto     `json:"photos" gorm:"serializer:json"`
+	Status             WarrantyClaimStatus `json:"status" gorm:"type:varchar(30);not null;default:'open';index"`
+	ResolutionNote     string              `json:"resolutionNote,omitempty" gorm:"type:text"`
+	ReplacementOrderID *string             `json:"replacementOrderId,omitempty" gorm:"type:varchar(36)"`
+	TriagedBy          string              `json:"triagedBy,omitempty" gorm:"type:varchar(36)"`
+	TriagedAt          *time.Time          `json:"triagedAt,omitempty"`
+	ResolvedAt         *time.Time          `json:"resolvedAt,omitempty"`
+	CreatedAt          time.Time           `json:"createdAt"`
+	UpdatedAt          time.Time           `json:"updatedAt"`
+
+	// Relationships
+	Serial           ProductSerial `json:"serial" gorm:"foreignKey:SerialID"`
+	User             *User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ReplacementOrder *Order        `json:"replacementOrder,omitempty" gorm:"foreignKey:ReplacementOrderID"`
+}
+
+func (w *WarrantyClaim) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
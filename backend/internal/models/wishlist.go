@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Wishlist is a named list of products a user wants to buy later. Public
+// lists can be viewed by anyone holding the share token.
+type Wishlist struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	UserID     string    `json:"userId" gorm:"type:varchar(36);not null;index"`
+	Name       string    `json:"name" gorm:"not null"`
+	IsPublic   bool      `json:"isPublic" gorm:"default:false"`
+	ShareToken string    `json:"shareToken" gorm:"type:varchar(36);uniqueIndex;not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// Relationships
+	User  *User          `json:"-" gorm:"foreignKey:UserID"`
+	Items []WishlistItem `json:"items"`
+
+	Owner string `json:"owner,omitempty" gorm:"-"` // Public name shown on shared lists
+}
+
+func (w *Wishlist) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	if w.ShareToken == "" {
+		w.ShareToken = uuid.New().String()
+	}
+	return nil
+}
+
+// WishlistItem is a product saved to a wishlist. PriceAtAdd is the product's
+// price, in its own currency, when it was saved; alerts compare against it.
+type WishlistItem struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	WishlistID string    `json:"wishlistId" gorm:"type:varchar(36);not null;uniqueIndex:idx_wishlist_product"`
+	ProductID  string    `json:"productId" gorm:"type:varchar(36);not null;uniqueIndex:idx_wishlist_product;index"`
+	PriceAtAdd float64   `json:"priceAtAdd" gorm:"not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// Alert state
+	AlertedPrice  *float64 `json:"-"` // Lowest price the owner has been emailed about
+	AwaitingStock bool     `json:"-"` // Out of stock since the owner was last told it was available
+
+	// Relationships
+	Product Product `json:"product" gorm:"foreignKey:ProductID"`
+
+	PriceDropped bool `json:"priceDropped" gorm:"-"` // Product is cheaper now than when saved
+}
+
+func (wi *WishlistItem) BeforeCreate(tx *gorm.DB) error {
+	if wi.ID == "" {
+		wi.ID = uuid.New().String()
+	}
+	return nil
+}
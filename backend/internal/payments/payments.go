@@ -0,0 +1,199 @@
+package payments
+
+import (
+	"bizoe-3d-store/internal/config"
+	"bizoe-3d-store/internal/pricing"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/customer"
+	"github.com/stripe/stripe-go/v74/paymentintent"
+	"github.com/stripe/stripe-go/v74/refund"
+	"github.com/stripe/stripe-go/v74/setupintent"
+)
+
+// ErrDeclined is returned when the payment method was refused. The charge
+// may succeed later or with another payment method, unlike other errors
+// which mean the gateway could not be reached or rejected the request.
+var ErrDeclined = errors.New("payment declined")
+
+// Charge is an off-session payment with a saved payment method
+type Charge struct {
+	CustomerID      string
+	PaymentMethodID string
+	Amount          float64
+	Currency        string
+	Description     string
+	Metadata        map[string]string
+	IdempotencyKey  string // Retries with the same key never charge twice
+}
+
+// Refund returns part or all of a payment to the customer
+type Refund struct {
+	PaymentID      string // Payment intent the refund is taken from
+	Amount         float64
+	Currency       string
+	Metadata       map[string]string
+	IdempotencyKey string // Retries with the same key never refund twice
+}
+
+// Gateway charges customers' saved payment methods. Stripe is used when a
+// secret key is configured; otherwise a test gateway stands in, which keeps
+// development setups working.
+type Gateway interface {
+	// CreateCustomer registers a customer that payment methods are saved to
+	CreateCustomer(email, name string) (string, error)
+	// SetupPaymentMethod starts saving a payment method for off-session use
+	// and returns the client secret the storefront confirms it with
+	SetupPaymentMethod(customerID string) (string, error)
+	// Charge takes an off-session payment and returns its payment ID.
+	// Refused payments wrap ErrDeclined.
+	Charge(charge Charge) (string, error)
+	// Refund pays back part or all of a payment and returns the refund ID
+	Refund(refund Refund) (string, error)
+	// CancelPayment cancels a payment the customer has not completed yet
+	CancelPayment(paymentID string) error
+}
+
+// NewGateway returns the gateway configured by the Stripe settings. Without
+// a Stripe key the test gateway stands in, but only in development and test
+// environments; anywhere else a missing key is an error rather than a store
+// that approves every charge.
+func NewGateway(cfg *config.Config) (Gateway, error) {
+	if cfg.StripeSecretKey == "" {
+		if cfg.Environment != "development" && cfg.Environment != "test" {
+			return nil, fmt.Errorf("STRIPE_SECRET_KEY is required in the %s environment", cfg.Environment)
+		}
+		return TestGateway{}, nil
+	}
+	stripe.Key = cfg.StripeSecretKey
+	return StripeGateway{}, nil
+}
+
+// StripeGateway charges through Stripe using the package level API key
+type StripeGateway struct{}
+
+// CreateCustomer implements Gateway
+func (StripeGateway) CreateCustomer(email, name string) (string, error) {
+	c, err := customer.New(&stripe.CustomerParams{
+		Email: stripe.String(email),
+		Name:  stripe.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+// SetupPaymentMethod implements Gateway
+func (StripeGateway) SetupPaymentMethod(customerID string) (string, error) {
+	si, err := setupintent.New(&stripe.SetupIntentParams{
+		Customer:           stripe.String(customerID),
+		Usage:              stripe.String(string(stripe.SetupIntentUsageOffSession)),
+		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
+	})
+	if err != nil {
+		return "", err
+	}
+	return si.ClientSecret, nil
+}
+
+// Charge implements Gateway
+func (StripeGateway) Charge(charge Charge) (string, error) {
+	params := &stripe.PaymentIntentParams{
+		Amount:        stripe.Int64(pricing.ToMinorUnits(charge.Amount, charge.Currency)),
+		Currency:      stripe.String(strings.ToLower(charge.Currency)),
+		Customer:      stripe.String(charge.CustomerID),
+		PaymentMethod: stripe.String(charge.PaymentMethodID),
+		Description:   stripe.String(charge.Description),
+		OffSession:    stripe.Bool(true),
+		Confirm:       stripe.Bool(true),
+	}
+	for key, value := range charge.Metadata {
+		params.AddMetadata(key, value)
+	}
+	if charge.IdempotencyKey != "" {
+		params.SetIdempotencyKey(charge.IdempotencyKey)
+	}
+
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard {
+			return "", fmt.Errorf("%w: %s", ErrDeclined, stripeErr.Msg)
+		}
+		return "", err
+	}
+	if pi.Status != stripe.PaymentIntentStatusSucceeded {
+		// Off-session payments cannot complete authentication or other actions
+		return pi.ID, fmt.Errorf("%w: payment %s", ErrDeclined, pi.Status)
+	}
+	return pi.ID, nil
+}
+
+// Refund implements Gateway
+func (StripeGateway) Refund(r Refund) (string, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(r.PaymentID),
+		Amount:        stripe.Int64(pricing.ToMinorUnits(r.Amount, r.Currency)),
+	}
+	for key, value := range r.Metadata {
+		params.AddMetadata(key, value)
+	}
+	if r.IdempotencyKey != "" {
+		params.SetIdempotencyKey(r.IdempotencyKey)
+	}
+
+	re, err := refund.New(params)
+	if err != nil {
+		return "", err
+	}
+	return re.ID, nil
+}
+
+// CancelPayment implements Gateway
+func (StripeGateway) CancelPayment(paymentID string) error {
+	_, err := paymentintent.Cancel(paymentID, nil)
+	return err
+}
+
+// TestGateway approves every charge except those made with payment method
+// IDs containing "decline", after Stripe's pm_card_chargeDeclined test
+// method. Charges and refunds are only logged.
+type TestGateway struct{}
+
+// CreateCustomer implements Gateway
+func (TestGateway) CreateCustomer(email, name string) (string, error) {
+	return "cus_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""), nil
+}
+
+// SetupPaymentMethod implements Gateway
+func (TestGateway) SetupPaymentMethod(customerID string) (string, error) {
+	return "seti_test_secret_" + customerID, nil
+}
+
+// Charge implements Gateway
+func (TestGateway) Charge(charge Charge) (string, error) {
+	if strings.Contains(strings.ToLower(charge.PaymentMethodID), "decline") {
+		log.Printf("Test payment of %.2f %s declined for %s", charge.Amount, charge.Currency, charge.CustomerID)
+		return "", fmt.Errorf("%w: your card was declined", ErrDeclined)
+	}
+	log.Printf("Test payment of %.2f %s charged to %s", charge.Amount, charge.Currency, charge.CustomerID)
+	return "pi_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""), nil
+}
+
+// Refund implements Gateway
+func (TestGateway) Refund(r Refund) (string, error) {
+	log.Printf("Test refund of %.2f %s from %s", r.Amount, r.Currency, r.PaymentID)
+	return "re_test_" + strings.ReplaceAll(uuid.New().String(), "-", ""), nil
+}
+
+// CancelPayment implements Gateway
+func (TestGateway) CancelPayment(paymentID string) error {
+	log.Printf("Test payment %s cancelled", paymentID)
+	return nil
+}
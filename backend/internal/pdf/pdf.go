@@ -0,0 +1,286 @@
+// Package pdf writes simple text-and-rule PDF documents such as invoices.
+// Latin text is set in the standard Helvetica fonts and traditional Chinese
+// in the Adobe-CNS1 font every PDF reader provides, so no font files are
+// embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type font int
+
+const (
+	fontRegular font = iota
+	fontBold
+	fontCJK
+)
+
+// Resource names of the fonts, in font order
+var fontNames = []string{"F1", "F2", "F3"}
+
+// Document is a PDF being built page by page. Coordinates are in points
+// from the top-left corner of the page, and text is placed by its baseline.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+}
+
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// AddPage starts a new page; drawing goes to the last page added
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// PageCount returns the number of pages added so far
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s starting at x
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	page := d.page()
+	for _, r := range splitRuns(s) {
+		f := fontRegular
+		if bold {
+			f = fontBold
+		}
+		if r.cjk {
+			f = fontCJK
+		}
+
+		fmt.Fprintf(page, "BT /%s %.2f Tf %.2f %.2f Td ", fontNames[f], size, x, PageHeight-y)
+		if r.cjk && bold {
+			// The CJK font has no bold face; stroke the outline as well
+			fmt.Fprintf(page, "2 Tr %.2f w ", size/30)
+		}
+		page.WriteString(encode(r.text, r.cjk))
+		page.WriteString(" Tj")
+		if r.cjk && bold {
+			page.WriteString(" 0 Tr")
+		}
+		page.WriteString(" ET\n")
+		x += runWidth(r, size, bold)
+	}
+}
+
+// TextRight draws s so that it ends at x
+func (d *Document) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// Line draws a thin rule from (x1, y1) to (x2, y2)
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// TextWidth returns how wide s is when drawn at size
+func TextWidth(s string, size float64, bold bool) float64 {
+	width := 0.0
+	for _, r := range splitRuns(s) {
+		width += runWidth(r, size, bold)
+	}
+	return width
+}
+
+// Truncate shortens s with an ellipsis so that it fits in width
+func Truncate(s string, size float64, bold bool, width float64) string {
+	if TextWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "..."
+		if TextWidth(candidate, size, bold) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Bytes returns the finished PDF file
+func (d *Document) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Pages are numbered after the catalog, page tree, fonts and info
+	const firstPage = 9
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type0 /BaseFont /MSung-Light-UniCNS-UCS2-H /Encoding /UniCNS-UCS2-H /DescendantFonts [6 0 R] >>")
+	object("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /MSung-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (CNS1) /Supplement 0 >> /FontDescriptor 7 0 R /DW 1000 /W [1 95 500] >>")
+	object("<< /Type /FontDescriptor /FontName /MSung-Light /Flags 6 /FontBBox [0 -200 1000 900] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (BIZOE 3D Store) /CreationDate (D:%s) >>",
+		encodeTitle(d.title), time.Now().UTC().Format("20060102150405Z")))
+
+	resources := fmt.Sprintf("<< /Font << /%s 3 0 R /%s 4 0 R /%s 5 0 R >> >>", fontNames[fontRegular], fontNames[fontBold], fontNames[fontCJK])
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			PageWidth, PageHeight, resources, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 8 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// run is a stretch of text drawn in one font
+type run struct {
+	text string
+	cjk  bool
+}
+
+// isLatin reports whether r can be drawn with the WinAnsi Helvetica fonts
+func isLatin(r rune) bool {
+	return r < 0x80 || (r >= 0xA0 && r <= 0xFF)
+}
+
+// splitRuns breaks s into Latin and CJK runs. Control characters become
+// spaces and characters outside the basic plane become question marks.
+func splitRuns(s string) []run {
+	var runs []run
+	var current strings.Builder
+	cjk := false
+	for _, r := range s {
+		switch {
+		case r < 0x20:
+			r = ' '
+		case r > 0xFFFF:
+			r = '?'
+		}
+		if current.Len() > 0 && isLatin(r) == cjk {
+			runs = append(runs, run{text: current.String(), cjk: cjk})
+			current.Reset()
+		}
+		cjk = !isLatin(r)
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		runs = append(runs, run{text: current.String(), cjk: cjk})
+	}
+	return runs
+}
+
+func runWidth(r run, size float64, bold bool) float64 {
+	units := 0
+	for _, c := range r.text {
+		switch {
+		case r.cjk:
+			units += 1000
+		case c >= 0x20 && c < 0x7F && bold:
+			units += helveticaBoldWidths[c-0x20]
+		case c >= 0x20 && c < 0x7F:
+			units += helveticaWidths[c-0x20]
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// encode writes a run as a PDF string: Latin-1 bytes for Helvetica and
+// UCS-2 for the CJK font
+func encode(text string, cjk bool) string {
+	if cjk {
+		var hex strings.Builder
+		hex.WriteString("<")
+		for _, r := range text {
+			fmt.Fprintf(&hex, "%04X", r)
+		}
+		hex.WriteString(">")
+		return hex.String()
+	}
+
+	var literal strings.Builder
+	literal.WriteString("(")
+	for _, r := range text {
+		switch r {
+		case '(', ')', '\\':
+			literal.WriteByte('\\')
+			literal.WriteByte(byte(r))
+		default:
+			if r < 0x80 {
+				literal.WriteByte(byte(r))
+			} else {
+				fmt.Fprintf(&literal, "\\%03o", r)
+			}
+		}
+	}
+	literal.WriteString(")")
+	return literal.String()
+}
+
+// encodeTitle writes a text string as UTF-16 so titles in any script show
+// up in the reader's title bar
+func encodeTitle(title string) string {
+	var hex strings.Builder
+	hex.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(title)) {
+		fmt.Fprintf(&hex, "%04X", unit)
+	}
+	hex.WriteString(">")
+	return hex.String()
+}
+
+// Glyph widths of printable ASCII in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,225 @@
+package pricing
+
+import (
+	"bizoe-3d-store/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// BaseCurrency is the currency exchange rates are quoted against
+const BaseCurrency = "USD"
+
+// SupportedCurrencies lists the storefront presentment currencies
+var SupportedCurrencies = []string{"USD", "TWD", "EUR"}
+
+// ErrCurrencyUnavailable is returned when a price cannot be expressed in the requested currency
+var ErrCurrencyUnavailable = errors.New("currency unavailable")
+
+// decimals is the number of minor-unit digits shown to customers
+var decimals = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"TWD": 0,
+}
+
+// Normalize upper-cases a currency code and returns "" when it is not supported
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, c := range SupportedCurrencies {
+		if c == code {
+			return c
+		}
+	}
+	return ""
+}
+
+// DefaultForLocale returns the storefront currency customers of a locale expect
+func DefaultForLocale(locale string) string {
+	if locale == "zh-TW" {
+		return "TWD"
+	}
+	return BaseCurrency
+}
+
+// Decimals returns the number of displayed decimal places for currency
+func Decimals(currency string) int {
+	if d, ok := decimals[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// stripeDecimals is the number of decimal places Stripe counts amounts in for
+// every supported currency, including TWD
+const stripeDecimals = 2
+
+// ToMinorUnits converts an amount into the integer unit Stripe expects. The
+// amount is first rounded to the currency's precision from Decimals, the same
+// rule prices are displayed and stored with, so a TWD charge is always whole
+// dollars even though Stripe counts TWD in cents.
+func ToMinorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(RoundAmount(amount, currency) * math.Pow(10, stripeDecimals)))
+}
+
+// RoundAmount rounds a raw amount to the currency's display precision
+func RoundAmount(amount float64, currency string) float64 {
+	factor := math.Pow(10, float64(Decimals(currency)))
+	return math.Round(amount*factor) / factor
+}
+
+// ApplyRounding rounds a converted price according to an exchange rate rule
+func ApplyRounding(amount float64, currency string, mode models.RoundingMode, increment float64) float64 {
+	minor := math.Pow(10, -float64(Decimals(currency)))
+	if increment < minor {
+		increment = minor
+	}
+
+	steps := amount / increment
+	var rounded float64
+	switch mode {
+	case models.RoundingUp:
+		rounded = math.Ceil(steps-1e-9) * increment
+	case models.RoundingDown:
+		rounded = math.Floor(steps+1e-9) * increment
+	case models.RoundingCharm:
+		rounded = math.Ceil(steps-1e-9)*increment - minor
+		if rounded <= 0 {
+			rounded = increment
+		}
+	default:
+		rounded = math.Round(steps) * increment
+	}
+
+	return RoundAmount(rounded, currency)
+}
+
+// Service resolves product prices and conversions against admin-managed rates
+type Service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Rate returns the exchange rate record for currency. The base currency
+// always has a rate of one with cent rounding.
+func (s *Service) Rate(currency string) (*models.ExchangeRate, error) {
+	if currency == BaseCurrency {
+		return &models.ExchangeRate{
+			Currency:          BaseCurrency,
+			Rate:              1,
+			Rounding:          models.RoundingNearest,
+			RoundingIncrement: 0.01,
+		}, nil
+	}
+
+	var rate models.ExchangeRate
+	if err := s.db.Where("currency = ?", currency).First(&rate).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: no exchange rate for %s", ErrCurrencyUnavailable, currency)
+		}
+		return nil, err
+	}
+	if rate.Rate <= 0 {
+		return nil, fmt.Errorf("%w: invalid exchange rate for %s", ErrCurrencyUnavailable, currency)
+	}
+	return &rate, nil
+}
+
+// Convert converts amount between currencies and applies the target rounding rule
+func (s *Service) Convert(amount float64, from, to string) (float64, error) {
+	if from == "" {
+		from = BaseCurrency
+	}
+	if from == to {
+		return RoundAmount(amount, to), nil
+	}
+
+	fromRate, err := s.Rate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := s.Rate(to)
+	if err != nil {
+		return 0, err
+	}
+
+	converted := amount / fromRate.Rate * toRate.Rate
+	return ApplyRounding(converted, to, toRate.Rounding, toRate.RoundingIncrement), nil
+}
+
+// Quote returns a product's price and original price in currency, preferring
+// an explicit price list entry over conversion
+func (s *Service) Quote(product *models.Product, currency string) (float64, *float64, error) {
+	var explicit models.ProductPrice
+	err := s.db.Where("product_id = ? AND currency = ?", product.ID, currency).First(&explicit).Error
+	if err == nil {
+		return explicit.Price, explicit.OriginalPrice, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return 0, nil, err
+	}
+
+	return s.convertProduct(product, currency)
+}
+
+// PresentProducts rewrites product prices into the presentment currency in place
+func (s *Service) PresentProducts(products []models.Product, currency string) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	productIDs := make([]string, len(products))
+	for i := range products {
+		productIDs[i] = products[i].ID
+	}
+
+	var explicit []models.ProductPrice
+	if err := s.db.Where("product_id IN ? AND currency = ?", productIDs, currency).Find(&explicit).Error; err != nil {
+		return err
+	}
+	byProduct := make(map[string]models.ProductPrice, len(explicit))
+	for _, pp := range explicit {
+		byProduct[pp.ProductID] = pp
+	}
+
+	for i := range products {
+		if pp, ok := byProduct[products[i].ID]; ok {
+			products[i].Price = pp.Price
+			products[i].OriginalPrice = pp.OriginalPrice
+		} else {
+			price, original, err := s.convertProduct(&products[i], currency)
+			if err != nil {
+				return err
+			}
+			products[i].Price = price
+			products[i].OriginalPrice = original
+		}
+		products[i].Currency = currency
+	}
+
+	return nil
+}
+
+func (s *Service) convertProduct(product *models.Product, currency string) (float64, *float64, error) {
+	price, err := s.Convert(product.Price, product.Currency, currency)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var original *float64
+	if product.OriginalPrice != nil {
+		converted, err := s.Convert(*product.OriginalPrice, product.Currency, currency)
+		if err != nil {
+			return 0, nil, err
+		}
+		original = &converted
+	}
+
+	return price, original, nil
+}
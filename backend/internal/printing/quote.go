@@ -0,0 +1,49 @@
+package printing
+
+import (
+	"bizoe-3d-store/internal/pricing"
+	"math"
+	"time"
+)
+
+const (
+	// SupportAllowance is the share of extra resin used by supports, rafts and
+	// what is left in the vat
+	SupportAllowance = 0.15
+	// LayerHeight is the layer thickness jobs are sliced at, in millimetres
+	LayerHeight = 0.05
+	// LayerSeconds is the exposure, lift and retract time of one layer
+	LayerSeconds = 9.0
+	// MachineRatePerHour is what an hour of printer time costs, in the base currency
+	MachineRatePerHour = 4.0
+	// MinimumUnitPrice is the least a printed part is sold for, in the base currency
+	MinimumUnitPrice = 5.0
+	// QuoteValidity is how long a quote can be ordered at its price
+	QuoteValidity = 14 * 24 * time.Hour
+)
+
+// Estimate is the cost of printing one part, in the base currency
+type Estimate struct {
+	MaterialCm3  float64
+	MaterialCost float64
+	PrintHours   float64
+	MachineCost  float64
+	UnitPrice    float64
+}
+
+// EstimatePart prices one part of volumeCm3 standing heightMM tall in a
+// material costing costPerCm3. Resin printers expose a whole layer at once,
+// so machine time depends on the height only.
+func EstimatePart(volumeCm3, heightMM, costPerCm3 float64) Estimate {
+	currency := pricing.BaseCurrency
+
+	e := Estimate{MaterialCm3: math.Round(volumeCm3*(1+SupportAllowance)*100) / 100}
+	e.MaterialCost = pricing.RoundAmount(e.MaterialCm3*costPerCm3, currency)
+
+	layers := math.Ceil(heightMM / LayerHeight)
+	e.PrintHours = math.Round(layers*LayerSeconds/3600*100) / 100
+	e.MachineCost = pricing.RoundAmount(e.PrintHours*MachineRatePerHour, currency)
+
+	e.UnitPrice = pricing.RoundAmount(math.Max(e.MaterialCost+e.MachineCost, MinimumUnitPrice), currency)
+	return e
+}
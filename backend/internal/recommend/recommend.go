@@ -0,0 +1,254 @@
+package recommend
+
+import (
+	"bizoe-3d-store/internal/models"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reasons explain why a product was recommended
+const (
+	ReasonPinned     = "pinned"
+	ReasonCoPurchase = "co_purchase"
+	ReasonSimilar    = "similar"
+)
+
+// Recommendation is one recommended product with its score. Co-purchase
+// scores are order counts; similarity scores stay below 1 so any real
+// co-purchase outranks a merely similar product.
+type Recommendation struct {
+	ProductID string  `json:"productId"`
+	Score     float64 `json:"score"`
+	Reason    string  `json:"reason"`
+}
+
+// Stats describes the model currently held in memory
+type Stats struct {
+	Products int       `json:"products"`
+	Pairs    int       `json:"pairs"`
+	Orders   int       `json:"orders"`
+	BuiltAt  time.Time `json:"builtAt"`
+}
+
+type productInfo struct {
+	categoryID string
+	specs      map[string]string
+}
+
+// Engine holds the co-purchase model in memory. Rebuild replaces it wholesale;
+// admin overrides are read from the database on every request so they apply
+// immediately.
+type Engine struct {
+	db *gorm.DB
+
+	mu         sync.RWMutex
+	coPurchase map[string]map[string]int
+	products   map[string]productInfo
+	stats      Stats
+}
+
+func NewEngine(db *gorm.DB) *Engine {
+	return &Engine{
+		db:         db,
+		coPurchase: map[string]map[string]int{},
+		products:   map[string]productInfo{},
+	}
+}
+
+// Rebuild mines non-cancelled orders for products bought together and
+// snapshots the catalog used for similarity
+func (e *Engine) Rebuild() error {
+	var products []models.Product
+	if err := e.db.Select("id", "category_id", "specifications").Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to load products: %w", err)
+	}
+
+	var rows []struct {
+		OrderID   string
+		ProductID string
+	}
+	err := e.db.Model(&models.OrderItem{}).
+		Select("order_items.order_id, order_items.product_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.status <> ? AND order_items.line_type <> ?", models.OrderStatusCancelled, models.OrderLinePrint).
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to load order history: %w", err)
+	}
+
+	byOrder := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if byOrder[row.OrderID] == nil {
+			byOrder[row.OrderID] = make(map[string]bool)
+		}
+		byOrder[row.OrderID][row.ProductID] = true
+	}
+
+	coPurchase := make(map[string]map[string]int)
+	pairs := 0
+	for _, items := range byOrder {
+		for a := range items {
+			for b := range items {
+				if a == b {
+					continue
+				}
+				if coPurchase[a] == nil {
+					coPurchase[a] = make(map[string]int)
+				}
+				if coPurchase[a][b] == 0 && a < b {
+					pairs++
+				}
+				coPurchase[a][b]++
+			}
+		}
+	}
+
+	catalog := make(map[string]productInfo, len(products))
+	for _, p := range products {
+		catalog[p.ID] = productInfo{categoryID: p.CategoryID, specs: p.Specifications}
+	}
+
+	e.mu.Lock()
+	e.coPurchase = coPurchase
+	e.products = catalog
+	e.stats = Stats{Products: len(catalog), Pairs: pairs, Orders: len(byOrder), BuiltAt: time.Now()}
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Stats returns the size and age of the current model
+func (e *Engine) Stats() Stats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.stats
+}
+
+// Recommend returns up to limit products to show next to the source
+// products: pinned products first, then co-purchases, then products from the
+// same category with similar specifications. Sources and excluded pairs are
+// never returned.
+func (e *Engine) Recommend(sourceIDs []string, limit int) ([]Recommendation, error) {
+	if len(sourceIDs) == 0 || limit <= 0 {
+		return []Recommendation{}, nil
+	}
+
+	var overrides []models.RecommendationOverride
+	if err := e.db.Where("product_id IN ?", sourceIDs).
+		Order("position ASC").Order("created_at ASC").
+		Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to load recommendation overrides: %w", err)
+	}
+
+	skip := make(map[string]bool, len(sourceIDs))
+	for _, id := range sourceIDs {
+		skip[id] = true
+	}
+	for _, o := range overrides {
+		if o.Action == models.RecommendationExclude {
+			skip[o.TargetProductID] = true
+		}
+	}
+
+	results := make([]Recommendation, 0, limit)
+	for _, o := range overrides {
+		if o.Action != models.RecommendationPin || skip[o.TargetProductID] {
+			continue
+		}
+		skip[o.TargetProductID] = true
+		results = append(results, Recommendation{ProductID: o.TargetProductID, Reason: ReasonPinned})
+	}
+
+	e.mu.RLock()
+	scored := make(map[string]Recommendation)
+	for _, source := range sourceIDs {
+		for target, count := range e.coPurchase[source] {
+			if skip[target] {
+				continue
+			}
+			if _, active := e.products[target]; !active {
+				continue
+			}
+			r := scored[target]
+			r.ProductID, r.Reason = target, ReasonCoPurchase
+			r.Score += float64(count)
+			scored[target] = r
+		}
+	}
+	for target, info := range e.products {
+		if skip[target] {
+			continue
+		}
+		if _, ok := scored[target]; ok {
+			continue
+		}
+		best := 0.0
+		for _, source := range sourceIDs {
+			if sourceInfo, ok := e.products[source]; ok {
+				if s := similarity(sourceInfo, info); s > best {
+					best = s
+				}
+			}
+		}
+		if best > 0 {
+			scored[target] = Recommendation{ProductID: target, Score: best, Reason: ReasonSimilar}
+		}
+	}
+	e.mu.RUnlock()
+
+	ranked := make([]Recommendation, 0, len(scored))
+	for _, r := range scored {
+		ranked = append(ranked, r)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ProductID < ranked[j].ProductID
+	})
+
+	results = append(results, ranked...)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// similarity scores two products between 0 and 0.9: sharing a category counts
+// for 0.4, identical specification values for up to 0.4 and shared
+// specification names for up to 0.1
+func similarity(a, b productInfo) float64 {
+	score := 0.0
+	if a.categoryID != "" && a.categoryID == b.categoryID {
+		score += 0.4
+	}
+
+	keys := make(map[string]bool, len(a.specs)+len(b.specs))
+	for k := range a.specs {
+		keys[k] = true
+	}
+	for k := range b.specs {
+		keys[k] = true
+	}
+	if len(keys) == 0 {
+		return score
+	}
+
+	sharedKeys, sameValues := 0, 0
+	for k := range keys {
+		av, inA := a.specs[k]
+		bv, inB := b.specs[k]
+		if inA && inB {
+			sharedKeys++
+			if av == bv {
+				sameValues++
+			}
+		}
+	}
+	score += 0.4*float64(sameValues)/float64(len(keys)) + 0.1*float64(sharedKeys)/float64(len(keys))
+	return score
+}
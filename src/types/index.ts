@@ -87,10 +87,35 @@ export interface Order {
   tax: number
   shipping: number
   total: number
+  edits?: OrderEdit[]
   createdAt: string
   updatedAt: string
 }
 
+export interface OrderChange {
+  type: 'item_added' | 'item_removed' | 'quantity_changed' | 'shipping_address' | 'billing_address'
+  productId?: string
+  productName?: string
+  fromQuantity?: number
+  toQuantity?: number
+  previousAddress?: Address
+}
+
+export interface OrderEdit {
+  id: string
+  orderId: string
+  editedBy: string
+  byAdmin: boolean
+  changes: OrderChange[]
+  note?: string
+  previousTotal: number
+  newTotal: number
+  difference: number
+  paymentId?: string
+  refundId?: string
+  createdAt: string
+}
+
 export interface OrderItem {
   id: string
   productId: string
@@ -110,15 +135,32 @@ export interface Address {
   state: string
   country: string
   zipCode: string
+  company?: string
+  taxId?: string
+}
+
+export interface SavedAddress {
+  id: string
+  userId: string
+  label: string
+  address: Address
+  isDefaultShipping: boolean
+  isDefaultBilling: boolean
+  createdAt: string
+  updatedAt: string
 }
 
 export enum OrderStatus {
   PENDING = 'pending',
   CONFIRMED = 'confirmed',
+  IN_PRODUCTION = 'in_production',
   PROCESSING = 'processing',
+  PARTIALLY_SHIPPED = 'partially_shipped',
   SHIPPED = 'shipped',
   DELIVERED = 'delivered',
   CANCELLED = 'cancelled',
+  PARTIALLY_RETURNED = 'partially_returned',
+  RETURNED = 'returned',
 }
 
 export enum PaymentStatus {
@@ -126,6 +168,7 @@ export enum PaymentStatus {
   PAID = 'paid',
   FAILED = 'failed',
   REFUNDED = 'refunded',
+  PARTIALLY_REFUNDED = 'partially_refunded',
 }
 
 // API Response Types